@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	alertFormatRules          = "rules"
+	alertFormatPrometheusRule = "prometheus-rule"
+
+	// defaultConnections is the DatabaseConnections of the instances from the
+	// CloudWatch exporter, with the label of this exporter
+	defaultConnections = `label_replace(aws_rds_database_connections_maximum, "dbinstanceidentifier", "$1", "dbinstance_identifier", "(.*)")`
+)
+
+// alertOptions are the flags of the generate alerts command.
+type alertOptions struct {
+	format              string
+	name                string
+	connections         string
+	utilizationWarning  float64
+	utilizationCritical float64
+	headroom            int
+	forDuration         time.Duration
+	certificateExpiry   time.Duration
+}
+
+type ruleGroups struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string      `yaml:"name"`
+	Rules []alertRule `yaml:"rules"`
+}
+
+type alertRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         model.Duration    `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// prometheusRule is the PrometheusRule resource of the Prometheus Operator.
+type prometheusRule struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec ruleGroups `yaml:"spec"`
+}
+
+// metricName returns the full name of a metric of this exporter.
+func metricName(name string) string {
+	return prometheus.BuildFQName("aws_custom", "rds", name)
+}
+
+// alertRules returns the alerts on the connection utilization and headroom
+// of the instances and on the health of the exporter. The current
+// connections come from another exporter, joined on dbinstanceidentifier,
+// and the alerts carry the labels of max_connections of the configuration so
+// that they can be routed by them.
+func alertRules(cfg *Config, opts alertOptions) []alertRule {
+	labels := cfg.LabelNames()
+	by := strings.Join(append([]string{"dbinstanceidentifier"}, labels...), ", ")
+	maxConnections := fmt.Sprintf("max by (%v) (%v > 0)", by, metricName("max_connections"))
+	join := "on (dbinstanceidentifier)"
+	if len(labels) != 0 {
+		join += " group_left (" + strings.Join(labels, ", ") + ")"
+	}
+	connections := fmt.Sprintf("max by (dbinstanceidentifier) (%v)", opts.connections)
+	utilization := fmt.Sprintf("100 * %v / %v %v", connections, join, maxConnections)
+	headroom := fmt.Sprintf("%v - on (dbinstanceidentifier) %v", maxConnections, connections)
+	forDuration := model.Duration(opts.forDuration)
+
+	return []alertRule{
+		{
+			Alert:       "RDSConnectionUtilizationHigh",
+			Expr:        fmt.Sprintf("%v > %v", utilization, opts.utilizationWarning),
+			For:         forDuration,
+			Labels:      map[string]string{"severity": "warning"},
+			Annotations: utilizationAnnotations(opts.utilizationWarning),
+		},
+		{
+			Alert:       "RDSConnectionUtilizationCritical",
+			Expr:        fmt.Sprintf("%v > %v", utilization, opts.utilizationCritical),
+			For:         forDuration,
+			Labels:      map[string]string{"severity": "critical"},
+			Annotations: utilizationAnnotations(opts.utilizationCritical),
+		},
+		{
+			Alert:  "RDSConnectionHeadroomLow",
+			Expr:   fmt.Sprintf("%v < %v", headroom, opts.headroom),
+			For:    forDuration,
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary":     fmt.Sprintf("Less than %v connections left on {{ $labels.dbinstanceidentifier }}", opts.headroom),
+				"description": "{{ $labels.dbinstanceidentifier }} can accept only {{ $value }} more connections before max_connections.",
+			},
+		},
+		{
+			Alert:  "RDSMaxConnectionsChanged",
+			Expr:   fmt.Sprintf("increase(%v[1h]) > 0", metricName("max_connections_changes_total")),
+			Labels: map[string]string{"severity": "info"},
+			Annotations: map[string]string{
+				"summary":     "max_connections of {{ $labels.dbinstanceidentifier }} changed",
+				"description": "The parameter group or the instance class of {{ $labels.dbinstanceidentifier }} changed its max_connections in the last hour.",
+			},
+		},
+		{
+			Alert:  "RDSMaxConnectionsUnknown",
+			Expr:   fmt.Sprintf(`%v{reason=~"%v|%v"} > 0`, metricName("skipped_instances"), exporter.SkipUnknownClass, exporter.SkipComputeError),
+			For:    forDuration,
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary":     "max_connections of {{ $value }} instances can not be computed ({{ $labels.reason }})",
+				"description": "The instances are not exported: their instance class or their max_connections formula is not supported, see the skip logs of the exporter.",
+			},
+		},
+		{
+			Alert:  "RDSCertificateExpiring",
+			Expr:   fmt.Sprintf("%v - time() < %.0f", metricName("certificate_expiry_timestamp_seconds"), opts.certificateExpiry.Seconds()),
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary":     "The server certificate of {{ $labels.dbinstanceidentifier }} expires soon",
+				"description": "The server certificate of {{ $labels.dbinstanceidentifier }} expires in {{ $value | humanizeDuration }}: rotate its CA.",
+			},
+		},
+		{
+			Alert:  "RDSMaxconExporterTargetDown",
+			Expr:   fmt.Sprintf("%v == 0", metricName("target_up")),
+			For:    forDuration,
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary":     "The exporter can not collect the target {{ $labels.target }}",
+				"description": "The last values of the instances of {{ $labels.target }} are served until they are stale.",
+			},
+		},
+		{
+			Alert:  "RDSMaxconExporterDataStale",
+			Expr:   fmt.Sprintf("%v == 1", metricName("data_stale")),
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary":     "The exporter serves stale data",
+				"description": "No snapshot succeeded for longer than stale_after: max_connections may be outdated.",
+			},
+		},
+	}
+}
+
+func utilizationAnnotations(threshold float64) map[string]string {
+	return map[string]string{
+		"summary":     fmt.Sprintf("{{ $labels.dbinstanceidentifier }} uses more than %v%% of its max_connections", threshold),
+		"description": "{{ $labels.dbinstanceidentifier }} uses {{ $value | humanize }}% of its max_connections.",
+	}
+}
+
+// generateAlerts writes the alert rules as a rule file of Prometheus or as a
+// PrometheusRule resource.
+func generateAlerts(w io.Writer, cfg *Config, opts alertOptions) error {
+	groups := ruleGroups{Groups: []ruleGroup{{Name: opts.name, Rules: alertRules(cfg, opts)}}}
+
+	var doc interface{} = groups
+	switch opts.format {
+	case alertFormatRules:
+	case alertFormatPrometheusRule:
+		rule := prometheusRule{APIVersion: "monitoring.coreos.com/v1", Kind: "PrometheusRule", Spec: groups}
+		rule.Metadata.Name = opts.name
+		doc = rule
+	default:
+		return fmt.Errorf("unknown format: %v", opts.format)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	err := enc.Encode(doc)
+	if err != nil {
+		return fmt.Errorf("failed to encode rules: %w", err)
+	}
+
+	return enc.Close() //nolint:wrapcheck
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateAlertsGolden(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(config, []byte("tag_labels:\n  Team: team\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{alertFormatRules, alertFormatPrometheusRule} {
+		t.Run(format, func(t *testing.T) {
+			f := newFlags()
+			command, cfg, err := f.parse([]string{"generate", "alerts", "--config.file", config, "--format", format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if command != "generate alerts" {
+				t.Fatalf("got command %q", command)
+			}
+
+			var b bytes.Buffer
+			err = generateAlerts(&b, cfg, f.alerts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareGolden(t, filepath.Join("testdata", "alerts", format+".yaml"), b.Bytes())
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type apiInstance struct {
+	Region               string   `json:"region"`
+	DBInstanceIdentifier string   `json:"db_instance_identifier"`
+	DBInstanceClass      string   `json:"db_instance_class"`
+	DBEngine             string   `json:"engine"`
+	MaxConnections       int      `json:"max_connections"`
+	DBParameterGroupName string   `json:"db_parameter_group_name"`
+	DBClusterIdentifier  string   `json:"db_cluster_identifier,omitempty"`
+	DatabaseConnections  *float64 `json:"database_connections,omitempty"`
+}
+
+type apiInstancesResponse struct {
+	UpdatedAt *time.Time    `json:"updated_at"`
+	Instances []apiInstance `json:"instances"`
+}
+
+func newAPIInstance(info RDSInfo) apiInstance {
+	// MaxConnections is always produced by strconv.Itoa
+	maxConnections, _ := strconv.Atoi(info.MaxConnections)
+
+	return apiInstance{
+		Region:               info.Region,
+		DBInstanceIdentifier: info.DBInstanceIdentifier,
+		DBInstanceClass:      info.DBInstanceClass,
+		DBEngine:             info.DBEngine,
+		MaxConnections:       maxConnections,
+		DBParameterGroupName: info.DBParameterGroupName,
+		DBClusterIdentifier:  info.DBClusterIdentifier,
+		DatabaseConnections:  info.DatabaseConnections,
+	}
+}
+
+// key identifies the instance across the targets, where the same identifier
+// may be used in another region.
+func (i apiInstance) key() string {
+	return i.Region + "|" + i.DBInstanceIdentifier
+}
+
+// instancesHandler serves GET /api/v1/instances with every instance
+// discovered by the last snapshot, including the skipped ones.
+func instancesHandler(store *Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		infos, updatedAt := store.Get()
+
+		resp := apiInstancesResponse{
+			Instances: make([]apiInstance, 0, len(infos)),
+		}
+		if !updatedAt.IsZero() {
+			resp.UpdatedAt = &updatedAt
+		}
+		for _, info := range infos {
+			resp.Instances = append(resp.Instances, newAPIInstance(info))
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		slog.Warn("failed to write response", "err", err)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/appconfigdata"
+	"github.com/aws/aws-sdk-go/service/appconfigdata/appconfigdataiface"
+)
+
+const (
+	// appConfigTimeout bounds the read of the configuration from AppConfig.
+	appConfigTimeout = 30 * time.Second
+	// appConfigPollInterval is how often an AppConfig configuration is polled
+	// without --config.refresh-interval, as it is meant to change at runtime.
+	appConfigPollInterval = time.Minute
+)
+
+// appConfig is a freeform configuration profile of AWS AppConfig holding the
+// configuration document in YAML or JSON, given as application/environment/
+// profile by name or identifier, so that its changes go through the
+// validators and the gradual deployment strategies of AppConfig. The latest
+// deployed version is polled through a configuration session, which returns
+// it only when it changed, and not more often than AppConfig allows.
+type appConfig struct {
+	application string
+	environment string
+	profile     string
+	svc         appconfigdataiface.AppConfigDataAPI
+
+	mu sync.Mutex
+	// token is the token of the next poll, empty before the session starts
+	token string
+	// next is the earliest time of the next poll, before which body is
+	// returned again
+	next time.Time
+	body []byte
+}
+
+func newAppConfig(identifier string) (*appConfig, error) {
+	parts := strings.Split(identifier, "/")
+	if len(parts) != 3 || len(parts[0]) == 0 || len(parts[1]) == 0 || len(parts[2]) == 0 {
+		return nil, fmt.Errorf("invalid AppConfig configuration, want application/environment/profile: %v", identifier)
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	handleAudit(sess, "")
+
+	return &appConfig{
+		application: parts[0],
+		environment: parts[1],
+		profile:     parts[2],
+		svc:         appconfigdata.New(sess),
+	}, nil
+}
+
+func (a *appConfig) String() string {
+	return "appconfig:" + a.application + "/" + a.environment + "/" + a.profile
+}
+
+func (a *appConfig) read() ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.body != nil && clk.Now().Before(a.next) {
+		return a.body, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), appConfigTimeout)
+	defer cancel()
+
+	out, err := a.poll(ctx)
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == appconfigdata.ErrCodeBadRequestException && len(a.token) != 0 {
+		// the token expires after 24 hours without poll: start a new session
+		a.token = ""
+		out, err = a.poll(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configuration %v: %w", a, err)
+	}
+
+	a.token = aws.StringValue(out.NextPollConfigurationToken)
+	a.next = clk.Now().Add(time.Duration(aws.Int64Value(out.NextPollIntervalInSeconds)) * time.Second)
+	// the configuration is empty when it did not change since the last poll
+	if len(out.Configuration) != 0 || a.body == nil {
+		a.body = out.Configuration
+	}
+
+	return a.body, nil
+}
+
+// poll gets the latest configuration, starting a session first when there is
+// none.
+func (a *appConfig) poll(ctx context.Context) (*appconfigdata.GetLatestConfigurationOutput, error) {
+	if len(a.token) == 0 {
+		session, err := a.svc.StartConfigurationSessionWithContext(ctx, &appconfigdata.StartConfigurationSessionInput{
+			ApplicationIdentifier:          aws.String(a.application),
+			EnvironmentIdentifier:          aws.String(a.environment),
+			ConfigurationProfileIdentifier: aws.String(a.profile),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to start configuration session: %w", err)
+		}
+		a.token = aws.StringValue(session.InitialConfigurationToken)
+	}
+
+	out, err := a.svc.GetLatestConfigurationWithContext(ctx, &appconfigdata.GetLatestConfigurationInput{
+		ConfigurationToken: aws.String(a.token),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest configuration: %w", err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/appconfigdata"
+	"github.com/aws/aws-sdk-go/service/appconfigdata/appconfigdataiface"
+)
+
+// fakeAppConfigData returns the deployed configuration once per session, and
+// then nothing until it is deployed again, as AppConfig does.
+type fakeAppConfigData struct {
+	appconfigdataiface.AppConfigDataAPI
+	deployed string
+	// sessions and polls count the calls
+	sessions int
+	polls    int
+	// expired makes the next poll fail as with an expired token
+	expired bool
+	// sent is the configuration returned on the last poll of a session
+	sent string
+}
+
+func (f *fakeAppConfigData) StartConfigurationSessionWithContext(_ aws.Context, input *appconfigdata.StartConfigurationSessionInput, _ ...request.Option) (*appconfigdata.StartConfigurationSessionOutput, error) {
+	f.sessions++
+	f.sent = ""
+	return &appconfigdata.StartConfigurationSessionOutput{InitialConfigurationToken: aws.String("initial")}, nil
+}
+
+func (f *fakeAppConfigData) GetLatestConfigurationWithContext(_ aws.Context, input *appconfigdata.GetLatestConfigurationInput, _ ...request.Option) (*appconfigdata.GetLatestConfigurationOutput, error) {
+	f.polls++
+	if f.expired && aws.StringValue(input.ConfigurationToken) != "initial" {
+		f.expired = false
+		return nil, awserr.New(appconfigdata.ErrCodeBadRequestException, "token expired", nil)
+	}
+	out := &appconfigdata.GetLatestConfigurationOutput{
+		NextPollConfigurationToken: aws.String("next"),
+		NextPollIntervalInSeconds:  aws.Int64(60),
+	}
+	if f.deployed != f.sent {
+		out.Configuration = []byte(f.deployed)
+		f.sent = f.deployed
+	}
+	return out, nil
+}
+
+func TestAppConfig(t *testing.T) {
+	fc := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := clk
+	t.Cleanup(func() { clk = c })
+	clk = fc
+
+	svc := &fakeAppConfigData{deployed: "targets:\n  - region: eu-west-1\n"}
+	a := &appConfig{application: "rds-maxcon", environment: "production", profile: "config", svc: svc}
+
+	cfg, err := loadConfig(a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Targets) != 1 || cfg.Targets[0].Region != "eu-west-1" {
+		t.Errorf("got targets %+v, want eu-west-1", cfg.Targets)
+	}
+
+	// not polled again before the poll interval
+	svc.deployed = "targets:\n  - region: us-east-1\n"
+	b, err := a.read()
+	if err != nil || string(b) != "targets:\n  - region: eu-west-1\n" || svc.polls != 1 {
+		t.Errorf("got %q (%v) after %v polls, want the current configuration without polling", b, err, svc.polls)
+	}
+
+	// the deployed version, then the same without it being sent again
+	for i := 0; i < 2; i++ {
+		fc.Advance(time.Minute)
+		b, err = a.read()
+		if err != nil || string(b) != "targets:\n  - region: us-east-1\n" {
+			t.Errorf("got %q (%v), want the deployed version", b, err)
+		}
+	}
+
+	// an expired token starts a new session
+	svc.expired = true
+	fc.Advance(time.Minute)
+	b, err = a.read()
+	if err != nil || string(b) != "targets:\n  - region: us-east-1\n" || svc.sessions != 2 {
+		t.Errorf("got %q (%v) with %v sessions, want a new session", b, err, svc.sessions)
+	}
+}
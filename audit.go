@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// auditLog writes a JSON line per AWS API call, so that compliance teams can
+// prove what the exporter reads from each account.
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// auditEntry is a line of the audit log. The caller is the role of the
+// target when assumed, and the access key of the credentials.
+type auditEntry struct {
+	Time        time.Time              `json:"time"`
+	Service     string                 `json:"service"`
+	Operation   string                 `json:"operation"`
+	Region      string                 `json:"region"`
+	RoleARN     string                 `json:"role_arn,omitempty"`
+	AccessKeyID string                 `json:"access_key_id,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	Duration    float64                `json:"duration_seconds"`
+	Retries     int                    `json:"retries"`
+	StatusCode  int                    `json:"status_code,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+// audit is the audit log, nil when disabled.
+//
+//nolint:gochecknoglobals
+var audit *auditLog
+
+func openAuditLog(path string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	return &auditLog{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (a *auditLog) write(entry auditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err := a.enc.Encode(entry)
+	if err != nil {
+		// the API calls are not failed for the audit log
+		auditErrors.Inc()
+	}
+}
+
+func (a *auditLog) Close() error {
+	return a.file.Close() //nolint:wrapcheck
+}
+
+// auditHandlerName names the handler of handleAudit, which replaces the one
+// of the session it was copied from.
+const auditHandlerName = "rdsmaxcon.audit"
+
+// handleAudit writes the completed requests of a session to the audit log,
+// with the role they are made with, empty for the credentials of the
+// environment.
+func handleAudit(sess *session.Session, roleARN string) {
+	sess.Handlers.Complete.RemoveByName(auditHandlerName)
+	sess.Handlers.Complete.PushBackNamed(request.NamedHandler{Name: auditHandlerName, Fn: func(r *request.Request) {
+		if audit == nil {
+			return
+		}
+
+		entry := auditEntry{
+			Time:       r.Time,
+			Service:    r.ClientInfo.ServiceName,
+			Operation:  r.Operation.Name,
+			Region:     aws.StringValue(r.Config.Region),
+			RoleARN:    roleARN,
+			Parameters: auditParameters(r.Params),
+			Duration:   time.Since(r.Time).Seconds(),
+			Retries:    r.RetryCount,
+		}
+		if r.Config.Credentials != nil {
+			// the credentials are cached once the request is signed
+			if creds, err := r.Config.Credentials.Get(); err == nil {
+				entry.AccessKeyID = creds.AccessKeyID
+			}
+		}
+		if r.HTTPResponse != nil {
+			entry.StatusCode = r.HTTPResponse.StatusCode
+		}
+		if r.Error != nil {
+			entry.Error = r.Error.Error()
+		}
+		audit.write(entry)
+	}})
+}
+
+// auditParameters summarizes the input of a request with its set fields.
+func auditParameters(params interface{}) map[string]interface{} {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil
+	}
+	var ret map[string]interface{}
+	err = json.Unmarshal(b, &ret)
+	if err != nil {
+		return nil
+	}
+	for k, v := range ret {
+		if v == nil {
+			delete(ret, k)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+func TestAuditLog(t *testing.T) {
+	const role = "arn:aws:iam::123456789012:role/rds-maxcon-exporter"
+	path := filepath.Join(t.TempDir(), "audit.log")
+	var err error
+	audit, err = openAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		audit.Close()
+		audit = nil
+	})
+
+	// the handler of the copy replaces the one of the environment
+	sess := newFakeRDS(t)
+	handleAudit(sess, "")
+	sess = sess.Copy()
+	handleAudit(sess, role)
+
+	_, err = rds.New(sess).DescribeDBParametersWithContext(context.Background(), &rds.DescribeDBParametersInput{DBParameterGroupName: aws.String("group-1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry auditEntry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	got := entries[0]
+	if got.Service != "rds" || got.Operation != "DescribeDBParameters" || got.Region != "ap-northeast-1" || got.RoleARN != role ||
+		got.AccessKeyID != "id" || got.StatusCode != 200 || got.Error != "" || got.Time.IsZero() {
+		t.Errorf("unexpected entry: %+v", got)
+	}
+	if len(got.Parameters) != 1 || got.Parameters["DBParameterGroupName"] != "group-1" {
+		t.Errorf("got parameters %v, want the parameter group only", got.Parameters)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+const (
+	// baselinePeriod is the period of the data points of the baseline
+	baselinePeriod = 5 * time.Minute
+	// baselineMinPoints is the number of data points needed for a baseline,
+	// an hour of them
+	baselineMinPoints = 12
+
+	minBaselineWindow = time.Hour
+	// maxBaselineWindow is the retention of the 5 minute data points of
+	// CloudWatch
+	maxBaselineWindow = 63 * 24 * time.Hour
+)
+
+// connectionsBaseline is the mean and the standard deviation of the maximum
+// connections of each period of the window.
+type connectionsBaseline struct {
+	mean   float64
+	stddev float64
+}
+
+// connectionsBaselines caches the baselines of the instances, nil when an
+// instance has too few data points.
+//
+//nolint:gochecknoglobals
+var connectionsBaselines = newHistoryCache[*connectionsBaseline]()
+
+// setConnectionsBaseline fills ConnectionsBaseline and ConnectionsDeviation of
+// the exported instances from the baseline of their connections over the
+// window, reading the ones not read within the refresh interval, and their
+// latest DatabaseConnections, read unless an output already did.
+func setConnectionsBaseline(ctx context.Context, svc cloudwatchiface.CloudWatchAPI, cfg BaselineConfig, infos []RDSInfo) error {
+	var identifiers []string
+	for _, info := range infos {
+		if len(info.SkipReason) == 0 && info.DatabaseConnections == nil {
+			identifiers = append(identifiers, info.DBInstanceIdentifier)
+		}
+	}
+	if len(identifiers) != 0 {
+		now := clk.Now()
+		history, err := getConnectionsHistory(ctx, svc, identifiers, now.Add(-10*time.Minute), now, time.Minute)
+		if err != nil {
+			return fmt.Errorf("failed to read database connections: %w", err)
+		}
+		for i := range infos {
+			if points := history[infos[i].DBInstanceIdentifier]; len(points) != 0 && infos[i].DatabaseConnections == nil {
+				v := points[len(points)-1].value
+				infos[i].DatabaseConnections = &v
+			}
+		}
+	}
+
+	return connectionsBaselines.set(ctx, svc, infos, cfg.Window, baselinePeriod, cfg.RefreshInterval,
+		newConnectionsBaseline,
+		func(info *RDSInfo, baseline *connectionsBaseline) {
+			if baseline == nil || info.DatabaseConnections == nil {
+				return
+			}
+			mean, deviation := baseline.mean, baseline.deviation(*info.DatabaseConnections)
+			info.ConnectionsBaseline, info.ConnectionsDeviation = &mean, &deviation
+		})
+}
+
+// newConnectionsBaseline returns the baseline of the points, nil with too few
+// of them.
+func newConnectionsBaseline(points []connectionsPoint) *connectionsBaseline {
+	if len(points) < baselineMinPoints {
+		return nil
+	}
+
+	var b connectionsBaseline
+	for _, point := range points {
+		b.mean += point.value
+	}
+	b.mean /= float64(len(points))
+	for _, point := range points {
+		b.stddev += (point.value - b.mean) * (point.value - b.mean)
+	}
+	b.stddev = math.Sqrt(b.stddev / float64(len(points)))
+
+	return &b
+}
+
+// deviation returns how many standard deviations the connections are above
+// the mean. The standard deviation is at least a connection, so that the
+// connections of an instance which always had the same ones are not
+// infinitely far from them when they change.
+func (b *connectionsBaseline) deviation(connections float64) float64 {
+	return (connections - b.mean) / math.Max(b.stddev, 1)
+}
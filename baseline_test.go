@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSetConnectionsBaseline(t *testing.T) {
+	fc := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := clk
+	t.Cleanup(func() { clk = c })
+	clk = fc
+
+	steady := make([]float64, baselineMinPoints)
+	for i := range steady {
+		// a mean of 100 with a standard deviation of 10
+		steady[i] = 90 + float64(i%2)*20
+	}
+	svc := &fakeCloudWatch{values: map[string][]float64{
+		"leaking": steady,
+		"idle":    make([]float64, baselineMinPoints),
+		"new":     {10, 20},
+	}}
+	infos := []RDSInfo{
+		{DBInstanceIdentifier: "leaking", DatabaseConnections: ptr(150.0)},
+		// the latest connections are read when an output did not
+		{DBInstanceIdentifier: "idle"},
+		{DBInstanceIdentifier: "new", DatabaseConnections: ptr(20.0)},
+		{DBInstanceIdentifier: "skipped", SkipReason: "parameter group error"},
+	}
+
+	cfg := defaultConfig().Baseline
+	err := setConnectionsBaseline(context.Background(), svc, cfg, infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []struct{ baseline, deviation float64 }{{100, 5}, {0, 0}} {
+		b, d := infos[i].ConnectionsBaseline, infos[i].ConnectionsDeviation
+		if b == nil || d == nil || math.Abs(*b-want.baseline) > 1e-9 || math.Abs(*d-want.deviation) > 1e-9 {
+			t.Errorf("%v: got baseline %v deviation %v, want %v", infos[i].DBInstanceIdentifier, b, d, want)
+		}
+	}
+	if infos[2].ConnectionsDeviation != nil || infos[3].ConnectionsDeviation != nil {
+		t.Errorf("got deviations %v %v, want none with too few data points", infos[2].ConnectionsDeviation, infos[3].ConnectionsDeviation)
+	}
+	// the latest connections of idle, and the baselines of the 3
+	if svc.queries != 4 {
+		t.Errorf("got %v queries, want 4", svc.queries)
+	}
+
+	// the baseline of the idle instance is at least a connection off
+	infos[1].DatabaseConnections = ptr(3.0)
+	err = setConnectionsBaseline(context.Background(), svc, cfg, infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := infos[1].ConnectionsDeviation; d == nil || *d != 3 {
+		t.Errorf("got deviation %v, want 3", d)
+	}
+	if svc.queries != 4 {
+		t.Errorf("got %v queries, want the baselines within the refresh interval cached", svc.queries)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter"
+)
+
+// breaker is the circuit breaker of a target, which stops calling the AWS
+// APIs for a backoff window after sustained failures or throttling, rather
+// than hammering the APIs and filling the logs.
+type breaker struct {
+	failures  int
+	backoff   time.Duration
+	openUntil time.Time
+}
+
+// allow reports whether the target can be collected, which is when the
+// circuit is closed or its backoff window is over (half-open).
+func (b *breaker) allow(now time.Time) bool {
+	return !now.Before(b.openUntil)
+}
+
+// record records the result of a collection, opening the circuit after
+// cfg.Failures failures in a row and closing it on success. A throttled
+// collection opens it at once, as the SDK already retried the requests.
+func (b *breaker) record(cfg CircuitBreakerConfig, target Target, now time.Time, err error) {
+	if cfg.Failures == 0 {
+		return
+	}
+
+	if err == nil {
+		if b.failures >= cfg.Failures {
+			slog.Info("circuit breaker closed", "target", targetName(target))
+		}
+		b.failures = 0
+		b.backoff = 0
+		circuitBreakerOpen.WithLabelValues(targetName(target)).Set(0)
+		return
+	}
+
+	b.failures++
+	if errors.Is(err, exporter.ErrThrottled) {
+		b.failures = max(b.failures, cfg.Failures)
+	}
+	if b.failures < cfg.Failures {
+		return
+	}
+
+	// double the window on each failure once open
+	switch {
+	case b.backoff == 0:
+		b.backoff = cfg.Backoff
+	case b.backoff*2 > cfg.MaxBackoff:
+		b.backoff = cfg.MaxBackoff
+	default:
+		b.backoff *= 2
+	}
+	b.openUntil = now.Add(b.backoff)
+	circuitBreakerOpen.WithLabelValues(targetName(target)).Set(1)
+	slog.Warn("circuit breaker open: serving the last values", "target", targetName(target), "failures", b.failures, "backoff", b.backoff, "throttled", errors.Is(err, exporter.ErrThrottled), "err", err)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBreaker(t *testing.T) {
+	cfg := CircuitBreakerConfig{Failures: 2, Backoff: time.Minute, MaxBackoff: 3 * time.Minute}
+	target := Target{Region: "ap-northeast-1"}
+	failed := errors.New("AccessDenied")
+	throttled := fmt.Errorf("failed to describe DB instances: %w", exporter.ErrThrottled)
+	circuitBreakerOpen.Reset()
+	t.Cleanup(circuitBreakerOpen.Reset)
+
+	c := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := &breaker{}
+	for _, step := range []struct {
+		name    string
+		advance time.Duration
+		allow   bool
+		err     error
+		open    float64
+	}{
+		{name: "closed under the failures", allow: true, err: failed, open: 0},
+		{name: "open after the failures", allow: true, err: failed, open: 1},
+		{name: "open in the backoff", advance: 30 * time.Second, allow: false, open: 1},
+		{name: "half-open after the backoff, open again", advance: 30 * time.Second, allow: true, err: failed, open: 1},
+		{name: "open in the doubled backoff", advance: time.Minute, allow: false, open: 1},
+		{name: "half-open after the doubled backoff", advance: time.Minute, allow: true, err: failed, open: 1},
+		{name: "open up to the max backoff", advance: 2 * time.Minute, allow: false, open: 1},
+		{name: "closed on success", advance: time.Minute, allow: true, open: 0},
+		{name: "open at once when throttled", allow: true, err: throttled, open: 1},
+		{name: "open in the reset backoff", advance: 59 * time.Second, allow: false, open: 1},
+		{name: "closed on success after the reset backoff", advance: time.Second, allow: true, open: 0},
+	} {
+		c.Advance(step.advance)
+		allow := b.allow(c.Now())
+		if allow != step.allow {
+			t.Errorf("%v: got allowed %v, want %v", step.name, allow, step.allow)
+		}
+		// the scheduler only collects when allowed
+		if allow {
+			b.record(cfg, target, c.Now(), step.err)
+		}
+		if got := testutil.ToFloat64(circuitBreakerOpen.WithLabelValues(targetName(target))); got != step.open {
+			t.Errorf("%v: got circuit breaker open %v, want %v", step.name, got, step.open)
+		}
+	}
+
+	// without failures, the circuit is never opened
+	b = &breaker{}
+	for i := 0; i < 3; i++ {
+		b.record(CircuitBreakerConfig{}, target, c.Now(), failed)
+	}
+	if !b.allow(c.Now()) {
+		t.Error("got the circuit open, want the circuit breaker disabled")
+	}
+}
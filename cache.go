@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// parameterCache keeps the max_connections of the parameter groups for
+// parameter_cache_ttl, since they change rarely and DescribeDBParameters is
+// the dominant source of API calls. An entry also records the fingerprint of
+// the instances using the group when it was fetched, so that a new instance
+// or a changed apply status refreshes the group before it expires.
+type parameterCache struct {
+	mu      sync.Mutex
+	entries map[string]parameterCacheEntry
+}
+
+type parameterCacheEntry struct {
+	raw         string
+	fingerprint string
+	expires     time.Time
+}
+
+//nolint:gochecknoglobals
+var parameters = &parameterCache{entries: map[string]parameterCacheEntry{}}
+
+// parameterStore is the storage of the parameter cache: the memory of the
+// process, or Redis shared by the replicas.
+type parameterStore interface {
+	get(key, fingerprint string) (string, bool)
+	set(key, fingerprint, raw string, ttl time.Duration)
+}
+
+// parameterCacheKey identifies a parameter group, whose name is only unique
+// within a region and an account.
+func parameterCacheKey(target Target, name string) string {
+	return target.Region + "|" + target.RoleARN + "|" + target.Endpoint + "|" + name
+}
+
+func (c *parameterCache) get(key, fingerprint string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		parameterCacheRequests.WithLabelValues("miss").Inc()
+		return "", false
+	}
+	if entry.fingerprint != fingerprint {
+		parameterCacheRequests.WithLabelValues("changed").Inc()
+		return "", false
+	}
+	parameterCacheRequests.WithLabelValues("hit").Inc()
+
+	return entry.raw, true
+}
+
+func (c *parameterCache) set(key, fingerprint, raw string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.entries[key] = parameterCacheEntry{raw: raw, fingerprint: fingerprint, expires: now.Add(ttl)}
+
+	// drop the expired entries, such as those of deleted groups
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// targetParameterCache is the parameter cache of a target for the collector.
+type targetParameterCache struct {
+	store  parameterStore
+	target Target
+	ttl    time.Duration
+}
+
+func (c targetParameterCache) Get(name, fingerprint string) (string, bool) {
+	return c.store.get(parameterCacheKey(c.target, name), fingerprint)
+}
+
+func (c targetParameterCache) Set(name, fingerprint, raw string) {
+	c.store.set(parameterCacheKey(c.target, name), fingerprint, raw, c.ttl)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
+)
+
+// check prints how max_connections of an instance is computed step by step.
+// The instance is looked up in each target in order.
+func check(ctx context.Context, w io.Writer, cfg *Config, identifier string) error {
+	for _, target := range cfg.Targets {
+		svc := newRDSAPI(newSession(cfg, target))
+
+		out, err := svc.DescribeDBInstancesWithContext(ctx, &rds.DescribeDBInstancesInput{
+			DBInstanceIdentifier: aws.String(identifier),
+		})
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == rds.ErrCodeDBInstanceNotFoundFault {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to describe DB instance: %w", err)
+		}
+		if len(out.DBInstances) == 0 {
+			continue
+		}
+
+		err = checkInstance(ctx, w, svc, target, out.DBInstances[0])
+		if err != nil {
+			return err
+		}
+		if v, ok := cfg.MaxConnectionsOverrides[identifier]; ok {
+			fmt.Fprintf(w, "Override: max_connections = %d (max_connections_overrides)\n", v)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("instance %v is not found in any target", identifier)
+}
+
+func checkInstance(ctx context.Context, w io.Writer, svc exporter.RDSAPI, target Target, instance *rds.DBInstance) error {
+	engine := aws.StringValue(instance.Engine)
+	class := aws.StringValue(instance.DBInstanceClass)
+
+	fmt.Fprintf(w, "Target:               %v\n", targetName(target))
+	fmt.Fprintf(w, "DBInstanceIdentifier: %v\n", aws.StringValue(instance.DBInstanceIdentifier))
+	fmt.Fprintf(w, "Engine:               %v %v\n", engine, aws.StringValue(instance.EngineVersion))
+	fmt.Fprintf(w, "DBInstanceClass:      %v\n", class)
+	fmt.Fprintf(w, "DBInstanceStatus:     %v\n", aws.StringValue(instance.DBInstanceStatus))
+	fmt.Fprintln(w)
+
+	// the last parameter group wins, as in the exporter
+	var rawMaxConnections string
+	collector := exporter.NewCollector(svc, exporter.Options{})
+	for i, group := range instance.DBParameterGroups {
+		raw, err := collector.RawMaxConnections(ctx, aws.StringValue(group.DBParameterGroupName))
+		if err != nil {
+			return fmt.Errorf("failed to get Parameter Group: %w", err)
+		}
+		rawMaxConnections = raw
+
+		fmt.Fprintf(w, "1. Parameter group #%d: %v (%v)\n", i+1, aws.StringValue(group.DBParameterGroupName), aws.StringValue(group.ParameterApplyStatus))
+		fmt.Fprintf(w, "   max_connections: %q\n", raw)
+	}
+	if len(instance.DBParameterGroups) == 0 {
+		fmt.Fprintln(w, "1. No parameter group is attached")
+	}
+
+	if !exporter.IsSupportedEngine(engine) {
+		fmt.Fprintf(w, "2. Engine %v is not supported: the instance is skipped\n", engine)
+		return nil
+	}
+
+	r, err := exporter.Resolve(engine, rawMaxConnections, class)
+	fmt.Fprintf(w, "2. Parser branch: %v\n", r.Branch)
+
+	switch r.Branch {
+	case maxcon.BranchDefaultFormula:
+		fmt.Fprintf(w, "   LEAST({DBInstanceClassMemory/%d},%d)\n", r.Divisor, r.Limit)
+		if r.Memory != 0 {
+			fmt.Fprintf(w, "3. Memory of %v: %d bytes (%.2f GiB)\n", class, r.Memory, float64(r.Memory)/(1<<30))
+			byMemory := r.Memory / r.Divisor
+			fmt.Fprintf(w, "   DBInstanceClassMemory/%d = %d, LEAST(%d,%d) = %d\n", r.Divisor, byMemory, byMemory, r.Limit, minInt64(byMemory, int64(r.Limit)))
+		} else {
+			fmt.Fprintf(w, "3. Memory of %v: unknown\n", class)
+		}
+		if err != nil {
+			fmt.Fprintf(w, "4. Default max_connections of %v: %v: the instance is skipped\n", class, err)
+			return nil
+		}
+		fmt.Fprintf(w, "4. Default max_connections of %v: %d\n", class, r.Value)
+	case maxcon.BranchFormula:
+		if r.Memory != 0 {
+			fmt.Fprintf(w, "3. Memory of %v: %d bytes (%.2f GiB)\n", class, r.Memory, float64(r.Memory)/(1<<30))
+		} else {
+			fmt.Fprintf(w, "3. Memory of %v: unknown\n", class)
+		}
+		if err != nil {
+			fmt.Fprintf(w, "4. %v: the instance is skipped\n", err)
+			return nil
+		}
+		fmt.Fprintf(w, "4. %v = %d\n", r.Raw, r.Value)
+	case maxcon.BranchExplicitValue:
+		fmt.Fprintf(w, "3. Explicit value: %d\n", r.Value)
+	default:
+		fmt.Fprintln(w, "3. No value could be parsed")
+	}
+
+	if r.Value == 0 {
+		fmt.Fprintln(w, "Result: max connection is 0: the instance is skipped")
+		return nil
+	}
+	fmt.Fprintf(w, "Result: max_connections = %d\n", r.Value)
+
+	return nil
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
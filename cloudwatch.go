@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+const (
+	// GetMetricData accepts at most 500 queries per request.
+	cloudWatchMaxQueries = 500
+	// PutMetricData accepts at most 1000 metrics per request.
+	cloudWatchMaxMetricData = 1000
+)
+
+// CloudWatch publishes max_connections as a custom metric with the same
+// DBInstanceIdentifier dimension as the AWS/RDS metrics, so alarms and
+// dashboards can combine it with DatabaseConnections.
+type CloudWatch struct {
+	svc       cloudwatchiface.CloudWatchAPI
+	namespace string
+}
+
+func NewCloudWatch(namespace string) *CloudWatch {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	handleAudit(sess, "")
+
+	return &CloudWatch{
+		svc:       cloudwatch.New(sess),
+		namespace: namespace,
+	}
+}
+
+func (c *CloudWatch) Name() string {
+	return "cloudwatch"
+}
+
+func (c *CloudWatch) Close() error {
+	return nil
+}
+
+func (c *CloudWatch) Write(infos []RDSInfo) error {
+	now := time.Now()
+	data := make([]*cloudwatch.MetricDatum, 0, len(infos))
+
+	for _, info := range infos {
+		v, err := strconv.ParseFloat(info.MaxConnections, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse max connections to float64: %w", err)
+		}
+
+		data = append(data, &cloudwatch.MetricDatum{
+			MetricName: aws.String("MaxConnections"),
+			Dimensions: []*cloudwatch.Dimension{
+				{
+					Name:  aws.String("DBInstanceIdentifier"),
+					Value: aws.String(info.DBInstanceIdentifier),
+				},
+			},
+			Timestamp: aws.Time(now),
+			Unit:      aws.String(cloudwatch.StandardUnitCount),
+			Value:     aws.Float64(v),
+		})
+	}
+
+	for offset := 0; offset < len(data); offset += cloudWatchMaxMetricData {
+		limit := offset + cloudWatchMaxMetricData
+		if limit > len(data) {
+			limit = len(data)
+		}
+
+		_, err := c.svc.PutMetricData(&cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(c.namespace),
+			MetricData: data[offset:limit],
+		})
+		if err != nil {
+			return fmt.Errorf("failed to put metric data: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// setDatabaseConnections fills DatabaseConnections with the latest maximum of
+// the DatabaseConnections CloudWatch metric of each instance.
+func setDatabaseConnections(ctx context.Context, sess *session.Session, infos []RDSInfo) error {
+	svc := cloudwatch.New(sess)
+	end := time.Now()
+	start := end.Add(-10 * time.Minute)
+
+	for offset := 0; offset < len(infos); offset += cloudWatchMaxQueries {
+		limit := offset + cloudWatchMaxQueries
+		if limit > len(infos) {
+			limit = len(infos)
+		}
+
+		queries := make([]*cloudwatch.MetricDataQuery, 0, limit-offset)
+		for i := offset; i < limit; i++ {
+			queries = append(queries, &cloudwatch.MetricDataQuery{
+				Id: aws.String(fmt.Sprintf("m%d", i)),
+				MetricStat: &cloudwatch.MetricStat{
+					Metric: &cloudwatch.Metric{
+						Namespace:  aws.String("AWS/RDS"),
+						MetricName: aws.String("DatabaseConnections"),
+						Dimensions: []*cloudwatch.Dimension{
+							{
+								Name:  aws.String("DBInstanceIdentifier"),
+								Value: aws.String(infos[i].DBInstanceIdentifier),
+							},
+						},
+					},
+					Period: aws.Int64(60),
+					Stat:   aws.String("Maximum"),
+				},
+			})
+		}
+
+		input := &cloudwatch.GetMetricDataInput{
+			StartTime:         aws.Time(start),
+			EndTime:           aws.Time(end),
+			MetricDataQueries: queries,
+			ScanBy:            aws.String(cloudwatch.ScanByTimestampDescending),
+		}
+
+		err := svc.GetMetricDataPagesWithContext(ctx, input, func(page *cloudwatch.GetMetricDataOutput, lastPage bool) bool {
+			for _, result := range page.MetricDataResults {
+				var i int
+				if _, err := fmt.Sscanf(*result.Id, "m%d", &i); err != nil {
+					continue
+				}
+				// results are sorted by timestamp descending, so the first value is the latest
+				if len(result.Values) > 0 && infos[i].DatabaseConnections == nil {
+					infos[i].DatabaseConnections = result.Values[0]
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get metric data: %w", err)
+		}
+	}
+
+	return nil
+}
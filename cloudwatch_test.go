@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+func (f *fakeCloudWatch) PutMetricData(input *cloudwatch.PutMetricDataInput) (*cloudwatch.PutMetricDataOutput, error) {
+	f.put = append(f.put, input)
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func TestCloudWatch(t *testing.T) {
+	svc := &fakeCloudWatch{}
+	c := &CloudWatch{svc: svc, namespace: "Custom/RDS"}
+
+	// the metrics are put by requests of cloudWatchMaxMetricData
+	var infos []RDSInfo
+	for i := 0; i < cloudWatchMaxMetricData+1; i++ {
+		infos = append(infos, RDSInfo{DBInstanceIdentifier: fmt.Sprintf("db%04d", i), MaxConnections: "1800"})
+	}
+	err := c.Write(infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(svc.put) != 2 || len(svc.put[0].MetricData) != cloudWatchMaxMetricData || len(svc.put[1].MetricData) != 1 {
+		t.Fatalf("got %d requests, want %d metrics in 2", len(svc.put), len(infos))
+	}
+	datum := svc.put[1].MetricData[0]
+	if aws.StringValue(svc.put[1].Namespace) != "Custom/RDS" || aws.StringValue(datum.MetricName) != "MaxConnections" || aws.Float64Value(datum.Value) != 1800 {
+		t.Errorf("got metric %v in %v, want MaxConnections 1800 in Custom/RDS", datum, aws.StringValue(svc.put[1].Namespace))
+	}
+	if len(datum.Dimensions) != 1 || aws.StringValue(datum.Dimensions[0].Name) != "DBInstanceIdentifier" || aws.StringValue(datum.Dimensions[0].Value) != "db1000" {
+		t.Errorf("got dimensions %v, want the DBInstanceIdentifier of db1000", datum.Dimensions)
+	}
+}
+
+func TestCloudWatchRedact(t *testing.T) {
+	svc := &fakeCloudWatch{}
+	hashed := publishRedacted(t, &CloudWatch{svc: svc, namespace: "Custom/RDS"})
+
+	if len(svc.put) != 1 || len(svc.put[0].MetricData) != 1 {
+		t.Fatalf("got requests %v, want a metric", svc.put)
+	}
+	if got := aws.StringValue(svc.put[0].MetricData[0].Dimensions[0].Value); got != hashed {
+		t.Errorf("got dimension %v, want the identifier hashed as %v", got, hashed)
+	}
+	if got := svc.put[0].String(); strings.Contains(got, "tenant") {
+		t.Errorf("got request %v, want every name of the tenant redacted", got)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ClusterEndpoints are the endpoints of the cluster of an instance, which the
+// applications connect to instead of the instance.
+type ClusterEndpoints struct {
+	Writer string
+	Reader string
+	Port   int64
+}
+
+// clusterEndpointLabels are the labels of the cluster endpoint info metric.
+//
+//nolint:gochecknoglobals
+var clusterEndpointLabels = []string{"dbclusteridentifier", "endpoint", "reader_endpoint", "port"}
+
+//nolint:gochecknoglobals
+var clusterEndpointInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "aws_custom",
+	Subsystem: "rds",
+	Name:      "cluster_endpoint_info",
+	Help:      "Writer and reader endpoints and port of the clusters of the exported instances, always 1",
+},
+	clusterEndpointLabels,
+)
+
+// rdsClusterAPI is the part of the RDS API describing the clusters and the
+// global clusters.
+type rdsClusterAPI interface {
+	DescribeDBClustersPagesWithContext(ctx context.Context, input *rds.DescribeDBClustersInput, fn func(*rds.DescribeDBClustersOutput, bool) bool, opts ...request.Option) error
+	DescribeGlobalClustersPagesWithContext(ctx context.Context, input *rds.DescribeGlobalClustersInput, fn func(*rds.DescribeGlobalClustersOutput, bool) bool, opts ...request.Option) error
+}
+
+// newRDSClusterAPI returns the RDS client describing the clusters, replaced
+// by a fake in the tests.
+//
+//nolint:gochecknoglobals
+var newRDSClusterAPI = func(sess *session.Session) rdsClusterAPI {
+	return rds.New(sess)
+}
+
+// setClusterEndpoints sets the endpoints of the cluster of the instances
+// which are in one. The clusters are only described when there are some.
+func setClusterEndpoints(ctx context.Context, svc rdsClusterAPI, infos []RDSInfo) error {
+	clusters := map[string]*ClusterEndpoints{}
+	for _, info := range infos {
+		if len(info.DBClusterIdentifier) != 0 {
+			clusters[info.DBClusterIdentifier] = nil
+		}
+	}
+	if len(clusters) == 0 {
+		return nil
+	}
+
+	err := svc.DescribeDBClustersPagesWithContext(ctx, &rds.DescribeDBClustersInput{}, func(page *rds.DescribeDBClustersOutput, lastPage bool) bool {
+		for _, cluster := range page.DBClusters {
+			identifier := aws.StringValue(cluster.DBClusterIdentifier)
+			if _, ok := clusters[identifier]; ok {
+				clusters[identifier] = &ClusterEndpoints{
+					Writer: aws.StringValue(cluster.Endpoint),
+					Reader: aws.StringValue(cluster.ReaderEndpoint),
+					Port:   aws.Int64Value(cluster.Port),
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe DB clusters: %w", err)
+	}
+
+	for i := range infos {
+		if endpoints := clusters[infos[i].DBClusterIdentifier]; endpoints != nil {
+			infos[i].ClusterEndpoints = endpoints
+		}
+	}
+
+	return nil
+}
+
+// updateClusterEndpointInfo replaces the series of the cluster endpoint info
+// metric with the clusters of the exported instances, none when disabled by
+// a reload.
+func updateClusterEndpointInfo(cfg *Config, infos []RDSInfo) {
+	clusterEndpointInfo.Reset()
+	if !cfg.ExportClusterEndpoints {
+		return
+	}
+	for _, info := range infos {
+		if info.ClusterEndpoints == nil {
+			continue
+		}
+		values := []string{info.DBClusterIdentifier, info.ClusterEndpoints.Writer, info.ClusterEndpoints.Reader, strconv.FormatInt(info.ClusterEndpoints.Port, 10)}
+		for i, name := range clusterEndpointLabels {
+			values[i] = cfg.LabelValues.Sanitize(name, values[i])
+		}
+		clusterEndpointInfo.WithLabelValues(values...).Set(1)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// fakeClusters serves the clusters in a page per cluster, counting the calls,
+// and the global clusters in a single page.
+type fakeClusters struct {
+	clusters []*rds.DBCluster
+	globals  []*rds.GlobalCluster
+	calls    int
+}
+
+func (f *fakeClusters) DescribeDBClustersPagesWithContext(ctx context.Context, input *rds.DescribeDBClustersInput, fn func(*rds.DescribeDBClustersOutput, bool) bool, opts ...request.Option) error {
+	f.calls++
+	for i, cluster := range f.clusters {
+		if !fn(&rds.DescribeDBClustersOutput{DBClusters: []*rds.DBCluster{cluster}}, i == len(f.clusters)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeClusters) DescribeGlobalClustersPagesWithContext(ctx context.Context, input *rds.DescribeGlobalClustersInput, fn func(*rds.DescribeGlobalClustersOutput, bool) bool, opts ...request.Option) error {
+	fn(&rds.DescribeGlobalClustersOutput{GlobalClusters: f.globals}, true)
+
+	return nil
+}
+
+func TestSetClusterEndpoints(t *testing.T) {
+	svc := &fakeClusters{clusters: []*rds.DBCluster{
+		{DBClusterIdentifier: aws.String("other"), Endpoint: aws.String("other.cluster"), ReaderEndpoint: aws.String("other.cluster-ro"), Port: aws.Int64(5432)},
+		{DBClusterIdentifier: aws.String("api"), Endpoint: aws.String("api.cluster"), ReaderEndpoint: aws.String("api.cluster-ro"), Port: aws.Int64(5433)},
+	}}
+	infos := []RDSInfo{
+		{DBInstanceIdentifier: "api-a01", DBClusterIdentifier: "api"},
+		{DBInstanceIdentifier: "api-a02", DBClusterIdentifier: "api"},
+		{DBInstanceIdentifier: "standalone"},
+		{DBInstanceIdentifier: "deleted-a01", DBClusterIdentifier: "deleted"},
+	}
+
+	err := setClusterEndpoints(context.Background(), svc, infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := ClusterEndpoints{Writer: "api.cluster", Reader: "api.cluster-ro", Port: 5433}
+	for _, info := range infos[:2] {
+		if info.ClusterEndpoints == nil || *info.ClusterEndpoints != want {
+			t.Errorf("%v: got %+v, want %+v", info.DBInstanceIdentifier, info.ClusterEndpoints, want)
+		}
+	}
+	for _, info := range infos[2:] {
+		if info.ClusterEndpoints != nil {
+			t.Errorf("%v: got %+v, want nil", info.DBInstanceIdentifier, info.ClusterEndpoints)
+		}
+	}
+
+	// the clusters are not described without instances in one
+	svc.calls = 0
+	err = setClusterEndpoints(context.Background(), svc, infos[2:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.calls != 0 {
+		t.Errorf("got %v calls, want 0", svc.calls)
+	}
+}
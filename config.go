@@ -0,0 +1,1133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/prometheus/common/model"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the exporter. It is read from the optional
+// YAML file given by --config.file, and the command-line flags and their
+// environment variables override the values from the file.
+type Config struct {
+	Interval time.Duration `yaml:"interval"`
+	// SnapshotTimeout bounds the collection of a snapshot, the interval by default
+	SnapshotTimeout time.Duration `yaml:"snapshot_timeout"`
+	// Schedule is a cron expression collecting at fixed times instead of
+	// every interval, in Timezone, the local time zone by default
+	Schedule string `yaml:"schedule"`
+	Timezone string `yaml:"timezone"`
+	// Jitter is the maximum random delay added to each tick
+	Jitter time.Duration `yaml:"jitter"`
+	// ParameterCacheTTL is how long the parameters of a parameter group are
+	// cached, 0 to fetch them on every snapshot
+	ParameterCacheTTL time.Duration `yaml:"parameter_cache_ttl"`
+	// Redis shares the parameter cache of the replicas
+	Redis RedisConfig `yaml:"redis"`
+	// CircuitBreaker pauses the collection of a failing target
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+	// StaleAfter is the age from which the served data is stale, twice the
+	// longest interval by default
+	StaleAfter time.Duration `yaml:"stale_after"`
+	// SeriesTTL is the number of snapshots of its target an instance is kept
+	// for when it is not seen, 0 to drop it on the first snapshot it is
+	// missing from and to keep the instances of a failing target
+	SeriesTTL int `yaml:"series_ttl"`
+	// SnapshotFile is where the last snapshot is saved, to be served on restart
+	SnapshotFile string `yaml:"snapshot_file"`
+	// AuditLogFile is where a JSON line is appended for each AWS API call
+	AuditLogFile string `yaml:"audit_log_file"`
+	// Watchdog detects a wedged collection loop
+	Watchdog WatchdogConfig `yaml:"watchdog"`
+	// LeaderElection only collects on one of the replicas when Table is set
+	LeaderElection LeaderElectionConfig `yaml:"leader_election"`
+	// Throttling is how the throttled AWS requests are retried
+	Throttling ThrottlingConfig `yaml:"throttling"`
+	// Concurrency is the maximum number of AWS requests in flight per target
+	Concurrency int      `yaml:"concurrency"`
+	Targets     []Target `yaml:"targets"`
+	// TargetFiles are glob patterns of YAML files listing more targets, such
+	// as the keys of a ConfigMap mounted as a directory
+	TargetFiles []string          `yaml:"target_files"`
+	Filters     Filters           `yaml:"filters"`
+	TagLabels   map[string]string `yaml:"tag_labels"`
+	// ExportUnsupportedEngines exports the instances of unsupported engines
+	// with the value 0 and supported="false" instead of skipping them
+	ExportUnsupportedEngines bool `yaml:"export_unsupported_engines"`
+	// ExportClusterEndpoints exports the endpoints of the clusters of the
+	// instances as an info metric
+	ExportClusterEndpoints bool `yaml:"export_cluster_endpoints"`
+	// ExportDBLoad exports the average active sessions of the instances with
+	// Performance Insights enabled, with the labels of max_connections
+	ExportDBLoad bool `yaml:"export_db_load"`
+	// ExportCostPerConnection exports the on-demand hourly price of the
+	// instances per connection of their max_connections
+	ExportCostPerConnection bool `yaml:"export_cost_per_connection"`
+	// ExportMaintenance exports the maintenance windows and the pending
+	// maintenance actions of the instances
+	ExportMaintenance bool `yaml:"export_maintenance"`
+	// GlobalClusters labels the instances of the Aurora global databases
+	GlobalClusters GlobalClustersConfig `yaml:"global_clusters"`
+	// StoppedInstances is what to do with the stopped instances: export, skip
+	// or label
+	StoppedInstances string `yaml:"stopped_instances"`
+	// MaxConnectionsOverrides replaces the computed max_connections of the
+	// instances by identifier, such as those behind a connection pooler
+	// enforcing a lower ceiling
+	MaxConnectionsOverrides map[string]int `yaml:"max_connections_overrides"`
+	// Sharding splits the instances across replicas
+	Sharding ShardingConfig `yaml:"sharding"`
+	// MaxInstances is the maximum number of instances to export, 0 for no limit
+	MaxInstances int `yaml:"max_instances"`
+	// Recommendations serves the instances whose class could be downsized or
+	// must be upsized for their peak connections
+	Recommendations RecommendationsConfig `yaml:"recommendations"`
+	// Forecast exports when the connections of the instances reach their
+	// max_connections at their recent trend
+	Forecast ForecastConfig `yaml:"forecast"`
+	// Baseline exports how far the connections of the instances are from
+	// their usual ones
+	Baseline BaselineConfig `yaml:"baseline"`
+	// PoolSizing exports the size of the connection pools of the clients of
+	// the instances
+	PoolSizing PoolSizingConfig `yaml:"pool_sizing"`
+
+	// LabelValues sanitizes the label values of the metrics
+	LabelValues LabelValuesConfig `yaml:"label_values"`
+	Outputs     OutputsConfig     `yaml:"outputs"`
+	Web         WebConfig         `yaml:"web"`
+	GRPC        GRPCConfig        `yaml:"grpc"`
+	Log         LogConfig         `yaml:"log"`
+
+	schedule cron.Schedule
+}
+
+// Target is a region and an optional role to assume in it. Labels are added
+// as constant labels to every instance discovered with this target. Interval
+// overrides the global interval, and Engines limits the engines collected by
+// this target when not empty, so that a region or an engine can be refreshed
+// more or less often than the others.
+type Target struct {
+	Region     string            `yaml:"region"`
+	RoleARN    string            `yaml:"role_arn"`
+	ExternalID string            `yaml:"external_id"`
+	Labels     map[string]string `yaml:"labels"`
+	Interval   time.Duration     `yaml:"interval"`
+	Engines    []string          `yaml:"engines"`
+	// Endpoint replaces the endpoint of the AWS APIs, such as to run against
+	// LocalStack or moto
+	Endpoint string `yaml:"endpoint"`
+
+	// path locates the targets of the target files in the errors
+	path string
+}
+
+// Filters select the instances to export. Include and Exclude are regular
+// expressions matched against the instance identifier, and Engines limits the
+// engines when not empty.
+type Filters struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	Engines []string `yaml:"engines"`
+
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// CircuitBreakerConfig opens the circuit of a target after Failures snapshots
+// failed in a row, calling no AWS API for Backoff while the last values keep
+// being served. The backoff doubles on each failure after it, up to
+// MaxBackoff. Failures 0 disables the circuit breaker.
+type CircuitBreakerConfig struct {
+	Failures   int           `yaml:"failures"`
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
+
+// RecommendationsConfig reads the peak DatabaseConnections of the instances
+// over Lookback from CloudWatch when Enabled, again after RefreshInterval, to
+// recommend a larger class of the same family for the instances above
+// UpsizeUtilization of their max_connections, and the smallest one keeping
+// them under it for the instances below DownsizeUtilization, in percent.
+type RecommendationsConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	Lookback            time.Duration `yaml:"lookback"`
+	RefreshInterval     time.Duration `yaml:"refresh_interval"`
+	UpsizeUtilization   float64       `yaml:"upsize_utilization"`
+	DownsizeUtilization float64       `yaml:"downsize_utilization"`
+}
+
+// ForecastConfig fits a linear trend to the maximum DatabaseConnections of
+// each 5 minutes of the Window of the instances when Enabled, on every
+// snapshot, to export the time until they reach max_connections.
+type ForecastConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Window  time.Duration `yaml:"window"`
+}
+
+// BaselineConfig reads the maximum DatabaseConnections of each 5 minutes of
+// the Window of the instances from CloudWatch when Enabled, again after
+// RefreshInterval, as their baseline, to export how many standard deviations
+// their latest connections are above its mean on every snapshot.
+type BaselineConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Window          time.Duration `yaml:"window"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// PoolSizingConfig splits the max_connections of the instances of Clients,
+// less ReservedConnections for the superusers, among the pools of their
+// number of clients by identifier, such as the replicas of an application or
+// of pgbouncer, counting MaxSurge percent more of them for a rolling deploy.
+type PoolSizingConfig struct {
+	ReservedConnections int            `yaml:"reserved_connections"`
+	MaxSurge            int            `yaml:"max_surge"`
+	Clients             map[string]int `yaml:"clients"`
+}
+
+// RedisConfig keeps the parameter cache in Redis at Address when set, such as
+// ElastiCache, so that the replicas of a highly available or sharded
+// deployment fetch each parameter group once per parameter_cache_ttl, under
+// KeyPrefix. The keys of several deployments sharing a Redis must not
+// collide.
+type RedisConfig struct {
+	Address   string        `yaml:"address"`
+	Username  string        `yaml:"username"`
+	Password  string        `yaml:"password"`
+	DB        int           `yaml:"db"`
+	TLS       bool          `yaml:"tls"`
+	KeyPrefix string        `yaml:"key_prefix"`
+	Timeout   time.Duration `yaml:"timeout"`
+}
+
+// WatchdogConfig fails the readiness when the collection loop of a target did
+// not go around within Intervals ticks of its interval or the schedule plus
+// its snapshot timeout, and exits the process when Exit is set. Intervals 0
+// disables the watchdog.
+type WatchdogConfig struct {
+	Intervals int  `yaml:"intervals"`
+	Exit      bool `yaml:"exit"`
+}
+
+// LeaderElectionConfig elects the replica which collects with the LockName
+// item of a DynamoDB Table in Region. The lock is held by Identity, the
+// hostname by default, for LeaseDuration after each renewal. The standby
+// serves the snapshots the leader saves to the snapshot file, which must be
+// shared by the replicas.
+type LeaderElectionConfig struct {
+	Table         string        `yaml:"dynamodb_table"`
+	Region        string        `yaml:"region"`
+	LockName      string        `yaml:"lock_name"`
+	Identity      string        `yaml:"identity"`
+	LeaseDuration time.Duration `yaml:"lease_duration"`
+}
+
+// ThrottlingConfig retries a throttled request up to MaxRetries, with a
+// backoff between MinDelay and MaxDelay. When Adaptive is set, the following
+// requests of the target are also delayed after a throttled request, the
+// delay doubling on each throttled request and halving on each success, and
+// being at least the Retry-After of the response. PageDelay is waited between
+// two pages of a paginated API, so that the discovery of a jumbo account
+// does not starve the other consumers of the RDS API.
+type ThrottlingConfig struct {
+	MaxRetries int           `yaml:"max_retries"`
+	MinDelay   time.Duration `yaml:"min_delay"`
+	MaxDelay   time.Duration `yaml:"max_delay"`
+	Adaptive   bool          `yaml:"adaptive"`
+	PageDelay  time.Duration `yaml:"page_delay"`
+}
+
+// ShardingConfig splits a very large fleet across TotalShards replicas, each
+// exporting the instances whose identifier hashes to its Shard, from 0.
+type ShardingConfig struct {
+	Shard       int `yaml:"shard"`
+	TotalShards int `yaml:"total_shards"`
+}
+
+// GlobalClustersConfig labels the instances of the Aurora global databases
+// with their global cluster and whether the region of their cluster is the
+// primary or a secondary one. CollectSecondaryRegions also collects the
+// instances of the member clusters in the regions which are not targets.
+type GlobalClustersConfig struct {
+	Enabled                 bool `yaml:"enabled"`
+	CollectSecondaryRegions bool `yaml:"collect_secondary_regions"`
+}
+
+// LabelValuesConfig rewrites the label values before they are emitted, for
+// downstream relabeling which breaks on some characters or lengths. The
+// values are first redacted by the first matching rule of Redact, then the
+// replacements are applied in order, then the value is truncated to
+// MaxLength characters when it is not 0.
+type LabelValuesConfig struct {
+	Redact    []LabelRedact  `yaml:"redact"`
+	HashKey   string         `yaml:"hash_key"`
+	Replace   []LabelReplace `yaml:"replace"`
+	MaxLength int            `yaml:"max_length"`
+}
+
+const (
+	// redactHash replaces a value with its keyed hash, so that the series
+	// stay distinct and stable without showing the value
+	redactHash = "hash"
+	// redactRemove replaces a value with redactedValue
+	redactRemove  = "redact"
+	redactedValue = "redacted"
+)
+
+// hashLength is the number of hex characters of a hashed value.
+const hashLength = 16
+
+// LabelRedact hides the values of Labels matching Regex, all of them when it
+// is empty, such as the instance identifiers which embed the names of the
+// tenants of a multi-tenant platform.
+type LabelRedact struct {
+	Labels []string `yaml:"labels"`
+	Regex  string   `yaml:"regex"`
+	Action string   `yaml:"action"`
+
+	regex *regexp.Regexp
+}
+
+// LabelReplace replaces the matches of Regex with Replacement, which can
+// refer to the groups as $1.
+type LabelReplace struct {
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+
+	regex *regexp.Regexp
+}
+
+type OutputsConfig struct {
+	DogStatsD   DogStatsDConfig   `yaml:"dogstatsd"`
+	StatsD      StatsDConfig      `yaml:"statsd"`
+	InfluxDB    InfluxDBConfig    `yaml:"influxdb"`
+	Webhook     WebhookConfig     `yaml:"webhook"`
+	SNS         SNSConfig         `yaml:"sns"`
+	Kafka       KafkaConfig       `yaml:"kafka"`
+	CloudWatch  CloudWatchConfig  `yaml:"cloudwatch"`
+	RemoteWrite RemoteWriteConfig `yaml:"remote_write"`
+}
+
+type DogStatsDConfig struct {
+	Address string `yaml:"address"`
+}
+
+type StatsDConfig struct {
+	Address string `yaml:"address"`
+	Prefix  string `yaml:"prefix"`
+}
+
+type InfluxDBConfig struct {
+	URL   string `yaml:"url"`
+	Token string `yaml:"token"`
+}
+
+type WebhookConfig struct {
+	URL    string `yaml:"url"`
+	Secret string `yaml:"secret"`
+}
+
+type SNSConfig struct {
+	TopicARN             string  `yaml:"topic_arn"`
+	UtilizationThreshold float64 `yaml:"utilization_threshold"`
+}
+
+type KafkaConfig struct {
+	Brokers []string `yaml:"brokers"`
+	Topic   string   `yaml:"topic"`
+	Mode    string   `yaml:"mode"`
+}
+
+type CloudWatchConfig struct {
+	Namespace string `yaml:"namespace"`
+}
+
+// RemoteWriteConfig pushes the metrics to a remote write URL when it is set,
+// signed with SigV4 for the region of SigV4Region when that is set, as
+// required by Amazon Managed Service for Prometheus.
+type RemoteWriteConfig struct {
+	URL         string `yaml:"url"`
+	SigV4Region string `yaml:"sigv4_region"`
+}
+
+type WebConfig struct {
+	ListenAddress string       `yaml:"listen_address"`
+	TelemetryPath string       `yaml:"telemetry_path"`
+	OpenMetrics   bool         `yaml:"open_metrics"`
+	TLS           WebTLSConfig `yaml:"tls"`
+	SigV4         SigV4Config  `yaml:"sigv4"`
+}
+
+// SigV4Config requires the requests to be signed with AWS Signature Version 4
+// by the access key when AccessKeyID is set, such as by a Prometheus scraping
+// through an ALB with its sigv4 configuration. The signature is recomputed
+// with the secret key, so the temporary credentials of a role can not be
+// verified. The health endpoints do not require a signature.
+type SigV4Config struct {
+	Region          string `yaml:"region"`
+	Service         string `yaml:"service"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+}
+
+// WebTLSConfig serves HTTPS when CertFile is set. With ClientCAFile, the
+// client certificates signed by the CA are required, and with ClientSPIFFEIDs
+// their URI SAN must be one of these SPIFFE IDs. The health endpoints do not
+// require a client certificate, for the probes of the orchestrator.
+type WebTLSConfig struct {
+	CertFile        string   `yaml:"cert_file"`
+	KeyFile         string   `yaml:"key_file"`
+	ClientCAFile    string   `yaml:"client_ca_file"`
+	ClientSPIFFEIDs []string `yaml:"client_spiffe_ids"`
+}
+
+type GRPCConfig struct {
+	ListenAddress string `yaml:"listen_address"`
+}
+
+const (
+	defaultIntervalSecond = 300
+	defaultConcurrency    = 4
+
+	defaultThrottlingMaxDelay = 30 * time.Second
+	defaultLeaseDuration      = 30 * time.Second
+
+	defaultCircuitBreakerBackoff    = 5 * time.Minute
+	defaultCircuitBreakerMaxBackoff = time.Hour
+
+	defaultRecommendationsLookback        = 14 * 24 * time.Hour
+	defaultRecommendationsRefreshInterval = time.Hour
+	defaultUpsizeUtilization              = 80
+	defaultDownsizeUtilization            = 30
+
+	defaultForecastWindow = 6 * time.Hour
+
+	defaultRedisKeyPrefix = "aws-rds-maxcon:"
+	defaultRedisTimeout   = time.Second
+
+	defaultBaselineWindow          = 7 * 24 * time.Hour
+	defaultBaselineRefreshInterval = time.Hour
+
+	// defaultReservedConnections is the superuser_reserved_connections of
+	// the default parameter groups of RDS for PostgreSQL
+	defaultReservedConnections = 3
+	// defaultMaxSurge is the default maxSurge of a Kubernetes Deployment
+	defaultMaxSurge = 25
+
+	// minInterval and maxConcurrency keep the AWS APIs from being throttled
+	minInterval    = 10 * time.Second
+	maxConcurrency = 100
+)
+
+// builtinLabels are the labels of every series, which can not be used by
+// target labels or tag labels.
+//
+//nolint:gochecknoglobals
+var builtinLabels = []string{"dbinstanceidentifier", "dbinstanceclass"}
+
+// outputFields are the fields of the instances written to the outputs which
+// are not labels of the metrics, such as parameter group names embedding the
+// names of the tenants, and can be redacted as labels.
+//
+//nolint:gochecknoglobals
+var outputFields = []string{"dbclusteridentifier", "dbparametergroupname"}
+
+// supportedLabel tells whether the engine of an instance is supported, when
+// the instances of unsupported engines are exported.
+const supportedLabel = "supported"
+
+const (
+	stoppedExport = "export"
+	stoppedSkip   = "skip"
+	// stoppedLabel adds the status label to every series
+	stoppedLabel = "label"
+
+	statusLabel = "status"
+)
+
+func defaultConfig() *Config {
+	return &Config{
+		Interval:         defaultIntervalSecond * time.Second,
+		Concurrency:      defaultConcurrency,
+		StoppedInstances: stoppedExport,
+		Sharding:         ShardingConfig{TotalShards: 1},
+		LeaderElection: LeaderElectionConfig{
+			LockName:      "aws-rds-maxcon-prometheus-exporter",
+			LeaseDuration: defaultLeaseDuration,
+		},
+		Throttling: ThrottlingConfig{
+			MaxRetries: client.DefaultRetryerMaxNumRetries,
+			MinDelay:   client.DefaultRetryerMinThrottleDelay,
+			MaxDelay:   defaultThrottlingMaxDelay,
+			Adaptive:   true,
+		},
+		CircuitBreaker: CircuitBreakerConfig{
+			Backoff:    defaultCircuitBreakerBackoff,
+			MaxBackoff: defaultCircuitBreakerMaxBackoff,
+		},
+		Recommendations: RecommendationsConfig{
+			Lookback:            defaultRecommendationsLookback,
+			RefreshInterval:     defaultRecommendationsRefreshInterval,
+			UpsizeUtilization:   defaultUpsizeUtilization,
+			DownsizeUtilization: defaultDownsizeUtilization,
+		},
+		Forecast: ForecastConfig{Window: defaultForecastWindow},
+		Redis:    RedisConfig{KeyPrefix: defaultRedisKeyPrefix, Timeout: defaultRedisTimeout},
+		Baseline: BaselineConfig{Window: defaultBaselineWindow, RefreshInterval: defaultBaselineRefreshInterval},
+		PoolSizing: PoolSizingConfig{
+			ReservedConnections: defaultReservedConnections,
+			MaxSurge:            defaultMaxSurge,
+		},
+		Log: LogConfig{
+			Level:        "info",
+			Format:       "logfmt",
+			SkipInterval: time.Hour,
+		},
+		Web: WebConfig{
+			ListenAddress: ":8080",
+			TelemetryPath: "/metrics",
+			SigV4:         SigV4Config{Service: "aps"},
+		},
+		Outputs: OutputsConfig{
+			SNS: SNSConfig{UtilizationThreshold: defaultSNSUtilizationThreshold},
+		},
+	}
+}
+
+// loadConfig reads the configuration from src, the defaults when it is nil,
+// then applies the overrides of the flags and the target files.
+func loadConfig(src configSource, overrides []func(*Config) error) (*Config, error) {
+	cfg := defaultConfig()
+
+	if src != nil {
+		b, err := src.read()
+		if err != nil {
+			return nil, err
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(b))
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+		// an empty file is the same as no file
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config %v: %w", src, err)
+		}
+	}
+
+	for _, override := range overrides {
+		err := override(cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := cfg.loadTargetFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// fieldError is a validation error of the field at Path, a YAML path such as
+// "targets[1].role_arn", so that it can be located in the file.
+type fieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *fieldError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Path, e.Err)
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.Err
+}
+
+//nolint:gochecknoglobals
+var (
+	roleARNRep = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/[\w+=,.@/-]+$`)
+	regionRep  = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+)
+
+// validate checks the configuration and fills the defaults that depend on
+// other fields. All the errors are returned, as *fieldError joined.
+func (c *Config) validate() error {
+	var errs []error
+	add := func(path string, format string, args ...interface{}) {
+		errs = append(errs, &fieldError{Path: path, Err: fmt.Errorf(format, args...)})
+	}
+
+	if c.Interval < minInterval {
+		add("interval", "must be at least %v: %v", minInterval, c.Interval)
+	}
+	if c.StaleAfter < 0 {
+		add("stale_after", "must not be negative: %v", c.StaleAfter)
+	}
+	if c.ParameterCacheTTL < 0 {
+		add("parameter_cache_ttl", "must not be negative: %v", c.ParameterCacheTTL)
+	}
+	if len(c.Redis.Address) != 0 {
+		if _, _, err := net.SplitHostPort(c.Redis.Address); err != nil {
+			add("redis.address", "must be host:port: %v", c.Redis.Address)
+		}
+		if c.ParameterCacheTTL == 0 {
+			add("redis.address", "needs a positive parameter_cache_ttl")
+		}
+		if c.Redis.DB < 0 {
+			add("redis.db", "must not be negative: %v", c.Redis.DB)
+		}
+		if c.Redis.Timeout <= 0 {
+			add("redis.timeout", "must be positive: %v", c.Redis.Timeout)
+		}
+	}
+	if c.SnapshotTimeout < 0 {
+		add("snapshot_timeout", "must not be negative: %v", c.SnapshotTimeout)
+	}
+	_, tzErr := time.LoadLocation(c.Timezone)
+	if tzErr != nil {
+		add("timezone", "invalid time zone: %v", tzErr)
+	}
+	c.schedule = nil
+	if len(c.Schedule) != 0 {
+		spec := c.Schedule
+		if len(c.Timezone) != 0 && tzErr == nil {
+			spec = "CRON_TZ=" + c.Timezone + " " + spec
+		}
+		schedule, err := cron.ParseStandard(spec)
+		if err != nil {
+			add("schedule", "invalid cron expression: %v", err)
+		}
+		c.schedule = schedule
+	} else if len(c.Timezone) != 0 {
+		add("timezone", "requires schedule")
+	}
+
+	if c.Watchdog.Intervals < 0 {
+		add("watchdog.intervals", "must not be negative: %v", c.Watchdog.Intervals)
+	}
+
+	le := c.LeaderElection
+	if len(le.Table) != 0 && len(le.LockName) == 0 {
+		add("leader_election.lock_name", "is required with dynamodb_table")
+	}
+	if len(le.Table) != 0 && le.LeaseDuration < 3*time.Second {
+		add("leader_election.lease_duration", "must be at least 3s: %v", le.LeaseDuration)
+	}
+	if len(le.Table) != 0 && len(c.SnapshotFile) == 0 {
+		add("snapshot_file", "is required with leader_election, for the standby to serve the snapshots of the leader")
+	}
+
+	th := c.Throttling
+	if th.MaxRetries < 0 {
+		add("throttling.max_retries", "must not be negative: %v", th.MaxRetries)
+	}
+	if th.MinDelay <= 0 {
+		add("throttling.min_delay", "must be positive: %v", th.MinDelay)
+	}
+	if th.PageDelay < 0 {
+		add("throttling.page_delay", "must not be negative: %v", th.PageDelay)
+	}
+	if th.MaxDelay < th.MinDelay {
+		add("throttling.max_delay", "must be at least the min delay %v: %v", th.MinDelay, th.MaxDelay)
+	}
+
+	cb := c.CircuitBreaker
+	if cb.Failures < 0 {
+		add("circuit_breaker.failures", "must not be negative: %v", cb.Failures)
+	}
+	if cb.Failures > 0 && cb.Backoff <= 0 {
+		add("circuit_breaker.backoff", "must be positive: %v", cb.Backoff)
+	}
+	if cb.Failures > 0 && cb.MaxBackoff < cb.Backoff {
+		add("circuit_breaker.max_backoff", "must be at least the backoff %v: %v", cb.Backoff, cb.MaxBackoff)
+	}
+
+	rc := c.Recommendations
+	if rc.Enabled && rc.Lookback < time.Hour {
+		add("recommendations.lookback", "must be at least 1h: %v", rc.Lookback)
+	}
+	if rc.Enabled && rc.RefreshInterval <= 0 {
+		add("recommendations.refresh_interval", "must be positive: %v", rc.RefreshInterval)
+	}
+	if rc.Enabled && (rc.UpsizeUtilization <= 0 || rc.UpsizeUtilization > 100) {
+		add("recommendations.upsize_utilization", "must be in (0, 100]: %v", rc.UpsizeUtilization)
+	}
+	if rc.Enabled && (rc.DownsizeUtilization < 0 || rc.DownsizeUtilization >= rc.UpsizeUtilization) {
+		add("recommendations.downsize_utilization", "must be in [0, the upsize utilization %v): %v", rc.UpsizeUtilization, rc.DownsizeUtilization)
+	}
+
+	if c.Forecast.Enabled && (c.Forecast.Window < minForecastWindow || c.Forecast.Window > maxForecastWindow) {
+		add("forecast.window", "must be in [%v, %v]: %v", minForecastWindow, maxForecastWindow, c.Forecast.Window)
+	}
+
+	if c.Baseline.Enabled {
+		if c.Baseline.Window < minBaselineWindow || c.Baseline.Window > maxBaselineWindow {
+			add("baseline.window", "must be in [%v, %v]: %v", minBaselineWindow, maxBaselineWindow, c.Baseline.Window)
+		}
+		if c.Baseline.RefreshInterval <= 0 {
+			add("baseline.refresh_interval", "must be positive: %v", c.Baseline.RefreshInterval)
+		}
+	}
+
+	if c.Concurrency < 1 || c.Concurrency > maxConcurrency {
+		add("concurrency", "must be in [1, %v]: %v", maxConcurrency, c.Concurrency)
+	}
+
+	if c.Web.ListenAddress == unixPrefix {
+		add("web.listen_address", "the path of the Unix socket is missing")
+	}
+	if c.GRPC.ListenAddress == unixPrefix {
+		add("grpc.listen_address", "the path of the Unix socket is missing")
+	}
+
+	tc := c.Web.TLS
+	if (len(tc.CertFile) == 0) != (len(tc.KeyFile) == 0) {
+		add("web.tls", "cert_file and key_file must be set together")
+	}
+	if len(tc.ClientCAFile) != 0 && len(tc.CertFile) == 0 {
+		add("web.tls.client_ca_file", "requires cert_file")
+	}
+	if len(tc.ClientSPIFFEIDs) != 0 && len(tc.ClientCAFile) == 0 {
+		add("web.tls.client_spiffe_ids", "requires client_ca_file")
+	}
+	for i, id := range tc.ClientSPIFFEIDs {
+		if u, err := url.Parse(id); err != nil || u.Scheme != "spiffe" || len(u.Host) == 0 {
+			add(fmt.Sprintf("web.tls.client_spiffe_ids[%d]", i), "invalid SPIFFE ID: %v", id)
+		}
+	}
+
+	sv := c.Web.SigV4
+	if len(sv.AccessKeyID) != 0 {
+		if len(sv.SecretAccessKey) == 0 {
+			add("web.sigv4.secret_access_key", "is required with access_key_id")
+		}
+		if len(sv.Region) == 0 {
+			add("web.sigv4.region", "is required with access_key_id")
+		}
+		if len(sv.Service) == 0 {
+			add("web.sigv4.service", "is required with access_key_id")
+		}
+	}
+
+	if _, err := parseLogLevel(c.Log.Level); err != nil {
+		add("log.level", "%v", err)
+	}
+	if err := validateLogFormat(c.Log.Format); err != nil {
+		add("log.format", "%v", err)
+	}
+	if c.Log.SkipInterval < 0 {
+		add("log.skip_interval", "must not be negative: %v", c.Log.SkipInterval)
+	}
+
+	if len(c.Targets) == 0 {
+		// the region and credentials of the environment
+		c.Targets = []Target{{}}
+	}
+
+	keys := map[string]bool{}
+	for i, target := range c.Targets {
+		path := target.yamlPath(i)
+		if len(target.Region) != 0 && !regionRep.MatchString(target.Region) {
+			add(path+".region", "invalid region: %v", target.Region)
+		}
+		if len(target.RoleARN) != 0 && !roleARNRep.MatchString(target.RoleARN) {
+			add(path+".role_arn", "invalid role ARN: %v", target.RoleARN)
+		}
+		if len(target.ExternalID) != 0 && len(target.RoleARN) == 0 {
+			add(path+".external_id", "requires role_arn")
+		}
+		if len(target.Endpoint) != 0 {
+			u, err := url.Parse(target.Endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+				add(path+".endpoint", "invalid endpoint URL: %v", target.Endpoint)
+			}
+		}
+		if target.Interval != 0 && target.Interval < minInterval {
+			add(path+".interval", "must be at least %v: %v", minInterval, target.Interval)
+		}
+		if c.Interval > 0 && (c.Jitter < 0 || c.Jitter >= c.TargetInterval(target)) {
+			add("jitter", "must be in [0, interval of %v): %v", path, c.Jitter)
+		}
+		if keys[target.key()] {
+			add(path, "duplicate target: %v", targetName(target))
+		}
+		keys[target.key()] = true
+	}
+
+	labels := map[string]bool{}
+	for _, name := range builtinLabels {
+		labels[name] = true
+	}
+	if c.ExportUnsupportedEngines {
+		labels[supportedLabel] = true
+	}
+	if c.StoppedInstances == stoppedLabel {
+		labels[statusLabel] = true
+	}
+	if c.GlobalClusters.Enabled {
+		labels[globalClusterLabel] = true
+		labels[globalRoleLabel] = true
+	}
+	for tag, name := range c.TagLabels {
+		path := "tag_labels." + tag
+		if !model.LabelName(name).IsValid() {
+			add(path, "invalid label name: %v", name)
+		}
+		if labels[name] {
+			add(path, "duplicate label name: %v", name)
+		}
+		labels[name] = true
+	}
+	for i, target := range c.Targets {
+		for name := range target.Labels {
+			path := fmt.Sprintf("%v.labels.%v", target.yamlPath(i), name)
+			if !model.LabelName(name).IsValid() {
+				add(path, "invalid label name: %v", name)
+			}
+			if labels[name] {
+				add(path, "label %v conflicts with another label", name)
+			}
+		}
+	}
+
+	c.Filters.include = nil
+	for i, expr := range c.Filters.Include {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			add(fmt.Sprintf("filters.include[%d]", i), "invalid regular expression: %v", err)
+			continue
+		}
+		c.Filters.include = append(c.Filters.include, re)
+	}
+	c.Filters.exclude = nil
+	for i, expr := range c.Filters.Exclude {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			add(fmt.Sprintf("filters.exclude[%d]", i), "invalid regular expression: %v", err)
+			continue
+		}
+		c.Filters.exclude = append(c.Filters.exclude, re)
+	}
+
+	sns := c.Outputs.SNS
+	if len(sns.TopicARN) != 0 && (sns.UtilizationThreshold <= 0 || sns.UtilizationThreshold > 100) {
+		add("outputs.sns.utilization_threshold", "must be in (0, 100]: %v", sns.UtilizationThreshold)
+	}
+
+	if u := c.Outputs.RemoteWrite.URL; len(u) != 0 {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			add("outputs.remote_write.url", "must be an http or https URL: %v", u)
+		}
+	}
+
+	for i := range c.LabelValues.Replace {
+		replace := &c.LabelValues.Replace[i]
+		re, err := regexp.Compile(replace.Regex)
+		if err != nil {
+			add(fmt.Sprintf("label_values.replace[%d].regex", i), "invalid regular expression: %v", err)
+			continue
+		}
+		replace.regex = re
+	}
+	labelNames := append(append(append([]string{}, builtinLabels...), outputFields...), c.LabelNames()...)
+	if c.ExportClusterEndpoints {
+		labelNames = append(labelNames, clusterEndpointLabels...)
+	}
+	hashed := false
+	for i := range c.LabelValues.Redact {
+		redact := &c.LabelValues.Redact[i]
+		path := fmt.Sprintf("label_values.redact[%d]", i)
+		if len(redact.Labels) == 0 {
+			add(path+".labels", "must not be empty")
+		}
+		for _, name := range redact.Labels {
+			if !slices.Contains(labelNames, name) {
+				add(path+".labels", "unknown label: %v", name)
+			}
+		}
+		switch redact.Action {
+		case "":
+			redact.Action = redactHash
+		case redactHash, redactRemove:
+		default:
+			add(path+".action", "must be %v or %v: %v", redactHash, redactRemove, redact.Action)
+		}
+		if redact.Action == redactRemove && slices.Contains(redact.Labels, "dbinstanceidentifier") {
+			add(path+".action", "the series of the instances would collide: hash dbinstanceidentifier instead")
+		}
+		hashed = hashed || redact.Action == redactHash
+		re, err := regexp.Compile(redact.Regex)
+		if err != nil {
+			add(path+".regex", "invalid regular expression: %v", err)
+			continue
+		}
+		redact.regex = re
+	}
+	if hashed && len(c.LabelValues.HashKey) == 0 {
+		add("label_values.hash_key", "required to hash, so that the values can not be found by hashing the known names")
+	}
+	if c.Sharding.TotalShards < 1 {
+		add("sharding.total_shards", "must be at least 1: %v", c.Sharding.TotalShards)
+	} else if c.Sharding.Shard < 0 || c.Sharding.Shard >= c.Sharding.TotalShards {
+		add("sharding.shard", "must be in [0, %v): %v", c.Sharding.TotalShards, c.Sharding.Shard)
+	}
+
+	if c.GlobalClusters.CollectSecondaryRegions && !c.GlobalClusters.Enabled {
+		add("global_clusters.collect_secondary_regions", "requires global_clusters.enabled")
+	}
+
+	if c.SeriesTTL < 0 {
+		add("series_ttl", "must not be negative: %v", c.SeriesTTL)
+	}
+	if c.MaxInstances < 0 {
+		add("max_instances", "must not be negative: %v", c.MaxInstances)
+	}
+	if c.LabelValues.MaxLength < 0 {
+		add("label_values.max_length", "must not be negative: %v", c.LabelValues.MaxLength)
+	}
+
+	for identifier, v := range c.MaxConnectionsOverrides {
+		if v <= 0 {
+			add("max_connections_overrides."+identifier, "must be positive: %v", v)
+		}
+	}
+
+	if c.PoolSizing.ReservedConnections < 0 {
+		add("pool_sizing.reserved_connections", "must not be negative: %v", c.PoolSizing.ReservedConnections)
+	}
+	if c.PoolSizing.MaxSurge < 0 {
+		add("pool_sizing.max_surge", "must not be negative: %v", c.PoolSizing.MaxSurge)
+	}
+	for identifier, v := range c.PoolSizing.Clients {
+		if v <= 0 {
+			add("pool_sizing.clients."+identifier, "must be positive: %v", v)
+		}
+	}
+
+	switch c.StoppedInstances {
+	case stoppedExport, stoppedSkip, stoppedLabel:
+	default:
+		add("stopped_instances", "must be %v, %v or %v: %v", stoppedExport, stoppedSkip, stoppedLabel, c.StoppedInstances)
+	}
+
+	kafka := c.Outputs.Kafka
+	if len(kafka.Brokers) != 0 && len(kafka.Topic) == 0 {
+		add("outputs.kafka.topic", "is required with brokers")
+	}
+	switch kafka.Mode {
+	case "", kafkaModeSnapshot, kafkaModeDiff:
+	default:
+		add("outputs.kafka.mode", "must be %v or %v: %v", kafkaModeSnapshot, kafkaModeDiff, kafka.Mode)
+	}
+
+	return errors.Join(errs...)
+}
+
+// LabelNames returns the extra label names of the series, in addition to the
+// builtin labels.
+func (c *Config) LabelNames() []string {
+	seen := map[string]bool{}
+	var names []string
+
+	for _, name := range c.TagLabels {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, target := range c.Targets {
+		for name := range target.Labels {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	if c.ExportUnsupportedEngines {
+		names = append(names, supportedLabel)
+	}
+	if c.StoppedInstances == stoppedLabel {
+		names = append(names, statusLabel)
+	}
+	if c.GlobalClusters.Enabled {
+		names = append(names, globalClusterLabel, globalRoleLabel)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// TargetInterval returns the interval of a target.
+func (c *Config) TargetInterval(target Target) time.Duration {
+	if target.Interval > 0 {
+		return target.Interval
+	}
+
+	return c.Interval
+}
+
+// TargetSnapshotTimeout returns the snapshot timeout of a target, which is
+// its interval by default so that its snapshots never overlap.
+func (c *Config) TargetSnapshotTimeout(target Target) time.Duration {
+	if c.SnapshotTimeout > 0 {
+		return c.SnapshotTimeout
+	}
+
+	return c.TargetInterval(target)
+}
+
+// MatchEngine reports whether the target collects an engine.
+func (t Target) MatchEngine(engine string) bool {
+	if len(t.Engines) == 0 {
+		return true
+	}
+
+	for _, e := range t.Engines {
+		if e == engine {
+			return true
+		}
+	}
+
+	return false
+}
+
+// yamlPath returns the path of the i-th target in the errors, in its target
+// file if it comes from one.
+func (t Target) yamlPath(i int) string {
+	if len(t.path) != 0 {
+		return t.path
+	}
+
+	return fmt.Sprintf("targets[%d]", i)
+}
+
+// key identifies a target across reloads.
+func (t Target) key() string {
+	return fmt.Sprintf("%v|%v|%v|%v|%v", t.Region, t.RoleARN, t.ExternalID, t.Engines, t.Endpoint)
+}
+
+// Sanitize rewrites the value of a label.
+func (c *LabelValuesConfig) Sanitize(name, v string) string {
+	v = c.redact(name, v)
+
+	for _, replace := range c.Replace {
+		if replace.regex != nil {
+			v = replace.regex.ReplaceAllString(v, replace.Replacement)
+		}
+	}
+
+	if c.MaxLength > 0 {
+		if r := []rune(v); len(r) > c.MaxLength {
+			v = string(r[:c.MaxLength])
+		}
+	}
+
+	return v
+}
+
+// redactInstance returns a copy of an instance whose identifiers and labels
+// are redacted as the label values of the metrics, for the outputs which send
+// them to shared backends as tags, dimensions or fields.
+func (c *LabelValuesConfig) redactInstance(info RDSInfo) RDSInfo {
+	if len(c.Redact) == 0 {
+		return info
+	}
+
+	info.DBInstanceIdentifier = c.redact("dbinstanceidentifier", info.DBInstanceIdentifier)
+	info.DBInstanceClass = c.redact("dbinstanceclass", info.DBInstanceClass)
+	info.DBClusterIdentifier = c.redact("dbclusteridentifier", info.DBClusterIdentifier)
+	info.DBParameterGroupName = c.redact("dbparametergroupname", info.DBParameterGroupName)
+	labels := make(map[string]string, len(info.Labels))
+	for name, v := range info.Labels {
+		labels[name] = c.redact(name, v)
+	}
+	info.Labels = labels
+
+	return info
+}
+
+func (c *LabelValuesConfig) redact(name, v string) string {
+	for _, redact := range c.Redact {
+		if !slices.Contains(redact.Labels, name) || redact.regex == nil || !redact.regex.MatchString(v) {
+			continue
+		}
+		if redact.Action == redactRemove {
+			return redactedValue
+		}
+
+		return hex.EncodeToString(hmacSHA256([]byte(c.HashKey), v))[:hashLength]
+	}
+
+	return v
+}
+
+// Owns reports whether an instance belongs to the shard.
+func (s ShardingConfig) Owns(identifier string) bool {
+	if s.TotalShards <= 1 {
+		return true
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(identifier))
+
+	return int(h.Sum32()%uint32(s.TotalShards)) == s.Shard
+}
+
+// Match reports whether an instance passes the filters.
+func (f *Filters) Match(identifier, engine string) bool {
+	if len(f.Engines) != 0 {
+		found := false
+		for _, e := range f.Engines {
+			if e == engine {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.include) != 0 {
+		found := false
+		for _, re := range f.include {
+			if re.MatchString(identifier) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, re := range f.exclude {
+		if re.MatchString(identifier) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLabelValuesSanitize(t *testing.T) {
+	invalid := LabelReplace{Regex: "[^a-zA-Z0-9_-]", Replacement: "_"}
+	tests := []struct {
+		name        string
+		labelValues LabelValuesConfig
+		label       string
+		value       string
+		want        string
+	}{
+		{name: "unchanged", label: "team", value: "api/v2", want: "api/v2"},
+		{name: "invalid characters", labelValues: LabelValuesConfig{Replace: []LabelReplace{invalid}}, label: "team", value: "api/v2 (beta)", want: "api_v2__beta_"},
+		{name: "replacements in order", labelValues: LabelValuesConfig{Replace: []LabelReplace{invalid, {Regex: "_+", Replacement: "-"}}}, label: "team", value: "api / v2", want: "api-v2"},
+		{name: "truncated", labelValues: LabelValuesConfig{MaxLength: 8}, label: "team", value: "platform-api", want: "platform"},
+		{name: "truncated by characters", labelValues: LabelValuesConfig{MaxLength: 3}, label: "team", value: "チームA", want: "チーム"},
+		{name: "truncated after the replacements", labelValues: LabelValuesConfig{Replace: []LabelReplace{invalid}, MaxLength: 5}, label: "team", value: "a/b/c/d", want: "a_b_c"},
+		{name: "not truncated at the max length", labelValues: LabelValuesConfig{MaxLength: 3}, label: "team", value: "api", want: "api"},
+		{name: "empty result", labelValues: LabelValuesConfig{Replace: []LabelReplace{{Regex: "^tmp-.*$", Replacement: ""}}}, label: "team", value: "tmp-api", want: ""},
+		{name: "empty value", labelValues: LabelValuesConfig{Replace: []LabelReplace{invalid}, MaxLength: 3}, label: "team", value: "", want: ""},
+		{
+			name:        "redacted before the replacements",
+			labelValues: LabelValuesConfig{Redact: []LabelRedact{{Labels: []string{"team"}, Action: redactRemove}}, Replace: []LabelReplace{{Regex: "d", Replacement: "D"}}},
+			label:       "team",
+			value:       "api/v2",
+			want:        "reDacteD",
+		},
+		{
+			name:        "redacted label only",
+			labelValues: LabelValuesConfig{Redact: []LabelRedact{{Labels: []string{"team"}, Action: redactRemove}}},
+			label:       "dbinstanceidentifier",
+			value:       "a01",
+			want:        "a01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, func(cfg *Config) {
+				cfg.TagLabels = map[string]string{"Team": "team"}
+				cfg.LabelValues = tt.labelValues
+			})
+
+			if got := cfg.LabelValues.Sanitize(tt.label, tt.value); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShardingOwns(t *testing.T) {
+	const total = 3
+	counts := make([]int, total)
+	for i := 0; i < 1000; i++ {
+		identifier := fmt.Sprintf("db-%04d", i)
+		var owners []int
+		for shard := 0; shard < total; shard++ {
+			if (ShardingConfig{Shard: shard, TotalShards: total}).Owns(identifier) {
+				owners = append(owners, shard)
+			}
+		}
+		if len(owners) != 1 {
+			t.Fatalf("got %v owned by shards %v, want exactly one", identifier, owners)
+		}
+		counts[owners[0]]++
+
+		if !(ShardingConfig{}).Owns(identifier) || !(ShardingConfig{TotalShards: 1}).Owns(identifier) {
+			t.Errorf("got %v not owned without sharding", identifier)
+		}
+	}
+	for shard, n := range counts {
+		if n < 250 || n > 420 {
+			t.Errorf("got %d instances of 1000 in shard %d, want about a third", n, shard)
+		}
+	}
+
+	// the split is stable across restarts and versions, so that a rollout
+	// does not move the instances between the shards
+	for identifier, shard := range map[string]int{"postgres-api-production-a01": 1, "mysql-production-a02": 2, "db-0001": 0} {
+		if !(ShardingConfig{Shard: shard, TotalShards: total}).Owns(identifier) {
+			t.Errorf("got %v not owned by shard %d", identifier, shard)
+		}
+	}
+}
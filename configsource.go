@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// configSource is where the YAML configuration document is read from: a
+// file, or an S3 object, a parameter of SSM Parameter Store or an AppConfig
+// configuration to share it across replicas and accounts. A source is read again on every reload.
+type configSource interface {
+	// String is the location of the source in the errors
+	String() string
+	read() ([]byte, error)
+}
+
+// fileConfig is the path of a configuration file.
+type fileConfig string
+
+func (f fileConfig) String() string {
+	return string(f)
+}
+
+func (f fileConfig) read() ([]byte, error) {
+	b, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	return b, nil
+}
+
+// configSource returns the source of --config.file, a path or an s3:// URL,
+// of --config.ssm-parameter or of --config.appconfig, nil when none is set.
+func (f *flags) configSource() (configSource, error) {
+	set := 0
+	for _, v := range []string{f.configFile, f.configSSMParameter, f.configAppConfig} {
+		if len(v) != 0 {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("--config.file, --config.ssm-parameter and --config.appconfig are mutually exclusive")
+	}
+
+	switch {
+	case strings.HasPrefix(f.configFile, "s3://"):
+		return newS3Config(f.configFile)
+	case len(f.configFile) != 0:
+		return fileConfig(f.configFile), nil
+	case len(f.configSSMParameter) != 0:
+		return newSSMConfig(f.configSSMParameter), nil
+	case len(f.configAppConfig) != 0:
+		return newAppConfig(f.configAppConfig)
+	}
+
+	return nil, nil
+}
+
+// refreshInterval returns how often the source of the configuration is read
+// again, by default only for AppConfig, whose configuration is meant to be
+// changed at runtime.
+func (f *flags) refreshInterval() time.Duration {
+	if _, ok := f.source.(*appConfig); ok && f.configRefreshInterval == 0 {
+		return appConfigPollInterval
+	}
+
+	return f.configRefreshInterval
+}
+
+// isConfigFile reports whether the configuration is read from a file, which
+// can be watched.
+func isConfigFile(src configSource) bool {
+	_, ok := src.(fileConfig)
+	return ok
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+// newCloudWatchAPI returns the CloudWatch client of a session reading the
+// history of the connections, replaced by a fake in the tests.
+//
+//nolint:gochecknoglobals
+var newCloudWatchAPI = func(sess *session.Session) cloudwatchiface.CloudWatchAPI {
+	return cloudwatch.New(sess)
+}
+
+// connectionsPoint is the maximum of DatabaseConnections over a period.
+type connectionsPoint struct {
+	timestamp time.Time
+	value     float64
+}
+
+// getConnectionsHistory returns the maximum DatabaseConnections of each period
+// between start and end of the instances, by identifier, in ascending order of
+// time. The instances without data point are missing.
+func getConnectionsHistory(ctx context.Context, svc cloudwatchiface.CloudWatchAPI, identifiers []string, start, end time.Time, period time.Duration) (map[string][]connectionsPoint, error) {
+	ret := map[string][]connectionsPoint{}
+
+	for offset := 0; offset < len(identifiers); offset += cloudWatchMaxQueries {
+		limit := offset + cloudWatchMaxQueries
+		if limit > len(identifiers) {
+			limit = len(identifiers)
+		}
+
+		queries := make([]*cloudwatch.MetricDataQuery, 0, limit-offset)
+		for i := offset; i < limit; i++ {
+			queries = append(queries, &cloudwatch.MetricDataQuery{
+				Id: aws.String(fmt.Sprintf("m%d", i)),
+				MetricStat: &cloudwatch.MetricStat{
+					Metric: &cloudwatch.Metric{
+						Namespace:  aws.String("AWS/RDS"),
+						MetricName: aws.String("DatabaseConnections"),
+						Dimensions: []*cloudwatch.Dimension{
+							{
+								Name:  aws.String("DBInstanceIdentifier"),
+								Value: aws.String(identifiers[i]),
+							},
+						},
+					},
+					Period: aws.Int64(int64(period / time.Second)),
+					Stat:   aws.String("Maximum"),
+				},
+			})
+		}
+
+		input := &cloudwatch.GetMetricDataInput{
+			StartTime:         aws.Time(start),
+			EndTime:           aws.Time(end),
+			MetricDataQueries: queries,
+			ScanBy:            aws.String(cloudwatch.ScanByTimestampAscending),
+		}
+
+		err := svc.GetMetricDataPagesWithContext(ctx, input, func(page *cloudwatch.GetMetricDataOutput, lastPage bool) bool {
+			for _, result := range page.MetricDataResults {
+				var i int
+				if _, err := fmt.Sscanf(aws.StringValue(result.Id), "m%d", &i); err != nil || i < 0 || i >= len(identifiers) {
+					continue
+				}
+				for j := range result.Values {
+					if j < len(result.Timestamps) {
+						ret[identifiers[i]] = append(ret[identifiers[i]], connectionsPoint{
+							timestamp: aws.TimeValue(result.Timestamps[j]),
+							value:     aws.Float64Value(result.Values[j]),
+						})
+					}
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get metric data: %w", err)
+		}
+	}
+
+	// the results of an instance can be split across the pages
+	for _, points := range ret {
+		sort.Slice(points, func(i, j int) bool { return points[i].timestamp.Before(points[j].timestamp) })
+	}
+
+	return ret, nil
+}
+
+// historyCache caches a value computed from the connections history of each
+// instance by region and identifier, so that the history is only read from
+// CloudWatch again after the refresh interval rather than on every snapshot.
+type historyCache[T any] struct {
+	mu         sync.Mutex
+	byInstance map[string]historyEntry[T]
+}
+
+type historyEntry[T any] struct {
+	value T
+	at    time.Time
+}
+
+func newHistoryCache[T any]() *historyCache[T] {
+	return &historyCache[T]{byInstance: map[string]historyEntry[T]{}}
+}
+
+// set fills the exported instances with fill and their value computed from
+// their history of the period over the lookback, reading the ones not read
+// within the refresh interval. The cached values are kept when CloudWatch
+// fails.
+func (c *historyCache[T]) set(ctx context.Context, svc cloudwatchiface.CloudWatchAPI, infos []RDSInfo, lookback, period, refresh time.Duration,
+	compute func([]connectionsPoint) T, fill func(*RDSInfo, T),
+) error {
+	now := clk.Now()
+	key := func(info RDSInfo) string { return info.Region + "|" + info.DBInstanceIdentifier }
+
+	// the keys of the instances to read, by identifier
+	stale := map[string]string{}
+	c.mu.Lock()
+	for _, info := range infos {
+		if len(info.SkipReason) != 0 {
+			continue
+		}
+		if entry, ok := c.byInstance[key(info)]; !ok || now.Sub(entry.at) >= refresh {
+			stale[info.DBInstanceIdentifier] = key(info)
+		}
+	}
+	c.mu.Unlock()
+
+	var err error
+	if len(stale) != 0 {
+		identifiers := make([]string, 0, len(stale))
+		for identifier := range stale {
+			identifiers = append(identifiers, identifier)
+		}
+		sort.Strings(identifiers)
+
+		var history map[string][]connectionsPoint
+		history, err = getConnectionsHistory(ctx, svc, identifiers, now.Add(-lookback), now, period)
+		if err == nil {
+			c.mu.Lock()
+			for identifier, k := range stale {
+				c.byInstance[k] = historyEntry[T]{value: compute(history[identifier]), at: now}
+			}
+			c.mu.Unlock()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range infos {
+		if entry, ok := c.byInstance[key(infos[i])]; ok {
+			fill(&infos[i], entry.value)
+		}
+	}
+	// the instances which are gone are forgotten
+	for k, entry := range c.byInstance {
+		if now.Sub(entry.at) > lookback+refresh {
+			delete(c.byInstance, k)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dashboardOptions are the flags of the generate dashboard command.
+type dashboardOptions struct {
+	title       string
+	uid         string
+	connections string
+}
+
+type grafanaDashboard struct {
+	Title         string            `json:"title"`
+	UID           string            `json:"uid"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Refresh       string            `json:"refresh"`
+	Time          grafanaTimeRange  `json:"time"`
+	Templating    grafanaTemplating `json:"templating"`
+	Panels        []grafanaPanel    `json:"panels"`
+}
+
+type grafanaTimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type grafanaTemplating struct {
+	List []grafanaVariable `json:"list"`
+}
+
+type grafanaVariable struct {
+	Name       string             `json:"name"`
+	Label      string             `json:"label,omitempty"`
+	Type       string             `json:"type"`
+	Query      string             `json:"query"`
+	Datasource *grafanaDatasource `json:"datasource,omitempty"`
+	Refresh    int                `json:"refresh,omitempty"`
+	IncludeAll bool               `json:"includeAll,omitempty"`
+	Multi      bool               `json:"multi,omitempty"`
+	AllValue   string             `json:"allValue,omitempty"`
+}
+
+type grafanaDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type grafanaPanel struct {
+	ID          int                `json:"id"`
+	Title       string             `json:"title"`
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	GridPos     grafanaGridPos     `json:"gridPos"`
+	Datasource  grafanaDatasource  `json:"datasource"`
+	Targets     []grafanaTarget    `json:"targets"`
+	FieldConfig grafanaFieldConfig `json:"fieldConfig"`
+}
+
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type grafanaTarget struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	Instant      bool   `json:"instant,omitempty"`
+	Format       string `json:"format,omitempty"`
+}
+
+type grafanaFieldConfig struct {
+	Defaults struct {
+		Unit string `json:"unit,omitempty"`
+	} `json:"defaults"`
+	Overrides []interface{} `json:"overrides"`
+}
+
+const (
+	// dashboardPanelWidth fits two panels in a row of 24 columns
+	dashboardPanelWidth  = 12
+	dashboardPanelHeight = 8
+)
+
+// dashboardSelector matches the series of the instances selected by the
+// variables of the dashboard, and by the extra matchers.
+func dashboardSelector(labels []string, extra ...string) string {
+	matchers := make([]string, 0, len(labels)+len(extra))
+	for _, name := range labels {
+		matchers = append(matchers, fmt.Sprintf("%v=~\"$%v\"", name, name))
+	}
+	matchers = append(matchers, extra...)
+
+	return "{" + strings.Join(matchers, ",") + "}"
+}
+
+// dashboard returns a dashboard of the metrics of the configuration, with a
+// variable per label of max_connections. The panels of the cluster endpoints,
+// the global databases, the DB load and the maintenance are only added when
+// the configuration exports them.
+func dashboard(cfg *Config, opts dashboardOptions) grafanaDashboard {
+	datasource := grafanaDatasource{Type: "prometheus", UID: "${datasource}"}
+	labels := append([]string{"dbinstanceidentifier"}, cfg.LabelNames()...)
+	selector := dashboardSelector(labels)
+	maxConnections := metricName("max_connections") + selector
+
+	d := grafanaDashboard{
+		Title:         opts.title,
+		UID:           opts.uid,
+		SchemaVersion: 39,
+		Refresh:       "1m",
+		Time:          grafanaTimeRange{From: "now-24h", To: "now"},
+	}
+	d.Templating.List = append(d.Templating.List, grafanaVariable{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"})
+	for _, name := range labels {
+		d.Templating.List = append(d.Templating.List, grafanaVariable{
+			Name:       name,
+			Type:       "query",
+			Query:      fmt.Sprintf("label_values(%v, %v)", metricName("max_connections"), name),
+			Datasource: &datasource,
+			// on dashboard load, for the new instances
+			Refresh:    1,
+			IncludeAll: true,
+			Multi:      true,
+			// the all value also matches the instances without the label
+			AllValue: ".*",
+		})
+	}
+
+	add := func(title, typ, unit, description string, targets ...grafanaTarget) {
+		n := len(d.Panels)
+		panel := grafanaPanel{
+			ID:          n + 1,
+			Title:       title,
+			Type:        typ,
+			Description: description,
+			GridPos:     grafanaGridPos{H: dashboardPanelHeight, W: dashboardPanelWidth, X: n % 2 * dashboardPanelWidth, Y: n / 2 * dashboardPanelHeight},
+			Datasource:  datasource,
+			Targets:     targets,
+		}
+		for i := range panel.Targets {
+			panel.Targets[i].RefID = string(rune('A' + i))
+		}
+		panel.FieldConfig.Defaults.Unit = unit
+		panel.FieldConfig.Overrides = []interface{}{}
+		d.Panels = append(d.Panels, panel)
+	}
+
+	connections := fmt.Sprintf("max by (dbinstanceidentifier) (%v)", opts.connections)
+	byInstance := fmt.Sprintf("max by (dbinstanceidentifier) (%v > 0)", maxConnections)
+	add("Max connections", "timeseries", "short", "max_connections of the instances, from their parameter group and instance class",
+		grafanaTarget{Expr: maxConnections, LegendFormat: "{{dbinstanceidentifier}}"})
+	add("Connection utilization", "timeseries", "percent", "Current connections of the instances over their max_connections",
+		grafanaTarget{Expr: fmt.Sprintf("100 * %v / on (dbinstanceidentifier) %v", connections, byInstance), LegendFormat: "{{dbinstanceidentifier}}"})
+	add("Connection headroom", "timeseries", "short", "Connections the instances can still accept",
+		grafanaTarget{Expr: fmt.Sprintf("%v - on (dbinstanceidentifier) %v", byInstance, connections), LegendFormat: "{{dbinstanceidentifier}}"})
+	add("Certificate expiry", "table", "s", "Time left before the server certificates of the instances expire",
+		grafanaTarget{Expr: fmt.Sprintf("%v%v - time()", metricName("certificate_expiry_timestamp_seconds"), selector), Instant: true, Format: "table"})
+
+	if cfg.ExportDBLoad {
+		add("DB load", "timeseries", "short", "Average active sessions of the instances from Performance Insights",
+			grafanaTarget{Expr: metricName("db_load") + selector, LegendFormat: "{{dbinstanceidentifier}}"})
+	}
+	if cfg.ExportMaintenance {
+		add("Pending maintenance actions", "table", "dateTimeAsIso", "Earliest apply date of the pending maintenance actions of the instances, which also apply the pending max_connections changes",
+			grafanaTarget{Expr: fmt.Sprintf("%v%v * 1000", metricName("pending_maintenance_apply_timestamp_seconds"), selector), Instant: true, Format: "table"})
+	}
+	if cfg.ExportClusterEndpoints {
+		add("Cluster endpoints", "table", "", "Writer and reader endpoints of the clusters",
+			grafanaTarget{Expr: metricName("cluster_endpoint_info"), Instant: true, Format: "table"})
+	}
+	if cfg.GlobalClusters.Enabled {
+		add("Max connections by global cluster", "timeseries", "short", "Total max_connections of the regions of the global databases, for failover capacity planning",
+			grafanaTarget{
+				Expr:         fmt.Sprintf("sum by (%v, %v) (%v)", globalClusterLabel, globalRoleLabel, metricName("max_connections")+dashboardSelector(labels, globalClusterLabel+`!=""`)),
+				LegendFormat: fmt.Sprintf("{{%v}} {{%v}}", globalClusterLabel, globalRoleLabel),
+			})
+	}
+
+	add("Exporter targets up", "timeseries", "short", "1 when the last collection of a target succeeded",
+		grafanaTarget{Expr: metricName("target_up"), LegendFormat: "{{target}}"})
+	add("Exporter data age", "timeseries", "s", "Age of the served data, since the last successful snapshot",
+		grafanaTarget{Expr: metricName("snapshot_age_seconds"), LegendFormat: "{{instance}}"})
+
+	return d
+}
+
+// generateDashboard writes the dashboard as the JSON model of Grafana, with
+// the comparisons of the expressions not escaped.
+func generateDashboard(w io.Writer, cfg *Config, opts dashboardOptions) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(dashboard(cfg, opts))
+	if err != nil {
+		return fmt.Errorf("failed to encode dashboard: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateDashboardGolden(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(config, []byte("tag_labels:\n  Team: team\nexport_db_load: true\nexport_maintenance: true\nexport_cluster_endpoints: true\nglobal_clusters:\n  enabled: true\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := newFlags()
+	command, cfg, err := f.parse([]string{"generate", "dashboard", "--config.file", config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command != "generate dashboard" {
+		t.Fatalf("got command %q", command)
+	}
+
+	var b bytes.Buffer
+	err = generateDashboard(&b, cfg, f.dashboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareGolden(t, filepath.Join("testdata", "dashboard.json"), b.Bytes())
+}
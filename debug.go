@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// debugInstance explains how max_connections of an instance was resolved,
+// for the recurring "why does the exporter say 1800?" questions.
+type debugInstance struct {
+	DBInstanceIdentifier string `json:"db_instance_identifier"`
+	DBInstanceClass      string `json:"db_instance_class"`
+	DBEngine             string `json:"engine"`
+	DBParameterGroupName string `json:"db_parameter_group_name"`
+	// AmbiguousParameterGroup tells that the parameter group was selected
+	// among several candidates
+	AmbiguousParameterGroup bool `json:"ambiguous_parameter_group,omitempty"`
+	// DBClusterParameterGroupName is the cluster parameter group the value
+	// is read from when the parameter group does not set it
+	DBClusterParameterGroupName string `json:"db_cluster_parameter_group_name,omitempty"`
+	// ServerlessMaxCapacity is the maximum capacity in ACUs max_connections
+	// of an Aurora Serverless v2 instance is computed with
+	ServerlessMaxCapacity float64 `json:"serverless_max_capacity,omitempty"`
+	// RawMaxConnections is the value of the max_connections parameter
+	RawMaxConnections string `json:"raw_max_connections"`
+	Branch            string `json:"branch,omitempty"`
+	Divisor           int64  `json:"divisor,omitempty"`
+	Limit             int    `json:"limit,omitempty"`
+	MemoryBytes       int64  `json:"memory_bytes,omitempty"`
+	Overridden        bool   `json:"overridden"`
+	MaxConnections    int    `json:"max_connections"`
+	SkipReason        string `json:"skip_reason,omitempty"`
+}
+
+type debugInstancesResponse struct {
+	UpdatedAt *time.Time      `json:"updated_at"`
+	Instances []debugInstance `json:"instances"`
+}
+
+func newDebugInstance(info RDSInfo) debugInstance {
+	d := debugInstance{
+		DBInstanceIdentifier:        info.DBInstanceIdentifier,
+		DBInstanceClass:             info.DBInstanceClass,
+		DBEngine:                    info.DBEngine,
+		DBParameterGroupName:        info.DBParameterGroupName,
+		AmbiguousParameterGroup:     info.AmbiguousParameterGroup,
+		DBClusterParameterGroupName: info.DBClusterParameterGroupName,
+		ServerlessMaxCapacity:       info.ServerlessMaxCapacity,
+		Overridden:                  info.Overridden,
+		MaxConnections:              newAPIInstance(info).MaxConnections,
+		SkipReason:                  info.SkipReason,
+	}
+	if r := info.Resolution; r != nil {
+		d.RawMaxConnections = r.Raw
+		d.Branch = r.Branch
+		d.Divisor = r.Divisor
+		d.Limit = r.Limit
+		d.MemoryBytes = r.Memory
+	}
+
+	return d
+}
+
+// debugInstancesHandler serves GET /debug/instances with, for every instance
+// of the last snapshot, the raw parameter value, the parser branch, the memory
+// and the final number.
+func debugInstancesHandler(store *Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		infos, updatedAt := store.Get()
+
+		resp := debugInstancesResponse{
+			Instances: make([]debugInstance, 0, len(infos)),
+		}
+		if !updatedAt.IsZero() {
+			resp.UpdatedAt = &updatedAt
+		}
+		for _, info := range infos {
+			resp.Instances = append(resp.Instances, newDebugInstance(info))
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
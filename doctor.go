@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// permission is an IAM action a feature needs on a resource.
+type permission struct {
+	action   string
+	resource string
+	feature  string
+}
+
+// newIAMAPI and newSTSAPI return the clients of the doctor, replaced by fakes
+// in the tests.
+//
+//nolint:gochecknoglobals
+var (
+	newIAMAPI = func(sess *session.Session) iamiface.IAMAPI { return iam.New(sess) }
+	newSTSAPI = func(sess *session.Session) stsiface.STSAPI { return sts.New(sess) }
+)
+
+// requiredPermissions lists the permissions the enabled features need by
+// principal, the identity of the environment or the role of a target. The
+// identity of the environment assumes the roles and runs the outputs and the
+// leader election.
+func requiredPermissions(cfg *Config, outputs []Output, identity, region string) map[string][]permission {
+	ret := map[string][]permission{}
+	add := func(principal, action, resource, feature string) {
+		for _, p := range ret[principal] {
+			if p.action == action && p.resource == resource {
+				return
+			}
+		}
+		ret[principal] = append(ret[principal], permission{action: action, resource: resource, feature: feature})
+	}
+
+	for _, target := range cfg.Targets {
+		principal := identity
+		if len(target.RoleARN) != 0 {
+			principal = target.RoleARN
+			add(identity, "sts:AssumeRole", target.RoleARN, "targets.role_arn")
+		}
+		add(principal, "rds:DescribeDBInstances", "*", "discovery")
+		add(principal, "rds:DescribeDBParameters", "*", "discovery")
+		// the fallback of the Aurora instances whose parameter group does
+		// not set max_connections
+		add(principal, "rds:DescribeDBClusters", "*", "discovery")
+		add(principal, "rds:DescribeDBClusterParameters", "*", "discovery")
+		if cfg.ExportClusterEndpoints {
+			add(principal, "rds:DescribeDBClusters", "*", "export_cluster_endpoints")
+		}
+		if cfg.ExportMaintenance {
+			add(principal, "rds:DescribePendingMaintenanceActions", "*", "export_maintenance")
+		}
+		if cfg.GlobalClusters.Enabled {
+			add(principal, "rds:DescribeGlobalClusters", "*", "global_clusters")
+		}
+		if cfg.ExportDBLoad {
+			add(principal, "pi:GetResourceMetrics", "*", "export_db_load")
+		}
+		if cfg.ExportCostPerConnection {
+			add(principal, "pricing:GetProducts", "*", "export_cost_per_connection")
+		}
+		if cfg.Recommendations.Enabled {
+			add(principal, "cloudwatch:GetMetricData", "*", "recommendations")
+		}
+		if cfg.Forecast.Enabled {
+			add(principal, "cloudwatch:GetMetricData", "*", "forecast")
+		}
+		if cfg.Baseline.Enabled {
+			add(principal, "cloudwatch:GetMetricData", "*", "baseline")
+		}
+		if needDatabaseConnections(outputs) {
+			add(principal, "cloudwatch:GetMetricData", "*", "database connections of the outputs")
+		}
+	}
+
+	if len(cfg.LeaderElection.Table) != 0 {
+		tableRegion := cfg.LeaderElection.Region
+		if len(tableRegion) == 0 {
+			tableRegion = region
+		}
+		resource := "*"
+		if a, err := arn.Parse(identity); err == nil {
+			resource = fmt.Sprintf("arn:%v:dynamodb:%v:%v:table/%v", a.Partition, tableRegion, a.AccountID, cfg.LeaderElection.Table)
+		}
+		add(identity, "dynamodb:PutItem", resource, "leader_election")
+	}
+	if len(cfg.Outputs.CloudWatch.Namespace) != 0 {
+		add(identity, "cloudwatch:PutMetricData", "*", "outputs.cloudwatch")
+	}
+	if len(cfg.Outputs.SNS.TopicARN) != 0 {
+		add(identity, "sns:Publish", cfg.Outputs.SNS.TopicARN, "outputs.sns")
+	}
+	if len(cfg.Outputs.RemoteWrite.SigV4Region) != 0 {
+		add(identity, "aps:RemoteWrite", workspaceARN(identity, cfg.Outputs.RemoteWrite), "outputs.remote_write")
+	}
+
+	return ret
+}
+
+// workspaceARN returns the ARN of the Amazon Managed Service for Prometheus
+// workspace of a remote write URL, such as
+// https://aps-workspaces.us-east-1.amazonaws.com/workspaces/ws-1234/api/v1/remote_write,
+// or * when it is not one, in the account of the identity.
+func workspaceARN(identity string, cfg RemoteWriteConfig) string {
+	a, err := arn.Parse(identity)
+	if err != nil {
+		return "*"
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return "*"
+	}
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "workspaces" || len(parts[1]) == 0 {
+		return "*"
+	}
+
+	return fmt.Sprintf("arn:%v:aps:%v:%v:workspace/%v", a.Partition, cfg.SigV4Region, a.AccountID, parts[1])
+}
+
+// principalARN returns the IAM ARN of a caller identity, whose policies can
+// be simulated: the role of an assumed role session. The path of the role is
+// not part of the session ARN, so a role with a path is not found.
+func principalARN(callerARN string) string {
+	a, err := arn.Parse(callerARN)
+	if err != nil || a.Service != "sts" || !strings.HasPrefix(a.Resource, "assumed-role/") {
+		return callerARN
+	}
+	parts := strings.Split(a.Resource, "/")
+
+	return arn.ARN{Partition: a.Partition, Service: "iam", AccountID: a.AccountID, Resource: "role/" + parts[1]}.String()
+}
+
+// doctor simulates the policies of the principals with
+// iam:SimulatePrincipalPolicy for every permission the configuration needs,
+// and prints a minimal policy for each of them. It returns false when a
+// permission is denied. The permissions which could not be simulated, such
+// as of the roles of the other accounts, are unknown: the dry run tries the
+// API calls instead.
+func doctor(ctx context.Context, w io.Writer, cfg *Config, outputs []Output) (bool, error) {
+	sess := newSession(cfg, Target{})
+
+	caller, err := newSTSAPI(sess).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return false, fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	identity := principalARN(aws.StringValue(caller.Arn))
+	permissions := requiredPermissions(cfg, outputs, identity, aws.StringValue(sess.Config.Region))
+
+	principals := make([]string, 0, len(permissions))
+	for principal := range permissions {
+		principals = append(principals, principal)
+	}
+	sort.Strings(principals)
+
+	ok := true
+	var unknown []string
+	svc := newIAMAPI(sess)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESULT\tPRINCIPAL\tACTION\tRESOURCE\tFEATURE")
+	for _, principal := range principals {
+		for _, p := range permissions[principal] {
+			result, err := simulate(ctx, svc, principal, p)
+			if err != nil {
+				result = "unknown"
+				unknown = append(unknown, fmt.Sprintf("unknown: %v %v: %v", principal, p.action, err))
+			}
+			if result == iam.PolicyEvaluationDecisionTypeExplicitDeny || result == iam.PolicyEvaluationDecisionTypeImplicitDeny {
+				ok = false
+			}
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", result, principal, p.action, p.resource, p.feature)
+		}
+	}
+	err = tw.Flush()
+	if err != nil {
+		return false, fmt.Errorf("failed to write table: %w", err)
+	}
+	if len(unknown) != 0 {
+		fmt.Fprintln(w)
+		for _, line := range unknown {
+			fmt.Fprintln(w, line)
+		}
+		fmt.Fprintln(w, "try the API calls of the unknown permissions with --dry-run")
+	}
+
+	for _, principal := range principals {
+		b, err := json.MarshalIndent(minimalPolicy(permissions[principal]), "", "  ")
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal policy: %w", err)
+		}
+		fmt.Fprintf(w, "\nMinimal policy of %v:\n%s\n", principal, b)
+	}
+
+	return ok, nil
+}
+
+func simulate(ctx context.Context, svc iamiface.IAMAPI, principal string, p permission) (string, error) {
+	input := &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principal),
+		ActionNames:     aws.StringSlice([]string{p.action}),
+	}
+	if p.resource != "*" {
+		input.ResourceArns = aws.StringSlice([]string{p.resource})
+	}
+
+	decision := iam.PolicyEvaluationDecisionTypeImplicitDeny
+	err := svc.SimulatePrincipalPolicyPagesWithContext(ctx, input, func(page *iam.SimulatePolicyResponse, lastPage bool) bool {
+		for _, result := range page.EvaluationResults {
+			decision = aws.StringValue(result.EvalDecision)
+		}
+		return true
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to simulate principal policy: %w", err)
+	}
+
+	return decision, nil
+}
+
+type policyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource string   `json:"Resource"`
+}
+
+// minimalPolicy allows the permissions with a statement per resource.
+func minimalPolicy(permissions []permission) policyDocument {
+	actions := map[string][]string{}
+	for _, p := range permissions {
+		actions[p.resource] = append(actions[p.resource], p.action)
+	}
+	resources := make([]string, 0, len(actions))
+	for resource := range actions {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	policy := policyDocument{Version: "2012-10-17"}
+	for _, resource := range resources {
+		sort.Strings(actions[resource])
+		policy.Statement = append(policy.Statement, policyStatement{Effect: "Allow", Action: actions[resource], Resource: resource})
+	}
+
+	return policy
+}
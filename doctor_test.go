@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+type fakeSTS struct {
+	stsiface.STSAPI
+	arn string
+}
+
+func (f *fakeSTS) GetCallerIdentityWithContext(aws.Context, *sts.GetCallerIdentityInput, ...request.Option) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Arn: aws.String(f.arn)}, nil
+}
+
+// fakeIAM allows the actions of its principals, and fails to simulate the
+// other principals.
+type fakeIAM struct {
+	iamiface.IAMAPI
+	allowed map[string][]string
+}
+
+func (f *fakeIAM) SimulatePrincipalPolicyPagesWithContext(_ aws.Context, input *iam.SimulatePrincipalPolicyInput, fn func(*iam.SimulatePolicyResponse, bool) bool, _ ...request.Option) error {
+	actions, ok := f.allowed[aws.StringValue(input.PolicySourceArn)]
+	if !ok {
+		return awserr.New(iam.ErrCodeNoSuchEntityException, "role not found", nil)
+	}
+
+	var results []*iam.EvaluationResult
+	for _, action := range input.ActionNames {
+		decision := iam.PolicyEvaluationDecisionTypeImplicitDeny
+		for _, allowed := range actions {
+			if allowed == *action {
+				decision = iam.PolicyEvaluationDecisionTypeAllowed
+			}
+		}
+		results = append(results, &iam.EvaluationResult{EvalActionName: action, EvalDecision: aws.String(decision)})
+	}
+	fn(&iam.SimulatePolicyResponse{EvaluationResults: results}, true)
+
+	return nil
+}
+
+func TestDoctor(t *testing.T) {
+	const (
+		identity = "arn:aws:iam::123456789012:role/exporter"
+		role     = "arn:aws:iam::210987654321:role/rds-maxcon-exporter"
+	)
+	cfg := testConfig(t, func(cfg *Config) {
+		cfg.Targets = []Target{{Region: "ap-northeast-1"}, {Region: "us-east-1", RoleARN: role}}
+		cfg.LeaderElection = LeaderElectionConfig{Table: "lock", Region: "ap-northeast-1", LockName: "exporter", LeaseDuration: defaultConfig().LeaderElection.LeaseDuration}
+		cfg.SnapshotFile = filepath.Join(t.TempDir(), "snapshot.json")
+		cfg.Outputs.RemoteWrite = RemoteWriteConfig{URL: "https://aps-workspaces.ap-northeast-1.amazonaws.com/workspaces/ws-1234/api/v1/remote_write", SigV4Region: "ap-northeast-1"}
+	})
+
+	newIAM, newSTS := newIAMAPI, newSTSAPI
+	t.Cleanup(func() { newIAMAPI, newSTSAPI = newIAM, newSTS })
+	newSTSAPI = func(*session.Session) stsiface.STSAPI {
+		return &fakeSTS{arn: "arn:aws:sts::123456789012:assumed-role/exporter/i-0123456789abcdef0"}
+	}
+	newIAMAPI = func(*session.Session) iamiface.IAMAPI {
+		return &fakeIAM{allowed: map[string][]string{identity: {"rds:DescribeDBInstances", "rds:DescribeDBParameters", "rds:DescribeDBClusters", "rds:DescribeDBClusterParameters", "sts:AssumeRole"}}}
+	}
+
+	var b bytes.Buffer
+	ok, err := doctor(context.Background(), &b, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Errorf("got ok, want the missing dynamodb:PutItem reported:\n%s", &b)
+	}
+
+	// the columns are compared regardless of their widths
+	got := strings.Join(strings.Fields(b.String()), " ")
+	for _, want := range []string{
+		"allowed " + identity + " rds:DescribeDBInstances * discovery",
+		"allowed " + identity + " sts:AssumeRole " + role + " targets.role_arn",
+		"implicitDeny " + identity + " dynamodb:PutItem arn:aws:dynamodb:ap-northeast-1:123456789012:table/lock leader_election",
+		"implicitDeny " + identity + " aps:RemoteWrite arn:aws:aps:ap-northeast-1:123456789012:workspace/ws-1234 outputs.remote_write",
+		"unknown " + role + " rds:DescribeDBInstances * discovery",
+		"unknown: " + role + " rds:DescribeDBInstances: failed to simulate principal policy: NoSuchEntity: role not found",
+		"Minimal policy of " + role + `: { "Version": "2012-10-17", "Statement": [ { "Effect": "Allow", "Action": [ "rds:DescribeDBClusterParameters", "rds:DescribeDBClusters", "rds:DescribeDBInstances", "rds:DescribeDBParameters" ], "Resource": "*" } ] }`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, &b)
+		}
+	}
+}
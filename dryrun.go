@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// dryRun performs a single discovery pass and prints which instances would
+// be exported or skipped and why, and the missing IAM permissions. It
+// returns false when discovery did not fully succeed.
+func dryRun(ctx context.Context, w io.Writer, cfg *Config, outputs []Output) bool {
+	ok := true
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESULT\tTARGET\tDBINSTANCEIDENTIFIER\tENGINE\tDBINSTANCECLASS\tMAX_CONNECTIONS/REASON")
+
+	var problems []string
+	for _, target := range cfg.Targets {
+		name := targetName(target)
+		sess := newSession(cfg, target)
+
+		infos, err := getRDSInstances(ctx, newRDSAPI(sess), cfg, target)
+		if err != nil {
+			ok = false
+			problems = append(problems, dryRunProblem(name, err, "rds:DescribeDBInstances"))
+			continue
+		}
+
+		if needDatabaseConnections(outputs) {
+			err = setDatabaseConnections(ctx, sess, infos)
+			if err != nil {
+				ok = false
+				problems = append(problems, dryRunProblem(name, err, "cloudwatch:GetMetricData"))
+			}
+		}
+
+		reported := map[string]bool{}
+		for _, info := range infos {
+			if info.Err != nil && !reported[info.DBParameterGroupName] {
+				reported[info.DBParameterGroupName] = true
+				ok = false
+				problems = append(problems, dryRunProblem(name, info.Err, "rds:DescribeDBParameters"))
+			}
+			if len(info.SkipReason) != 0 {
+				fmt.Fprintf(tw, "skip\t%v\t%v\t%v\t%v\t%v\n", name, info.DBInstanceIdentifier, info.DBEngine, info.DBInstanceClass, info.SkipReason)
+				continue
+			}
+			fmt.Fprintf(tw, "export\t%v\t%v\t%v\t%v\t%v\n", name, info.DBInstanceIdentifier, info.DBEngine, info.DBInstanceClass, info.MaxConnections)
+		}
+	}
+	tw.Flush()
+
+	if len(problems) != 0 {
+		fmt.Fprintln(w)
+		for _, problem := range problems {
+			fmt.Fprintln(w, problem)
+		}
+	}
+
+	return ok
+}
+
+func dryRunProblem(target string, err error, action string) string {
+	if isAccessDenied(err) {
+		return fmt.Sprintf("missing permission: %v (target: %v): %v", deniedAction(err, action), target, err)
+	}
+
+	return fmt.Sprintf("error (target: %v): %v", target, err)
+}
+
+// targetName describes a target for humans.
+func targetName(target Target) string {
+	name := target.Region
+	if len(name) == 0 {
+		name = "default"
+	}
+	if len(target.RoleARN) != 0 {
+		name += "/" + target.RoleARN
+	}
+
+	return name
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	exportFormatJSON = "json"
+	exportFormatCSV  = "csv"
+)
+
+// inventoryInstance is an instance of the inventory of the export command:
+// max_connections as configured in its parameter group, as computed by the
+// exporter and as the default of its engine, so that a drift detection can
+// compare them with the parameter groups of its Terraform code.
+type inventoryInstance struct {
+	Region                      string `json:"region"`
+	DBInstanceIdentifier        string `json:"db_instance_identifier"`
+	DBInstanceARN               string `json:"db_instance_arn"`
+	DBEngine                    string `json:"engine"`
+	DBInstanceClass             string `json:"db_instance_class"`
+	DBClusterIdentifier         string `json:"db_cluster_identifier"`
+	DBParameterGroupName        string `json:"db_parameter_group_name"`
+	DBClusterParameterGroupName string `json:"db_cluster_parameter_group_name"`
+	// ConfiguredMaxConnections is the raw max_connections parameter, such as
+	// a formula, empty when it is not set
+	ConfiguredMaxConnections string `json:"configured_max_connections"`
+	// Source is how MaxConnections was resolved: the parser branch, or
+	// "override" with max_connections_overrides
+	Source string `json:"source"`
+	// MaxConnections and DefaultMaxConnections are nil when not known, as of
+	// a skipped instance
+	MaxConnections        *int   `json:"max_connections"`
+	DefaultMaxConnections *int   `json:"default_max_connections"`
+	Custom                bool   `json:"custom"`
+	SkipReason            string `json:"skip_reason"`
+}
+
+func newInventoryInstance(info RDSInfo) inventoryInstance {
+	i := inventoryInstance{
+		Region:                      info.Region,
+		DBInstanceIdentifier:        info.DBInstanceIdentifier,
+		DBInstanceARN:               info.DBInstanceARN,
+		DBEngine:                    info.DBEngine,
+		DBInstanceClass:             info.DBInstanceClass,
+		DBClusterIdentifier:         info.DBClusterIdentifier,
+		DBParameterGroupName:        info.DBParameterGroupName,
+		DBClusterParameterGroupName: info.DBClusterParameterGroupName,
+		SkipReason:                  info.SkipReason,
+	}
+	if r := info.Resolution; r != nil {
+		i.ConfiguredMaxConnections = r.Raw
+		i.Source = r.Branch
+	}
+	if info.Overridden {
+		i.Source = "override"
+	}
+	if len(info.SkipReason) != 0 {
+		return i
+	}
+
+	if v, err := strconv.Atoi(info.MaxConnections); err == nil {
+		i.MaxConnections = &v
+	}
+	if v, ok := defaultMaxConnections(info); ok {
+		i.DefaultMaxConnections = &v
+		i.Custom, _ = isCustomMaxConnections(info)
+	}
+
+	return i
+}
+
+// export prints the inventory of the instances of a single discovery pass,
+// including the skipped ones, in JSON or CSV.
+func export(ctx context.Context, w io.Writer, cfg *Config, format string) error {
+	infos, err := collect(ctx, cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	return writeInventory(w, infos, format)
+}
+
+func writeInventory(w io.Writer, infos []RDSInfo, format string) error {
+	instances := make([]inventoryInstance, 0, len(infos))
+	for _, info := range infos {
+		instances = append(instances, newInventoryInstance(info))
+	}
+
+	switch format {
+	case exportFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(instances)
+		if err != nil {
+			return fmt.Errorf("failed to write JSON: %w", err)
+		}
+	case exportFormatCSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{
+			"region", "db_instance_identifier", "db_instance_arn", "engine", "db_instance_class", "db_cluster_identifier",
+			"db_parameter_group_name", "db_cluster_parameter_group_name", "configured_max_connections", "source",
+			"max_connections", "default_max_connections", "custom", "skip_reason",
+		})
+		for _, i := range instances {
+			_ = cw.Write([]string{
+				i.Region, i.DBInstanceIdentifier, i.DBInstanceARN, i.DBEngine, i.DBInstanceClass, i.DBClusterIdentifier,
+				i.DBParameterGroupName, i.DBClusterParameterGroupName, i.ConfiguredMaxConnections, i.Source,
+				formatOptionalInt(i.MaxConnections), formatOptionalInt(i.DefaultMaxConnections), strconv.FormatBool(i.Custom), i.SkipReason,
+			})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown format: %v", format)
+	}
+
+	return nil
+}
+
+// formatOptionalInt formats v, empty when it is nil.
+func formatOptionalInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+
+	return strconv.Itoa(*v)
+}
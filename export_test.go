@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
+)
+
+func TestWriteInventory(t *testing.T) {
+	infos := []RDSInfo{
+		{
+			Region: "ap-northeast-1", DBInstanceIdentifier: "a01", DBInstanceARN: "arn:aws:rds:ap-northeast-1:123456789012:db:a01",
+			DBEngine: "postgres", DBInstanceClass: "db.r5.large", DBParameterGroupName: "custom", MaxConnections: "1000",
+			Resolution: &maxcon.Resolution{Raw: "1000", Branch: maxcon.BranchExplicitValue, Value: 1000},
+		},
+		{
+			Region: "ap-northeast-1", DBInstanceIdentifier: "b01", DBEngine: "postgres", DBInstanceClass: "db.r5.large",
+			DBParameterGroupName: "missing", SkipReason: "parameter group error",
+		},
+	}
+
+	var b bytes.Buffer
+	err := writeInventory(&b, infos, exportFormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `region,db_instance_identifier,db_instance_arn,engine,db_instance_class,db_cluster_identifier,db_parameter_group_name,db_cluster_parameter_group_name,configured_max_connections,source,max_connections,default_max_connections,custom,skip_reason
+ap-northeast-1,a01,arn:aws:rds:ap-northeast-1:123456789012:db:a01,postgres,db.r5.large,,custom,,1000,explicit value,1000,1800,true,
+ap-northeast-1,b01,,postgres,db.r5.large,,missing,,,,,,false,parameter group error
+`
+	if b.String() != want {
+		t.Errorf("got CSV:\n%s\nwant:\n%s", &b, want)
+	}
+
+	b.Reset()
+	err = writeInventory(&b, infos, exportFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []inventoryInstance
+	err = json.Unmarshal(b.Bytes(), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].MaxConnections == nil || *got[0].MaxConnections != 1000 || got[1].MaxConnections != nil {
+		t.Errorf("got JSON %s, want the max_connections of a01 only", &b)
+	}
+}
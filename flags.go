@@ -0,0 +1,441 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+)
+
+// flags overrides the configuration file with the command-line flags and
+// their environment variables, in that order of precedence.
+type flags struct {
+	app         *kingpin.Application
+	configFile  string
+	watchConfig bool
+	dryRun      bool
+	once        bool
+	overrides   []func(*Config) error
+
+	// configSSMParameter is the Parameter Store parameter to read the
+	// configuration from instead of configFile
+	configSSMParameter string
+	// configAppConfig is the application/environment/profile of the AppConfig
+	// configuration to read the configuration from instead of configFile
+	configAppConfig string
+	// source is the source of the configuration, nil when there is none
+	source configSource
+	// configRefreshInterval is how often the source is read again to reload
+	// it when it changed, 0 to disable
+	configRefreshInterval time.Duration
+
+	checkIdentifier string
+	exportFormat    string
+	reportFormat    string
+	validatePath    string
+	alerts          alertOptions
+	dashboard       dashboardOptions
+
+	targetsOutputDir string
+
+	// legacyEnvars maps the environment variables to their deprecated names
+	legacyEnvars map[string]string
+	// deprecated are the deprecated environment variables in use
+	deprecated []deprecatedEnvar
+}
+
+type deprecatedEnvar struct {
+	legacy string
+	envar  string
+}
+
+// envPrefix is the prefix of the environment variable of every flag, whose
+// name is the flag name in upper case with "." and "-" replaced by "_".
+const envPrefix = "RDS_MAXCON_"
+
+func newFlags() *flags {
+	f := &flags{
+		app:          kingpin.New("aws-rds-maxcon-prometheus-exporter", "Prometheus Exporter for AWS RDS Max Connections."),
+		legacyEnvars: map[string]string{},
+	}
+	f.app.HelpFlag.Short('h')
+	f.app.Version(versionString())
+
+	f.app.Flag("config.file", "Path to the YAML configuration file, or s3://bucket/key of an S3 object.").
+		Envar(f.envar("config.file", "CONFIG_FILE")).StringVar(&f.configFile)
+	f.app.Flag("config.ssm-parameter", "Name or ARN of the SSM Parameter Store parameter to read the YAML configuration from instead of a file.").
+		Envar(f.envar("config.ssm-parameter", "")).StringVar(&f.configSSMParameter)
+	f.app.Flag("config.appconfig", "application/environment/profile of the AWS AppConfig freeform configuration to read the YAML configuration from instead of a file.").
+		Envar(f.envar("config.appconfig", "")).StringVar(&f.configAppConfig)
+	f.app.Flag("config.refresh-interval", "Read the configuration again every interval and reload it when it changed, such as an S3 object, 0 to disable; 1m for AppConfig by default.").
+		Envar(f.envar("config.refresh-interval", "")).DurationVar(&f.configRefreshInterval)
+	f.app.Flag("config.watch", "Reload the configuration file whenever it changes.").
+		Envar(f.envar("config.watch", "")).BoolVar(&f.watchConfig)
+	f.app.Flag("dry-run", "Perform a single discovery pass, print the instances that would be exported or skipped and the missing permissions, and exit.").
+		BoolVar(&f.dryRun)
+	f.app.Flag("once", "Take a single snapshot, print the metrics in the Prometheus text format to stdout, and exit.").
+		BoolVar(&f.once)
+
+	f.app.Command("run", "Run the exporter.").Default()
+	f.app.Command("lambda", "Serve the invocations of the Lambda runtime API, each taking a snapshot written to the outputs. The default command in Lambda.")
+	f.app.Command("list", "Print the discovered instances as a table and exit.")
+	f.app.Command("export", "Print the inventory of the discovered instances with their configured, computed and default max_connections, and exit.").
+		Flag("format", "Format of the inventory: json or csv.").Default(exportFormatJSON).EnumVar(&f.exportFormat, exportFormatJSON, exportFormatCSV)
+	f.app.Command("report", "Print the instances over or under the utilization thresholds of the recommendations at their peak connections, the skipped instances and the pending changes, for a capacity review, and exit.").
+		Flag("format", "Format of the report: markdown or csv.").Default(reportFormatMarkdown).EnumVar(&f.reportFormat, reportFormatMarkdown, reportFormatCSV)
+	f.app.Command("check", "Show how max_connections of an instance is computed step by step.").
+		Arg("instance", "DB instance identifier.").Required().StringVar(&f.checkIdentifier)
+	f.app.Command("doctor", "Check that the identity has every IAM permission the configured features need, and print a minimal policy.")
+	generate := f.app.Command("generate", "Generate the configurations of other tools for the metrics of this exporter.")
+	alerts := generate.Command("alerts", "Print the alert rules on the connection utilization and headroom of the instances and on the exporter.")
+	alerts.Flag("format", "Format of the rules: rules for a rule file of Prometheus, or prometheus-rule for a PrometheusRule resource.").
+		Default(alertFormatRules).EnumVar(&f.alerts.format, alertFormatRules, alertFormatPrometheusRule)
+	alerts.Flag("name", "Name of the rule group and of the PrometheusRule.").Default("aws-rds-maxcon").StringVar(&f.alerts.name)
+	alerts.Flag("connections", "PromQL expression of the current connections of the instances, with a dbinstanceidentifier label.").
+		Default(defaultConnections).StringVar(&f.alerts.connections)
+	alerts.Flag("utilization-warning", "Connection utilization percentage of the warning alert.").Default("80").Float64Var(&f.alerts.utilizationWarning)
+	alerts.Flag("utilization-critical", "Connection utilization percentage of the critical alert.").Default("95").Float64Var(&f.alerts.utilizationCritical)
+	alerts.Flag("headroom", "Number of free connections under which to alert.").Default("20").IntVar(&f.alerts.headroom)
+	alerts.Flag("for", "How long a condition must hold before alerting.").Default("10m").DurationVar(&f.alerts.forDuration)
+	alerts.Flag("certificate-expiry", "How long before the expiry of the server certificates to alert.").Default("720h").DurationVar(&f.alerts.certificateExpiry)
+	dashboard := generate.Command("dashboard", "Print a Grafana dashboard of the metrics of the configuration.")
+	dashboard.Flag("title", "Title of the dashboard.").Default("AWS RDS max connections").StringVar(&f.dashboard.title)
+	dashboard.Flag("uid", "UID of the dashboard, which keeps its URL across imports.").Default("aws-rds-maxcon").StringVar(&f.dashboard.uid)
+	dashboard.Flag("connections", "PromQL expression of the current connections of the instances, with a dbinstanceidentifier label.").
+		Default(defaultConnections).StringVar(&f.dashboard.connections)
+	targets := generate.Command("targets", "Print the endpoints of the discovered instances as target groups of the file service discovery of Prometheus.")
+	targets.Flag("output-dir", "Directory to write the target groups of each engine family to, as <family>.json, instead of printing them all.").
+		StringVar(&f.targetsOutputDir)
+	f.app.Command("validate", "Validate a configuration file, by default the one of --config.file, and exit.").
+		Arg("file", "Path to the YAML configuration file.").StringVar(&f.validatePath)
+
+	f.duration("scrape.interval", "How often to collect from AWS, as a duration or seconds.", "AWS_API_INTERVAL",
+		func(c *Config) *time.Duration { return &c.Interval })
+	f.duration("scrape.timeout", "Timeout of a snapshot, the interval by default.", "SNAPSHOT_TIMEOUT",
+		func(c *Config) *time.Duration { return &c.SnapshotTimeout })
+	f.string("scrape.schedule", "Cron expression to collect at fixed times instead of every interval, such as \"*/10 * * * *\".", "",
+		func(c *Config) *string { return &c.Schedule })
+	f.string("scrape.timezone", "Time zone of the schedule, such as Asia/Tokyo, the local time zone by default.", "",
+		func(c *Config) *string { return &c.Timezone })
+	f.duration("scrape.jitter", "Maximum random delay added to each tick.", "JITTER",
+		func(c *Config) *time.Duration { return &c.Jitter })
+	f.duration("scrape.parameter-cache-ttl", "How long the parameters of a parameter group are cached, 0 to fetch them on every snapshot.", "",
+		func(c *Config) *time.Duration { return &c.ParameterCacheTTL })
+	f.string("redis.address", "host:port of the Redis, such as ElastiCache, sharing the parameter cache of the replicas.", "",
+		func(c *Config) *string { return &c.Redis.Address })
+	f.string("redis.password", "Password of the Redis.", "",
+		func(c *Config) *string { return &c.Redis.Password })
+	f.bool("redis.tls", "Connect to the Redis with TLS, as to ElastiCache with encryption in transit.", "",
+		func(c *Config) *bool { return &c.Redis.TLS })
+	f.duration("stale-after", "Age from which the served data is stale, twice the longest interval by default.", "",
+		func(c *Config) *time.Duration { return &c.StaleAfter })
+	f.int("series-ttl", "Number of snapshots an instance is not seen in before its series expire, 0 to expire them as soon as it is not seen.", "",
+		func(c *Config) *int { return &c.SeriesTTL })
+	f.string("snapshot.file", "File to save the last snapshot to, served on restart while the first snapshot runs.", "",
+		func(c *Config) *string { return &c.SnapshotFile })
+	f.string("audit-log.file", "File to append a JSON line to for each AWS API call, disabled when empty.", "",
+		func(c *Config) *string { return &c.AuditLogFile })
+	f.strings("target-files", "Comma separated glob patterns of YAML files listing more targets, such as a mounted ConfigMap.", "",
+		func(c *Config) *[]string { return &c.TargetFiles })
+	f.int("scrape.concurrency", "Maximum number of AWS requests in flight per target.", "CONCURRENCY",
+		func(c *Config) *int { return &c.Concurrency })
+	f.bool("export.unsupported-engines", "Export the instances of unsupported engines with the value 0 and supported=\"false\" instead of skipping them.", "EXPORT_UNSUPPORTED_ENGINES",
+		func(c *Config) *bool { return &c.ExportUnsupportedEngines })
+	f.bool("export.cluster-endpoints", "Export the writer and reader endpoints and the port of the clusters of the instances.", "",
+		func(c *Config) *bool { return &c.ExportClusterEndpoints })
+	f.bool("recommendations.enabled", "Serve the instances whose class could be downsized or must be upsized for their peak connections.", "",
+		func(c *Config) *bool { return &c.Recommendations.Enabled })
+	f.duration("recommendations.lookback", "How far back the peak connections of the recommendations are read.", "",
+		func(c *Config) *time.Duration { return &c.Recommendations.Lookback })
+	f.bool("forecast.enabled", "Export the estimated time until the connections of the instances reach max_connections at their recent trend.", "",
+		func(c *Config) *bool { return &c.Forecast.Enabled })
+	f.duration("forecast.window", "How far back the trend of the connections is fitted.", "",
+		func(c *Config) *time.Duration { return &c.Forecast.Window })
+	f.bool("baseline.enabled", "Export how many standard deviations the connections of the instances are above their baseline.", "",
+		func(c *Config) *bool { return &c.Baseline.Enabled })
+	f.duration("baseline.window", "How far back the baseline of the connections is read.", "",
+		func(c *Config) *time.Duration { return &c.Baseline.Window })
+	f.bool("export.db-load", "Export the average active sessions of the instances from Performance Insights.", "",
+		func(c *Config) *bool { return &c.ExportDBLoad })
+	f.bool("export.cost-per-connection", "Export the on-demand hourly price of the instances per connection from the Price List API.", "",
+		func(c *Config) *bool { return &c.ExportCostPerConnection })
+	f.bool("export.maintenance", "Export the maintenance windows and the pending maintenance actions of the instances.", "",
+		func(c *Config) *bool { return &c.ExportMaintenance })
+	f.bool("global-clusters.enabled", "Label the instances of the Aurora global databases with their global cluster and role.", "",
+		func(c *Config) *bool { return &c.GlobalClusters.Enabled })
+	f.bool("global-clusters.collect-secondary-regions", "Collect the instances of the global databases in the regions which are not targets.", "",
+		func(c *Config) *bool { return &c.GlobalClusters.CollectSecondaryRegions })
+	f.int("shard", "Shard of the instances to export, from 0, with --total-shards.", "",
+		func(c *Config) *int { return &c.Sharding.Shard })
+	f.int("total-shards", "Number of replicas to split the instances across by hash of their identifier.", "",
+		func(c *Config) *int { return &c.Sharding.TotalShards })
+	f.int("max-instances", "Maximum number of instances to export, 0 for no limit.", "",
+		func(c *Config) *int { return &c.MaxInstances })
+	f.string("label-values.hash-key", "Key of the hashes of the redacted label values.", "",
+		func(c *Config) *string { return &c.LabelValues.HashKey })
+	f.string("stopped-instances", "What to do with the stopped instances: export, skip, or label to add the status label to every series.", "STOPPED_INSTANCES",
+		func(c *Config) *string { return &c.StoppedInstances })
+	f.string("log.level", "Only log messages with the given severity or above: debug, info, warn or error.", "LOG_LEVEL",
+		func(c *Config) *string { return &c.Log.Level })
+	f.string("log.format", "Output format of log messages: logfmt or json.", "LOG_FORMAT",
+		func(c *Config) *string { return &c.Log.Format })
+	f.duration("log.skip-interval", "How often a skipped instance is logged again with the same reason, 0 to log it on every snapshot.", "",
+		func(c *Config) *time.Duration { return &c.Log.SkipInterval })
+	f.string("web.listen-address", "Address to listen on for the metrics and the API.", "WEB_LISTEN_ADDRESS",
+		func(c *Config) *string { return &c.Web.ListenAddress })
+	f.string("web.telemetry-path", "Path under which to expose metrics.", "WEB_TELEMETRY_PATH",
+		func(c *Config) *string { return &c.Web.TelemetryPath })
+	f.bool("web.open-metrics", "Serve the OpenMetrics format, with the units and the _created series, to the scrapers asking for it.", "",
+		func(c *Config) *bool { return &c.Web.OpenMetrics })
+	f.string("web.tls-cert-file", "Certificate to serve HTTPS with.", "",
+		func(c *Config) *string { return &c.Web.TLS.CertFile })
+	f.string("web.tls-key-file", "Private key of the certificate to serve HTTPS with.", "",
+		func(c *Config) *string { return &c.Web.TLS.KeyFile })
+	f.string("web.tls-client-ca-file", "CA of the client certificates to require, except on the health endpoints.", "",
+		func(c *Config) *string { return &c.Web.TLS.ClientCAFile })
+	f.strings("web.tls-client-spiffe-ids", "Comma separated SPIFFE IDs of the allowed client certificates.", "",
+		func(c *Config) *[]string { return &c.Web.TLS.ClientSPIFFEIDs })
+	f.string("web.sigv4-region", "Region of the SigV4 signatures to require.", "",
+		func(c *Config) *string { return &c.Web.SigV4.Region })
+	f.string("web.sigv4-service", "Service of the SigV4 signatures to require.", "",
+		func(c *Config) *string { return &c.Web.SigV4.Service })
+	f.string("web.sigv4-access-key-id", "Access key of the SigV4 signatures to require, except on the health endpoints.", "",
+		func(c *Config) *string { return &c.Web.SigV4.AccessKeyID })
+	f.string("web.sigv4-secret-access-key", "Secret key of the access key of the SigV4 signatures.", "",
+		func(c *Config) *string { return &c.Web.SigV4.SecretAccessKey })
+	f.string("grpc.listen-address", "Address to serve the gRPC API on, disabled when empty.", "GRPC_LISTEN_ADDRESS",
+		func(c *Config) *string { return &c.GRPC.ListenAddress })
+
+	f.string("dogstatsd.address", "DogStatsD agent to send the values to.", "DOGSTATSD_ADDRESS",
+		func(c *Config) *string { return &c.Outputs.DogStatsD.Address })
+	f.string("statsd.address", "StatsD server to send the values to.", "STATSD_ADDRESS",
+		func(c *Config) *string { return &c.Outputs.StatsD.Address })
+	f.string("statsd.prefix", "Prefix of the StatsD metric names.", "STATSD_PREFIX",
+		func(c *Config) *string { return &c.Outputs.StatsD.Prefix })
+	f.string("influxdb.url", "InfluxDB write URL to post the line protocol to.", "INFLUXDB_URL",
+		func(c *Config) *string { return &c.Outputs.InfluxDB.URL })
+	f.string("influxdb.token", "InfluxDB token.", "INFLUXDB_TOKEN",
+		func(c *Config) *string { return &c.Outputs.InfluxDB.Token })
+	f.string("webhook.url", "URL to post the changed instances to.", "WEBHOOK_URL",
+		func(c *Config) *string { return &c.Outputs.Webhook.URL })
+	f.string("webhook.secret", "Secret to sign the webhook body with.", "WEBHOOK_SECRET",
+		func(c *Config) *string { return &c.Outputs.Webhook.Secret })
+	f.string("sns.topic-arn", "SNS topic to notify of utilization threshold breaches.", "SNS_TOPIC_ARN",
+		func(c *Config) *string { return &c.Outputs.SNS.TopicARN })
+	f.float("sns.utilization-threshold", "Connection utilization percentage to notify at.", "SNS_UTILIZATION_THRESHOLD",
+		func(c *Config) *float64 { return &c.Outputs.SNS.UtilizationThreshold })
+	f.strings("kafka.brokers", "Comma separated Kafka brokers to produce to.", "KAFKA_BROKERS",
+		func(c *Config) *[]string { return &c.Outputs.Kafka.Brokers })
+	f.string("kafka.topic", "Kafka topic to produce to.", "KAFKA_TOPIC",
+		func(c *Config) *string { return &c.Outputs.Kafka.Topic })
+	f.string("kafka.mode", "What to produce to Kafka: snapshot or diff.", "KAFKA_MODE",
+		func(c *Config) *string { return &c.Outputs.Kafka.Mode })
+	f.string("cloudwatch.namespace", "CloudWatch namespace to publish the values to.", "CLOUDWATCH_NAMESPACE",
+		func(c *Config) *string { return &c.Outputs.CloudWatch.Namespace })
+	f.string("remote-write.url", "Remote write URL to push the metrics to, such as of an Amazon Managed Service for Prometheus workspace.", "",
+		func(c *Config) *string { return &c.Outputs.RemoteWrite.URL })
+	f.string("remote-write.sigv4-region", "Region to sign the remote write requests for with SigV4.", "",
+		func(c *Config) *string { return &c.Outputs.RemoteWrite.SigV4Region })
+
+	return f
+}
+
+// envar returns the environment variable of a flag, and registers its
+// deprecated name which keeps working with a warning.
+func (f *flags) envar(name, legacy string) string {
+	envar := envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+	if len(legacy) != 0 {
+		f.legacyEnvars[envar] = legacy
+	}
+
+	return envar
+}
+
+// applyLegacyEnvars copies the deprecated environment variables to the new
+// ones unless these are set.
+func (f *flags) applyLegacyEnvars() error {
+	envars := make([]string, 0, len(f.legacyEnvars))
+	for envar := range f.legacyEnvars {
+		envars = append(envars, envar)
+	}
+	sort.Strings(envars)
+
+	for _, envar := range envars {
+		legacy := f.legacyEnvars[envar]
+		v := os.Getenv(legacy)
+		if len(v) == 0 {
+			continue
+		}
+		f.deprecated = append(f.deprecated, deprecatedEnvar{legacy: legacy, envar: envar})
+		if len(os.Getenv(envar)) != 0 {
+			continue
+		}
+		err := os.Setenv(envar, v)
+		if err != nil {
+			return fmt.Errorf("failed to set %v: %w", envar, err)
+		}
+	}
+
+	return nil
+}
+
+// warnDeprecated logs the deprecated environment variables in use.
+func (f *flags) warnDeprecated() {
+	for _, d := range f.deprecated {
+		slog.Warn("environment variable is deprecated", "name", d.legacy, "use", d.envar)
+	}
+}
+
+// isSet reports whether a flag has been given on the command line or with its
+// environment variable.
+func isSet(setByUser bool, envar string) bool {
+	return setByUser || len(os.Getenv(envar)) != 0
+}
+
+func (f *flags) string(name, help, legacy string, field func(*Config) *string) {
+	envar := f.envar(name, legacy)
+	var v string
+	var setByUser bool
+	f.app.Flag(name, help).Envar(envar).IsSetByUser(&setByUser).StringVar(&v)
+
+	f.overrides = append(f.overrides, func(c *Config) error {
+		if isSet(setByUser, envar) {
+			*field(c) = v
+		}
+		return nil
+	})
+}
+
+func (f *flags) bool(name, help, legacy string, field func(*Config) *bool) {
+	envar := f.envar(name, legacy)
+	var v bool
+	var setByUser bool
+	f.app.Flag(name, help).Envar(envar).IsSetByUser(&setByUser).BoolVar(&v)
+
+	f.overrides = append(f.overrides, func(c *Config) error {
+		if isSet(setByUser, envar) {
+			*field(c) = v
+		}
+		return nil
+	})
+}
+
+func (f *flags) strings(name, help, legacy string, field func(*Config) *[]string) {
+	envar := f.envar(name, legacy)
+	var v string
+	var setByUser bool
+	f.app.Flag(name, help).Envar(envar).IsSetByUser(&setByUser).StringVar(&v)
+
+	f.overrides = append(f.overrides, func(c *Config) error {
+		if isSet(setByUser, envar) {
+			*field(c) = strings.Split(v, ",")
+		}
+		return nil
+	})
+}
+
+func (f *flags) int(name, help, legacy string, field func(*Config) *int) {
+	envar := f.envar(name, legacy)
+	var v int
+	var setByUser bool
+	f.app.Flag(name, help).Envar(envar).IsSetByUser(&setByUser).IntVar(&v)
+
+	f.overrides = append(f.overrides, func(c *Config) error {
+		if isSet(setByUser, envar) {
+			*field(c) = v
+		}
+		return nil
+	})
+}
+
+func (f *flags) float(name, help, legacy string, field func(*Config) *float64) {
+	envar := f.envar(name, legacy)
+	var v float64
+	var setByUser bool
+	f.app.Flag(name, help).Envar(envar).IsSetByUser(&setByUser).Float64Var(&v)
+
+	f.overrides = append(f.overrides, func(c *Config) error {
+		if isSet(setByUser, envar) {
+			*field(c) = v
+		}
+		return nil
+	})
+}
+
+func (f *flags) duration(name, help, legacy string, field func(*Config) *time.Duration) {
+	envar := f.envar(name, legacy)
+	var v secondsOrDuration
+	var setByUser bool
+	f.app.Flag(name, help).Envar(envar).IsSetByUser(&setByUser).SetValue(&v)
+
+	f.overrides = append(f.overrides, func(c *Config) error {
+		if isSet(setByUser, envar) {
+			*field(c) = time.Duration(v)
+		}
+		return nil
+	})
+}
+
+// parse parses the command line and loads the configuration. The
+// configuration is not loaded for the validate command, which reports the
+// problems itself.
+func (f *flags) parse(args []string) (string, *Config, error) {
+	err := f.applyLegacyEnvars()
+	if err != nil {
+		return "", nil, err
+	}
+
+	command, err := f.app.Parse(args)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	if command == "validate" {
+		if len(f.validatePath) == 0 {
+			f.validatePath = f.configFile
+		}
+		if len(f.validatePath) == 0 {
+			return "", nil, fmt.Errorf("no configuration file to validate: give a file or --config.file")
+		}
+		return command, nil, nil
+	}
+
+	f.source, err = f.configSource()
+	if err != nil {
+		return "", nil, err
+	}
+	cfg, err := loadConfig(f.source, f.overrides)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return command, cfg, nil
+}
+
+// secondsOrDuration accepts a Go duration such as "5m", or a number of
+// seconds for compatibility with AWS_API_INTERVAL.
+type secondsOrDuration time.Duration
+
+func (d *secondsOrDuration) Set(s string) error {
+	if seconds, err := strconv.Atoi(s); err == nil {
+		*d = secondsOrDuration(time.Duration(seconds) * time.Second)
+		return nil
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	*d = secondsOrDuration(v)
+
+	return nil
+}
+
+func (d *secondsOrDuration) String() string {
+	return time.Duration(*d).String()
+}
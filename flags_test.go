@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// setLegacyEnvars sets the environment variables of the flags to be restored
+// after the test, since applyLegacyEnvars sets them.
+func setLegacyEnvars(t *testing.T, f *flags) {
+	t.Helper()
+
+	for envar, legacy := range f.legacyEnvars {
+		t.Setenv(envar, "")
+		t.Setenv(legacy, "")
+	}
+}
+
+func TestApplyLegacyEnvars(t *testing.T) {
+	f := newFlags()
+	setLegacyEnvars(t, f)
+	envars := map[string]bool{}
+	for _, flag := range f.app.Model().Flags {
+		envars[flag.Envar] = true
+	}
+
+	for envar, legacy := range f.legacyEnvars {
+		if !envars[envar] {
+			t.Errorf("%v: got %v, want the environment variable of a flag", legacy, envar)
+		}
+		t.Setenv(legacy, "legacy-"+legacy)
+	}
+	err := f.applyLegacyEnvars()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for envar, legacy := range f.legacyEnvars {
+		if got := os.Getenv(envar); got != "legacy-"+legacy {
+			t.Errorf("got %v=%q, want the value of %v", envar, got, legacy)
+		}
+	}
+	if len(f.deprecated) != len(f.legacyEnvars) {
+		t.Errorf("got deprecated %v, want every legacy variable", f.deprecated)
+	}
+}
+
+func TestLegacyEnvarsPrecedence(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		envars map[string]string
+		args   []string
+		want   time.Duration
+	}{
+		{name: "legacy", envars: map[string]string{"AWS_API_INTERVAL": "30"}, want: 30 * time.Second},
+		{name: "new variable", envars: map[string]string{"AWS_API_INTERVAL": "30", "RDS_MAXCON_SCRAPE_INTERVAL": "1m"}, want: time.Minute},
+		{name: "flag", envars: map[string]string{"AWS_API_INTERVAL": "30"}, args: []string{"--scrape.interval=2m"}, want: 2 * time.Minute},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFlags()
+			setLegacyEnvars(t, f)
+			for name, v := range tc.envars {
+				t.Setenv(name, v)
+			}
+
+			_, cfg, err := f.parse(tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Interval != tc.want {
+				t.Errorf("got interval %v, want %v", cfg.Interval, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+const (
+	// forecastPeriod is the period of the data points the trend is fitted to
+	forecastPeriod = 5 * time.Minute
+	// forecastMinPoints is the number of data points needed to fit a trend
+	forecastMinPoints = 3
+
+	minForecastWindow = forecastMinPoints * forecastPeriod
+	maxForecastWindow = 24 * time.Hour
+)
+
+// setConnectionsForecast fills SecondsUntilExhaustion of the exported instances
+// with a linear trend of their maximum DatabaseConnections over the window.
+// The instances with too few data points have no forecast.
+func setConnectionsForecast(ctx context.Context, svc cloudwatchiface.CloudWatchAPI, cfg ForecastConfig, infos []RDSInfo) error {
+	identifiers := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if len(info.SkipReason) == 0 {
+			identifiers = append(identifiers, info.DBInstanceIdentifier)
+		}
+	}
+	if len(identifiers) == 0 {
+		return nil
+	}
+
+	now := clk.Now()
+	history, err := getConnectionsHistory(ctx, svc, identifiers, now.Add(-cfg.Window), now, forecastPeriod)
+	if err != nil {
+		return err
+	}
+
+	for i := range infos {
+		maxConnections, err := strconv.Atoi(infos[i].MaxConnections)
+		if len(infos[i].SkipReason) != 0 || err != nil || maxConnections <= 0 {
+			continue
+		}
+		if v, ok := secondsUntilExhaustion(history[infos[i].DBInstanceIdentifier], maxConnections, now); ok {
+			infos[i].SecondsUntilExhaustion = &v
+		}
+	}
+
+	return nil
+}
+
+// secondsUntilExhaustion fits a line to the points by least squares, and
+// returns the seconds from now until it reaches maxConnections: 0 when it is
+// already reached, +Inf when the line does not increase. It returns false
+// with too few points to fit.
+func secondsUntilExhaustion(points []connectionsPoint, maxConnections int, now time.Time) (float64, bool) {
+	if len(points) < forecastMinPoints {
+		return 0, false
+	}
+
+	// the times are in seconds since the first point
+	var meanX, meanY float64
+	for _, point := range points {
+		meanX += point.timestamp.Sub(points[0].timestamp).Seconds()
+		meanY += point.value
+	}
+	meanX /= float64(len(points))
+	meanY /= float64(len(points))
+
+	var sxy, sxx float64
+	for _, point := range points {
+		dx := point.timestamp.Sub(points[0].timestamp).Seconds() - meanX
+		sxy += dx * (point.value - meanY)
+		sxx += dx * dx
+	}
+	if sxx == 0 {
+		return 0, false
+	}
+	slope := sxy / sxx
+
+	current := meanY + slope*(now.Sub(points[0].timestamp).Seconds()-meanX)
+	switch {
+	case current >= float64(maxConnections):
+		return 0, true
+	case slope <= 0:
+		return math.Inf(1), true
+	}
+
+	return (float64(maxConnections) - current) / slope, true
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSetConnectionsForecast(t *testing.T) {
+	fc := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := clk
+	t.Cleanup(func() { clk = c })
+	clk = fc
+
+	// the fake serves a data point per hour from the start of the window
+	svc := &fakeCloudWatch{values: map[string][]float64{
+		"growing":   {10, 20, 30},
+		"exhausted": {80, 90, 100},
+		"flat":      {50, 50, 40},
+		"sparse":    {10, 20},
+	}}
+	infos := []RDSInfo{
+		{DBInstanceIdentifier: "growing", MaxConnections: "100"},
+		{DBInstanceIdentifier: "exhausted", MaxConnections: "100"},
+		{DBInstanceIdentifier: "flat", MaxConnections: "100"},
+		{DBInstanceIdentifier: "sparse", MaxConnections: "100"},
+		{DBInstanceIdentifier: "skipped", MaxConnections: "0", SkipReason: "parameter group error"},
+	}
+
+	err := setConnectionsForecast(context.Background(), svc, defaultConfig().Forecast, infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10 connections per hour reach 100 from 70 at the end of the 6h window in 3h
+	for i, want := range []float64{3 * 3600, 0, math.Inf(1)} {
+		if got := infos[i].SecondsUntilExhaustion; got == nil || *got != want && math.Abs(*got-want) > 1e-6 {
+			t.Errorf("%v: got %v, want %v", infos[i].DBInstanceIdentifier, got, want)
+		}
+	}
+	if infos[3].SecondsUntilExhaustion != nil || infos[4].SecondsUntilExhaustion != nil {
+		t.Errorf("got %v %v, want no forecast with too few data points", infos[3].SecondsUntilExhaustion, infos[4].SecondsUntilExhaustion)
+	}
+	if svc.queries != 4 {
+		t.Errorf("got %v queries, want 4 without the skipped instance", svc.queries)
+	}
+}
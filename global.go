@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+const (
+	// globalClusterLabel is the Aurora global database of the cluster of an
+	// instance, empty when it is not in one
+	globalClusterLabel = "globalclusteridentifier"
+	// globalRoleLabel is primary or secondary for the instances of a global
+	// database, as the region of their cluster
+	globalRoleLabel = "globalclusterrole"
+
+	globalPrimary   = "primary"
+	globalSecondary = "secondary"
+)
+
+// globalMember is a cluster of a global database.
+type globalMember struct {
+	globalCluster string
+	region        string
+	role          string
+}
+
+// describeGlobalMembers returns the member clusters of the global databases
+// by region and cluster identifier.
+func describeGlobalMembers(ctx context.Context, svc rdsClusterAPI) (map[string]globalMember, error) {
+	members := map[string]globalMember{}
+	err := svc.DescribeGlobalClustersPagesWithContext(ctx, &rds.DescribeGlobalClustersInput{}, func(page *rds.DescribeGlobalClustersOutput, lastPage bool) bool {
+		for _, global := range page.GlobalClusters {
+			for _, member := range global.GlobalClusterMembers {
+				a, err := arn.Parse(aws.StringValue(member.DBClusterArn))
+				if err != nil {
+					continue
+				}
+				role := globalSecondary
+				if aws.BoolValue(member.IsWriter) {
+					role = globalPrimary
+				}
+				members[a.Region+"|"+strings.TrimPrefix(a.Resource, "cluster:")] = globalMember{
+					globalCluster: aws.StringValue(global.GlobalClusterIdentifier),
+					region:        a.Region,
+					role:          role,
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe global clusters: %w", err)
+	}
+
+	return members, nil
+}
+
+// setGlobalLabels labels the instances of the global databases with their
+// global cluster and role.
+func setGlobalLabels(infos []RDSInfo, members map[string]globalMember) {
+	for _, info := range infos {
+		member, ok := members[info.Region+"|"+info.DBClusterIdentifier]
+		if !ok || len(info.DBClusterIdentifier) == 0 {
+			continue
+		}
+		info.Labels[globalClusterLabel] = member.globalCluster
+		info.Labels[globalRoleLabel] = member.role
+	}
+}
+
+// collectGlobalClusters labels the instances of a target which are in a
+// global database. With collect_secondary_regions, it returns the instances
+// of the secondary clusters of the global databases whose primary cluster is
+// in the region of the target, for the regions which are not targets, in
+// which the target assumes the same role.
+func collectGlobalClusters(ctx context.Context, cfg *Config, target Target, outputs []Output, sess *session.Session, infos []RDSInfo) ([]RDSInfo, error) {
+	members, err := describeGlobalMembers(ctx, newRDSClusterAPI(sess))
+	if err != nil {
+		return nil, err
+	}
+	setGlobalLabels(infos, members)
+	if !cfg.GlobalClusters.CollectSecondaryRegions {
+		return nil, nil
+	}
+
+	region := aws.StringValue(sess.Config.Region)
+	owned := map[string]bool{}
+	for _, member := range members {
+		if member.role == globalPrimary && member.region == region {
+			owned[member.globalCluster] = true
+		}
+	}
+	var regions []string
+	for _, member := range members {
+		if owned[member.globalCluster] && member.region != region && !slices.Contains(regions, member.region) {
+			regions = append(regions, member.region)
+		}
+	}
+	sort.Strings(regions)
+
+	keys := map[string]bool{}
+	for _, t := range cfg.Targets {
+		keys[t.key()] = true
+	}
+	var secondaries []RDSInfo
+	for _, secondary := range regions {
+		sub := target
+		sub.Region = secondary
+		if keys[sub.key()] {
+			continue
+		}
+
+		subSess := newSession(cfg, sub)
+		subInfos, err := readInstances(ctx, cfg, subSess, sub)
+		if err != nil {
+			return nil, fmt.Errorf("region %v: %w", secondary, err)
+		}
+		setGlobalLabels(subInfos, members)
+		var in []RDSInfo
+		for _, info := range subInfos {
+			if owned[info.Labels[globalClusterLabel]] {
+				in = append(in, info)
+			}
+		}
+		err = enrichInstances(ctx, cfg, subSess, outputs, in)
+		if err != nil {
+			return nil, fmt.Errorf("region %v: %w", secondary, err)
+		}
+		secondaries = append(secondaries, in...)
+	}
+
+	return secondaries, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter/exportertest"
+)
+
+func clusterInstance(identifier, cluster string) *rds.DBInstance {
+	instance := exportertest.Instance(identifier, "aurora-postgresql", "group")
+	instance.DBClusterIdentifier = aws.String(cluster)
+
+	return instance
+}
+
+func globalCluster(identifier string, writer string, readers ...string) *rds.GlobalCluster {
+	global := &rds.GlobalCluster{
+		GlobalClusterIdentifier: aws.String(identifier),
+		GlobalClusterMembers:    []*rds.GlobalClusterMember{{DBClusterArn: aws.String(writer), IsWriter: aws.Bool(true)}},
+	}
+	for _, reader := range readers {
+		global.GlobalClusterMembers = append(global.GlobalClusterMembers, &rds.GlobalClusterMember{DBClusterArn: aws.String(reader), IsWriter: aws.Bool(false)})
+	}
+
+	return global
+}
+
+func TestCollectGlobalClusters(t *testing.T) {
+	regions := map[string]*exportertest.RDS{
+		"us-east-1": {InstancePages: [][]*rds.DBInstance{{
+			clusterInstance("orders-a01", "orders-use1"),
+			clusterInstance("billing-a01", "billing-use1"),
+			exportertest.Instance("standalone", "postgres", "group"),
+		}}},
+		"eu-west-1": {InstancePages: [][]*rds.DBInstance{{
+			clusterInstance("orders-e01", "orders-euw1"),
+			clusterInstance("billing-e01", "billing-euw1"),
+			exportertest.Instance("other-e01", "postgres", "group"),
+		}}},
+		"ap-northeast-1": {InstancePages: [][]*rds.DBInstance{{
+			clusterInstance("orders-t01", "orders-apne1"),
+		}}},
+	}
+	for _, svc := range regions {
+		svc.ParameterPages = map[string][][]*rds.Parameter{"group": exportertest.Parameters("100")}
+	}
+	clusters := &fakeClusters{globals: []*rds.GlobalCluster{
+		globalCluster("orders", "arn:aws:rds:us-east-1:123456789012:cluster:orders-use1",
+			"arn:aws:rds:eu-west-1:123456789012:cluster:orders-euw1", "arn:aws:rds:ap-northeast-1:123456789012:cluster:orders-apne1"),
+		// the primary of billing is in eu-west-1, whose instances are not collected by the target of us-east-1
+		globalCluster("billing", "arn:aws:rds:eu-west-1:123456789012:cluster:billing-euw1", "arn:aws:rds:us-east-1:123456789012:cluster:billing-use1"),
+	}}
+	newRDS, newClusters := newRDSAPI, newRDSClusterAPI
+	newRDSAPI = func(sess *session.Session) exporter.RDSAPI { return regions[aws.StringValue(sess.Config.Region)] }
+	newRDSClusterAPI = func(*session.Session) rdsClusterAPI { return clusters }
+	t.Cleanup(func() { newRDSAPI, newRDSClusterAPI = newRDS, newClusters })
+
+	cfg := testConfig(t, func(cfg *Config) {
+		cfg.Targets = []Target{{Region: "us-east-1"}, {Region: "ap-northeast-1"}}
+		cfg.GlobalClusters = GlobalClustersConfig{Enabled: true, CollectSecondaryRegions: true}
+	})
+
+	infos, err := collectTarget(context.Background(), cfg, cfg.Targets[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ap-northeast-1 is a target, which collects its own instances
+	want := map[string][2]string{
+		"orders-a01":  {"orders", globalPrimary},
+		"billing-a01": {"billing", globalSecondary},
+		"standalone":  {"", ""},
+		"orders-e01":  {"orders", globalSecondary},
+	}
+	got := infosByIdentifier(infos)
+	if len(infos) != len(want) {
+		t.Errorf("got %d instances, want %d", len(infos), len(want))
+	}
+	for identifier, labels := range want {
+		info, ok := got[identifier]
+		if !ok {
+			t.Errorf("instance %v is missing", identifier)
+			continue
+		}
+		if info.Labels[globalClusterLabel] != labels[0] || info.Labels[globalRoleLabel] != labels[1] {
+			t.Errorf("instance %v: got %v/%v, want %v/%v", identifier, info.Labels[globalClusterLabel], info.Labels[globalRoleLabel], labels[0], labels[1])
+		}
+	}
+	if got["orders-e01"].Region != "eu-west-1" {
+		t.Errorf("got region %v of orders-e01, want eu-west-1", got["orders-e01"].Region)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/rdsmaxconpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// instanceServer implements rdsmaxconpb.InstanceServiceServer on top of the
+// last snapshot.
+type instanceServer struct {
+	rdsmaxconpb.UnimplementedInstanceServiceServer
+
+	store *Store
+}
+
+func newPBInstance(info RDSInfo) *rdsmaxconpb.Instance {
+	i := newAPIInstance(info)
+
+	return &rdsmaxconpb.Instance{
+		DbInstanceIdentifier: i.DBInstanceIdentifier,
+		DbInstanceClass:      i.DBInstanceClass,
+		Engine:               i.DBEngine,
+		MaxConnections:       int64(i.MaxConnections),
+		DbParameterGroupName: i.DBParameterGroupName,
+		DbClusterIdentifier:  i.DBClusterIdentifier,
+		DatabaseConnections:  i.DatabaseConnections,
+		Region:               i.Region,
+	}
+}
+
+func (s *instanceServer) ListInstances(_ *rdsmaxconpb.ListInstancesRequest, stream rdsmaxconpb.InstanceService_ListInstancesServer) error {
+	infos, _ := s.store.Get()
+
+	for _, info := range infos {
+		err := stream.Send(newPBInstance(info))
+		if err != nil {
+			return fmt.Errorf("failed to send instance: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *instanceServer) GetInstance(_ context.Context, req *rdsmaxconpb.GetInstanceRequest) (*rdsmaxconpb.Instance, error) {
+	infos, _ := s.store.Get()
+
+	for _, info := range infos {
+		if info.Region == req.GetRegion() && info.DBInstanceIdentifier == req.GetDbInstanceIdentifier() {
+			return newPBInstance(info), nil
+		}
+	}
+
+	return nil, status.Errorf(codes.NotFound, "instance %v in %v is not found", req.GetDbInstanceIdentifier(), req.GetRegion())
+}
+
+func serveGRPC(ctx context.Context, address string, store *Store) error {
+	lis, err := listen(address)
+	if err != nil {
+		return err
+	}
+
+	s := grpc.NewServer()
+	rdsmaxconpb.RegisterInstanceServiceServer(s, &instanceServer{store: store})
+
+	go func() {
+		<-ctx.Done()
+		s.GracefulStop()
+	}()
+
+	err = s.Serve(lis)
+	if err != nil {
+		return fmt.Errorf("failed to serve gRPC: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// healthyHandler reports that the process is up.
+func healthyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "Healthy.")
+	})
+}
+
+// readyHandler reports ready once the first snapshot has completed, or a
+// snapshot of the leader was served by a standby, so that the metrics are
+// never served empty, and while the collection loop is not wedged.
+func readyHandler(store *Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if dog.Wedged() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "The collection loop is wedged.")
+			return
+		}
+
+		_, updatedAt := store.Get()
+		if updatedAt.IsZero() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "Waiting for the first snapshot.")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "Ready.")
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// sdMetaLabelPrefix is the prefix of the labels of the target groups, which
+// Prometheus drops after the relabeling.
+const sdMetaLabelPrefix = "__meta_rds_"
+
+// sdTargetGroup is a target group of the Prometheus HTTP service discovery.
+// ref: https://prometheus.io/docs/prometheus/latest/http_sd/
+type sdTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// newSDTargetGroup returns the target group of the endpoint of an instance,
+// with its attributes and its labels as meta labels, which are only available
+// to the relabeling, such as to pass the address as the target parameter of
+// a multi-target exporter.
+func newSDTargetGroup(info RDSInfo) sdTargetGroup {
+	labels := map[string]string{
+		sdMetaLabelPrefix + "region":                 info.Region,
+		sdMetaLabelPrefix + "db_instance_identifier": info.DBInstanceIdentifier,
+		sdMetaLabelPrefix + "db_instance_class":      info.DBInstanceClass,
+		sdMetaLabelPrefix + "db_cluster_identifier":  info.DBClusterIdentifier,
+		sdMetaLabelPrefix + "engine":                 info.DBEngine,
+		sdMetaLabelPrefix + "engine_family":          engineFamily(info.DBEngine),
+		sdMetaLabelPrefix + "status":                 info.Status,
+		sdMetaLabelPrefix + "address":                info.Endpoint,
+		sdMetaLabelPrefix + "port":                   strconv.FormatInt(info.Port, 10),
+	}
+	if len(info.SkipReason) == 0 {
+		labels[sdMetaLabelPrefix+"max_connections"] = info.MaxConnections
+	}
+	for name, value := range info.Labels {
+		labels[sdMetaLabelPrefix+"label_"+name] = value
+	}
+
+	return sdTargetGroup{
+		Targets: []string{net.JoinHostPort(info.Endpoint, strconv.FormatInt(info.Port, 10))},
+		Labels:  labels,
+	}
+}
+
+// httpSDHandler serves GET /api/v1/sd with a target group for the endpoint of
+// every instance discovered by the last snapshot, including the skipped ones
+// and the ones of the unsupported engines, which can still be scraped by an
+// exporter of their database, such as postgres_exporter or mysqld_exporter.
+// The engine parameter keeps the instances of a comma separated list of
+// engines.
+func httpSDHandler(store *Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var engines map[string]bool
+		if engine := r.URL.Query().Get("engine"); len(engine) != 0 {
+			engines = map[string]bool{}
+			for _, e := range strings.Split(engine, ",") {
+				engines[strings.TrimSpace(e)] = true
+			}
+		}
+
+		infos, _ := store.Get()
+		groups := []sdTargetGroup{}
+		for _, info := range infos {
+			// a creating instance has no endpoint yet
+			if len(info.Endpoint) == 0 || (engines != nil && !engines[info.DBEngine]) {
+				continue
+			}
+			groups = append(groups, newSDTargetGroup(info))
+		}
+
+		writeJSON(w, http.StatusOK, groups)
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPSD(t *testing.T) {
+	store := &Store{}
+	store.Set([]RDSInfo{
+		{
+			Region: "ap-northeast-1", DBInstanceIdentifier: "postgres-a01", DBInstanceClass: "db.r5.large", DBEngine: "postgres", MaxConnections: "1800",
+			Status: "available", Endpoint: "postgres-a01.abc.ap-northeast-1.rds.amazonaws.com", Port: 5432, Labels: map[string]string{"team": "api"},
+		},
+		{
+			Region: "ap-northeast-1", DBInstanceIdentifier: "mysql-a01", DBInstanceClass: "db.r5.large", DBEngine: "mysql", MaxConnections: "0",
+			Status: "available", Endpoint: "mysql-a01.abc.ap-northeast-1.rds.amazonaws.com", Port: 3306, SkipReason: "unsupported engine: mysql", Unsupported: true,
+		},
+		{Region: "ap-northeast-1", DBInstanceIdentifier: "creating", DBEngine: "postgres", Status: "creating"},
+	})
+	handler := httpSDHandler(store)
+
+	get := func(url string) []sdTargetGroup {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %v", w.Code)
+		}
+		var groups []sdTargetGroup
+		err := json.Unmarshal(w.Body.Bytes(), &groups)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return groups
+	}
+
+	groups := get("/api/v1/sd")
+	if len(groups) != 2 {
+		t.Fatalf("got %v target groups, want 2 without the creating instance", len(groups))
+	}
+	if got := groups[0].Targets; len(got) != 1 || got[0] != "postgres-a01.abc.ap-northeast-1.rds.amazonaws.com:5432" {
+		t.Errorf("got targets %v", got)
+	}
+	for name, want := range map[string]string{
+		"__meta_rds_db_instance_identifier": "postgres-a01",
+		"__meta_rds_engine":                 "postgres",
+		"__meta_rds_max_connections":        "1800",
+		"__meta_rds_label_team":             "api",
+	} {
+		if got := groups[0].Labels[name]; got != want {
+			t.Errorf("got %v %q, want %q", name, got, want)
+		}
+	}
+	// the max_connections of an unsupported engine is not known
+	if _, ok := groups[1].Labels["__meta_rds_max_connections"]; ok {
+		t.Errorf("got max_connections of mysql-a01")
+	}
+
+	groups = get("/api/v1/sd?engine=mysql,aurora-mysql")
+	if len(groups) != 1 || groups[0].Labels["__meta_rds_db_instance_identifier"] != "mysql-a01" {
+		t.Errorf("got %+v, want the mysql instance only", groups)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InfluxDB posts each snapshot in InfluxDB line protocol to a write URL,
+// e.g. http://influxdb:8086/write?db=rds (1.x) or
+// http://influxdb:8086/api/v2/write?org=example&bucket=rds (2.x).
+type InfluxDB struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+const influxDBMeasurement = "aws_custom_rds"
+
+//nolint:gochecknoglobals
+var influxDBTagReplacer = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+func NewInfluxDB(url, token string) *InfluxDB {
+	return &InfluxDB{
+		client: &http.Client{Timeout: 30 * time.Second},
+		url:    url,
+		token:  token,
+	}
+}
+
+func (i *InfluxDB) Name() string {
+	return "influxdb"
+}
+
+func (i *InfluxDB) Close() error {
+	return nil
+}
+
+func (i *InfluxDB) Write(infos []RDSInfo) error {
+	var buf bytes.Buffer
+	now := time.Now().UnixNano()
+
+	for _, info := range infos {
+		v, err := strconv.Atoi(info.MaxConnections)
+		if err != nil {
+			return fmt.Errorf("failed to parse max connections to int: %w", err)
+		}
+
+		// e.g. aws_custom_rds,dbinstanceidentifier=a01,dbinstanceclass=db.r5.large,dbengine=postgres max_connections=1800i 1600000000000000000
+		fmt.Fprintf(&buf, "%v,dbinstanceidentifier=%v,dbinstanceclass=%v,dbengine=%v max_connections=%di",
+			influxDBMeasurement,
+			influxDBTagReplacer.Replace(info.DBInstanceIdentifier),
+			influxDBTagReplacer.Replace(info.DBInstanceClass),
+			influxDBTagReplacer.Replace(info.DBEngine),
+			v,
+		)
+		if info.DatabaseConnections != nil {
+			fmt.Fprintf(&buf, ",database_connections=%v", strconv.FormatFloat(*info.DatabaseConnections, 'f', -1, 64))
+		}
+		fmt.Fprintf(&buf, " %d\n", now)
+	}
+
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, i.url, &buf)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if len(i.token) != 0 {
+		req.Header.Set("Authorization", "Token "+i.token)
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post line protocol: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %v: %s", resp.Status, body)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestInfluxDB(t *testing.T) {
+	var lines []string
+	var authorization string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		b, _ := io.ReadAll(r.Body)
+		lines = strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(http.StatusText(status)))
+	}))
+	defer server.Close()
+
+	i := NewInfluxDB(server.URL+"/api/v2/write?org=example&bucket=rds", "token")
+	err := i.Write([]RDSInfo{
+		{DBInstanceIdentifier: "a01", DBInstanceClass: "db.r5.large", DBEngine: "postgres", MaxConnections: "1800", DatabaseConnections: aws.Float64(900)},
+		{DBInstanceIdentifier: "b 01,x=y", DBInstanceClass: "db.t3.micro", DBEngine: "mysql", MaxConnections: "66"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authorization != "Token token" {
+		t.Errorf("got Authorization %q, want the token", authorization)
+	}
+	// the timestamps are the same for all the lines of a snapshot
+	if len(lines) != 2 {
+		t.Fatalf("got lines %q, want 2", lines)
+	}
+	var timestamps []string
+	for j, line := range lines {
+		k := strings.LastIndexByte(line, ' ')
+		timestamps = append(timestamps, line[k+1:])
+		lines[j] = line[:k]
+	}
+	want := []string{
+		"aws_custom_rds,dbinstanceidentifier=a01,dbinstanceclass=db.r5.large,dbengine=postgres max_connections=1800i,database_connections=900",
+		`aws_custom_rds,dbinstanceidentifier=b\ 01\,x\=y,dbinstanceclass=db.t3.micro,dbengine=mysql max_connections=66i`,
+	}
+	if !equalStrings(lines, want) {
+		t.Errorf("got lines %q, want %q", lines, want)
+	}
+	if timestamps[0] != timestamps[1] {
+		t.Errorf("got timestamps %v, want the same", timestamps)
+	}
+
+	// an error status fails the write
+	status = http.StatusUnauthorized
+	err = i.Write([]RDSInfo{{DBInstanceIdentifier: "a01", MaxConnections: "1800"}})
+	if err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("got error %v, want the unauthorized status", err)
+	}
+}
+
+func TestInfluxDBRedact(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	hashed := publishRedacted(t, NewInfluxDB(server.URL, ""))
+	if strings.Contains(body, "tenant") || !strings.Contains(body, "dbinstanceidentifier="+hashed+",") {
+		t.Errorf("got lines %q, want the identifier hashed as %v", body, hashed)
+	}
+}
@@ -0,0 +1,149 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// The integration tests run against LocalStack or moto, at the endpoint of
+// RDS_MAXCON_TEST_ENDPOINT, http://localhost:4566 by default:
+//
+//	docker run --rm -d -p 4566:5000 motoserver/moto
+//	go test -tags integration -run Integration ./...
+const defaultTestEndpoint = "http://localhost:4566"
+
+// seedInstance is an instance created for the test, using its own parameter
+// group setting max_connections.
+type seedInstance struct {
+	identifier     string
+	engine         string
+	family         string
+	class          string
+	maxConnections string
+}
+
+func seed(t *testing.T, svc *rds.RDS, instances []seedInstance) {
+	t.Helper()
+	ctx := context.Background()
+
+	for _, instance := range instances {
+		group := instance.identifier + "-params"
+		_, err := svc.CreateDBParameterGroupWithContext(ctx, &rds.CreateDBParameterGroupInput{
+			DBParameterGroupName:   aws.String(group),
+			DBParameterGroupFamily: aws.String(instance.family),
+			Description:            aws.String("integration test of aws-rds-maxcon-prometheus-exporter"),
+		})
+		if err != nil {
+			t.Fatalf("failed to create parameter group %v: %v", group, err)
+		}
+		t.Cleanup(func() {
+			_, err := svc.DeleteDBParameterGroup(&rds.DeleteDBParameterGroupInput{DBParameterGroupName: aws.String(group)})
+			if err != nil {
+				t.Logf("failed to delete parameter group %v: %v", group, err)
+			}
+		})
+
+		_, err = svc.ModifyDBParameterGroupWithContext(ctx, &rds.ModifyDBParameterGroupInput{
+			DBParameterGroupName: aws.String(group),
+			Parameters: []*rds.Parameter{{
+				ParameterName:  aws.String("max_connections"),
+				ParameterValue: aws.String(instance.maxConnections),
+				ApplyMethod:    aws.String(rds.ApplyMethodPendingReboot),
+			}},
+		})
+		if err != nil {
+			t.Fatalf("failed to set max_connections of %v: %v", group, err)
+		}
+
+		identifier := instance.identifier
+		_, err = svc.CreateDBInstanceWithContext(ctx, &rds.CreateDBInstanceInput{
+			DBInstanceIdentifier: aws.String(identifier),
+			DBInstanceClass:      aws.String(instance.class),
+			Engine:               aws.String(instance.engine),
+			DBParameterGroupName: aws.String(group),
+			AllocatedStorage:     aws.Int64(20),
+			MasterUsername:       aws.String("exporter"),
+			MasterUserPassword:   aws.String("integration-test"),
+		})
+		if err != nil {
+			t.Fatalf("failed to create instance %v: %v", identifier, err)
+		}
+		// registered after the group, so that the instance is deleted first
+		t.Cleanup(func() {
+			_, err := svc.DeleteDBInstance(&rds.DeleteDBInstanceInput{
+				DBInstanceIdentifier: aws.String(identifier),
+				SkipFinalSnapshot:    aws.Bool(true),
+			})
+			if err != nil {
+				t.Logf("failed to delete instance %v: %v", identifier, err)
+			}
+		})
+	}
+}
+
+func TestIntegrationSnapshot(t *testing.T) {
+	endpoint := os.Getenv("RDS_MAXCON_TEST_ENDPOINT")
+	if len(endpoint) == 0 {
+		endpoint = defaultTestEndpoint
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	// the instances of the other runs are filtered out
+	prefix := fmt.Sprintf("it-%d", time.Now().UnixNano())
+	cfg := defaultConfig()
+	cfg.Targets = []Target{{Region: "us-east-1", Endpoint: endpoint}}
+	cfg.Filters.Include = []string{"^" + prefix + "-"}
+	err := cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := rds.New(newSession(cfg, cfg.Targets[0]))
+	seed(t, svc, []seedInstance{
+		{identifier: prefix + "-default", engine: "postgres", family: "postgres15", class: "db.t3.micro", maxConnections: "LEAST({DBInstanceClassMemory/9531392},5000)"},
+		{identifier: prefix + "-explicit", engine: "postgres", family: "postgres15", class: "db.r5.large", maxConnections: "300"},
+		{identifier: prefix + "-mysql", engine: "mysql", family: "mysql8.0", class: "db.t3.micro", maxConnections: "150"},
+	})
+
+	reg := prometheus.NewRegistry()
+	registerInstanceMetrics(reg, cfg.LabelNames())
+
+	store := &Store{}
+	err = snapshot(context.Background(), cfg, store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the instance of the unsupported engine is served by the API only
+	expected := fmt.Sprintf(`# HELP aws_custom_rds_max_connections Max Connections of RDS
+# TYPE aws_custom_rds_max_connections gauge
+aws_custom_rds_max_connections{dbinstanceclass="db.r5.large",dbinstanceidentifier="%[1]v-explicit"} 300
+aws_custom_rds_max_connections{dbinstanceclass="db.t3.micro",dbinstanceidentifier="%[1]v-default"} 125
+`, prefix)
+	err = testutil.GatherAndCompare(reg, strings.NewReader(expected), "aws_custom_rds_max_connections")
+	if err != nil {
+		t.Error(err)
+	}
+
+	infos, _ := store.Get()
+	if len(infos) != 3 {
+		t.Errorf("got %d instances, want 3", len(infos))
+	}
+	for _, info := range infos {
+		if info.DBEngine == "mysql" && !info.Unsupported {
+			t.Errorf("instance %v is not unsupported", info.DBInstanceIdentifier)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka produces one JSON message per instance, keyed by the region and the
+// identifier of the instance so that the topic can be compacted. In snapshot mode every
+// instance is produced on each snapshot; in diff mode only the instances that
+// were added, changed or removed since the previous snapshot are.
+type Kafka struct {
+	writer   kafkaWriter
+	diff     bool
+	previous map[string]apiInstance
+}
+
+// kafkaWriter produces the messages, a *kafka.Writer but in the tests.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, messages ...kafka.Message) error
+	Close() error
+}
+
+type kafkaMessage struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Change is one of "snapshot", "added", "changed" or "removed"
+	Change   string      `json:"change"`
+	Instance apiInstance `json:"instance"`
+}
+
+const (
+	kafkaModeSnapshot = "snapshot"
+	kafkaModeDiff     = "diff"
+)
+
+func NewKafka(brokers []string, topic, mode string) (*Kafka, error) {
+	switch mode {
+	case "", kafkaModeSnapshot, kafkaModeDiff:
+	default:
+		return nil, fmt.Errorf("unsupported Kafka mode %v: must be %v or %v", mode, kafkaModeSnapshot, kafkaModeDiff)
+	}
+
+	return &Kafka{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+		diff: mode == kafkaModeDiff,
+	}, nil
+}
+
+func (k *Kafka) Name() string {
+	return "kafka"
+}
+
+// Stateful reports whether the changes are produced in diff mode.
+func (k *Kafka) Stateful() bool {
+	return k.diff
+}
+
+// Close flushes the pending messages and closes the connections to the
+// brokers.
+func (k *Kafka) Close() error {
+	return k.writer.Close() //nolint:wrapcheck
+}
+
+func (k *Kafka) Write(infos []RDSInfo) error {
+	var messages []kafka.Message
+	now := time.Now()
+
+	add := func(change string, i apiInstance) error {
+		value, err := json.Marshal(kafkaMessage{Timestamp: now, Change: change, Instance: i})
+		if err != nil {
+			return fmt.Errorf("failed to marshal message: %w", err)
+		}
+		messages = append(messages, kafka.Message{Key: []byte(i.key()), Value: value, Time: now})
+		return nil
+	}
+
+	payload, current := diffInstances(k.previous, infos)
+	if k.diff {
+		for change, instances := range map[string][]apiInstance{"added": payload.Added, "changed": payload.Changed, "removed": payload.Removed} {
+			for _, i := range instances {
+				if err := add(change, i); err != nil {
+					return err
+				}
+			}
+		}
+	} else {
+		for _, info := range infos {
+			if err := add(kafkaModeSnapshot, newAPIInstance(info)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(messages) != 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		err := k.writer.WriteMessages(ctx, messages...)
+		if err != nil {
+			return fmt.Errorf("failed to write messages: %w", err)
+		}
+	}
+
+	k.previous = current
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeKafkaWriter records the produced messages.
+type fakeKafkaWriter struct {
+	messages []kafka.Message
+}
+
+func (f *fakeKafkaWriter) Close() error {
+	return nil
+}
+
+func (f *fakeKafkaWriter) WriteMessages(_ context.Context, messages ...kafka.Message) error {
+	f.messages = append(f.messages, messages...)
+	return nil
+}
+
+// kafkaChanges returns the identifiers and the changes of the produced
+// messages, such as "a01=added", in order.
+func kafkaChanges(t *testing.T, messages []kafka.Message) []string {
+	t.Helper()
+
+	var ret []string
+	for _, m := range messages {
+		var message kafkaMessage
+		err := json.Unmarshal(m.Value, &message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if message.Instance.key() != string(m.Key) {
+			t.Errorf("got key %q for instance %v, want its region and identifier", m.Key, message.Instance.DBInstanceIdentifier)
+		}
+		ret = append(ret, message.Instance.DBInstanceIdentifier+"="+message.Change)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+func TestKafka(t *testing.T) {
+	for _, tc := range []struct {
+		mode string
+		want [][]string
+	}{
+		{
+			mode: kafkaModeSnapshot,
+			want: [][]string{{"a01=snapshot", "b01=snapshot"}, {"a01=snapshot", "b01=snapshot"}, {"a01=snapshot"}},
+		},
+		{
+			mode: kafkaModeDiff,
+			want: [][]string{{"a01=added", "b01=added"}, nil, {"a01=changed", "b01=removed"}},
+		},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			k, err := NewKafka([]string{"localhost:9092"}, "rds", tc.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writer := &fakeKafkaWriter{}
+			k.writer = writer
+
+			for i, infos := range [][]RDSInfo{
+				{{DBInstanceIdentifier: "a01", MaxConnections: "1800"}, {DBInstanceIdentifier: "b01", MaxConnections: "66"}},
+				{{DBInstanceIdentifier: "a01", MaxConnections: "1800"}, {DBInstanceIdentifier: "b01", MaxConnections: "66"}},
+				{{DBInstanceIdentifier: "a01", MaxConnections: "3600"}},
+			} {
+				writer.messages = nil
+				err := k.Write(infos)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := kafkaChanges(t, writer.messages); !equalStrings(got, tc.want[i]) {
+					t.Errorf("write %d: got messages %q, want %q", i, got, tc.want[i])
+				}
+			}
+		})
+	}
+
+	_, err := NewKafka([]string{"localhost:9092"}, "rds", "stream")
+	if err == nil {
+		t.Error("got no error, want the unsupported mode")
+	}
+}
+
+func TestKafkaRedact(t *testing.T) {
+	k, err := NewKafka([]string{"localhost:9092"}, "rds", kafkaModeSnapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := &fakeKafkaWriter{}
+	k.writer = writer
+
+	hashed := publishRedacted(t, k)
+	if got := kafkaChanges(t, writer.messages); !equalStrings(got, []string{hashed + "=snapshot"}) {
+		t.Errorf("got messages %q, want the identifier hashed as %v", got, hashed)
+	}
+	for _, m := range writer.messages {
+		if strings.Contains(string(m.Key)+string(m.Value), "tenant") {
+			t.Errorf("got message %s: %s, want every name of the tenant redacted", m.Key, m.Value)
+		}
+	}
+}
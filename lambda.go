@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lambdaRuntimeAPIEnvar is set by Lambda to the address of the runtime API
+// of the custom runtimes, from which the invocations are read.
+// ref: https://docs.aws.amazon.com/lambda/latest/dg/runtimes-api.html
+const lambdaRuntimeAPIEnvar = "AWS_LAMBDA_RUNTIME_API"
+
+// lambdaResponse is the result of an invocation, as shown by the console and
+// the destinations of the asynchronous invocations.
+type lambdaResponse struct {
+	Instances int `json:"instances"`
+	Skipped   int `json:"skipped"`
+}
+
+// lambdaError is the error of an invocation or of the initialization.
+type lambdaError struct {
+	ErrorMessage string `json:"errorMessage"`
+	ErrorType    string `json:"errorType"`
+}
+
+// runLambda serves the invocations of the Lambda runtime API, each of which
+// takes a snapshot within the deadline of the invocation and writes it to the
+// outputs, such as remote_write to Amazon Managed Service for Prometheus or
+// CloudWatch, as nothing scrapes a function. The metrics of the registry are
+// kept between the invocations of a warm function.
+func runLambda(ctx context.Context, cfg *Config) error {
+	api := os.Getenv(lambdaRuntimeAPIEnvar)
+	if len(api) == 0 {
+		return fmt.Errorf("%v is not set: not running in Lambda", lambdaRuntimeAPIEnvar)
+	}
+	runtime := &lambdaRuntime{client: &http.Client{}, url: "http://" + api + "/2018-06-01/runtime"}
+
+	slog.Info("starting in Lambda", "version", version, "commit", commit, "date", date)
+
+	outputs, err := getOutputs(cfg.Outputs)
+	if err != nil {
+		err = fmt.Errorf("failed to create outputs: %w", err)
+		return errors.Join(err, runtime.post(ctx, "/init/error", err))
+	}
+	defer closeOutputs(outputs)
+	if len(outputs) == 0 {
+		slog.Warn("no output is configured: the snapshots are not written anywhere")
+	}
+	setStaleAfter(cfg)
+
+	store := &Store{}
+	registerInstanceMetrics(prometheus.DefaultRegisterer, cfg.LabelNames())
+	registerMetrics(prometheus.DefaultRegisterer)
+	configReloadSuccess.Set(1)
+
+	return runtime.serve(ctx, func(ctx context.Context) (any, error) {
+		err := snapshot(ctx, cfg, store, outputs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to take snapshot: %w", err)
+		}
+		return newLambdaResponse(store), nil
+	})
+}
+
+func newLambdaResponse(store *Store) lambdaResponse {
+	var ret lambdaResponse
+	infos, _ := store.Get()
+	for _, info := range infos {
+		if len(info.SkipReason) != 0 {
+			ret.Skipped++
+		} else {
+			ret.Instances++
+		}
+	}
+
+	return ret
+}
+
+// lambdaRuntime is a client of the Lambda runtime API.
+type lambdaRuntime struct {
+	client *http.Client
+	url    string
+}
+
+// serve handles the invocations one at a time until ctx is canceled or the
+// runtime API fails, which ends the function instance.
+func (r *lambdaRuntime) serve(ctx context.Context, handle func(ctx context.Context) (any, error)) error {
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url+"/invocation/next", nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		// the request blocks until the next invocation, so it has no timeout
+		resp, err := r.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to get next invocation: %w", err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to get next invocation: status %v", resp.Status)
+		}
+
+		id := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+		err = r.invoke(ctx, id, resp.Header.Get("Lambda-Runtime-Deadline-Ms"), handle)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// invoke runs an invocation within its deadline and posts its response or
+// its error.
+func (r *lambdaRuntime) invoke(ctx context.Context, id, deadline string, handle func(ctx context.Context) (any, error)) error {
+	ictx := ctx
+	if ms, err := strconv.ParseInt(deadline, 10, 64); err == nil {
+		var cancel context.CancelFunc
+		ictx, cancel = context.WithDeadline(ctx, time.UnixMilli(ms))
+		defer cancel()
+	}
+
+	logger := slog.With("request_id", id)
+	logger.Info("invoked")
+	body, err := handle(ictx)
+	if err != nil {
+		logger.Error("invocation failed", "err", err)
+		return r.post(ctx, "/invocation/"+id+"/error", err)
+	}
+
+	return r.post(ctx, "/invocation/"+id+"/response", body)
+}
+
+// post posts a response, or an error when v is one.
+func (r *lambdaRuntime) post(ctx context.Context, path string, v any) error {
+	if err, ok := v.(error); ok {
+		v = lambdaError{ErrorMessage: err.Error(), ErrorType: "Error"}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url+path, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if _, ok := v.(lambdaError); ok {
+		req.Header.Set("Lambda-Runtime-Function-Error-Type", "Error")
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post %v: %w", path, err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to post %v: status %v", path, resp.Status)
+	}
+
+	return nil
+}
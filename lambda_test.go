@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLambdaRuntime(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ids := []string{"a", "b"}
+	posted := map[string]string{}
+	deadline := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/2018-06-01/runtime/invocation/next":
+			if len(ids) == 0 {
+				// no more invocations: the function is frozen until canceled
+				cancel()
+				<-r.Context().Done()
+				return
+			}
+			w.Header().Set("Lambda-Runtime-Aws-Request-Id", ids[0])
+			w.Header().Set("Lambda-Runtime-Deadline-Ms", strconv.FormatInt(deadline.UnixMilli(), 10))
+			ids = ids[1:]
+			_, _ = w.Write([]byte("{}"))
+		case r.Method == http.MethodPost:
+			b, _ := io.ReadAll(r.Body)
+			posted[r.URL.Path] = string(b)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	runtime := &lambdaRuntime{client: server.Client(), url: server.URL + "/2018-06-01/runtime"}
+	invocations := 0
+	err := runtime.serve(ctx, func(ctx context.Context) (any, error) {
+		invocations++
+		if d, ok := ctx.Deadline(); !ok || !d.Equal(deadline) {
+			t.Errorf("got deadline %v, want %v", d, deadline)
+		}
+		if invocations == 2 {
+			return nil, errors.New("throttled")
+		}
+		return lambdaResponse{Instances: 3, Skipped: 1}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response lambdaResponse
+	err = json.Unmarshal([]byte(posted["/2018-06-01/runtime/invocation/a/response"]), &response)
+	if err != nil || response != (lambdaResponse{Instances: 3, Skipped: 1}) {
+		t.Errorf("got response %+v (%v), want 3 instances and 1 skipped", response, err)
+	}
+	var lerr lambdaError
+	err = json.Unmarshal([]byte(posted["/2018-06-01/runtime/invocation/b/error"]), &lerr)
+	if err != nil || lerr.ErrorMessage != "throttled" {
+		t.Errorf("got error %+v (%v), want throttled", lerr, err)
+	}
+}
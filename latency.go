@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//nolint:gochecknoglobals
+var apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "aws_custom",
+	Subsystem: "rds",
+	Name:      "api_request_duration_seconds",
+	Help:      "Duration of the AWS API calls including the retries, with the request ID of the AWS call as exemplar",
+	Buckets:   prometheus.DefBuckets,
+},
+	[]string{"service", "operation"},
+)
+
+// latencyHandlerName names the handler of handleLatency, so that it is not
+// added twice to the copies of a session.
+const latencyHandlerName = "rdsmaxcon.latency"
+
+// handleLatency observes the duration of the completed requests of a session.
+// The exemplars carry the request ID of the call, which AWS support and
+// CloudTrail know it by, and the X-Ray trace ID of the call when it is traced.
+func handleLatency(sess *session.Session) {
+	sess.Handlers.Complete.RemoveByName(latencyHandlerName)
+	sess.Handlers.Complete.PushBackNamed(request.NamedHandler{Name: latencyHandlerName, Fn: func(r *request.Request) {
+		observer := apiRequestDuration.WithLabelValues(r.ClientInfo.ServiceName, r.Operation.Name)
+		exemplar := latencyExemplar(r)
+		if len(exemplar) == 0 {
+			observer.Observe(time.Since(r.Time).Seconds())
+			return
+		}
+		observer.(prometheus.ExemplarObserver).ObserveWithExemplar(time.Since(r.Time).Seconds(), exemplar) //nolint:forcetypeassert
+	}})
+}
+
+// latencyExemplar returns the labels of the exemplar of a request, empty
+// when it was not sent.
+func latencyExemplar(r *request.Request) prometheus.Labels {
+	labels := prometheus.Labels{}
+	if len(r.RequestID) != 0 {
+		labels["aws_request_id"] = r.RequestID
+	}
+	if r.HTTPRequest != nil {
+		if id := traceID(r.HTTPRequest.Header.Get("X-Amzn-Trace-Id")); len(id) != 0 {
+			labels["trace_id"] = id
+		}
+	}
+
+	return labels
+}
+
+// traceID returns the root trace ID of an X-Ray trace header such as
+// Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1,
+// keeping the exemplar under the 128 characters of OpenMetrics.
+func traceID(header string) string {
+	for _, part := range strings.Split(header, ";") {
+		if id, ok := strings.CutPrefix(strings.TrimSpace(part), "Root="); ok {
+			return id
+		}
+	}
+
+	return ""
+}
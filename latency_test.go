@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestHandleLatency(t *testing.T) {
+	apiRequestDuration.Reset()
+	t.Cleanup(apiRequestDuration.Reset)
+
+	// the handler of the copy replaces the one of the session
+	sess := newFakeRDS(t)
+	handleLatency(sess)
+	sess = sess.Copy()
+	handleLatency(sess)
+	sess.Handlers.Build.PushBack(func(r *request.Request) {
+		r.HTTPRequest.Header.Set("X-Amzn-Trace-Id", "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+	})
+
+	_, err := rds.New(sess).DescribeDBParametersWithContext(context.Background(), &rds.DescribeDBParametersInput{DBParameterGroupName: aws.String("group-1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(apiRequestDuration)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 1 || len(families[0].GetMetric()) != 1 {
+		t.Fatalf("got %v, want a series", families)
+	}
+	metric := families[0].GetMetric()[0]
+	labels := map[string]string{}
+	for _, label := range metric.GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+	if labels["service"] != "rds" || labels["operation"] != "DescribeDBParameters" {
+		t.Errorf("got labels %v", labels)
+	}
+	if got := metric.GetHistogram().GetSampleCount(); got != 1 {
+		t.Errorf("got %v samples, want 1", got)
+	}
+
+	exemplar := map[string]string{}
+	for _, bucket := range metric.GetHistogram().GetBucket() {
+		for _, label := range bucket.GetExemplar().GetLabel() {
+			exemplar[label.GetName()] = label.GetValue()
+		}
+	}
+	want := map[string]string{"aws_request_id": "1", "trace_id": "1-5759e988-bd862e3fe1be46a994272793"}
+	if len(exemplar) != len(want) || exemplar["aws_request_id"] != want["aws_request_id"] || exemplar["trace_id"] != want["trace_id"] {
+		t.Errorf("got exemplar %v, want %v", exemplar, want)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// elector elects a leader among the replicas with a lock item in a DynamoDB
+// table, whose hash key is the string "name". Only the leader collects, while
+// the standby serves the snapshots the leader saves to snapshot_file, so that
+// two replicas do not double the API load. The lock expires when the leader
+// stops renewing it.
+type elector struct {
+	svc      dynamodbiface.DynamoDBAPI
+	cfg      LeaderElectionConfig
+	identity string
+	leading  atomic.Bool
+	// expiresAt is when the lease last renewed by this replica expires, in
+	// Unix nanoseconds
+	expiresAt atomic.Int64
+
+	mu sync.Mutex
+	// gained is closed when the leadership is gained, and replaced
+	gained chan struct{}
+}
+
+//nolint:gochecknoglobals
+var leader *elector
+
+func newElector(cfg *Config) (*elector, error) {
+	identity := cfg.LeaderElection.Identity
+	if len(identity) == 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname: %w", err)
+		}
+		identity = hostname
+	}
+
+	sess := newSession(cfg, Target{Region: cfg.LeaderElection.Region})
+
+	return &elector{
+		svc:      dynamodb.New(sess),
+		cfg:      cfg.LeaderElection,
+		identity: identity,
+		gained:   make(chan struct{}),
+	}, nil
+}
+
+// IsLeader reports whether this replica collects, which is always the case
+// without leader election. A leader which fails to renew its lock keeps
+// leading until its lease expires, when a standby can take it over.
+func (e *elector) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+
+	return e.leading.Load() && clk.Now().UnixNano() < e.expiresAt.Load()
+}
+
+// Gained returns a channel closed when the leadership is gained, so that the
+// new leader collects right away rather than on the next tick. It is nil
+// without leader election.
+func (e *elector) Gained() <-chan struct{} {
+	if e == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.gained
+}
+
+// acquire tries to acquire or renew the lock once. It is called before the
+// collection starts, so that the leader takes the first snapshot at startup.
+// The leader only steps down when another replica holds the lock, or when
+// the renewals kept failing until its lease expired, so that a transient
+// error of DynamoDB does not stop the collection.
+func (e *elector) acquire(ctx context.Context) {
+	was := e.IsLeader()
+	leading, err := e.tryAcquire(ctx)
+	if err != nil && was {
+		slog.Warn("failed to renew leader lock: leading until the lease expires", "expires_at", time.Unix(0, e.expiresAt.Load()), "err", err)
+		return
+	}
+	if err != nil {
+		slog.Warn("failed to acquire leader lock", "err", err)
+	}
+	// a lease which expired without being renewed was lost too
+	if leading != was || leading != e.leading.Load() {
+		slog.Info("leadership changed", "leader", leading, "identity", e.identity)
+	}
+	if leading && !was {
+		e.mu.Lock()
+		close(e.gained)
+		e.gained = make(chan struct{})
+		e.mu.Unlock()
+	}
+	e.leading.Store(leading)
+	if leading {
+		leaderGauge.Set(1)
+	} else {
+		leaderGauge.Set(0)
+	}
+}
+
+// run renews or acquires the lock every third of the lease duration, after
+// the acquire done before the collection started.
+func (e *elector) run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.LeaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		e.acquire(ctx)
+	}
+}
+
+// tryAcquire takes the lock when it is free, expired or already ours, and
+// records when the lease expires.
+func (e *elector) tryAcquire(ctx context.Context) (bool, error) {
+	now := clk.Now()
+
+	_, err := e.svc.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(e.cfg.Table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"name":       {S: aws.String(e.cfg.LockName)},
+			"owner":      {S: aws.String(e.identity)},
+			"expires_at": {N: aws.String(strconv.FormatInt(now.Add(e.cfg.LeaseDuration).UnixMilli(), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#name) OR expires_at < :now OR #owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#name":  aws.String("name"),
+			"#owner": aws.String("owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now":   {N: aws.String(strconv.FormatInt(now.UnixMilli(), 10))},
+			":owner": {S: aws.String(e.identity)},
+		},
+	})
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to put lock item: %w", err)
+	}
+	e.expiresAt.Store(now.Add(e.cfg.LeaseDuration).UnixNano())
+
+	return true, nil
+}
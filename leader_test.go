@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeLockTable holds the lock item of a DynamoDB table, put on the condition
+// of the elector.
+type fakeLockTable struct {
+	dynamodbiface.DynamoDBAPI
+	mu        sync.Mutex
+	owner     string
+	expiresAt int64
+	// err fails the requests when set, such as a transient error
+	err error
+}
+
+func (f *fakeLockTable) PutItemWithContext(_ aws.Context, input *dynamodb.PutItemInput, _ ...request.Option) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	now, _ := strconv.ParseInt(aws.StringValue(input.ExpressionAttributeValues[":now"].N), 10, 64)
+	owner := aws.StringValue(input.ExpressionAttributeValues[":owner"].S)
+	if len(f.owner) != 0 && f.expiresAt >= now && f.owner != owner {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+
+	f.owner = aws.StringValue(input.Item["owner"].S)
+	f.expiresAt, _ = strconv.ParseInt(aws.StringValue(input.Item["expires_at"].N), 10, 64)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// expire makes the lock expire, as when its owner stopped renewing it.
+func (f *fakeLockTable) expire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.expiresAt = 0
+}
+
+func newTestElector(svc dynamodbiface.DynamoDBAPI, identity string) *elector {
+	return &elector{
+		svc:      svc,
+		cfg:      LeaderElectionConfig{Table: "lock", LockName: "exporter", LeaseDuration: time.Hour},
+		identity: identity,
+		gained:   make(chan struct{}),
+	}
+}
+
+func TestElector(t *testing.T) {
+	table := &fakeLockTable{}
+	a, b := newTestElector(table, "a"), newTestElector(table, "b")
+	ctx := context.Background()
+
+	a.acquire(ctx)
+	b.acquire(ctx)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("got leaders a %v and b %v, want a", a.IsLeader(), b.IsLeader())
+	}
+
+	// the leader renews its lock
+	a.acquire(ctx)
+	if !a.IsLeader() || table.owner != "a" {
+		t.Errorf("got the lock of %v, want a renewed", table.owner)
+	}
+
+	// the standby takes over the expired lock, waking the collection
+	gained := b.Gained()
+	table.expire()
+	b.acquire(ctx)
+	a.acquire(ctx)
+	if a.IsLeader() || !b.IsLeader() {
+		t.Errorf("got leaders a %v and b %v, want b", a.IsLeader(), b.IsLeader())
+	}
+	select {
+	case <-gained:
+	default:
+		t.Error("the gained leadership was not notified")
+	}
+	if b.Gained() == gained {
+		t.Error("got the closed channel, want a new one for the next gain")
+	}
+
+	// without leader election, the replica always collects
+	var none *elector
+	if !none.IsLeader() || none.Gained() != nil {
+		t.Error("got no leadership without leader election")
+	}
+}
+
+func TestElectorTransientErrors(t *testing.T) {
+	c := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	realClk := clk
+	clk = c
+	t.Cleanup(func() { clk = realClk })
+
+	table := &fakeLockTable{}
+	a, b := newTestElector(table, "a"), newTestElector(table, "b")
+	ctx := context.Background()
+	a.acquire(ctx)
+
+	// the leader keeps leading while its renewals fail
+	table.err = awserr.New(dynamodb.ErrCodeInternalServerError, "Internal server error", nil)
+	for i := 0; i < 2; i++ {
+		c.Advance(20 * time.Minute)
+		a.acquire(ctx)
+		if !a.IsLeader() {
+			t.Fatalf("got the leader stepping down after %v, want it to lead until its lease expires", 20*time.Minute*time.Duration(i+1))
+		}
+	}
+
+	// a renewal extends the lease
+	table.err = nil
+	a.acquire(ctx)
+	table.err = awserr.New(dynamodb.ErrCodeInternalServerError, "Internal server error", nil)
+	c.Advance(59 * time.Minute)
+	a.acquire(ctx)
+	if !a.IsLeader() {
+		t.Error("got the leader stepping down, want its renewed lease kept")
+	}
+	table.err = nil
+	b.acquire(ctx)
+	if b.IsLeader() {
+		t.Error("got the standby leading, want the lease of the leader kept")
+	}
+
+	// until the lease expires, when the standby takes over without overlap
+	table.err = awserr.New(dynamodb.ErrCodeInternalServerError, "Internal server error", nil)
+	c.Advance(time.Minute)
+	if a.IsLeader() {
+		t.Error("got the leader leading after its lease expired")
+	}
+	a.acquire(ctx)
+	table.err = nil
+	c.Advance(time.Millisecond)
+	b.acquire(ctx)
+	if a.IsLeader() || !b.IsLeader() {
+		t.Errorf("got leaders a %v and b %v, want b", a.IsLeader(), b.IsLeader())
+	}
+
+	// a lost lock steps down at once
+	table.expire()
+	a.acquire(ctx)
+	b.acquire(ctx)
+	if b.IsLeader() {
+		t.Error("got b leading, want it to step down when a took the lock")
+	}
+}
+
+func TestRunTargetLeader(t *testing.T) {
+	table := &fakeLockTable{}
+	other := newTestElector(table, "other")
+	other.acquire(context.Background())
+	e := newTestElector(table, "self")
+	// acquired before the collection starts, as by run
+	e.acquire(context.Background())
+	realLeader := leader
+	leader = e
+	t.Cleanup(func() { leader = realLeader })
+
+	cfg := testConfig(t, func(cfg *Config) { cfg.Interval = time.Minute })
+	c := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	collected := startTarget(t, cfg, c)
+
+	// the standby does not collect
+	c.BlockUntil(1)
+	expectNotCollected(t, collected)
+
+	// until it gains the leadership, without waiting for the next tick
+	table.expire()
+	e.acquire(context.Background())
+	expectCollected(t, collected)
+}
+
+func TestRunTargetLeaderStartup(t *testing.T) {
+	e := newTestElector(&fakeLockTable{}, "self")
+	e.acquire(context.Background())
+	realLeader := leader
+	leader = e
+	t.Cleanup(func() { leader = realLeader })
+
+	// the leader takes the first snapshot at startup
+	cfg := testConfig(t, func(cfg *Config) { cfg.Interval = time.Minute })
+	collected := startTarget(t, cfg, newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	expectCollected(t, collected)
+}
+
+func TestFollowLeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	cfg := testConfig(t, func(cfg *Config) {
+		cfg.SnapshotFile = path
+		cfg.LeaderElection = LeaderElectionConfig{Table: "lock", LockName: "exporter", LeaseDuration: time.Minute}
+	})
+	registerInstanceMetrics(prometheus.NewRegistry(), cfg.LabelNames())
+	r := newResults(cfg, nil)
+	store := &Store{}
+	ready := func() int {
+		rec := httptest.NewRecorder()
+		readyHandler(store).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/ready", nil))
+		return rec.Code
+	}
+
+	// the standby is not ready before the leader saved a snapshot
+	r.followLeader(cfg, store)
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("got status %v, want not ready", code)
+	}
+
+	key := cfg.Targets[0].key()
+	for _, identifiers := range [][]string{{"a01"}, {"a01", "b01"}} {
+		var infos []RDSInfo
+		for _, identifier := range identifiers {
+			infos = append(infos, RDSInfo{DBInstanceIdentifier: identifier, MaxConnections: "100"})
+		}
+		err := saveSnapshot(path, map[string][]RDSInfo{key: infos})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r.followLeader(cfg, store)
+		served, _ := store.Get()
+		if len(served) != len(identifiers) {
+			t.Errorf("got instances %+v, want %v", served, identifiers)
+		}
+		if code := ready(); code != http.StatusOK {
+			t.Errorf("got status %v, want ready", code)
+		}
+	}
+	// to be collected from when it becomes the leader
+	if len(r.byTarget[key]) != 2 {
+		t.Errorf("got results %+v, want the snapshot of the leader", r.byTarget)
+	}
+}
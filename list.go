@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// list prints the instances of a single discovery pass as a table.
+func list(ctx context.Context, w io.Writer, cfg *Config) error {
+	infos, err := collect(ctx, cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DBINSTANCEIDENTIFIER\tENGINE\tDBINSTANCECLASS\tDBPARAMETERGROUP\tMAX_CONNECTIONS")
+	for _, info := range infos {
+		maxConnections := info.MaxConnections
+		if len(info.SkipReason) != 0 {
+			maxConnections = "- (" + info.SkipReason + ")"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", info.DBInstanceIdentifier, info.DBEngine, info.DBInstanceClass, info.DBParameterGroupName, maxConnections)
+	}
+
+	err = tw.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to write table: %w", err)
+	}
+
+	return nil
+}
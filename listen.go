@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+// unixPrefix makes a listen address a Unix domain socket, such as
+// "unix:/run/exporter/metrics.sock", for the sidecars scraped over the
+// filesystem without opening a network port.
+const unixPrefix = "unix:"
+
+// socketMode lets the group of the exporter, such as a local agent, connect
+// to the socket.
+const socketMode fs.FileMode = 0o660
+
+// listen listens on a TCP address, or on a Unix domain socket with
+// unixPrefix. The socket left by a previous process is removed.
+func listen(address string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(address, unixPrefix)
+	if !ok {
+		l, err := net.Listen("tcp", address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen %v: %w", address, err)
+		}
+		return l, nil
+	}
+
+	if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		err = os.Remove(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %v: %w", path, err)
+		}
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to stat socket %v: %w", path, err)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen %v: %w", address, err)
+	}
+	err = os.Chmod(path, socketMode)
+	if err != nil {
+		l.Close()
+		return nil, fmt.Errorf("failed to chmod socket %v: %w", path, err)
+	}
+
+	return l, nil
+}
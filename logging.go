@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// logLevel is shared by the logger so that the level can be changed on
+// reload.
+//
+//nolint:gochecknoglobals
+var logLevel = new(slog.LevelVar)
+
+type LogConfig struct {
+	// Level is one of debug, info, warn and error
+	Level string `yaml:"level"`
+	// Format is logfmt or json
+	Format string `yaml:"format"`
+	// SkipInterval is how often a skipped instance is logged again when its
+	// reason did not change, 0 to log it on every snapshot
+	SkipInterval time.Duration `yaml:"skip_interval"`
+}
+
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", s, err)
+	}
+
+	return level, nil
+}
+
+func validateLogFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "logfmt", "json":
+		return nil
+	}
+
+	return fmt.Errorf("invalid log format %q: must be logfmt or json", format)
+}
+
+// setupLogger sets the default logger. The format can not be changed after
+// the first call, but the level can.
+func setupLogger(c LogConfig) {
+	logLevel.Set(mustParseLogLevel(c.Level))
+
+	opts := &slog.HandlerOptions{Level: logLevel}
+
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if strings.ToLower(c.Format) == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	}
+
+	slog.SetDefault(slog.New(handler))
+}
+
+// fatal logs at error level and exits, which is log.Fatal with slog.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// mustParseLogLevel is parseLogLevel for a validated configuration.
+func mustParseLogLevel(s string) slog.Level {
+	level, err := parseLogLevel(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return level
+}
@@ -1,18 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
 	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/rds"
-	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/postgresql"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/sync/errgroup"
 )
 
 type RDSInfo struct {
@@ -20,169 +29,817 @@ type RDSInfo struct {
 	DBInstanceClass      string
 	MaxConnections       string
 	DBEngine             string
+	DBParameterGroupName string
+	DBClusterIdentifier  string
+	Region               string
+	DatabaseConnections  *float64
+	DbiResourceID        string
+	// PerformanceInsightsEnabled is set when the instance has Performance
+	// Insights, whose DBLoad is the average active sessions with export_db_load
+	PerformanceInsightsEnabled bool
+	DBLoad                     *float64
+	// CertificateValidTill is the expiry of the server certificate, nil when
+	// it is not known yet, as of a creating instance
+	CertificateValidTill *time.Time
+	DBInstanceARN        string
+	// MaintenanceWindow is the preferred maintenance window in UTC, such as
+	// sun:05:00-sun:06:00
+	MaintenanceWindow string
+	// PendingMaintenanceActions are the number of pending maintenance actions
+	// of the instance and its cluster with export_maintenance, and
+	// PendingMaintenanceApplyDate the earliest date one of them is applied
+	PendingMaintenanceActions   int
+	PendingMaintenanceApplyDate *time.Time
+	// Labels are the target labels and the tag labels of the instance
+	Labels map[string]string
+	// SkipReason tells why the instance is not exported, empty when it is,
+	// and SkipCode is one of the exporter.Skip codes of the reason
+	SkipReason string
+	SkipCode   string
+	// Unsupported is set when the engine of the instance is not supported
+	Unsupported bool
+	// Resolution records how MaxConnections was resolved from the parameter
+	// group, nil when the engine is not supported or the instance is skipped
+	Resolution *maxcon.Resolution
+	// Overridden is set when MaxConnections comes from max_connections_overrides
+	Overridden bool
+	// AmbiguousParameterGroup is set when several parameter groups of the
+	// instance could be the one DBParameterGroupName was selected from
+	AmbiguousParameterGroup bool
+	// DBClusterParameterGroupName is the cluster parameter group
+	// max_connections is read from when DBParameterGroupName does not set it
+	DBClusterParameterGroupName string
+	// ServerlessMaxCapacity is the maximum capacity in ACUs of the cluster
+	// of an Aurora Serverless v2 instance, 0 else
+	ServerlessMaxCapacity float64
+	// Missed is the number of snapshots of its target the instance was not
+	// seen in, until series_ttl
+	Missed int
+	// PeakDatabaseConnections is the maximum DatabaseConnections over the
+	// lookback of the recommendations, nil when it is not known
+	PeakDatabaseConnections *float64
+	// SecondsUntilExhaustion is the estimated time until the connections
+	// reach max_connections with forecast, +Inf when they do not increase,
+	// nil when it is not known
+	SecondsUntilExhaustion *float64
+	// ConnectionsBaseline is the mean connections of the window of baseline,
+	// and ConnectionsDeviation how many standard deviations the latest
+	// DatabaseConnections are above it, nil when they are not known
+	ConnectionsBaseline  *float64
+	ConnectionsDeviation *float64
+	// Status is the status of the instance, such as available or stopped
+	Status string
+	// Endpoint and Port are the address of the instance, empty until it is
+	// created
+	Endpoint string
+	Port     int64
+	// MultiAZ is set when the instance has a standby in another zone
+	MultiAZ bool
+	// PendingDBInstanceClass is the class the instance is being modified to,
+	// applied in the next maintenance window, empty when there is none
+	PendingDBInstanceClass string
+	// PendingReboot is set when a parameter group of the instance has changes
+	// applied on the next reboot, such as of max_connections
+	PendingReboot bool
+	// HourlyPrice is the on-demand hourly price in USD of the class of the
+	// instance with export_cost_per_connection, nil when it is not known
+	HourlyPrice *float64
+	// ClusterEndpoints are the endpoints of the cluster of the instance, set
+	// with export_cluster_endpoints
+	ClusterEndpoints *ClusterEndpoints
+	// Err is the error of fetching the parameter group of a skipped instance
+	Err error `json:"-"`
 }
 
+//nolint:gochecknoglobals
 var (
-	//nolint:gochecknoglobals
-	maxcon = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "aws_custom",
-		Subsystem: "rds",
-		Name:      "max_connections",
-		Help:      "Max Connections of RDS",
-	},
-		[]string{"dbinstanceidentifier", "dbinstanceclass"},
-	)
+	maxconMetric            *instanceMetric
+	dbLoadMetric            *instanceMetric
+	certificateExpiryMetric *instanceMetric
+	// pendingMaintenanceMetric and pendingMaintenanceApplyMetric are the number
+	// and the earliest apply date of the pending maintenance actions
+	pendingMaintenanceMetric      *instanceMetric
+	pendingMaintenanceApplyMetric *instanceMetric
+	// maxconIsCustomMetric is 1 for the instances whose max_connections is
+	// not the engine default of their class
+	maxconIsCustomMetric *instanceMetric
+	// exhaustionMetric is the estimated time until the connections of the
+	// instances reach max_connections
+	exhaustionMetric *instanceMetric
+	// baselineMetric is the baseline utilization of the instances, and
+	// deviationMetric how far their connections are from it
+	baselineMetric  *instanceMetric
+	deviationMetric *instanceMetric
+	// costPerConnectionMetric is the hourly price of the instances per
+	// connection
+	costPerConnectionMetric *instanceMetric
+	// poolSizeMetric is the recommended size of the connection pools of the
+	// clients of the instances
+	poolSizeMetric *instanceMetric
 )
 
 func main() {
-	interval, err := getInterval()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	f := newFlags()
+	command, cfg, err := f.parse(os.Args[1:])
 	if err != nil {
-		log.Fatal(err)
+		fatal("failed to load config", "err", err)
+	}
+
+	if command == "validate" {
+		if !validateFile(os.Stdout, f.validatePath) {
+			os.Exit(1)
+		}
+		return
 	}
 
-	prometheus.MustRegister(maxcon)
+	setupLogger(cfg.Log)
+	f.warnDeprecated()
 
-	http.Handle("/metrics", promhttp.Handler())
+	if len(cfg.AuditLogFile) != 0 {
+		audit, err = openAuditLog(cfg.AuditLogFile)
+		if err != nil {
+			fatal("failed to open audit log", "err", err)
+		}
+		defer audit.Close()
+	}
 
-	go func() {
-		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	// the bootstrap of a custom runtime is run without arguments
+	if command == "run" && len(os.Getenv(lambdaRuntimeAPIEnvar)) != 0 {
+		command = "lambda"
+	}
 
-		// register metrics as background
-		for range ticker.C {
-			err := snapshot()
-			if err != nil {
-				log.Fatal(err)
-			}
+	switch command {
+	case "lambda":
+		err := runLambda(ctx, cfg)
+		if err != nil {
+			fatal("Lambda runtime stopped", "err", err)
+		}
+		return
+	case "list":
+		err := list(ctx, os.Stdout, cfg)
+		if err != nil {
+			fatal("failed to list instances", "err", err)
+		}
+		return
+	case "export":
+		err := export(ctx, os.Stdout, cfg, f.exportFormat)
+		if err != nil {
+			fatal("failed to export instances", "err", err)
+		}
+		return
+	case "report":
+		err := report(ctx, os.Stdout, cfg, f.reportFormat)
+		if err != nil {
+			fatal("failed to report instances", "err", err)
+		}
+		return
+	case "check":
+		err := check(ctx, os.Stdout, cfg, f.checkIdentifier)
+		if err != nil {
+			fatal("failed to check instance", "err", err)
+		}
+		return
+	case "generate alerts":
+		err := generateAlerts(os.Stdout, cfg, f.alerts)
+		if err != nil {
+			fatal("failed to generate alerts", "err", err)
 		}
-	}()
-	log.Fatal(http.ListenAndServe(":8080", nil))
+		return
+	case "generate dashboard":
+		err := generateDashboard(os.Stdout, cfg, f.dashboard)
+		if err != nil {
+			fatal("failed to generate dashboard", "err", err)
+		}
+		return
+	case "generate targets":
+		err := generateTargets(ctx, os.Stdout, cfg, f.targetsOutputDir)
+		if err != nil {
+			fatal("failed to generate targets", "err", err)
+		}
+		return
+	case "doctor":
+		outputs, err := getOutputs(cfg.Outputs)
+		if err != nil {
+			fatal("failed to create outputs", "err", err)
+		}
+		ok, err := doctor(ctx, os.Stdout, cfg, outputs)
+		if err != nil {
+			fatal("failed to check permissions", "err", err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if f.dryRun {
+		outputs, err := getOutputs(cfg.Outputs)
+		if err != nil {
+			fatal("failed to create outputs", "err", err)
+		}
+		if !dryRun(ctx, os.Stdout, cfg, outputs) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	err = run(ctx, f, cfg)
+	if err != nil {
+		fatal("exporter stopped", "err", err)
+	}
+	slog.Info("stopped")
 }
 
-func snapshot() error {
-	maxcon.Reset()
+// shutdownTimeout bounds the shutdown of the servers.
+const shutdownTimeout = 5 * time.Second
+
+// run serves the exporter until ctx is canceled or a server fails, then stops
+// the collection, its in-flight AWS requests and the servers together.
+func run(ctx context.Context, f *flags, cfg *Config) error {
+	slog.Info("starting", "version", version, "commit", commit, "date", date, "go", runtime.Version())
+
+	st, err := newState(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create outputs: %w", err)
+	}
+	defer st.close()
+	setStaleAfter(cfg)
+
+	store := &Store{}
+
+	registerInstanceMetrics(prometheus.DefaultRegisterer, cfg.LabelNames())
+	registerMetrics(prometheus.DefaultRegisterer)
+	configReloadSuccess.Set(1)
+
+	if f.once {
+		_, outputs := st.get()
+		return once(ctx, os.Stdout, cfg, store, outputs)
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+
+	eg.Go(func() error {
+		st.reloadOnSIGHUP(ctx, f)
+		return nil
+	})
+	if interval := f.refreshInterval(); interval > 0 && f.source != nil {
+		eg.Go(func() error {
+			st.refreshEvery(ctx, f, interval)
+			return nil
+		})
+	}
+	if f.watchConfig && (isConfigFile(f.source) || len(cfg.TargetFiles) != 0) {
+		err := st.reloadOnChange(ctx, f)
+		if err != nil {
+			return fmt.Errorf("failed to watch config file: %w", err)
+		}
+	}
+
+	if len(cfg.LeaderElection.Table) != 0 {
+		leader, err = newElector(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to create leader elector: %w", err)
+		}
+		leader.acquire(ctx)
+		eg.Go(func() error {
+			leader.run(ctx)
+			return nil
+		})
+	}
 
-	InstanceInfos, err := getRDSInstances()
+	eg.Go(func() error {
+		dog.run(ctx, st)
+		return nil
+	})
+
+	previous := restoreSnapshot(cfg, store)
+	eg.Go(func() error {
+		schedule(ctx, st, store, previous)
+		return nil
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Web.TelemetryPath, metricsHandler(prometheus.DefaultRegisterer, prometheus.DefaultGatherer, cfg.Web.OpenMetrics))
+	mux.Handle("/api/v1/instances", instancesHandler(store))
+	mux.Handle("/api/v1/recommendations", recommendationsHandler(st, store))
+	mux.Handle("/api/v1/stream", streamHandler(changes))
+	mux.Handle("/api/v1/sd", httpSDHandler(store))
+	mux.Handle("/debug/instances", debugInstancesHandler(store))
+	mux.Handle("/-/healthy", healthyHandler())
+	mux.Handle("/-/ready", readyHandler(store))
+
+	tlsConfig, err := serverTLSConfig(cfg.Web.TLS)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Handler:           requireClientCert(cfg.Web.TLS, requireSigV4(cfg.Web.SigV4, mux)),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	server.RegisterOnShutdown(changes.close)
+	l, err := listen(cfg.Web.ListenAddress)
 	if err != nil {
-		return fmt.Errorf("failed to read RDS Instance infos: %w", err)
+		return err
 	}
+	eg.Go(func() error {
+		var err error
+		if tlsConfig != nil {
+			err = server.ServeTLS(l, "", "")
+		} else {
+			err = server.Serve(l)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("HTTP server stopped: %w", err)
+		}
+		return nil
+	})
+	eg.Go(func() error {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return server.Shutdown(sctx) //nolint:contextcheck
+	})
+
+	if len(cfg.GRPC.ListenAddress) != 0 {
+		eg.Go(func() error {
+			return serveGRPC(ctx, cfg.GRPC.ListenAddress, store)
+		})
+	}
+
+	return eg.Wait() //nolint:wrapcheck
+}
 
-	for _, InstanceInfo := range InstanceInfos {
-		if InstanceInfo.MaxConnections == "0" {
-			log.Printf("skip: max connection is 0. dbinstanceidentifier: %v, dbinstanceclass: %v\n", InstanceInfo.DBInstanceIdentifier, InstanceInfo.DBInstanceClass)
-			break
+// snapshot collects all the targets and publishes them. The targets which
+// succeeded are published even when others failed.
+func snapshot(ctx context.Context, cfg *Config, store *Store, outputs []Output) error {
+	InstanceInfos, err := collect(ctx, cfg, outputs)
+	if len(InstanceInfos) != 0 || err == nil {
+		perr := publish(cfg, store, outputs, InstanceInfos)
+		if perr != nil {
+			return perr
+		}
+	}
+
+	return err
+}
+
+// publish replaces the served instances and metrics with infos, and writes
+// the exported ones to the outputs.
+func publish(cfg *Config, store *Store, outputs []Output, InstanceInfos []RDSInfo) error {
+	return publishCollected(cfg, store, outputs, InstanceInfos, nil)
+}
+
+// publishCollected publishes infos like publish, but writes to the outputs
+// which are not stateful only the exported instances for which collected
+// reports true, such as the ones of the target just collected, all of them
+// when collected is nil.
+func publishCollected(cfg *Config, store *Store, outputs []Output, InstanceInfos []RDSInfo, collected func(i int) bool) error {
+	previous, _ := store.Get()
+	countMaxConnectionsChanges(cfg, previous, InstanceInfos)
+	changes.publish(instanceChanges(previous, InstanceInfos, time.Now()))
+	store.Set(InstanceInfos)
+
+	labelNames := cfg.LabelNames()
+	exported := make([]RDSInfo, 0, len(InstanceInfos))
+	// the outputs are written the redacted instances
+	var written, writtenCollected []RDSInfo
+	samples := make([]instanceSample, 0, len(InstanceInfos))
+	var loadSamples, certificateSamples, maintenanceSamples, applySamples, customSamples, exhaustionSamples []instanceSample
+	var baselineSamples, deviationSamples, poolSizeSamples, costSamples []instanceSample
+	truncated := 0
+	setInstanceCount(InstanceInfos)
+	setSkippedInstances(InstanceInfos)
+
+	for i, InstanceInfo := range InstanceInfos {
+		if len(InstanceInfo.SkipReason) != 0 && !(InstanceInfo.Unsupported && cfg.ExportUnsupportedEngines) {
+			continue
+		}
+		if cfg.MaxInstances > 0 && len(samples) >= cfg.MaxInstances {
+			truncated++
+			continue
 		}
 
 		labels := prometheus.Labels{
 			"dbinstanceidentifier": InstanceInfo.DBInstanceIdentifier,
 			"dbinstanceclass":      InstanceInfo.DBInstanceClass,
 		}
+		for _, name := range labelNames {
+			labels[name] = InstanceInfo.Labels[name]
+		}
+		if cfg.ExportUnsupportedEngines {
+			labels[supportedLabel] = strconv.FormatBool(!InstanceInfo.Unsupported)
+		}
+		for name, value := range labels {
+			labels[name] = cfg.LabelValues.Sanitize(name, value)
+		}
+		if cfg.ExportDBLoad && InstanceInfo.DBLoad != nil {
+			loadSamples = append(loadSamples, instanceSample{labels: labels, value: *InstanceInfo.DBLoad})
+		}
+		if cfg.Forecast.Enabled && InstanceInfo.SecondsUntilExhaustion != nil {
+			exhaustionSamples = append(exhaustionSamples, instanceSample{labels: labels, value: *InstanceInfo.SecondsUntilExhaustion})
+		}
+		if cfg.ExportMaintenance {
+			maintenanceSamples = append(maintenanceSamples, instanceSample{labels: labels, value: float64(InstanceInfo.PendingMaintenanceActions)})
+			if InstanceInfo.PendingMaintenanceApplyDate != nil {
+				applySamples = append(applySamples, instanceSample{labels: labels, value: float64(InstanceInfo.PendingMaintenanceApplyDate.Unix())})
+			}
+		}
+		if InstanceInfo.CertificateValidTill != nil {
+			certificateSamples = append(certificateSamples, instanceSample{labels: labels, value: float64(InstanceInfo.CertificateValidTill.Unix())})
+		}
+		// the coverage gap is only shown by the metric, not by the outputs
+		if InstanceInfo.Unsupported {
+			samples = append(samples, instanceSample{labels: labels, value: 0})
+			continue
+		}
+
 		v, err := strconv.ParseFloat(InstanceInfo.MaxConnections, 64)
 		if err != nil {
 			return fmt.Errorf("failed to parse max connections to float64: %w", err)
 		}
 
-		maxcon.With(labels).Set(v)
+		samples = append(samples, instanceSample{labels: labels, value: v})
+		if cfg.Baseline.Enabled && InstanceInfo.ConnectionsBaseline != nil && InstanceInfo.ConnectionsDeviation != nil && v > 0 {
+			baselineSamples = append(baselineSamples, instanceSample{labels: labels, value: *InstanceInfo.ConnectionsBaseline / v})
+			deviationSamples = append(deviationSamples, instanceSample{labels: labels, value: *InstanceInfo.ConnectionsDeviation})
+		}
+		if cfg.ExportCostPerConnection && InstanceInfo.HourlyPrice != nil && v > 0 {
+			costSamples = append(costSamples, instanceSample{labels: labels, value: *InstanceInfo.HourlyPrice / v})
+		}
+		if size, ok := recommendedPoolSize(cfg.PoolSizing, InstanceInfo.DBInstanceIdentifier, int(v)); ok {
+			poolSizeSamples = append(poolSizeSamples, instanceSample{labels: labels, value: float64(size)})
+		}
+		if custom, ok := isCustomMaxConnections(InstanceInfo); ok {
+			sample := instanceSample{labels: labels}
+			if custom {
+				sample.value = 1
+			}
+			customSamples = append(customSamples, sample)
+		}
+		exported = append(exported, InstanceInfo)
+		redacted := cfg.LabelValues.redactInstance(InstanceInfo)
+		written = append(written, redacted)
+		if collected == nil || collected(i) {
+			writtenCollected = append(writtenCollected, redacted)
+		}
+	}
+
+	if truncated != 0 {
+		discoveryTruncated.Set(1)
+		slog.Warn("too many instances: the rest are not exported", "max_instances", cfg.MaxInstances, "truncated", truncated)
+	} else {
+		discoveryTruncated.Set(0)
+	}
+
+	maxconMetric.Update(labelNames, samples)
+	dbLoadMetric.Update(labelNames, loadSamples)
+	certificateExpiryMetric.Update(labelNames, certificateSamples)
+	pendingMaintenanceMetric.Update(labelNames, maintenanceSamples)
+	pendingMaintenanceApplyMetric.Update(labelNames, applySamples)
+	maxconIsCustomMetric.Update(labelNames, customSamples)
+	exhaustionMetric.Update(labelNames, exhaustionSamples)
+	baselineMetric.Update(labelNames, baselineSamples)
+	deviationMetric.Update(labelNames, deviationSamples)
+	poolSizeMetric.Update(labelNames, poolSizeSamples)
+	costPerConnectionMetric.Update(labelNames, costSamples)
+	updateMaintenanceWindowInfo(cfg, exported)
+	updateClusterEndpointInfo(cfg, exported)
+	setSnapshotTime(time.Now())
+
+	// an output failing does not fail the snapshot nor the other outputs
+	for _, output := range outputs {
+		infos := writtenCollected
+		if isStateful(output) {
+			infos = written
+		}
+		err := output.Write(infos)
+		if err != nil {
+			outputErrors.WithLabelValues(output.Name()).Inc()
+			slog.Error("failed to write to output", "output", output.Name(), "err", err)
+		}
 	}
 
 	return nil
 }
 
-func getInterval() (int, error) {
-	const defaultGithubAPIIntervalSecond = 300
-	githubAPIInterval := os.Getenv("AWS_API_INTERVAL")
-	if len(githubAPIInterval) == 0 {
-		return defaultGithubAPIIntervalSecond, nil
+// collect discovers the instances of all the targets concurrently, so that a
+// slow region does not delay the others. Instances that can not be exported
+// have SkipReason set. The instances of the targets which succeeded are
+// returned with the errors of the others joined.
+func collect(ctx context.Context, cfg *Config, outputs []Output) ([]RDSInfo, error) {
+	results := make([][]RDSInfo, len(cfg.Targets))
+	errs := make([]error, len(cfg.Targets))
+
+	var eg errgroup.Group
+	for i, target := range cfg.Targets {
+		i, target := i, target
+		eg.Go(func() error {
+			infos, err := collectTarget(ctx, cfg, target, outputs)
+			setTargetUp(target, err)
+			if err != nil {
+				errs[i] = fmt.Errorf("target %v: %w", targetName(target), err)
+				return nil
+			}
+			results[i] = infos
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	var InstanceInfos []RDSInfo
+	for _, infos := range results {
+		InstanceInfos = append(InstanceInfos, infos...)
+	}
+
+	return InstanceInfos, errors.Join(errs...)
+}
+
+func collectTarget(ctx context.Context, cfg *Config, target Target, outputs []Output) ([]RDSInfo, error) {
+	sess := newSession(cfg, target)
+
+	infos, err := readInstances(ctx, cfg, sess, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var secondaries []RDSInfo
+	if cfg.GlobalClusters.Enabled {
+		secondaries, err = collectGlobalClusters(ctx, cfg, target, outputs, sess, infos)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = enrichInstances(ctx, cfg, sess, outputs, infos)
+	if err != nil {
+		return nil, err
 	}
 
-	integerGithubAPIInterval, err := strconv.Atoi(githubAPIInterval)
+	return append(infos, secondaries...), nil
+}
+
+// readInstances collects the instances of a target in the region of the
+// session.
+func readInstances(ctx context.Context, cfg *Config, sess *session.Session, target Target) ([]RDSInfo, error) {
+	infos, err := getRDSInstances(ctx, newRDSAPI(sess), cfg, target)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read Datadog Config: %w", err)
+		return nil, fmt.Errorf("failed to read RDS Instance infos: %w", err)
+	}
+	for i := range infos {
+		infos[i].Region = aws.StringValue(sess.Config.Region)
 	}
 
-	return integerGithubAPIInterval, nil
+	return infos, nil
 }
 
-func getRDSInstances() ([]RDSInfo, error) {
-	var rawMaxConnections string
+// enrichInstances adds the values of the other APIs than the ones of
+// max_connections, which the enabled features need.
+func enrichInstances(ctx context.Context, cfg *Config, sess *session.Session, outputs []Output, infos []RDSInfo) error {
+	if cfg.ExportClusterEndpoints {
+		err := setClusterEndpoints(ctx, newRDSClusterAPI(sess), infos)
+		if err != nil {
+			return fmt.Errorf("failed to read cluster endpoints: %w", err)
+		}
+	}
+
+	if cfg.ExportMaintenance {
+		err := setPendingMaintenance(ctx, newRDSMaintenanceAPI(sess), infos)
+		if err != nil {
+			return fmt.Errorf("failed to read pending maintenance actions: %w", err)
+		}
+	}
+
+	if cfg.ExportDBLoad {
+		setDBLoad(ctx, newPIAPI(sess), infos, cfg.Concurrency)
+	}
+
+	if cfg.ExportCostPerConnection {
+		err := setHourlyPrices(ctx, newPricingAPI(sess), infos)
+		if err != nil {
+			// the instances are exported without cost
+			slog.Warn("failed to read prices", "region", aws.StringValue(sess.Config.Region), "err", err)
+		}
+	}
+
+	if cfg.Recommendations.Enabled {
+		err := setPeakConnections(ctx, newCloudWatchAPI(sess), cfg.Recommendations, infos)
+		if err != nil {
+			// the recommendations are made with the cached values
+			slog.Warn("failed to read peak connections", "region", aws.StringValue(sess.Config.Region), "err", err)
+		}
+	}
+
+	if cfg.Forecast.Enabled {
+		err := setConnectionsForecast(ctx, newCloudWatchAPI(sess), cfg.Forecast, infos)
+		if err != nil {
+			// the instances are exported without forecast
+			slog.Warn("failed to forecast connections", "region", aws.StringValue(sess.Config.Region), "err", err)
+		}
+	}
+
+	if needDatabaseConnections(outputs) {
+		err := setDatabaseConnections(ctx, sess, infos)
+		if err != nil {
+			// the instances are exported without utilization
+			slog.Warn("failed to read database connections", "region", aws.StringValue(sess.Config.Region), "err", err)
+		}
+	}
+
+	if cfg.Baseline.Enabled {
+		err := setConnectionsBaseline(ctx, newCloudWatchAPI(sess), cfg.Baseline, infos)
+		if err != nil {
+			// the deviations are exported with the cached baselines
+			slog.Warn("failed to read connections baseline", "region", aws.StringValue(sess.Config.Region), "err", err)
+		}
+	}
+
+	return nil
+}
+
+// newSession returns a session for the region of the target, assuming its
+// role when configured.
+func newSession(cfg *Config, target Target) *session.Session {
+	config := aws.Config{
+		// throttled requests are retried with a longer backoff than the other errors
+		Retryer: client.DefaultRetryer{
+			NumMaxRetries:    cfg.Throttling.MaxRetries,
+			MinThrottleDelay: cfg.Throttling.MinDelay,
+			MaxThrottleDelay: cfg.Throttling.MaxDelay,
+		},
+	}
+	if len(target.Region) != 0 {
+		config.Region = aws.String(target.Region)
+	}
+	if len(target.Endpoint) != 0 {
+		config.Endpoint = aws.String(target.Endpoint)
+	}
 
 	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		Config:            config,
 		SharedConfigState: session.SharedConfigEnable,
 	}))
+	// before the copy, so that the AssumeRole calls are audited and observed too
+	handleAudit(sess, "")
+	handleLatency(sess)
+
+	if len(target.RoleARN) != 0 {
+		creds := stscreds.NewCredentials(sess, target.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+			if len(target.ExternalID) != 0 {
+				p.ExternalID = aws.String(target.ExternalID)
+			}
+		})
+		sess = sess.Copy(&aws.Config{Credentials: creds})
+		handleAudit(sess, target.RoleARN)
+	}
+	handleThrottling(sess, cfg.Throttling, target)
+
+	return sess
+}
 
-	svc := rds.New(sess)
-	input := &rds.DescribeDBInstancesInput{}
+// newRDSAPI returns the RDS client of a session, replaced by a fake in the
+// tests.
+//
+//nolint:gochecknoglobals
+var newRDSAPI = func(sess *session.Session) exporter.RDSAPI {
+	return rds.New(sess)
+}
 
-	RDSInstances, err := svc.DescribeDBInstances(input)
+// getRDSInstances collects the instances of a target with the configured
+// filters, then applies the overrides and adds the labels.
+func getRDSInstances(ctx context.Context, svc exporter.RDSAPI, cfg *Config, target Target) ([]RDSInfo, error) {
+	opts := exporter.Options{
+		Filter: func(instance *rds.DBInstance) bool {
+			return cfg.Filters.Match(*instance.DBInstanceIdentifier, *instance.Engine) && target.MatchEngine(*instance.Engine) &&
+				cfg.Sharding.Owns(*instance.DBInstanceIdentifier)
+		},
+		SkipStopped: cfg.StoppedInstances == stoppedSkip,
+		Concurrency: cfg.Concurrency,
+		PageDelay:   cfg.Throttling.PageDelay,
+		Logger:      skipLogger(cfg, target),
+	}
+	if cfg.ParameterCacheTTL > 0 {
+		opts.Cache = targetParameterCache{store: parameterStoreFor(cfg), target: target, ttl: cfg.ParameterCacheTTL}
+	}
+
+	instances, err := exporter.NewCollector(svc, opts).Collect(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe DB instances: %w", err)
+		return nil, err //nolint:wrapcheck
 	}
 
-	RDSInfos := make([]RDSInfo, len(RDSInstances.DBInstances))
-	var maxConnections int
+	RDSInfos := make([]RDSInfo, 0, len(instances))
 
-	for i, RDSInstance := range RDSInstances.DBInstances {
-		for _, DBParameterGroup := range RDSInstance.DBParameterGroups {
-			rawMaxConnections, err = getRawMaxConnections(DBParameterGroup.DBParameterGroupName)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get Parameter Group: %w", err)
-			}
+	for _, instance := range instances {
+		RDSInstance := instance.DBInstance
+		maxConnections := instance.MaxConnections
+		skipReason, skipCode := instance.SkipReason, instance.SkipCode
+		unsupported := instance.Unsupported
+		var overridden bool
+
+		if instance.Err != nil {
+			instanceErrors.WithLabelValues(cfg.LabelValues.Sanitize("dbinstanceidentifier", *RDSInstance.DBInstanceIdentifier)).Inc()
 		}
 
-		if *RDSInstance.Engine == "aurora-postgresql" || *RDSInstance.Engine == "postgres" {
-			maxConnections, err = postgresql.GetPostgresMaxConnections(rawMaxConnections, RDSInstance.DBInstanceClass)
-			if err != nil {
-				log.Printf("skip: failed to get max connections: %v", err)
+		if v, ok := cfg.MaxConnectionsOverrides[*RDSInstance.DBInstanceIdentifier]; ok && !isSkippedStopped(cfg, RDSInstance) {
+			maxConnections, skipReason, skipCode, unsupported, overridden = v, "", "", false, true
+			slog.Debug("max connections overridden", "dbinstanceidentifier", *RDSInstance.DBInstanceIdentifier, "max_connections", v)
+		}
+
+		labels := make(map[string]string, len(target.Labels)+len(cfg.TagLabels))
+		for name, value := range target.Labels {
+			labels[name] = value
+		}
+		for _, tag := range RDSInstance.TagList {
+			if name, ok := cfg.TagLabels[aws.StringValue(tag.Key)]; ok {
+				labels[name] = aws.StringValue(tag.Value)
 			}
-		} else {
-			log.Printf("skip: unsupported engine: %v, DBInstanceIdentifier: %v", *RDSInstance.Engine, *RDSInstance.DBInstanceIdentifier)
+		}
+		if cfg.StoppedInstances == stoppedLabel {
+			labels[statusLabel] = aws.StringValue(RDSInstance.DBInstanceStatus)
 		}
 
-		RDSInfos[i] = RDSInfo{
+		RDSInfos = append(RDSInfos, RDSInfo{
 			DBInstanceIdentifier: *RDSInstance.DBInstanceIdentifier,
 			DBInstanceClass:      *RDSInstance.DBInstanceClass,
 			MaxConnections:       strconv.Itoa(maxConnections),
 			DBEngine:             *RDSInstance.Engine,
-		}
+			DBParameterGroupName: instance.DBParameterGroupName,
+			DBClusterIdentifier:  aws.StringValue(RDSInstance.DBClusterIdentifier),
+			DbiResourceID:        aws.StringValue(RDSInstance.DbiResourceId),
+			Labels:               labels,
+			SkipReason:           skipReason,
+			SkipCode:             skipCode,
+			Unsupported:          unsupported,
+			Resolution:           instance.Resolution,
+			Overridden:           overridden,
+			Err:                  instance.Err,
+
+			PerformanceInsightsEnabled:  aws.BoolValue(RDSInstance.PerformanceInsightsEnabled),
+			CertificateValidTill:        certificateValidTill(RDSInstance),
+			DBInstanceARN:               aws.StringValue(RDSInstance.DBInstanceArn),
+			MaintenanceWindow:           aws.StringValue(RDSInstance.PreferredMaintenanceWindow),
+			AmbiguousParameterGroup:     instance.AmbiguousParameterGroup,
+			DBClusterParameterGroupName: instance.DBClusterParameterGroupName,
+			ServerlessMaxCapacity:       instance.ServerlessMaxCapacity,
+			Status:                      aws.StringValue(RDSInstance.DBInstanceStatus),
+			Endpoint:                    endpointAddress(RDSInstance),
+			Port:                        endpointPort(RDSInstance),
+			MultiAZ:                     aws.BoolValue(RDSInstance.MultiAZ),
+			PendingDBInstanceClass:      pendingDBInstanceClass(RDSInstance),
+			PendingReboot:               isPendingReboot(RDSInstance),
+		})
 	}
 
 	return RDSInfos, nil
 }
 
-func getRawMaxConnections(parameterGroupName *string) (string, error) {
-	var ParameterInfos []*rds.DescribeDBParametersOutput
-	var rawMaxConenctions string
+func endpointAddress(instance *rds.DBInstance) string {
+	if instance.Endpoint == nil {
+		return ""
+	}
 
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
+	return aws.StringValue(instance.Endpoint.Address)
+}
 
-	svc := rds.New(sess)
-	input := &rds.DescribeDBParametersInput{
-		DBParameterGroupName: parameterGroupName,
+func endpointPort(instance *rds.DBInstance) int64 {
+	if instance.Endpoint == nil {
+		return 0
 	}
 
-	for {
-		result, err := svc.DescribeDBParameters(input)
-		if err != nil {
-			return "", fmt.Errorf("failed to describe DB instances: %w", err)
-		}
+	return aws.Int64Value(instance.Endpoint.Port)
+}
+
+func pendingDBInstanceClass(instance *rds.DBInstance) string {
+	if instance.PendingModifiedValues == nil {
+		return ""
+	}
 
-		ParameterInfos = append(ParameterInfos, result)
+	return aws.StringValue(instance.PendingModifiedValues.DBInstanceClass)
+}
 
-		// pagination
-		if result.Marker == nil {
-			break
+func isPendingReboot(instance *rds.DBInstance) bool {
+	for _, group := range instance.DBParameterGroups {
+		if aws.StringValue(group.ParameterApplyStatus) == "pending-reboot" {
+			return true
 		}
-		input.SetMarker(*result.Marker)
 	}
 
-	for _, ParameterInfo := range ParameterInfos {
-		for _, Parameter := range ParameterInfo.Parameters {
-			if *Parameter.ParameterName == "max_connections" {
-				rawMaxConenctions = *Parameter.ParameterValue
-			}
-		}
+	return false
+}
+
+func certificateValidTill(instance *rds.DBInstance) *time.Time {
+	if instance.CertificateDetails == nil {
+		return nil
 	}
 
-	return rawMaxConenctions, nil
+	return instance.CertificateDetails.ValidTill
+}
+
+// isSkippedStopped reports whether an instance is stopped and the stopped
+// instances are skipped, in which case its parameter groups are not fetched.
+func isSkippedStopped(cfg *Config, instance *rds.DBInstance) bool {
+	return cfg.StoppedInstances == stoppedSkip && aws.StringValue(instance.DBInstanceStatus) == "stopped"
 }
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter/exportertest"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	benchInstances          = 3000
+	benchInstancesPerPage   = 100
+	benchParameterGroups    = 10
+	benchParameters         = 400
+	benchParametersPerPage  = 100
+	benchMaxConnectionsPage = 1
+)
+
+// newFakeRDS serves DescribeDBInstances and DescribeDBParameters with a fleet
+// of benchInstances using benchParameterGroups parameter groups, in pages as
+// large as the ones of the real API.
+func newFakeRDS(tb testing.TB) *session.Session {
+	tb.Helper()
+
+	var instancePages []string
+	for page := 0; page < benchInstances/benchInstancesPerPage; page++ {
+		var b strings.Builder
+		b.WriteString(`<DescribeDBInstancesResponse xmlns="http://rds.amazonaws.com/doc/2014-10-31/"><DescribeDBInstancesResult>`)
+		if page+1 < benchInstances/benchInstancesPerPage {
+			fmt.Fprintf(&b, "<Marker>%d</Marker>", page+1)
+		}
+		b.WriteString("<DBInstances>")
+		for i := page * benchInstancesPerPage; i < (page+1)*benchInstancesPerPage; i++ {
+			fmt.Fprintf(&b, `<DBInstance><DBInstanceIdentifier>postgres-%04d</DBInstanceIdentifier><DBInstanceClass>db.r5.large</DBInstanceClass>`+
+				`<Engine>aurora-postgresql</Engine><DBInstanceStatus>available</DBInstanceStatus><EngineVersion>11.9</EngineVersion>`+
+				`<DBParameterGroups><DBParameterGroup><DBParameterGroupName>group-%d</DBParameterGroupName><ParameterApplyStatus>in-sync</ParameterApplyStatus></DBParameterGroup></DBParameterGroups>`+
+				`<TagList><Tag><Key>Team</Key><Value>team-%d</Value></Tag></TagList></DBInstance>`, i, i%benchParameterGroups, i%7)
+		}
+		b.WriteString("</DBInstances></DescribeDBInstancesResult><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></DescribeDBInstancesResponse>")
+		instancePages = append(instancePages, b.String())
+	}
+
+	var parameterPages []string
+	for page := 0; page < benchParameters/benchParametersPerPage; page++ {
+		var b strings.Builder
+		b.WriteString(`<DescribeDBParametersResponse xmlns="http://rds.amazonaws.com/doc/2014-10-31/"><DescribeDBParametersResult>`)
+		if page+1 < benchParameters/benchParametersPerPage {
+			fmt.Fprintf(&b, "<Marker>%d</Marker>", page+1)
+		}
+		b.WriteString("<Parameters>")
+		for i := 0; i < benchParametersPerPage; i++ {
+			name := fmt.Sprintf("parameter_%d_%d", page, i)
+			value := "on"
+			if page == benchMaxConnectionsPage && i == benchParametersPerPage/2 {
+				name, value = "max_connections", "LEAST({DBInstanceClassMemory/9531392},5000)"
+			}
+			fmt.Fprintf(&b, "<Parameter><ParameterName>%v</ParameterName><ParameterValue>%v</ParameterValue>"+
+				"<Description>A parameter of the benchmark with a description as long as the real ones.</Description>"+
+				"<Source>engine-default</Source><ApplyType>static</ApplyType><DataType>string</DataType><IsModifiable>true</IsModifiable></Parameter>", name, value)
+		}
+		b.WriteString("</Parameters></DescribeDBParametersResult><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></DescribeDBParametersResponse>")
+		parameterPages = append(parameterPages, b.String())
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		page, _ := strconv.Atoi(r.PostForm.Get("Marker"))
+
+		w.Header().Set("Content-Type", "text/xml")
+		w.Header().Set("X-Amzn-Requestid", "1")
+		switch r.PostForm.Get("Action") {
+		case "DescribeDBInstances":
+			fmt.Fprint(w, instancePages[page])
+		case "DescribeDBParameters":
+			fmt.Fprint(w, parameterPages[page])
+		default:
+			http.Error(w, "unknown action", http.StatusBadRequest)
+		}
+	}))
+	tb.Cleanup(server.Close)
+
+	return session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("ap-northeast-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+}
+
+func BenchmarkGetRawMaxConnections(b *testing.B) {
+	collector := exporter.NewCollector(newRDSAPI(newFakeRDS(b)), exporter.Options{})
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		raw, err := collector.RawMaxConnections(context.Background(), "group-0")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if raw != "LEAST({DBInstanceClassMemory/9531392},5000)" {
+			b.Fatalf("unexpected max_connections: %q", raw)
+		}
+	}
+}
+
+func BenchmarkGetRDSInstances(b *testing.B) {
+	svc := newRDSAPI(newFakeRDS(b))
+	cfg := defaultConfig()
+	cfg.TagLabels = map[string]string{"Team": "team"}
+	err := cfg.validate()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		infos, err := getRDSInstances(context.Background(), svc, cfg, cfg.Targets[0])
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(infos) != benchInstances {
+			b.Fatalf("unexpected number of instances: %d", len(infos))
+		}
+	}
+}
+
+func stoppedInstance(identifier, engine, group string) *rds.DBInstance {
+	instance := exportertest.Instance(identifier, engine, group)
+	instance.DBInstanceStatus = aws.String("stopped")
+
+	return instance
+}
+
+func testConfig(t *testing.T, modify func(*Config)) *Config {
+	t.Helper()
+
+	cfg := defaultConfig()
+	if modify != nil {
+		modify(cfg)
+	}
+	err := cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cfg
+}
+
+// publishRedacted publishes an instance of a tenant, tenant-a01 of the
+// cluster tenant-a with the parameter group tenant-a-params, at 1700 of 1800
+// connections, to an output with its names hashed. Every string field
+// written to the outputs is set, so that no "tenant" may be written. The
+// hashed identifier is returned.
+func publishRedacted(t *testing.T, output Output) string {
+	t.Helper()
+
+	cfg := testConfig(t, func(cfg *Config) {
+		cfg.LabelValues = LabelValuesConfig{
+			Redact:  []LabelRedact{{Labels: []string{"dbinstanceidentifier", "dbclusteridentifier", "dbparametergroupname"}, Regex: "^tenant-"}},
+			HashKey: "key",
+		}
+	})
+	registerInstanceMetrics(prometheus.NewRegistry(), cfg.LabelNames())
+	err := publish(cfg, &Store{}, []Output{output}, []RDSInfo{{
+		Region:               "ap-northeast-1",
+		DBInstanceIdentifier: "tenant-a01",
+		DBInstanceClass:      "db.r5.large",
+		DBEngine:             "postgres",
+		DBParameterGroupName: "tenant-a-params",
+		DBClusterIdentifier:  "tenant-a",
+		MaxConnections:       "1800",
+		DatabaseConnections:  aws.Float64(1700),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cfg.LabelValues.Sanitize("dbinstanceidentifier", "tenant-a01")
+}
+
+func infosByIdentifier(infos []RDSInfo) map[string]RDSInfo {
+	ret := make(map[string]RDSInfo, len(infos))
+	for _, info := range infos {
+		ret[info.DBInstanceIdentifier] = info
+	}
+
+	return ret
+}
+
+func TestGetRDSInstancesPagination(t *testing.T) {
+	svc := &exportertest.RDS{
+		InstancePages: [][]*rds.DBInstance{
+			{exportertest.Instance("a", "postgres", "group-1"), exportertest.Instance("b", "postgres", "group-1")},
+			{exportertest.Instance("c", "aurora-postgresql", "group-2")},
+		},
+		ParameterPages: map[string][][]*rds.Parameter{
+			"group-1": {
+				{{ParameterName: aws.String("shared_buffers"), ParameterValue: aws.String("{DBInstanceClassMemory/32768}")}},
+				{{ParameterName: aws.String("max_connections"), ParameterValue: aws.String("100")}},
+				{{ParameterName: aws.String("work_mem"), ParameterValue: aws.String("4096")}},
+			},
+			"group-2": exportertest.Parameters("200"),
+		},
+	}
+	cfg := testConfig(t, nil)
+
+	infos, err := getRDSInstances(context.Background(), svc, cfg, cfg.Targets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := infosByIdentifier(infos)
+	for identifier, want := range map[string]string{"a": "100", "b": "100", "c": "200"} {
+		info, ok := got[identifier]
+		if !ok {
+			t.Errorf("instance %v is missing", identifier)
+			continue
+		}
+		if info.MaxConnections != want || info.SkipReason != "" {
+			t.Errorf("instance %v: max connections %v (%q), want %v", identifier, info.MaxConnections, info.SkipReason, want)
+		}
+	}
+	if len(infos) != 3 {
+		t.Errorf("got %d instances, want 3", len(infos))
+	}
+
+	// the group is fetched once for its two instances, and the last page
+	// is not fetched once max_connections is found
+	if n := svc.ParameterPagesFetched("group-1"); n != 2 {
+		t.Errorf("fetched %d pages of group-1, want 2", n)
+	}
+}
+
+func TestGetRDSInstancesSkips(t *testing.T) {
+	tests := []struct {
+		name        string
+		instance    *rds.DBInstance
+		parameters  string
+		modify      func(*Config)
+		missing     bool
+		skipped     bool
+		unsupported bool
+		want        string
+	}{
+		{
+			name:       "explicit value",
+			instance:   exportertest.Instance("db", "postgres", "group"),
+			parameters: "100",
+			want:       "100",
+		},
+		{
+			name:        "unsupported engine",
+			instance:    exportertest.Instance("db", "mysql", "group"),
+			parameters:  "100",
+			skipped:     true,
+			unsupported: true,
+			want:        "0",
+		},
+		{
+			name:       "zero max connections",
+			instance:   exportertest.Instance("db", "postgres", "group"),
+			parameters: "0",
+			skipped:    true,
+			want:       "0",
+		},
+		{
+			name:       "unparsable max connections",
+			instance:   exportertest.Instance("db", "postgres", "group"),
+			parameters: "{DBInstanceClassMemory/0}",
+			skipped:    true,
+			want:       "0",
+		},
+		{
+			name:       "stopped instance exported",
+			instance:   stoppedInstance("db", "postgres", "group"),
+			parameters: "100",
+			want:       "100",
+		},
+		{
+			name:       "stopped instance skipped",
+			instance:   stoppedInstance("db", "postgres", "group"),
+			parameters: "100",
+			modify:     func(cfg *Config) { cfg.StoppedInstances = stoppedSkip },
+			skipped:    true,
+			want:       "0",
+		},
+		{
+			name:       "excluded instance",
+			instance:   exportertest.Instance("db", "postgres", "group"),
+			parameters: "100",
+			modify:     func(cfg *Config) { cfg.Filters.Exclude = []string{"^db$"} },
+			missing:    true,
+		},
+		{
+			name:       "other engine of the target",
+			instance:   exportertest.Instance("db", "postgres", "group"),
+			parameters: "100",
+			modify:     func(cfg *Config) { cfg.Targets = []Target{{Engines: []string{"aurora-postgresql"}}} },
+			missing:    true,
+		},
+		{
+			name:       "overridden unsupported engine",
+			instance:   exportertest.Instance("db", "mysql", "group"),
+			parameters: "100",
+			modify:     func(cfg *Config) { cfg.MaxConnectionsOverrides = map[string]int{"db": 300} },
+			want:       "300",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &exportertest.RDS{
+				InstancePages:  [][]*rds.DBInstance{{tt.instance}},
+				ParameterPages: map[string][][]*rds.Parameter{"group": exportertest.Parameters(tt.parameters)},
+			}
+			cfg := testConfig(t, tt.modify)
+
+			infos, err := getRDSInstances(context.Background(), svc, cfg, cfg.Targets[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.missing {
+				if len(infos) != 0 {
+					t.Fatalf("got %d instances, want none", len(infos))
+				}
+				return
+			}
+			if len(infos) != 1 {
+				t.Fatalf("got %d instances, want 1", len(infos))
+			}
+			info := infos[0]
+			if skipped := info.SkipReason != ""; skipped != tt.skipped {
+				t.Errorf("skip reason %q, want skipped %v", info.SkipReason, tt.skipped)
+			}
+			if info.Unsupported != tt.unsupported {
+				t.Errorf("unsupported %v, want %v", info.Unsupported, tt.unsupported)
+			}
+			if info.MaxConnections != tt.want {
+				t.Errorf("max connections %v, want %v", info.MaxConnections, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRDSInstancesErrors(t *testing.T) {
+	t.Run("describe DB instances", func(t *testing.T) {
+		errThrottled := awserr.New("Throttling", "Rate exceeded", nil)
+		svc := &exportertest.RDS{InstancesErr: errThrottled}
+		cfg := testConfig(t, nil)
+
+		_, err := getRDSInstances(context.Background(), svc, cfg, cfg.Targets[0])
+		if !errors.Is(err, errThrottled) {
+			t.Fatalf("got error %v, want %v", err, errThrottled)
+		}
+	})
+
+	t.Run("describe DB parameters", func(t *testing.T) {
+		errDenied := awserr.New("AccessDenied", "not authorized to perform rds:DescribeDBParameters", nil)
+		svc := &exportertest.RDS{
+			InstancePages: [][]*rds.DBInstance{{
+				exportertest.Instance("denied", "postgres", "denied-group"),
+				exportertest.Instance("allowed", "postgres", "group"),
+			}},
+			ParameterPages: map[string][][]*rds.Parameter{"group": exportertest.Parameters("100")},
+			ParametersErr:  map[string]error{"denied-group": errDenied},
+		}
+		cfg := testConfig(t, nil)
+
+		infos, err := getRDSInstances(context.Background(), svc, cfg, cfg.Targets[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// only the instances of the group which could not be fetched are skipped
+		got := infosByIdentifier(infos)
+		if denied := got["denied"]; !errors.Is(denied.Err, errDenied) || denied.SkipReason == "" || denied.MaxConnections != "0" {
+			t.Errorf("denied instance: err %v, skip reason %q, max connections %v", denied.Err, denied.SkipReason, denied.MaxConnections)
+		}
+		if allowed := got["allowed"]; allowed.Err != nil || allowed.MaxConnections != "100" {
+			t.Errorf("allowed instance: err %v, max connections %v", allowed.Err, allowed.MaxConnections)
+		}
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		svc := &exportertest.RDS{
+			InstancePages:  [][]*rds.DBInstance{{exportertest.Instance("db", "postgres", "group")}, {}},
+			ParameterPages: map[string][][]*rds.Parameter{"group": exportertest.Parameters("100")},
+		}
+		cfg := testConfig(t, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := getRDSInstances(ctx, svc, cfg, cfg.Targets[0])
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got error %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestCheck(t *testing.T) {
+	svc := &exportertest.RDS{
+		InstancePages:  [][]*rds.DBInstance{{exportertest.Instance("db", "postgres", "group")}},
+		ParameterPages: map[string][][]*rds.Parameter{"group": exportertest.Parameters("100")},
+	}
+	newRDS := newRDSAPI
+	newRDSAPI = func(*session.Session) exporter.RDSAPI { return svc }
+	t.Cleanup(func() { newRDSAPI = newRDS })
+	cfg := testConfig(t, nil)
+
+	var b strings.Builder
+	err := check(context.Background(), &b, cfg, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "Result: max_connections = 100\n") {
+		t.Errorf("unexpected output:\n%v", b.String())
+	}
+
+	err = check(context.Background(), &b, cfg, "missing")
+	if err == nil {
+		t.Error("got no error for a missing instance")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//nolint:gochecknoglobals
+var maintenanceWindowInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "aws_custom",
+	Subsystem: "rds",
+	Name:      "maintenance_window_info",
+	Help:      "Preferred maintenance window of the exported instances in UTC, always 1",
+},
+	[]string{"dbinstanceidentifier", "window"},
+)
+
+// rdsMaintenanceAPI is the part of the RDS API describing the pending
+// maintenance actions.
+type rdsMaintenanceAPI interface {
+	DescribePendingMaintenanceActionsPagesWithContext(ctx context.Context, input *rds.DescribePendingMaintenanceActionsInput, fn func(*rds.DescribePendingMaintenanceActionsOutput, bool) bool, opts ...request.Option) error
+}
+
+// newRDSMaintenanceAPI returns the RDS client describing the pending
+// maintenance actions, replaced by a fake in the tests.
+//
+//nolint:gochecknoglobals
+var newRDSMaintenanceAPI = func(sess *session.Session) rdsMaintenanceAPI {
+	return rds.New(sess)
+}
+
+// setPendingMaintenance counts the pending maintenance actions of each
+// instance, including the ones of its cluster such as the engine upgrades of
+// Aurora, and sets the earliest date one of them is applied.
+func setPendingMaintenance(ctx context.Context, svc rdsMaintenanceAPI, infos []RDSInfo) error {
+	actions := map[string][]*rds.PendingMaintenanceAction{}
+	err := svc.DescribePendingMaintenanceActionsPagesWithContext(ctx, &rds.DescribePendingMaintenanceActionsInput{}, func(page *rds.DescribePendingMaintenanceActionsOutput, lastPage bool) bool {
+		for _, resource := range page.PendingMaintenanceActions {
+			arn := aws.StringValue(resource.ResourceIdentifier)
+			actions[arn] = append(actions[arn], resource.PendingMaintenanceActionDetails...)
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe pending maintenance actions: %w", err)
+	}
+
+	for i := range infos {
+		info := &infos[i]
+		pending := actions[info.DBInstanceARN]
+		if clusterARN := instanceClusterARN(info.DBInstanceARN, info.DBClusterIdentifier); len(clusterARN) != 0 {
+			pending = append(pending, actions[clusterARN]...)
+		}
+
+		info.PendingMaintenanceActions = len(pending)
+		info.PendingMaintenanceApplyDate = nil
+		for _, action := range pending {
+			at := applyDate(action)
+			if at != nil && (info.PendingMaintenanceApplyDate == nil || at.Before(*info.PendingMaintenanceApplyDate)) {
+				info.PendingMaintenanceApplyDate = at
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyDate returns when a pending maintenance action is applied: the date
+// it is scheduled for when opted in, else the earliest of the date it is
+// automatically applied in the maintenance window after and the date it is
+// forced at. It is nil when the action is only applied on demand.
+func applyDate(action *rds.PendingMaintenanceAction) *time.Time {
+	if action.CurrentApplyDate != nil {
+		return action.CurrentApplyDate
+	}
+
+	var earliest *time.Time
+	for _, at := range []*time.Time{action.AutoAppliedAfterDate, action.ForcedApplyDate} {
+		if at != nil && (earliest == nil || at.Before(*earliest)) {
+			earliest = at
+		}
+	}
+
+	return earliest
+}
+
+// instanceClusterARN returns the ARN of the cluster of an instance from the
+// ARN of the instance, empty when it is not in a cluster.
+func instanceClusterARN(instanceARN, cluster string) string {
+	i := strings.LastIndex(instanceARN, ":db:")
+	if i < 0 || len(cluster) == 0 {
+		return ""
+	}
+
+	return instanceARN[:i] + ":cluster:" + cluster
+}
+
+// updateMaintenanceWindowInfo replaces the series of the maintenance window
+// info metric with the windows of the exported instances, none when disabled
+// by a reload.
+func updateMaintenanceWindowInfo(cfg *Config, infos []RDSInfo) {
+	maintenanceWindowInfo.Reset()
+	if !cfg.ExportMaintenance {
+		return
+	}
+	for _, info := range infos {
+		if len(info.MaintenanceWindow) != 0 {
+			maintenanceWindowInfo.WithLabelValues(cfg.LabelValues.Sanitize("dbinstanceidentifier", info.DBInstanceIdentifier), info.MaintenanceWindow).Set(1)
+		}
+	}
+}
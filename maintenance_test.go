@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// fakeMaintenance serves the pending maintenance actions in a page per
+// resource.
+type fakeMaintenance struct {
+	resources []*rds.ResourcePendingMaintenanceActions
+}
+
+func (f *fakeMaintenance) DescribePendingMaintenanceActionsPagesWithContext(ctx context.Context, input *rds.DescribePendingMaintenanceActionsInput, fn func(*rds.DescribePendingMaintenanceActionsOutput, bool) bool, opts ...request.Option) error {
+	for i, resource := range f.resources {
+		page := &rds.DescribePendingMaintenanceActionsOutput{PendingMaintenanceActions: []*rds.ResourcePendingMaintenanceActions{resource}}
+		if !fn(page, i == len(f.resources)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func TestSetPendingMaintenance(t *testing.T) {
+	day := func(d int) *time.Time { return aws.Time(time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)) }
+	svc := &fakeMaintenance{resources: []*rds.ResourcePendingMaintenanceActions{
+		{ResourceIdentifier: aws.String("arn:aws:rds:us-east-1:123456789012:db:single"), PendingMaintenanceActionDetails: []*rds.PendingMaintenanceAction{
+			{Action: aws.String("system-update"), AutoAppliedAfterDate: day(20), ForcedApplyDate: day(10)},
+			// opted in, the scheduled date wins over the later forced one
+			{Action: aws.String("os-upgrade"), CurrentApplyDate: day(15), ForcedApplyDate: day(30)},
+		}},
+		{ResourceIdentifier: aws.String("arn:aws:rds:us-east-1:123456789012:cluster:aurora"), PendingMaintenanceActionDetails: []*rds.PendingMaintenanceAction{
+			{Action: aws.String("db-upgrade"), AutoAppliedAfterDate: day(5)},
+		}},
+		{ResourceIdentifier: aws.String("arn:aws:rds:us-east-1:123456789012:db:on-demand"), PendingMaintenanceActionDetails: []*rds.PendingMaintenanceAction{
+			{Action: aws.String("ca-certificate-rotation")},
+		}},
+	}}
+	infos := []RDSInfo{
+		{DBInstanceIdentifier: "single", DBInstanceARN: "arn:aws:rds:us-east-1:123456789012:db:single"},
+		{DBInstanceIdentifier: "aurora-a01", DBInstanceARN: "arn:aws:rds:us-east-1:123456789012:db:aurora-a01", DBClusterIdentifier: "aurora"},
+		{DBInstanceIdentifier: "on-demand", DBInstanceARN: "arn:aws:rds:us-east-1:123456789012:db:on-demand"},
+		{DBInstanceIdentifier: "none", DBInstanceARN: "arn:aws:rds:us-east-1:123456789012:db:none"},
+	}
+
+	err := setPendingMaintenance(context.Background(), svc, infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		actions int
+		apply   *time.Time
+	}{
+		{actions: 2, apply: day(10)},
+		{actions: 1, apply: day(5)},
+		{actions: 1},
+		{actions: 0},
+	}
+	for i, tt := range tests {
+		info := infos[i]
+		if info.PendingMaintenanceActions != tt.actions {
+			t.Errorf("%v: got %v actions, want %v", info.DBInstanceIdentifier, info.PendingMaintenanceActions, tt.actions)
+		}
+		if (info.PendingMaintenanceApplyDate == nil) != (tt.apply == nil) || tt.apply != nil && !info.PendingMaintenanceApplyDate.Equal(*tt.apply) {
+			t.Errorf("%v: got apply date %v, want %v", info.DBInstanceIdentifier, info.PendingMaintenanceApplyDate, tt.apply)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//nolint:gochecknoglobals
+var (
+	snapshotErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "snapshot_errors_total",
+		Help:      "Number of snapshots that failed, keeping the previous values",
+	})
+	snapshotTimeouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "snapshot_timeouts_total",
+		Help:      "Number of snapshots aborted by the snapshot timeout",
+	})
+	outputErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "output_errors_total",
+		Help:      "Number of failed writes to an output",
+	},
+		[]string{"output"},
+	)
+	lastSnapshotSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "last_snapshot_success_timestamp_seconds",
+		Help:      "Unix time of the last successful snapshot",
+	})
+	parameterCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "parameter_cache_requests_total",
+		Help:      "Number of parameter group lookups in the parameter cache, by result: hit, miss, changed or error, when Redis fails",
+	},
+		[]string{"result"},
+	)
+	instanceErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "instance_errors_total",
+		Help:      "Number of times an instance was skipped because its parameter group could not be fetched",
+	},
+		[]string{"dbinstanceidentifier"},
+	)
+	throttledRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "throttled_requests_total",
+		Help:      "Number of AWS requests throttled, including the retries",
+	},
+		[]string{"service", "operation"},
+	)
+	targetUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "target_up",
+		Help:      "1 when the last collection of a target succeeded",
+	},
+		[]string{"target"},
+	)
+	circuitBreakerOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "circuit_breaker_open",
+		Help:      "1 when the circuit breaker of a target is open and its AWS APIs are not called",
+	},
+		[]string{"target"},
+	)
+	leaderGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "leader",
+		Help:      "1 when this replica is the leader and collects, with leader election",
+	})
+	discoveryTruncated = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "discovery_truncated",
+		Help:      "1 when more instances than max_instances were discovered and the rest were not exported",
+	})
+	maxConnectionsChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "max_connections_changes_total",
+		Help:      "Number of times the max_connections of an instance changed between two snapshots",
+	},
+		[]string{"dbinstanceidentifier"},
+	)
+	instanceCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "instances",
+		Help:      "Number of instances of the last snapshot by engine and instance class, including the skipped ones",
+	},
+		[]string{"engine", "instance_class"},
+	)
+	skippedInstances = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "skipped_instances",
+		Help:      "Number of instances of the last snapshot whose max_connections is not known, by reason",
+	},
+		[]string{"reason"},
+	)
+	seriesExpired = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "series_expired_total",
+		Help:      "Number of instances whose series expired after they were not seen for series_ttl snapshots",
+	})
+	configReloadSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "config_last_reload_successful",
+		Help:      "1 when the last reload of the configuration succeeded, 0 when the current one was kept",
+	})
+	auditErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "audit_log_errors_total",
+		Help:      "Number of AWS API calls which could not be written to the audit log",
+	})
+)
+
+func registerMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(snapshotErrors, snapshotTimeouts, outputErrors, lastSnapshotSuccess, parameterCacheRequests, instanceErrors, throttledRequests, targetUp, circuitBreakerOpen, leaderGauge, discoveryTruncated, auditErrors, clusterEndpointInfo, maxConnectionsChanges, maintenanceWindowInfo, apiRequestDuration, instanceCount, skippedInstances, seriesExpired, configReloadSuccess)
+	reg.MustRegister(newStalenessCollectors()...)
+}
+
+// countMaxConnectionsChanges counts the instances whose max_connections is
+// not the one of the previous snapshot, such as after a change of their
+// parameter group or instance class. The instances which are new or skipped
+// in either snapshot are not counted.
+func countMaxConnectionsChanges(cfg *Config, previous, current []RDSInfo) {
+	values := make(map[string]string, len(previous))
+	for _, info := range previous {
+		if len(info.SkipReason) == 0 {
+			values[info.Region+"|"+info.DBInstanceIdentifier] = info.MaxConnections
+		}
+	}
+
+	for _, info := range current {
+		v, ok := values[info.Region+"|"+info.DBInstanceIdentifier]
+		if !ok || len(info.SkipReason) != 0 || v == info.MaxConnections {
+			continue
+		}
+		slog.Info("max connections changed", "dbinstanceidentifier", info.DBInstanceIdentifier, "previous", v, "max_connections", info.MaxConnections)
+		maxConnectionsChanges.WithLabelValues(cfg.LabelValues.Sanitize("dbinstanceidentifier", info.DBInstanceIdentifier)).Inc()
+	}
+}
+
+// setInstanceCount counts the instances of a snapshot by engine and class,
+// for the dashboards of the composition of the fleet.
+func setInstanceCount(infos []RDSInfo) {
+	instanceCount.Reset()
+	for _, info := range infos {
+		instanceCount.WithLabelValues(info.DBEngine, info.DBInstanceClass).Inc()
+	}
+}
+
+// setSkippedInstances counts the skipped instances of a snapshot by reason,
+// which are only logged when new or changed.
+func setSkippedInstances(infos []RDSInfo) {
+	skippedInstances.Reset()
+	for _, info := range infos {
+		if len(info.SkipReason) != 0 {
+			skippedInstances.WithLabelValues(info.SkipCode).Inc()
+		}
+	}
+}
+
+func setTargetUp(target Target, err error) {
+	if err != nil {
+		targetUp.WithLabelValues(targetName(target)).Set(0)
+		return
+	}
+
+	targetUp.WithLabelValues(targetName(target)).Set(1)
+}
+
+// instanceMetric holds a GaugeVec of the instances, such as max_connections,
+// which is replaced in the registry when the label names change on a
+// configuration reload.
+type instanceMetric struct {
+	reg        prometheus.Registerer
+	opts       prometheus.GaugeOpts
+	mu         sync.Mutex
+	vec        *prometheus.GaugeVec
+	labelNames []string
+}
+
+type instanceSample struct {
+	labels prometheus.Labels
+	value  float64
+}
+
+func newInstanceGauge(opts prometheus.GaugeOpts, labelNames []string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(opts, append(append([]string{}, builtinLabels...), labelNames...))
+}
+
+func newInstanceMetric(reg prometheus.Registerer, opts prometheus.GaugeOpts, labelNames []string) *instanceMetric {
+	m := &instanceMetric{
+		reg:        reg,
+		opts:       opts,
+		vec:        newInstanceGauge(opts, labelNames),
+		labelNames: labelNames,
+	}
+	reg.MustRegister(m.vec)
+
+	return m
+}
+
+func newMaxConnectionsMetric(reg prometheus.Registerer, labelNames []string) *instanceMetric {
+	return newInstanceMetric(reg, prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "max_connections",
+		Help:      "Max Connections of RDS",
+	}, labelNames)
+}
+
+func newDBLoadMetric(reg prometheus.Registerer, labelNames []string) *instanceMetric {
+	return newInstanceMetric(reg, prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "db_load",
+		Help:      "Average active sessions of the instance from Performance Insights",
+	}, labelNames)
+}
+
+func newCertificateExpiryMetric(reg prometheus.Registerer, labelNames []string) *instanceMetric {
+	return newInstanceMetric(reg, prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "certificate_expiry_timestamp_seconds",
+		Help:      "Unix time when the server certificate of the instance expires",
+	}, labelNames)
+}
+
+func newPendingMaintenanceMetric(reg prometheus.Registerer, labelNames []string) *instanceMetric {
+	return newInstanceMetric(reg, prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "pending_maintenance_actions",
+		Help:      "Number of pending maintenance actions of the instance and its cluster",
+	}, labelNames)
+}
+
+func newPendingMaintenanceApplyMetric(reg prometheus.Registerer, labelNames []string) *instanceMetric {
+	return newInstanceMetric(reg, prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "pending_maintenance_apply_timestamp_seconds",
+		Help:      "Unix time of the earliest date a pending maintenance action of the instance or its cluster is applied",
+	}, labelNames)
+}
+
+func newMaxConnectionsIsCustomMetric(reg prometheus.Registerer, labelNames []string) *instanceMetric {
+	return newInstanceMetric(reg, prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "max_connections_is_custom",
+		Help:      "1 when the max_connections of the instance is not the engine default of its instance class",
+	}, labelNames)
+}
+
+func newExhaustionMetric(reg prometheus.Registerer, labelNames []string) *instanceMetric {
+	return newInstanceMetric(reg, prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "estimated_seconds_until_connection_exhaustion",
+		Help:      "Estimated seconds until the connections of the instance reach max_connections at their recent trend, +Inf when they do not increase",
+	}, labelNames)
+}
+
+func newBaselineMetric(reg prometheus.Registerer, labelNames []string) *instanceMetric {
+	return newInstanceMetric(reg, prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "connection_utilization_baseline_ratio",
+		Help:      "Mean of the maximum connections of each 5 minutes of the baseline window of the instance, as a ratio of max_connections",
+	}, labelNames)
+}
+
+func newDeviationMetric(reg prometheus.Registerer, labelNames []string) *instanceMetric {
+	return newInstanceMetric(reg, prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "connection_utilization_baseline_deviation",
+		Help:      "Number of standard deviations the latest connections of the instance are above its baseline",
+	}, labelNames)
+}
+
+func newPoolSizeMetric(reg prometheus.Registerer, labelNames []string) *instanceMetric {
+	return newInstanceMetric(reg, prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "recommended_pool_size",
+		Help:      "Recommended maximum size of the connection pool of each client of the instance, from its max_connections less the reserved connections and its clients during a rolling deploy",
+	}, labelNames)
+}
+
+func newCostPerConnectionMetric(reg prometheus.Registerer, labelNames []string) *instanceMetric {
+	return newInstanceMetric(reg, prometheus.GaugeOpts{
+		Namespace: "aws_custom",
+		Subsystem: "rds",
+		Name:      "cost_per_connection_usd_hourly",
+		Help:      "On-demand hourly price in USD of the instance class per connection of its max_connections",
+	}, labelNames)
+}
+
+// registerInstanceMetrics registers the metrics of the instances with the
+// label names.
+func registerInstanceMetrics(reg prometheus.Registerer, labelNames []string) {
+	maxconMetric = newMaxConnectionsMetric(reg, labelNames)
+	dbLoadMetric = newDBLoadMetric(reg, labelNames)
+	certificateExpiryMetric = newCertificateExpiryMetric(reg, labelNames)
+	pendingMaintenanceMetric = newPendingMaintenanceMetric(reg, labelNames)
+	pendingMaintenanceApplyMetric = newPendingMaintenanceApplyMetric(reg, labelNames)
+	maxconIsCustomMetric = newMaxConnectionsIsCustomMetric(reg, labelNames)
+	exhaustionMetric = newExhaustionMetric(reg, labelNames)
+	baselineMetric = newBaselineMetric(reg, labelNames)
+	deviationMetric = newDeviationMetric(reg, labelNames)
+	poolSizeMetric = newPoolSizeMetric(reg, labelNames)
+	costPerConnectionMetric = newCostPerConnectionMetric(reg, labelNames)
+}
+
+// isCustomMaxConnections reports whether the applied max_connections of an
+// instance, including max_connections_overrides, differs from the default
+// formula of its engine, and false for ok when the default is not known.
+func isCustomMaxConnections(info RDSInfo) (custom, ok bool) {
+	value, ok := defaultMaxConnections(info)
+	if !ok {
+		return false, false
+	}
+
+	return strconv.Itoa(value) != info.MaxConnections, true
+}
+
+// defaultMaxConnections returns the max_connections of an instance with the
+// default formula of its engine, false when it is not known.
+func defaultMaxConnections(info RDSInfo) (int, bool) {
+	var r maxcon.Resolution
+	var err error
+	if info.DBInstanceClass == maxcon.ServerlessInstanceClass {
+		r, err = exporter.ResolveServerless(info.DBEngine, "", info.ServerlessMaxCapacity)
+	} else {
+		r, err = exporter.Resolve(info.DBEngine, "", info.DBInstanceClass)
+	}
+	if err != nil {
+		return 0, false
+	}
+
+	return r.Value, true
+}
+
+// Update replaces all the series with the samples of a snapshot.
+func (m *instanceMetric) Update(labelNames []string, samples []instanceSample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !equalStrings(m.labelNames, labelNames) {
+		vec := newInstanceGauge(m.opts, labelNames)
+		m.reg.Unregister(m.vec)
+		m.reg.MustRegister(vec)
+		m.vec = vec
+		m.labelNames = labelNames
+	}
+
+	m.vec.Reset()
+	for _, sample := range samples {
+		m.vec.With(sample.labels).Set(sample.value)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
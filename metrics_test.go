@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+// compareGolden compares got with the golden file, which is rewritten with
+// -update.
+func compareGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+
+	if *update {
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, got, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file, run go test -update: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%v differs, run go test -update if the change is intended:\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
+
+func render(t *testing.T, reg prometheus.Gatherer) []byte {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	for _, family := range families {
+		_, err := expfmt.MetricFamilyToText(&b, family)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return b.Bytes()
+}
+
+func TestExpositionGolden(t *testing.T) {
+	instances := []RDSInfo{
+		{DBInstanceIdentifier: "postgres-api-production-a01", DBInstanceClass: "db.r5.4xlarge", MaxConnections: "5000", DBEngine: "aurora-postgresql",
+			DBLoad: ptr(1.5), SecondsUntilExhaustion: ptr(7200.0), ConnectionsBaseline: ptr(1000.0), ConnectionsDeviation: ptr(4.5), HourlyPrice: ptr(2.32), DBClusterIdentifier: "postgres-api-production", ClusterEndpoints: &ClusterEndpoints{
+				Writer: "postgres-api-production.cluster-abc.ap-northeast-1.rds.amazonaws.com", Reader: "postgres-api-production.cluster-ro-abc.ap-northeast-1.rds.amazonaws.com", Port: 5432},
+			Labels: map[string]string{"team": "api", "account": "production", statusLabel: "available"}},
+		{DBInstanceIdentifier: "postgres-batch-production-a01", DBInstanceClass: "db.r5.large", MaxConnections: "1800", DBEngine: "postgres",
+			SecondsUntilExhaustion: ptr(math.Inf(1)), CertificateValidTill: ptr(time.Date(2061, 5, 1, 0, 0, 0, 0, time.UTC)), MaintenanceWindow: "sun:05:00-sun:06:00",
+			PendingMaintenanceActions: 2, PendingMaintenanceApplyDate: ptr(time.Date(2060, 1, 4, 5, 0, 0, 0, time.UTC)),
+			Labels: map[string]string{"team": "batch/jobs", "account": "production", statusLabel: "stopped"}},
+		{DBInstanceIdentifier: "postgres-broken-production-a01", DBInstanceClass: "db.x9.large", MaxConnections: "0", DBEngine: "postgres",
+			SkipReason: "failed to get max connections: instance class db.x9.large is not supported", Labels: map[string]string{statusLabel: "available"}},
+		{DBInstanceIdentifier: "mysql-production-a01", DBInstanceClass: "db.r5.large", MaxConnections: "0", DBEngine: "mysql",
+			SkipReason: "unsupported engine: mysql", Unsupported: true, Labels: map[string]string{"team": "legacy", statusLabel: "available"}},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+	}{
+		{name: "default"},
+		{name: "labels", modify: func(cfg *Config) {
+			cfg.TagLabels = map[string]string{"Team": "team"}
+			cfg.Targets = []Target{{Labels: map[string]string{"account": "production"}}}
+		}},
+		{name: "unsupported_engines", modify: func(cfg *Config) { cfg.ExportUnsupportedEngines = true }},
+		{name: "stopped_label", modify: func(cfg *Config) { cfg.StoppedInstances = stoppedLabel }},
+		{name: "label_values", modify: func(cfg *Config) {
+			cfg.TagLabels = map[string]string{"Team": "team"}
+			cfg.LabelValues = LabelValuesConfig{Replace: []LabelReplace{{Regex: "/", Replacement: "_"}}, MaxLength: 24}
+		}},
+		{name: "redact", modify: func(cfg *Config) {
+			cfg.TagLabels = map[string]string{"Team": "team"}
+			cfg.LabelValues = LabelValuesConfig{
+				Redact: []LabelRedact{
+					{Labels: []string{"dbinstanceidentifier"}, Regex: "^postgres-api-"},
+					{Labels: []string{"team"}, Regex: "/", Action: redactRemove},
+				},
+				HashKey: "key",
+			}
+		}},
+		{name: "cluster_endpoints", modify: func(cfg *Config) { cfg.ExportClusterEndpoints = true }},
+		{name: "db_load", modify: func(cfg *Config) {
+			cfg.TagLabels = map[string]string{"Team": "team"}
+			cfg.ExportDBLoad = true
+		}},
+		{name: "maintenance", modify: func(cfg *Config) { cfg.ExportMaintenance = true }},
+		{name: "max_instances", modify: func(cfg *Config) { cfg.MaxInstances = 1 }},
+		{name: "forecast", modify: func(cfg *Config) { cfg.Forecast.Enabled = true }},
+		{name: "baseline", modify: func(cfg *Config) { cfg.Baseline.Enabled = true }},
+		{name: "cost_per_connection", modify: func(cfg *Config) { cfg.ExportCostPerConnection = true }},
+		{name: "pool_sizing", modify: func(cfg *Config) {
+			cfg.PoolSizing.Clients = map[string]int{"postgres-api-production-a01": 40, "mysql-production-a01": 4}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, tt.modify)
+			reg := prometheus.NewPedanticRegistry()
+			registerInstanceMetrics(reg, cfg.LabelNames())
+			reg.MustRegister(discoveryTruncated, clusterEndpointInfo, maintenanceWindowInfo)
+
+			err := publish(cfg, &Store{}, nil, instances)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			compareGolden(t, filepath.Join("testdata", "exposition", tt.name+".prom"), render(t, reg))
+		})
+	}
+}
+
+// recordingRegisterer records the registered collectors.
+type recordingRegisterer struct {
+	prometheus.Registerer
+	collectors []prometheus.Collector
+}
+
+func (r *recordingRegisterer) MustRegister(collectors ...prometheus.Collector) {
+	r.Registerer.MustRegister(collectors...)
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// TestMetricDescriptionsGolden catches the changes of the names, HELP texts
+// and labels of every metric, including the ones without series yet.
+func TestMetricDescriptionsGolden(t *testing.T) {
+	cfg := testConfig(t, nil)
+	reg := &recordingRegisterer{Registerer: prometheus.NewPedanticRegistry()}
+	registerMetrics(reg)
+	registerInstanceMetrics(reg, cfg.LabelNames())
+
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		for _, collector := range reg.collectors {
+			collector.Describe(descs)
+		}
+		close(descs)
+	}()
+	var lines []string
+	for desc := range descs {
+		lines = append(lines, desc.String())
+	}
+	sort.Strings(lines)
+
+	var b bytes.Buffer
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+	compareGolden(t, filepath.Join("testdata", "metrics.golden"), b.Bytes())
+}
+
+func TestCountMaxConnectionsChanges(t *testing.T) {
+	cfg := testConfig(t, nil)
+	maxConnectionsChanges.Reset()
+	t.Cleanup(maxConnectionsChanges.Reset)
+
+	previous := []RDSInfo{
+		{DBInstanceIdentifier: "resized", Region: "us-east-1", MaxConnections: "1800"},
+		{DBInstanceIdentifier: "same", Region: "us-east-1", MaxConnections: "1800"},
+		{DBInstanceIdentifier: "broken", Region: "us-east-1", MaxConnections: "0", SkipReason: "failed to get max connections"},
+	}
+	current := []RDSInfo{
+		{DBInstanceIdentifier: "resized", Region: "us-east-1", MaxConnections: "3600"},
+		{DBInstanceIdentifier: "same", Region: "us-east-1", MaxConnections: "1800"},
+		{DBInstanceIdentifier: "broken", Region: "us-east-1", MaxConnections: "1800"},
+		{DBInstanceIdentifier: "resized", Region: "eu-west-1", MaxConnections: "100"},
+	}
+	countMaxConnectionsChanges(cfg, previous, current)
+	countMaxConnectionsChanges(cfg, current, previous)
+
+	expected := `# HELP aws_custom_rds_max_connections_changes_total Number of times the max_connections of an instance changed between two snapshots
+# TYPE aws_custom_rds_max_connections_changes_total counter
+aws_custom_rds_max_connections_changes_total{dbinstanceidentifier="resized"} 2
+`
+	err := testutil.CollectAndCompare(maxConnectionsChanges, strings.NewReader(expected))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetInstanceCount(t *testing.T) {
+	instanceCount.Reset()
+	t.Cleanup(instanceCount.Reset)
+
+	setInstanceCount([]RDSInfo{
+		{DBInstanceIdentifier: "postgres-a01", DBEngine: "postgres", DBInstanceClass: "db.r5.large"},
+		{DBInstanceIdentifier: "postgres-a02", DBEngine: "postgres", DBInstanceClass: "db.r5.large"},
+		{DBInstanceIdentifier: "aurora-a01", DBEngine: "aurora-postgresql", DBInstanceClass: "db.r6g.xlarge"},
+		{DBInstanceIdentifier: "mysql-a01", DBEngine: "mysql", DBInstanceClass: "db.r5.large", SkipReason: "unsupported engine: mysql"},
+	})
+
+	expected := `# HELP aws_custom_rds_instances Number of instances of the last snapshot by engine and instance class, including the skipped ones
+# TYPE aws_custom_rds_instances gauge
+aws_custom_rds_instances{engine="aurora-postgresql",instance_class="db.r6g.xlarge"} 1
+aws_custom_rds_instances{engine="mysql",instance_class="db.r5.large"} 1
+aws_custom_rds_instances{engine="postgres",instance_class="db.r5.large"} 2
+`
+	err := testutil.CollectAndCompare(instanceCount, strings.NewReader(expected))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIsCustomMaxConnections(t *testing.T) {
+	tests := []struct {
+		info   RDSInfo
+		custom bool
+		ok     bool
+	}{
+		{info: RDSInfo{DBEngine: "postgres", DBInstanceClass: "db.r5.large", MaxConnections: "1800"}, ok: true},
+		{info: RDSInfo{DBEngine: "aurora-postgresql", DBInstanceClass: "db.r5.4xlarge", MaxConnections: "5000"}, ok: true},
+		{info: RDSInfo{DBEngine: "postgres", DBInstanceClass: "db.r5.large", MaxConnections: "100"}, custom: true, ok: true},
+		{info: RDSInfo{DBEngine: "postgres", DBInstanceClass: "db.r5.large", MaxConnections: "500", Overridden: true}, custom: true, ok: true},
+		{info: RDSInfo{DBEngine: "aurora-postgresql", DBInstanceClass: "db.serverless", MaxConnections: "3360", ServerlessMaxCapacity: 16}, ok: true},
+		{info: RDSInfo{DBEngine: "postgres", DBInstanceClass: "db.x9.large", MaxConnections: "100"}},
+		{info: RDSInfo{DBEngine: "mysql", DBInstanceClass: "db.r5.large", MaxConnections: "0"}},
+	}
+
+	for _, tt := range tests {
+		custom, ok := isCustomMaxConnections(tt.info)
+		if custom != tt.custom || ok != tt.ok {
+			t.Errorf("%v %v %v: got %v, %v, want %v, %v", tt.info.DBEngine, tt.info.DBInstanceClass, tt.info.MaxConnections, custom, ok, tt.custom, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// runtimeMetricPrefixes are the metrics of the default registry that are not
+// about RDS, which are left out of --once.
+//
+//nolint:gochecknoglobals
+var runtimeMetricPrefixes = []string{"go_", "process_", "promhttp_"}
+
+// once takes a single snapshot and writes the exposition of its metrics in
+// the Prometheus text format.
+func once(ctx context.Context, w io.Writer, cfg *Config, store *Store, outputs []Output) error {
+	err := snapshot(ctx, cfg, store, outputs)
+	if err != nil {
+		return fmt.Errorf("failed to take snapshot: %w", err)
+	}
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	for _, family := range families {
+		if hasAnyPrefix(family.GetName(), runtimeMetricPrefixes) {
+			continue
+		}
+
+		_, err := expfmt.MetricFamilyToText(w, family)
+		if err != nil {
+			return fmt.Errorf("failed to write metrics: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
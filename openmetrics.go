@@ -0,0 +1,96 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+)
+
+// metricUnits are the units of the metrics named with them as a suffix, as
+// required by OpenMetrics.
+//
+//nolint:gochecknoglobals
+var metricUnits = []string{"seconds", "bytes"}
+
+// metricsHandler serves the metrics of the registry. With open, the
+// OpenMetrics format is served to the scrapers asking for it, with the unit
+// of the metrics and the _created series of the counters, which the receivers
+// of OpenTelemetry use as the start of the cumulative series. The other
+// scrapers are served the Prometheus formats as before.
+func metricsHandler(reg prometheus.Registerer, gatherer prometheus.Gatherer, open bool) http.Handler {
+	prom := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+	if !open {
+		return promhttp.InstrumentMetricHandler(reg, prom)
+	}
+
+	return promhttp.InstrumentMetricHandler(reg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		if format.FormatType() != expfmt.TypeOpenMetrics {
+			prom.ServeHTTP(w, r)
+			return
+		}
+
+		families, err := gatherer.Gather()
+		if err != nil {
+			slog.Error("failed to gather metrics", "err", err)
+			if len(families) == 0 {
+				http.Error(w, "failed to gather metrics", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", string(format))
+		var out io.Writer = w
+		if acceptsGzip(r) {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			out = gz
+		}
+
+		enc := expfmt.NewEncoder(out, format, expfmt.WithCreatedLines(), expfmt.WithUnit())
+		for _, family := range families {
+			err := enc.Encode(withUnit(family))
+			if err != nil {
+				slog.Error("failed to write metrics", "err", err)
+				return
+			}
+		}
+		err = enc.(expfmt.Closer).Close()
+		if err != nil {
+			slog.Error("failed to write metrics", "err", err)
+		}
+	}))
+}
+
+// withUnit returns the family with the unit its name ends with, if any.
+func withUnit(family *dto.MetricFamily) *dto.MetricFamily {
+	name := strings.TrimSuffix(family.GetName(), "_total")
+	for _, unit := range metricUnits {
+		if strings.HasSuffix(name, "_"+unit) {
+			family = proto.Clone(family).(*dto.MetricFamily) //nolint:forcetypeassert
+			family.Unit = proto.String(unit)
+			break
+		}
+	}
+
+	return family
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(strings.Split(part, ";")[0]) == "gzip" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricsHandlerOpenMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	errs := prometheus.NewCounter(prometheus.CounterOpts{Namespace: "aws_custom", Subsystem: "rds", Name: "snapshot_errors_total", Help: "Errors"})
+	success := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: "aws_custom", Subsystem: "rds", Name: "last_snapshot_success_timestamp_seconds", Help: "Success"})
+	reg.MustRegister(errs, success)
+	errs.Inc()
+	success.Set(1)
+
+	tests := []struct {
+		name    string
+		open    bool
+		accept  string
+		format  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "openmetrics",
+			open:   true,
+			accept: "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5",
+			format: "application/openmetrics-text",
+			want: []string{
+				"# TYPE aws_custom_rds_snapshot_errors counter\n",
+				"aws_custom_rds_snapshot_errors_total 1.0\n",
+				"aws_custom_rds_snapshot_errors_created ",
+				"# UNIT aws_custom_rds_last_snapshot_success_timestamp_seconds seconds\n",
+				"# EOF\n",
+			},
+		},
+		{
+			name:    "text",
+			open:    true,
+			accept:  "text/plain;version=0.0.4",
+			format:  "text/plain",
+			want:    []string{"aws_custom_rds_snapshot_errors_total 1\n"},
+			notWant: []string{"_created", "# UNIT", "# EOF"},
+		},
+		{
+			name:    "disabled",
+			accept:  "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5",
+			format:  "text/plain",
+			want:    []string{"aws_custom_rds_snapshot_errors_total 1\n"},
+			notWant: []string{"_created", "# EOF"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(metricsHandler(prometheus.NewRegistry(), reg, tt.open))
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept", tt.accept)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.HasPrefix(res.Header.Get("Content-Type"), tt.format) {
+				t.Errorf("got content type %q, want %q", res.Header.Get("Content-Type"), tt.format)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(b), want) {
+					t.Errorf("%q not in:\n%s", want, b)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(string(b), notWant) {
+					t.Errorf("%q in:\n%s", notWant, b)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// Output receives the instances exported by each snapshot, in addition to
+// the Prometheus registry.
+type Output interface {
+	Name() string
+	Write(infos []RDSInfo) error
+	// Close releases the connections of the output, which is not written
+	// anymore.
+	Close() error
+}
+
+// connectionsOutput is implemented by outputs that emit utilization and
+// therefore need DatabaseConnections to be populated.
+type connectionsOutput interface {
+	NeedDatabaseConnections() bool
+}
+
+// outputSpec creates an output from its section of the configuration, by
+// which it is keyed so that an unchanged output is kept on reload.
+type outputSpec struct {
+	key    string
+	create func() (Output, error)
+}
+
+func outputSpecs(cfg OutputsConfig) []outputSpec {
+	var specs []outputSpec
+	add := func(section any, create func() (Output, error)) {
+		specs = append(specs, outputSpec{key: fmt.Sprintf("%T%+v", section, section), create: create})
+	}
+
+	if len(cfg.DogStatsD.Address) != 0 {
+		add(cfg.DogStatsD, func() (Output, error) {
+			d, err := NewDogStatsD(cfg.DogStatsD.Address)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create DogStatsD output: %w", err)
+			}
+			return d, nil
+		})
+	}
+
+	if len(cfg.StatsD.Address) != 0 {
+		add(cfg.StatsD, func() (Output, error) {
+			s, err := NewStatsD(cfg.StatsD.Address, cfg.StatsD.Prefix)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create StatsD output: %w", err)
+			}
+			return s, nil
+		})
+	}
+
+	if len(cfg.InfluxDB.URL) != 0 {
+		add(cfg.InfluxDB, func() (Output, error) {
+			return NewInfluxDB(cfg.InfluxDB.URL, cfg.InfluxDB.Token), nil
+		})
+	}
+
+	if len(cfg.Webhook.URL) != 0 {
+		add(cfg.Webhook, func() (Output, error) {
+			return NewWebhook(cfg.Webhook.URL, cfg.Webhook.Secret), nil
+		})
+	}
+
+	if len(cfg.SNS.TopicARN) != 0 {
+		add(cfg.SNS, func() (Output, error) {
+			return NewSNS(cfg.SNS.TopicARN, cfg.SNS.UtilizationThreshold), nil
+		})
+	}
+
+	if len(cfg.Kafka.Brokers) != 0 {
+		add(cfg.Kafka, func() (Output, error) {
+			k, err := NewKafka(cfg.Kafka.Brokers, cfg.Kafka.Topic, cfg.Kafka.Mode)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create Kafka output: %w", err)
+			}
+			return k, nil
+		})
+	}
+
+	if len(cfg.CloudWatch.Namespace) != 0 {
+		add(cfg.CloudWatch, func() (Output, error) {
+			return NewCloudWatch(cfg.CloudWatch.Namespace), nil
+		})
+	}
+
+	if len(cfg.RemoteWrite.URL) != 0 {
+		add(cfg.RemoteWrite, func() (Output, error) {
+			return NewRemoteWrite(cfg.RemoteWrite.URL, cfg.RemoteWrite.SigV4Region), nil
+		})
+	}
+
+	return specs
+}
+
+func getOutputs(cfg OutputsConfig) ([]Output, error) {
+	outputs, _, err := reuseOutputs(cfg, nil)
+
+	return outputs, err
+}
+
+// reuseOutputs creates the outputs of cfg, reusing the ones of previous whose
+// section did not change with their state, such as the last snapshot the
+// webhook diffs with or the instances SNS notified. The outputs are returned
+// by key too, to be reused by the next reload. On error, the outputs created
+// are closed.
+func reuseOutputs(cfg OutputsConfig, previous map[string]Output) ([]Output, map[string]Output, error) {
+	var outputs []Output
+	byKey := map[string]Output{}
+
+	for _, spec := range outputSpecs(cfg) {
+		if output, ok := previous[spec.key]; ok {
+			outputs = append(outputs, output)
+			byKey[spec.key] = output
+			continue
+		}
+
+		output, err := spec.create()
+		if err != nil {
+			closeOutputs(unusedOutputs(byKey, previous))
+			return nil, nil, err
+		}
+		outputs = append(outputs, output)
+		byKey[spec.key] = output
+	}
+
+	return outputs, byKey, nil
+}
+
+// unusedOutputs returns the outputs of byKey which are not the same output in
+// used, sorted by key.
+func unusedOutputs(byKey, used map[string]Output) []Output {
+	keys := make([]string, 0, len(byKey))
+	for key, output := range byKey {
+		if used[key] != output {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	ret := make([]Output, 0, len(keys))
+	for _, key := range keys {
+		ret = append(ret, byKey[key])
+	}
+
+	return ret
+}
+
+// closeOutputs closes the outputs, logging the ones failing.
+func closeOutputs(outputs []Output) {
+	for _, output := range outputs {
+		err := output.Close()
+		if err != nil {
+			slog.Warn("failed to close output", "output", output.Name(), "err", err)
+		}
+	}
+}
+
+// statefulOutput is implemented by the outputs comparing the instances of
+// each write with the ones of the previous write, such as to send their
+// changes, which are written all the instances of a snapshot. The other
+// outputs are written only the instances of the targets just collected, so
+// that the samples of a target are not written again on each tick of the
+// others.
+type statefulOutput interface {
+	Stateful() bool
+}
+
+func isStateful(output Output) bool {
+	o, ok := output.(statefulOutput)
+
+	return ok && o.Stateful()
+}
+
+func needDatabaseConnections(outputs []Output) bool {
+	for _, output := range outputs {
+		if o, ok := output.(connectionsOutput); ok && o.NeedDatabaseConnections() {
+			return true
+		}
+	}
+
+	return false
+}
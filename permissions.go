@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+//nolint:gochecknoglobals
+var notAuthorizedActionRep = regexp.MustCompile(`perform: ([a-zA-Z0-9-]+:[a-zA-Z0-9]+)`)
+
+// isAccessDenied reports whether err is an AWS authorization error.
+func isAccessDenied(err error) bool {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return false
+	}
+
+	switch aerr.Code() {
+	case "AccessDenied", "AccessDeniedException", "AuthorizationError", "UnauthorizedOperation":
+		return true
+	}
+
+	return false
+}
+
+// deniedAction returns the IAM action from an authorization error message,
+// e.g. "User: ... is not authorized to perform: rds:DescribeDBInstances", or
+// fallback when the message does not tell.
+func deniedAction(err error, fallback string) string {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		if m := notAuthorizedActionRep.FindStringSubmatch(aerr.Message()); m != nil {
+			return m[1]
+		}
+	}
+
+	return fallback
+}
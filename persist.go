@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// persistedSnapshot is the last snapshot of each target saved to
+// snapshot_file, which is served on restart while the first live snapshot
+// runs, so that rolling restarts do not create gaps in the metrics.
+type persistedSnapshot struct {
+	SavedAt time.Time            `json:"saved_at"`
+	Targets map[string][]RDSInfo `json:"targets"`
+}
+
+// saveSnapshot writes the snapshot to a temporary file renamed over path, so
+// that a crash never leaves a partial file.
+func saveSnapshot(path string, byTarget map[string][]RDSInfo) error {
+	b, err := json.Marshal(persistedSnapshot{SavedAt: time.Now(), Targets: byTarget})
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("failed to rename snapshot file: %w", err)
+	}
+
+	return nil
+}
+
+func loadSnapshot(path string) (*persistedSnapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot file: %w", err)
+	}
+
+	var snapshot persistedSnapshot
+	err = json.Unmarshal(b, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot file %v: %w", path, err)
+	}
+
+	return &snapshot, nil
+}
+
+// restoreSnapshot serves the snapshot saved by the previous process, and
+// returns it by target for the scheduler. The outputs are not written, since
+// they already received it.
+func restoreSnapshot(cfg *Config, store *Store) map[string][]RDSInfo {
+	if len(cfg.SnapshotFile) == 0 {
+		return nil
+	}
+
+	snapshot, err := loadSnapshot(cfg.SnapshotFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		slog.Warn("failed to restore snapshot", "err", err)
+		return nil
+	}
+
+	var all []RDSInfo
+	for _, target := range cfg.Targets {
+		all = append(all, snapshot.Targets[target.key()]...)
+	}
+
+	err = publish(cfg, store, nil, all)
+	if err != nil {
+		slog.Warn("failed to restore snapshot", "err", err)
+		return nil
+	}
+	setSnapshotTime(snapshot.SavedAt)
+	slog.Info("restored snapshot", "saved_at", snapshot.SavedAt, "instances", len(all))
+
+	return snapshot.Targets
+}
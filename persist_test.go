@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSaveSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "snapshot.json")
+	byTarget := map[string][]RDSInfo{
+		"ap-northeast-1|": {{
+			DBInstanceIdentifier: "a01",
+			DBInstanceClass:      "db.r5.large",
+			MaxConnections:       "1800",
+			Region:               "ap-northeast-1",
+			DatabaseConnections:  aws.Float64(10),
+			Labels:               map[string]string{"team": "api"},
+		}},
+		"us-east-1|": {{DBInstanceIdentifier: "b01", SkipReason: "unknown class"}},
+	}
+
+	err := saveSnapshot(path, byTarget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := loadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(snapshot.Targets, byTarget) || snapshot.SavedAt.IsZero() {
+		t.Errorf("got snapshot %+v, want %+v", snapshot, byTarget)
+	}
+
+	// the temporary file is renamed over the snapshot
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got files %v, want the snapshot only", entries)
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	cfg := testConfig(t, func(cfg *Config) { cfg.SnapshotFile = path })
+	registerInstanceMetrics(prometheus.NewRegistry(), cfg.LabelNames())
+	restore := func() (map[string][]RDSInfo, []RDSInfo) {
+		t.Helper()
+		store := &Store{}
+		byTarget := restoreSnapshot(cfg, store)
+		infos, _ := store.Get()
+		return byTarget, infos
+	}
+
+	// the first start has no snapshot
+	if byTarget, infos := restore(); byTarget != nil || len(infos) != 0 {
+		t.Errorf("got snapshot %v serving %v, want none", byTarget, infos)
+	}
+
+	// a corrupted file is ignored
+	err := os.WriteFile(path, []byte(`{"saved_at":"2024-01-01T00:00:00Z","targets":{`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byTarget, infos := restore(); byTarget != nil || len(infos) != 0 {
+		t.Errorf("got snapshot %v serving %v, want none", byTarget, infos)
+	}
+
+	// only the configured targets are served
+	key := cfg.Targets[0].key()
+	err = saveSnapshot(path, map[string][]RDSInfo{
+		key:           {{DBInstanceIdentifier: "a01", MaxConnections: "1800"}},
+		"removed|arn": {{DBInstanceIdentifier: "b01", MaxConnections: "66"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byTarget, infos := restore()
+	if len(byTarget[key]) != 1 || len(infos) != 1 || infos[0].DBInstanceIdentifier != "a01" {
+		t.Errorf("got snapshot %v serving %v, want a01", byTarget, infos)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/pi"
+	"github.com/aws/aws-sdk-go/service/pi/piiface"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// piDBLoad is the average active sessions of Performance Insights
+	piDBLoad = "db.load.avg"
+	// dbLoadWindow is how far back the latest data point is looked for
+	dbLoadWindow = 5 * time.Minute
+)
+
+// newPIAPI returns the Performance Insights client of a session, replaced by
+// a fake in the tests.
+//
+//nolint:gochecknoglobals
+var newPIAPI = func(sess *session.Session) piiface.PIAPI {
+	return pi.New(sess)
+}
+
+// setDBLoad fills DBLoad with the latest average active sessions of each
+// instance with Performance Insights enabled, with up to concurrency requests
+// in flight. An instance whose load can not be read is logged, and still
+// exported without it.
+func setDBLoad(ctx context.Context, svc piiface.PIAPI, infos []RDSInfo, concurrency int) {
+	end := clk.Now()
+	start := end.Add(-dbLoadWindow)
+
+	var eg errgroup.Group
+	eg.SetLimit(concurrency)
+	for i := range infos {
+		if !infos[i].PerformanceInsightsEnabled || (len(infos[i].SkipReason) != 0 && !infos[i].Unsupported) {
+			continue
+		}
+		info := &infos[i]
+		eg.Go(func() error {
+			out, err := svc.GetResourceMetricsWithContext(ctx, &pi.GetResourceMetricsInput{
+				ServiceType:     aws.String(pi.ServiceTypeRds),
+				Identifier:      aws.String(info.DbiResourceID),
+				MetricQueries:   []*pi.MetricQuery{{Metric: aws.String(piDBLoad)}},
+				StartTime:       aws.Time(start),
+				EndTime:         aws.Time(end),
+				PeriodInSeconds: aws.Int64(60),
+			})
+			if err != nil {
+				slog.Warn("failed to get DB load", "dbinstanceidentifier", info.DBInstanceIdentifier, "err", err)
+				return nil
+			}
+			for _, metric := range out.MetricList {
+				// the data points are sorted by timestamp ascending, and the
+				// ones without value have no activity reported yet
+				for _, point := range metric.DataPoints {
+					if point.Value != nil {
+						info.DBLoad = point.Value
+					}
+				}
+			}
+			return nil
+		})
+	}
+	_ = eg.Wait()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/pi"
+	"github.com/aws/aws-sdk-go/service/pi/piiface"
+)
+
+// fakePI serves the data points of each resource, and fails for the others.
+type fakePI struct {
+	piiface.PIAPI
+	points map[string][]*pi.DataPoint
+
+	mu        sync.Mutex
+	requested []string
+}
+
+func (f *fakePI) GetResourceMetricsWithContext(ctx context.Context, input *pi.GetResourceMetricsInput, opts ...request.Option) (*pi.GetResourceMetricsOutput, error) {
+	f.mu.Lock()
+	f.requested = append(f.requested, aws.StringValue(input.Identifier))
+	f.mu.Unlock()
+
+	points, ok := f.points[aws.StringValue(input.Identifier)]
+	if !ok {
+		return nil, errors.New("not authorized")
+	}
+	if aws.StringValue(input.MetricQueries[0].Metric) != piDBLoad {
+		return nil, errors.New("unexpected metric")
+	}
+
+	return &pi.GetResourceMetricsOutput{MetricList: []*pi.MetricKeyDataPoints{{DataPoints: points}}}, nil
+}
+
+func TestSetDBLoad(t *testing.T) {
+	svc := &fakePI{points: map[string][]*pi.DataPoint{
+		"db-API":  {{Value: aws.Float64(1)}, {Value: aws.Float64(2.5)}, {}},
+		"db-IDLE": {},
+	}}
+	infos := []RDSInfo{
+		{DBInstanceIdentifier: "api", DbiResourceID: "db-API", PerformanceInsightsEnabled: true},
+		{DBInstanceIdentifier: "idle", DbiResourceID: "db-IDLE", PerformanceInsightsEnabled: true},
+		{DBInstanceIdentifier: "denied", DbiResourceID: "db-DENIED", PerformanceInsightsEnabled: true},
+		{DBInstanceIdentifier: "disabled", DbiResourceID: "db-DISABLED"},
+		{DBInstanceIdentifier: "skipped", DbiResourceID: "db-SKIPPED", PerformanceInsightsEnabled: true, SkipReason: "max connection is 0"},
+	}
+
+	setDBLoad(context.Background(), svc, infos, 2)
+
+	// the latest data point with a value is taken
+	if infos[0].DBLoad == nil || *infos[0].DBLoad != 2.5 {
+		t.Errorf("api: got %v, want 2.5", infos[0].DBLoad)
+	}
+	for _, info := range infos[1:] {
+		if info.DBLoad != nil {
+			t.Errorf("%v: got %v, want nil", info.DBInstanceIdentifier, *info.DBLoad)
+		}
+	}
+	if len(svc.requested) != 3 {
+		t.Errorf("got requests for %v, want the 3 exported instances with Performance Insights", svc.requested)
+	}
+}
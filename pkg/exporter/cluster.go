@@ -0,0 +1,163 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
+)
+
+// ClusterParameterAPI is the part of the RDS API used to read the clusters of
+// the Aurora instances, implemented by *rds.RDS: max_connections is read from
+// the cluster parameter group of the instances whose parameter group does not
+// set it, and the maximum capacity of Aurora Serverless v2 from the cluster
+// of the instances of the class maxcon.ServerlessInstanceClass. The
+// collectors of an RDSAPI not implementing it resolve the former with the
+// engine default and skip the latter.
+type ClusterParameterAPI interface {
+	DescribeDBClustersPagesWithContext(ctx context.Context, input *rds.DescribeDBClustersInput, fn func(*rds.DescribeDBClustersOutput, bool) bool, opts ...request.Option) error
+	DescribeDBClusterParametersPagesWithContext(ctx context.Context, input *rds.DescribeDBClusterParametersInput, fn func(*rds.DescribeDBClusterParametersOutput, bool) bool, opts ...request.Option) error
+}
+
+var _ ClusterParameterAPI = (*rds.RDS)(nil)
+
+// clusterParameterGroupCachePrefix prefixes the cluster parameter groups in
+// the cache, whose names can not contain a colon.
+const clusterParameterGroupCachePrefix = "cluster:"
+
+// cluster is what the members of a cluster read from it: the max_connections
+// of its cluster parameter group, fetched for the members whose parameter
+// group does not set it, and its maximum capacity for the serverless
+// members.
+type cluster struct {
+	parameterGroup string
+	raw            string
+	maxCapacity    float64
+	// err is why the cluster or its parameter group could not be fetched
+	err error
+}
+
+// isServerless reports whether an instance is of Aurora Serverless v2.
+func isServerless(instance *rds.DBInstance) bool {
+	return aws.StringValue(instance.DBInstanceClass) == maxcon.ServerlessInstanceClass
+}
+
+// clusters returns the Aurora clusters of the serverless instances and of the
+// instances whose parameter group does not set max_connections, by cluster
+// identifier. The clusters not found are not returned, and only their
+// instances are skipped when the clusters or their parameter group could not
+// be fetched.
+func (c *Collector) clusters(ctx context.Context, instances []*rds.DBInstance,
+	rawMaxConnectionsByGroup map[string]string, groupErrors map[string]error) (map[string]cluster, error) {
+	svc, ok := c.svc.(ClusterParameterAPI)
+	if !ok {
+		return nil, nil
+	}
+
+	// the clusters to describe, true for the ones whose cluster parameter
+	// group is needed
+	needed := map[string]bool{}
+	for _, instance := range instances {
+		name, _ := ParameterGroup(instance)
+		identifier := aws.StringValue(instance.DBClusterIdentifier)
+		if len(identifier) == 0 || c.isSkippedStopped(instance) || !IsSupportedEngine(aws.StringValue(instance.Engine)) || groupErrors[name] != nil {
+			continue
+		}
+		if len(strings.TrimSpace(rawMaxConnectionsByGroup[name])) == 0 {
+			needed[identifier] = true
+		} else if isServerless(instance) && !needed[identifier] {
+			needed[identifier] = false
+		}
+	}
+	if len(needed) == 0 {
+		return nil, nil
+	}
+
+	ret := make(map[string]cluster, len(needed))
+	// the clusters using each cluster parameter group
+	parameterGroups := map[string][]string{}
+	err := svc.DescribeDBClustersPagesWithContext(ctx, &rds.DescribeDBClustersInput{}, func(page *rds.DescribeDBClustersOutput, lastPage bool) bool {
+		for _, DBCluster := range page.DBClusters {
+			identifier := aws.StringValue(DBCluster.DBClusterIdentifier)
+			withParameterGroup, ok := needed[identifier]
+			if !ok {
+				continue
+			}
+			var found cluster
+			if scaling := DBCluster.ServerlessV2ScalingConfiguration; scaling != nil {
+				found.maxCapacity = aws.Float64Value(scaling.MaxCapacity)
+			}
+			if withParameterGroup {
+				found.parameterGroup = aws.StringValue(DBCluster.DBClusterParameterGroup)
+				parameterGroups[found.parameterGroup] = append(parameterGroups[found.parameterGroup], identifier)
+			}
+			ret[identifier] = found
+		}
+		return lastPage || waitPage(ctx, c.opts.PageDelay)
+	})
+	if ctx.Err() != nil {
+		// the pagination stops without error when ctx is done
+		return nil, fmt.Errorf("failed to describe DB clusters: %w", classify(ctx.Err()))
+	}
+	if err != nil {
+		// the other instances are still collected
+		ret = make(map[string]cluster, len(needed))
+		for identifier := range needed {
+			ret[identifier] = cluster{err: fmt.Errorf("failed to describe DB clusters: %w", classify(err))}
+		}
+		return ret, nil
+	}
+	if len(parameterGroups) == 0 {
+		return ret, nil
+	}
+
+	raws, groupErrors, err := c.rawMaxConnectionsByGroup(ctx, parameterGroups, func(ctx context.Context, name string) (string, error) {
+		return c.clusterRawMaxConnections(ctx, svc, name)
+	}, clusterParameterGroupCachePrefix)
+	if err != nil {
+		return nil, err
+	}
+	for identifier, found := range ret {
+		if len(found.parameterGroup) != 0 {
+			found.raw, found.err = raws[found.parameterGroup], groupErrors[found.parameterGroup]
+			ret[identifier] = found
+		}
+	}
+
+	return ret, nil
+}
+
+// clusterRawMaxConnections returns the raw max_connections of a cluster
+// parameter group, empty when it is not set, like RawMaxConnections.
+func (c *Collector) clusterRawMaxConnections(ctx context.Context, svc ClusterParameterAPI, name string) (string, error) {
+	var rawMaxConnections string
+
+	input := &rds.DescribeDBClusterParametersInput{
+		DBClusterParameterGroupName: aws.String(name),
+	}
+
+	found := false
+	err := svc.DescribeDBClusterParametersPagesWithContext(ctx, input, func(page *rds.DescribeDBClusterParametersOutput, lastPage bool) bool {
+		for _, Parameter := range page.Parameters {
+			if aws.StringValue(Parameter.ParameterName) == "max_connections" {
+				rawMaxConnections = aws.StringValue(Parameter.ParameterValue)
+				found = true
+				return false
+			}
+		}
+		return lastPage || waitPage(ctx, c.opts.PageDelay)
+	})
+	if err == nil && !found {
+		// the pagination stops without error when ctx is done
+		err = ctx.Err()
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to describe DB cluster parameters: %w", classify(err))
+	}
+
+	return rawMaxConnections, nil
+}
@@ -0,0 +1,96 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
+)
+
+// RDSInfo is a discovered instance with its max_connections.
+type RDSInfo struct {
+	DBInstanceIdentifier string
+	DBInstanceClass      string
+	DBEngine             string
+	// DBClusterIdentifier is the Aurora cluster of the instance, empty for
+	// the other engines
+	DBClusterIdentifier  string
+	Region               string
+	DBParameterGroupName string
+	RawMaxConnections    string
+	MaxConnections       int
+	// Resolution records how MaxConnections was computed, nil when the
+	// engine is not supported or the instance is skipped before.
+	Resolution *maxcon.Resolution
+	// Unsupported tells that the engine is not supported
+	Unsupported bool
+	// SkipReason tells why MaxConnections is not known, empty when it is
+	SkipReason string
+	// Err is why the parameter group could not be fetched, if so
+	Err error `json:"-"`
+}
+
+// DiscoverOptions configure Discover. The zero value discovers every instance
+// of the region and credentials of the environment.
+type DiscoverOptions struct {
+	Options
+	// Session is the session of the account to discover, a session of the
+	// environment and the shared config when nil.
+	Session *session.Session
+	// Region overrides the region of the session.
+	Region string
+	// Client replaces the RDS client of the session, such as by the fake of
+	// exportertest; Region is then only reported.
+	Client RDSAPI
+}
+
+// Discover lists the instances of an account and region with their
+// max_connections. As Collect, an error is only returned when the instances
+// could not be listed.
+func Discover(ctx context.Context, opts DiscoverOptions) ([]RDSInfo, error) {
+	svc, region := opts.Client, opts.Region
+	if svc == nil {
+		sess := opts.Session
+		if sess == nil {
+			var err error
+			sess, err = session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+			if err != nil {
+				return nil, fmt.Errorf("failed to create session: %w", err)
+			}
+		}
+		config := aws.NewConfig()
+		if len(region) != 0 {
+			config = config.WithRegion(region)
+		}
+		client := rds.New(sess, config)
+		svc, region = client, aws.StringValue(client.Config.Region)
+	}
+
+	instances, err := NewCollector(svc, opts.Options).Collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]RDSInfo, 0, len(instances))
+	for _, instance := range instances {
+		ret = append(ret, RDSInfo{
+			DBInstanceIdentifier: aws.StringValue(instance.DBInstance.DBInstanceIdentifier),
+			DBInstanceClass:      aws.StringValue(instance.DBInstance.DBInstanceClass),
+			DBEngine:             aws.StringValue(instance.DBInstance.Engine),
+			DBClusterIdentifier:  aws.StringValue(instance.DBInstance.DBClusterIdentifier),
+			Region:               region,
+			DBParameterGroupName: instance.DBParameterGroupName,
+			RawMaxConnections:    instance.RawMaxConnections,
+			MaxConnections:       instance.MaxConnections,
+			Resolution:           instance.Resolution,
+			Unsupported:          instance.Unsupported,
+			SkipReason:           instance.SkipReason,
+			Err:                  instance.Err,
+		})
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,59 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+var (
+	// ErrUnsupportedEngine is matched by the errors of an engine whose
+	// max_connections cannot be computed, with errors.Is.
+	ErrUnsupportedEngine = errors.New("unsupported engine")
+	// ErrThrottled is matched by the errors of an AWS request which was still
+	// throttled after the retries of the SDK, with errors.Is. The AWS error
+	// itself is kept in the chain for errors.As.
+	ErrThrottled = errors.New("throttled")
+)
+
+// UnsupportedEngineError is returned when max_connections is resolved for an
+// engine which is not supported.
+type UnsupportedEngineError struct {
+	Engine string
+}
+
+func (e *UnsupportedEngineError) Error() string {
+	return fmt.Sprintf("unsupported engine: %v", e.Engine)
+}
+
+// Is makes the error match ErrUnsupportedEngine.
+func (e *UnsupportedEngineError) Is(target error) bool {
+	return target == ErrUnsupportedEngine
+}
+
+// throttledError marks a throttled AWS error without changing its message.
+type throttledError struct {
+	err error
+}
+
+func (e *throttledError) Error() string {
+	return e.err.Error()
+}
+
+func (e *throttledError) Unwrap() error {
+	return e.err
+}
+
+func (e *throttledError) Is(target error) bool {
+	return target == ErrThrottled
+}
+
+// classify marks err as throttled when it is an AWS throttling error.
+func classify(err error) error {
+	if request.IsErrorThrottle(err) {
+		return &throttledError{err: err}
+	}
+
+	return err
+}
@@ -0,0 +1,452 @@
+// Package exporter discovers the RDS instances of an account and region and
+// computes their max_connections, so that other services can embed the
+// discovery of aws-rds-maxcon-prometheus-exporter.
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon/postgresql"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultConcurrency is the number of DescribeDBParameters requests in flight
+// when Options.Concurrency is not set.
+const DefaultConcurrency = 4
+
+// RDSAPI is the part of the RDS API used by the collector, implemented by
+// *rds.RDS and by the fake of exportertest.
+type RDSAPI interface {
+	DescribeDBInstancesWithContext(ctx context.Context, input *rds.DescribeDBInstancesInput, opts ...request.Option) (*rds.DescribeDBInstancesOutput, error)
+	DescribeDBInstancesPagesWithContext(ctx context.Context, input *rds.DescribeDBInstancesInput, fn func(*rds.DescribeDBInstancesOutput, bool) bool, opts ...request.Option) error
+	DescribeDBParametersPagesWithContext(ctx context.Context, input *rds.DescribeDBParametersInput, fn func(*rds.DescribeDBParametersOutput, bool) bool, opts ...request.Option) error
+}
+
+var _ RDSAPI = (*rds.RDS)(nil)
+
+// ParameterCache keeps the raw max_connections of the parameter groups
+// between two collections. The fingerprint lists the instances using a group
+// with their apply status, which changes when the group is modified, so that
+// a group is fetched again when it differs from the cached one. The groups
+// are fetched concurrently, so implementations must be safe for concurrent
+// use.
+type ParameterCache interface {
+	Get(name, fingerprint string) (string, bool)
+	Set(name, fingerprint, raw string)
+}
+
+// Options configure a Collector. The zero value collects every instance.
+type Options struct {
+	// Filter selects the instances to collect, all of them when nil.
+	Filter func(instance *rds.DBInstance) bool
+	// SkipStopped skips the stopped instances without fetching their
+	// parameter groups.
+	SkipStopped bool
+	// Concurrency is the maximum number of DescribeDBParameters requests in
+	// flight, DefaultConcurrency when not positive.
+	Concurrency int
+	// PageDelay is waited between two pages of DescribeDBInstances and
+	// DescribeDBParameters.
+	PageDelay time.Duration
+	// Cache keeps the parameter groups between collections, nil to fetch
+	// them on every collection.
+	Cache ParameterCache
+	// Logger logs the skipped instances, slog.Default() when nil.
+	Logger *slog.Logger
+}
+
+// Instance is a collected instance with its max_connections.
+type Instance struct {
+	DBInstance *rds.DBInstance
+	// DBParameterGroupName is the parameter group max_connections is read
+	// from, as selected by ParameterGroup, and AmbiguousParameterGroup tells
+	// that it was not the only candidate.
+	DBParameterGroupName    string
+	AmbiguousParameterGroup bool
+	// DBClusterParameterGroupName is the cluster parameter group
+	// max_connections is read from when the parameter group of the instance
+	// does not set it, empty else
+	DBClusterParameterGroupName string
+	// ServerlessMaxCapacity is the maximum capacity in ACUs of the cluster of
+	// an Aurora Serverless v2 instance, which max_connections is computed
+	// with, 0 else
+	ServerlessMaxCapacity float64
+	// RawMaxConnections is the value of the parameter, such as
+	// "LEAST({DBInstanceClassMemory/9531392},5000)"
+	RawMaxConnections string
+	MaxConnections    int
+	// Resolution records how max_connections was computed, nil when the
+	// engine is not supported or the instance is skipped before.
+	Resolution *maxcon.Resolution
+	// Unsupported tells that the engine is not supported
+	Unsupported bool
+	// SkipReason tells why MaxConnections is not known, empty when it is
+	SkipReason string
+	// SkipCode is SkipReason as one of the Skip constants, for the metrics
+	SkipCode string
+	// Err is why the parameter group could not be fetched, if so
+	Err error
+}
+
+// The codes of the reasons an instance is skipped. The instances whose
+// max_connections can not be computed are skipped with SkipUnknownClass when
+// the memory of their class is not known, else with SkipComputeError, such as
+// for a formula which can not be evaluated.
+const (
+	SkipStopped             = "stopped"
+	SkipParameterGroupError = "parameter_group_error"
+	SkipUnknownClass        = "unknown_class"
+	SkipComputeError        = "compute_error"
+	SkipZeroMaxConnections  = "zero_max_connections"
+	SkipUnsupportedEngine   = "unsupported_engine"
+)
+
+// Collector collects the instances of an RDS client.
+type Collector struct {
+	svc  RDSAPI
+	opts Options
+}
+
+// NewCollector returns a collector of the instances of svc.
+func NewCollector(svc RDSAPI, opts Options) *Collector {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	return &Collector{svc: svc, opts: opts}
+}
+
+// resolvers are the supported engines.
+//
+//nolint:gochecknoglobals
+var resolvers = map[string]maxcon.Resolver{
+	"aurora-postgresql": postgresql.Resolve,
+	"postgres":          postgresql.Resolve,
+}
+
+// engineDefaults are the max_connections of the supported engines when no
+// parameter group sets it.
+//
+//nolint:gochecknoglobals
+var engineDefaults = map[string]string{
+	"aurora-postgresql": postgresql.DefaultFormula,
+	"postgres":          postgresql.DefaultFormula,
+}
+
+// serverlessResolvers are the supported engines of Aurora Serverless v2.
+//
+//nolint:gochecknoglobals
+var serverlessResolvers = map[string]maxcon.ServerlessResolver{
+	"aurora-postgresql": postgresql.ResolveServerless,
+}
+
+// IsSupportedEngine reports whether max_connections can be computed for the
+// engine.
+func IsSupportedEngine(engine string) bool {
+	_, ok := resolvers[engine]
+	return ok
+}
+
+// Resolve computes max_connections of an instance class from the raw
+// parameter value for an engine. The error matches ErrUnsupportedEngine when
+// the engine is not supported, and maxcon.ErrUnknownInstanceClass when the
+// default of the instance class is not known. An empty value, such as of the
+// default.aurora-postgresql parameter groups, resolves with the default of the
+// engine and the branch maxcon.BranchEngineDefault.
+func Resolve(engine, rawMaxConnections, instanceClass string) (maxcon.Resolution, error) {
+	resolve, ok := resolvers[engine]
+	if !ok {
+		return maxcon.Resolution{Raw: rawMaxConnections, Branch: maxcon.BranchNoValue}, &UnsupportedEngineError{Engine: engine}
+	}
+
+	return resolveOrDefault(engine, rawMaxConnections, func(raw string) (maxcon.Resolution, error) {
+		return resolve(raw, instanceClass)
+	})
+}
+
+// ResolveServerless computes max_connections of an Aurora Serverless v2
+// instance, of the class maxcon.ServerlessInstanceClass, from the raw
+// parameter value and the maximum capacity of its cluster in ACUs, like
+// Resolve. The error matches maxcon.ErrUnknownInstanceClass when the engine
+// has no serverless instances or the capacity is not known.
+func ResolveServerless(engine, rawMaxConnections string, maxCapacity float64) (maxcon.Resolution, error) {
+	if !IsSupportedEngine(engine) {
+		return maxcon.Resolution{Raw: rawMaxConnections, Branch: maxcon.BranchNoValue}, &UnsupportedEngineError{Engine: engine}
+	}
+	resolve, ok := serverlessResolvers[engine]
+	if !ok {
+		return maxcon.Resolution{Raw: rawMaxConnections, Branch: maxcon.BranchNoValue}, &maxcon.UnknownInstanceClassError{Class: maxcon.ServerlessInstanceClass}
+	}
+
+	return resolveOrDefault(engine, rawMaxConnections, func(raw string) (maxcon.Resolution, error) {
+		return resolve(raw, maxCapacity)
+	})
+}
+
+// resolveOrDefault resolves the raw value, or the default of the engine when
+// it is empty.
+func resolveOrDefault(engine, rawMaxConnections string, resolve func(raw string) (maxcon.Resolution, error)) (maxcon.Resolution, error) {
+	if len(strings.TrimSpace(rawMaxConnections)) != 0 {
+		return resolve(rawMaxConnections)
+	}
+
+	r, err := resolve(engineDefaults[engine])
+	r.Raw, r.Branch = rawMaxConnections, maxcon.BranchEngineDefault
+	return r, err
+}
+
+// Collect returns the instances selected by the filter with their
+// max_connections. An error is only returned when the instances could not be
+// listed or ctx is done: the instances whose parameter group could not be
+// fetched are returned with their Err. Both errors match ErrThrottled when
+// the requests were throttled.
+func (c *Collector) Collect(ctx context.Context) ([]Instance, error) {
+	var instances []*rds.DBInstance
+	// the instances using each parameter group with their apply status
+	parameterGroups := map[string][]string{}
+
+	// each page is filtered as it arrives, so that the instances filtered out
+	// of a large fleet are not kept
+	err := c.svc.DescribeDBInstancesPagesWithContext(ctx, &rds.DescribeDBInstancesInput{}, func(page *rds.DescribeDBInstancesOutput, lastPage bool) bool {
+		for _, RDSInstance := range page.DBInstances {
+			if c.opts.Filter != nil && !c.opts.Filter(RDSInstance) {
+				continue
+			}
+			instances = append(instances, RDSInstance)
+			if c.isSkippedStopped(RDSInstance) {
+				continue
+			}
+			// only the group max_connections is read from is fetched
+			name, _ := ParameterGroup(RDSInstance)
+			for _, DBParameterGroup := range RDSInstance.DBParameterGroups {
+				if aws.StringValue(DBParameterGroup.DBParameterGroupName) == name {
+					parameterGroups[name] = append(parameterGroups[name], *RDSInstance.DBInstanceIdentifier+"="+aws.StringValue(DBParameterGroup.ParameterApplyStatus))
+					break
+				}
+			}
+		}
+		return lastPage || waitPage(ctx, c.opts.PageDelay)
+	})
+	if err == nil {
+		// the pagination stops without error when ctx is done
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe DB instances: %w", classify(err))
+	}
+
+	rawMaxConnectionsByGroup, groupErrors, err := c.rawMaxConnectionsByGroup(ctx, parameterGroups, c.RawMaxConnections, "")
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := c.clusters(ctx, instances, rawMaxConnectionsByGroup, groupErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]Instance, 0, len(instances))
+	for _, RDSInstance := range instances {
+		instance := Instance{DBInstance: RDSInstance}
+		instance.DBParameterGroupName, instance.AmbiguousParameterGroup = ParameterGroup(RDSInstance)
+		instance.RawMaxConnections = rawMaxConnectionsByGroup[instance.DBParameterGroupName]
+		if instance.AmbiguousParameterGroup && !c.isSkippedStopped(RDSInstance) {
+			c.opts.Logger.Warn("ambiguous parameter groups: max_connections is read from the last candidate", "dbinstanceidentifier", aws.StringValue(RDSInstance.DBInstanceIdentifier),
+				"dbparametergroups", parameterGroupNames(RDSInstance), "dbparametergroup", instance.DBParameterGroupName)
+		}
+		groupErr := groupErrors[instance.DBParameterGroupName]
+		if cluster, ok := clusters[aws.StringValue(RDSInstance.DBClusterIdentifier)]; ok && groupErr == nil {
+			switch {
+			case len(strings.TrimSpace(instance.RawMaxConnections)) == 0:
+				instance.DBClusterParameterGroupName, instance.RawMaxConnections, groupErr = cluster.parameterGroup, cluster.raw, cluster.err
+			case isServerless(RDSInstance):
+				groupErr = cluster.err
+			}
+			if isServerless(RDSInstance) {
+				instance.ServerlessMaxCapacity = cluster.maxCapacity
+			}
+		}
+		c.resolve(&instance, groupErr)
+		ret = append(ret, instance)
+	}
+
+	return ret, nil
+}
+
+func (c *Collector) resolve(instance *Instance, groupErr error) {
+	log := c.opts.Logger
+	identifier := aws.StringValue(instance.DBInstance.DBInstanceIdentifier)
+	engine := aws.StringValue(instance.DBInstance.Engine)
+	class := aws.StringValue(instance.DBInstance.DBInstanceClass)
+
+	switch {
+	case c.isSkippedStopped(instance.DBInstance):
+		instance.SkipReason = "instance is stopped"
+		instance.SkipCode = SkipStopped
+		log.Debug("skip: instance is stopped", "dbinstanceidentifier", identifier)
+	case groupErr != nil:
+		// the other instances are still collected
+		group := instance.DBParameterGroupName
+		if len(instance.DBClusterParameterGroupName) != 0 {
+			group = instance.DBClusterParameterGroupName
+		}
+		instance.SkipReason = fmt.Sprintf("failed to get Parameter Group %v: %v", group, groupErr)
+		instance.SkipCode = SkipParameterGroupError
+		instance.Err = groupErr
+		log.Warn("skip: failed to get Parameter Group", "dbinstanceidentifier", identifier, "dbparametergroup", group, "err", groupErr)
+	case IsSupportedEngine(engine):
+		var r maxcon.Resolution
+		var err error
+		if isServerless(instance.DBInstance) {
+			r, err = ResolveServerless(engine, instance.RawMaxConnections, instance.ServerlessMaxCapacity)
+		} else {
+			r, err = Resolve(engine, instance.RawMaxConnections, class)
+		}
+		instance.Resolution = &r
+		log.Debug("resolved max connections", "dbinstanceidentifier", identifier, "raw", r.Raw, "branch", r.Branch, "dbclusterparametergroup", instance.DBClusterParameterGroupName,
+			"memory", r.Memory, "max_connections", r.Value, "err", err)
+		if err != nil {
+			instance.SkipReason = fmt.Sprintf("failed to get max connections: %v", err)
+			instance.SkipCode = SkipComputeError
+			if errors.Is(err, maxcon.ErrUnknownInstanceClass) || errors.Is(err, maxcon.ErrUnknownMemory) {
+				instance.SkipCode = SkipUnknownClass
+			}
+			log.Warn("skip: failed to get max connections", "dbinstanceidentifier", identifier, "err", err)
+			return
+		}
+		instance.MaxConnections = r.Value
+		if r.Value == 0 {
+			instance.SkipReason = "max connection is 0"
+			instance.SkipCode = SkipZeroMaxConnections
+			log.Debug("skip: max connection is 0", "dbinstanceidentifier", identifier, "dbinstanceclass", class)
+		}
+	default:
+		instance.SkipReason = (&UnsupportedEngineError{Engine: engine}).Error()
+		instance.SkipCode = SkipUnsupportedEngine
+		instance.Unsupported = true
+		log.Debug("skip: unsupported engine", "engine", engine, "dbinstanceidentifier", identifier)
+	}
+}
+
+func (c *Collector) isSkippedStopped(instance *rds.DBInstance) bool {
+	return c.opts.SkipStopped && aws.StringValue(instance.DBInstanceStatus) == "stopped"
+}
+
+// rawMaxConnectionsByGroup fetches the max_connections of each parameter
+// group, given with the instances using it and their apply status, with at
+// most Concurrency requests in flight. The groups are cached under their name
+// prefixed with cachePrefix. The groups which could not be fetched are
+// returned with their error, so that only their instances are skipped, and an
+// error is only returned when ctx is done.
+func (c *Collector) rawMaxConnectionsByGroup(ctx context.Context, parameterGroups map[string][]string,
+	fetch func(ctx context.Context, name string) (string, error), cachePrefix string) (map[string]string, map[string]error, error) {
+	var mu sync.Mutex
+	ret := make(map[string]string, len(parameterGroups))
+	failed := map[string]error{}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(c.opts.Concurrency)
+
+	for name, instances := range parameterGroups {
+		sort.Strings(instances)
+		fingerprint := strings.Join(instances, ",")
+		if c.opts.Cache != nil {
+			if raw, ok := c.opts.Cache.Get(cachePrefix+name, fingerprint); ok {
+				mu.Lock()
+				ret[name] = raw
+				mu.Unlock()
+				continue
+			}
+		}
+
+		name := name
+		eg.Go(func() error {
+			raw, err := fetch(ctx, name)
+			if ctx.Err() != nil {
+				return fmt.Errorf("failed to get Parameter Group %v: %w", name, ctx.Err())
+			}
+			if err != nil {
+				mu.Lock()
+				failed[name] = err
+				mu.Unlock()
+				return nil
+			}
+			if c.opts.Cache != nil {
+				c.opts.Cache.Set(cachePrefix+name, fingerprint, raw)
+			}
+
+			mu.Lock()
+			ret[name] = raw
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	err := eg.Wait()
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck
+	}
+
+	return ret, failed, nil
+}
+
+// RawMaxConnections returns the raw max_connections of a parameter group,
+// empty when it is not set. The pages are processed as they arrive rather
+// than accumulated, and the remaining pages are not fetched once the
+// parameter is found.
+func (c *Collector) RawMaxConnections(ctx context.Context, parameterGroupName string) (string, error) {
+	var rawMaxConnections string
+
+	input := &rds.DescribeDBParametersInput{
+		DBParameterGroupName: aws.String(parameterGroupName),
+	}
+
+	found := false
+	err := c.svc.DescribeDBParametersPagesWithContext(ctx, input, func(page *rds.DescribeDBParametersOutput, lastPage bool) bool {
+		for _, Parameter := range page.Parameters {
+			if aws.StringValue(Parameter.ParameterName) == "max_connections" {
+				rawMaxConnections = aws.StringValue(Parameter.ParameterValue)
+				found = true
+				return false
+			}
+		}
+		return lastPage || waitPage(ctx, c.opts.PageDelay)
+	})
+	if err == nil && !found {
+		// the pagination stops without error when ctx is done
+		err = ctx.Err()
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to describe DB parameters: %w", classify(err))
+	}
+
+	return rawMaxConnections, nil
+}
+
+// waitPage waits for the page delay between two pages of a paginated API, and
+// reports false when ctx is done so that the pagination stops.
+func waitPage(ctx context.Context, delay time.Duration) bool {
+	if delay == 0 {
+		return true
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
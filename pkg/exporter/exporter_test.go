@@ -0,0 +1,384 @@
+package exporter_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter/exportertest"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
+)
+
+// mapCache is a ParameterCache never expiring.
+type mapCache struct {
+	mu      sync.Mutex
+	entries map[string][2]string
+}
+
+func (c *mapCache) Get(name, fingerprint string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[name]
+	if !ok || entry[0] != fingerprint {
+		return "", false
+	}
+
+	return entry[1], true
+}
+
+func (c *mapCache) Set(name, fingerprint, raw string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[string][2]string{}
+	}
+	c.entries[name] = [2]string{fingerprint, raw}
+}
+
+func TestCollectCache(t *testing.T) {
+	svc := &exportertest.RDS{
+		InstancePages:  [][]*rds.DBInstance{{exportertest.Instance("db", "postgres", "group")}},
+		ParameterPages: map[string][][]*rds.Parameter{"group": exportertest.Parameters("100")},
+	}
+	collector := exporter.NewCollector(svc, exporter.Options{Cache: &mapCache{}})
+
+	for i := 0; i < 2; i++ {
+		instances, err := collector.Collect(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(instances) != 1 || instances[0].MaxConnections != 100 {
+			t.Fatalf("unexpected instances: %+v", instances)
+		}
+	}
+	if n := svc.ParameterPagesFetched("group"); n != 1 {
+		t.Errorf("fetched %d pages, want 1", n)
+	}
+
+	// a changed apply status fetches the group again
+	svc.InstancePages[0][0].DBParameterGroups[0].ParameterApplyStatus = aws.String("pending-reboot")
+	_, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := svc.ParameterPagesFetched("group"); n != 2 {
+		t.Errorf("fetched %d pages, want 2", n)
+	}
+}
+
+func TestCollectFilter(t *testing.T) {
+	svc := &exportertest.RDS{
+		InstancePages: [][]*rds.DBInstance{
+			{exportertest.Instance("a", "postgres", "group-a")},
+			{exportertest.Instance("b", "postgres", "group-b")},
+		},
+		ParameterPages: map[string][][]*rds.Parameter{"group-a": exportertest.Parameters("100")},
+	}
+	collector := exporter.NewCollector(svc, exporter.Options{
+		Filter: func(instance *rds.DBInstance) bool { return aws.StringValue(instance.DBInstanceIdentifier) == "a" },
+	})
+
+	// the parameter group of the filtered out instance is not fetched
+	instances, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 1 || aws.StringValue(instances[0].DBInstance.DBInstanceIdentifier) != "a" || instances[0].Err != nil {
+		t.Fatalf("unexpected instances: %+v", instances)
+	}
+}
+
+func TestCollectSkipCode(t *testing.T) {
+	unknown := exportertest.Instance("unknown", "postgres", "formula")
+	unknown.DBInstanceClass = aws.String("db.x9.large")
+	svc := &exportertest.RDS{
+		InstancePages: [][]*rds.DBInstance{{
+			exportertest.Instance("postgres", "postgres", "formula"),
+			unknown,
+			exportertest.Instance("invalid", "postgres", "invalid"),
+			exportertest.Instance("zero", "postgres", "zero"),
+			exportertest.Instance("mysql", "mysql", "formula"),
+		}},
+		ParameterPages: map[string][][]*rds.Parameter{
+			"formula": exportertest.Parameters("LEAST({DBInstanceClassMemory/9531392},5000)"),
+			"invalid": exportertest.Parameters("{DBInstanceClassMemory/0}"),
+			"zero":    exportertest.Parameters("0"),
+		},
+	}
+
+	instances, err := exporter.NewCollector(svc, exporter.Options{}).Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"", exporter.SkipUnknownClass, exporter.SkipComputeError, exporter.SkipZeroMaxConnections, exporter.SkipUnsupportedEngine}
+	for i, instance := range instances {
+		if instance.SkipCode != want[i] {
+			t.Errorf("%v: got skip code %q, want %q (%v)", *instance.DBInstance.DBInstanceIdentifier, instance.SkipCode, want[i], instance.SkipReason)
+		}
+	}
+}
+
+func TestCollectFallback(t *testing.T) {
+	member := func(identifier, cluster, group string) *rds.DBInstance {
+		instance := exportertest.Instance(identifier, "aurora-postgresql", group)
+		instance.DBClusterIdentifier = aws.String(cluster)
+		return instance
+	}
+	svc := &exportertest.RDS{
+		InstancePages: [][]*rds.DBInstance{{
+			member("instance", "custom", "custom"),
+			member("cluster", "custom", "default.aurora-postgresql15"),
+			member("default", "default", "default.aurora-postgresql15"),
+			member("broken", "broken", "default.aurora-postgresql15"),
+			exportertest.Instance("postgres", "postgres", "unset"),
+		}},
+		ParameterPages: map[string][][]*rds.Parameter{
+			"custom":                      exportertest.Parameters("100"),
+			"default.aurora-postgresql15": exportertest.Parameters(""),
+			"unset":                       {{{ParameterName: aws.String("work_mem"), ParameterValue: aws.String("4096")}}},
+		},
+		ClusterPages: [][]*rds.DBCluster{{
+			{DBClusterIdentifier: aws.String("custom"), DBClusterParameterGroup: aws.String("custom-cluster")},
+			{DBClusterIdentifier: aws.String("default"), DBClusterParameterGroup: aws.String("default.aurora-postgresql15")},
+			{DBClusterIdentifier: aws.String("broken"), DBClusterParameterGroup: aws.String("missing")},
+		}},
+		ClusterParameterPages: map[string][][]*rds.Parameter{
+			"custom-cluster":              exportertest.Parameters("200"),
+			"default.aurora-postgresql15": exportertest.Parameters(""),
+		},
+	}
+	collector := exporter.NewCollector(svc, exporter.Options{Cache: &mapCache{}})
+
+	tests := []struct {
+		maxConnections int
+		clusterGroup   string
+		branch         string
+		skipCode       string
+	}{
+		{maxConnections: 100, branch: maxcon.BranchExplicitValue},
+		{maxConnections: 200, clusterGroup: "custom-cluster", branch: maxcon.BranchExplicitValue},
+		{maxConnections: 1800, clusterGroup: "default.aurora-postgresql15", branch: maxcon.BranchEngineDefault},
+		{clusterGroup: "missing", skipCode: exporter.SkipParameterGroupError},
+		{maxConnections: 1800, branch: maxcon.BranchEngineDefault},
+	}
+	for i := 0; i < 2; i++ {
+		instances, err := collector.Collect(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, instance := range instances {
+			tt := tests[i]
+			var branch string
+			if instance.Resolution != nil {
+				branch = instance.Resolution.Branch
+			}
+			if instance.MaxConnections != tt.maxConnections || instance.DBClusterParameterGroupName != tt.clusterGroup || branch != tt.branch || instance.SkipCode != tt.skipCode {
+				t.Errorf("%v: got %d, %q, %q, %q, want %d, %q, %q, %q (%v)", *instance.DBInstance.DBInstanceIdentifier,
+					instance.MaxConnections, instance.DBClusterParameterGroupName, branch, instance.SkipCode,
+					tt.maxConnections, tt.clusterGroup, tt.branch, tt.skipCode, instance.SkipReason)
+			}
+		}
+	}
+	// the cluster parameter groups are cached like the parameter groups
+	if n := svc.ClusterParameterPagesFetched("custom-cluster"); n != 1 {
+		t.Errorf("fetched %d pages, want 1", n)
+	}
+}
+
+func TestCollectServerless(t *testing.T) {
+	member := func(identifier, cluster, class string) *rds.DBInstance {
+		instance := exportertest.Instance(identifier, "aurora-postgresql", "group")
+		instance.DBClusterIdentifier = aws.String(cluster)
+		instance.DBInstanceClass = aws.String(class)
+		return instance
+	}
+	svc := &exportertest.RDS{
+		InstancePages: [][]*rds.DBInstance{{
+			member("serverless", "mixed", "db.serverless"),
+			member("provisioned", "mixed", "db.r5.large"),
+			member("unknown", "deleted", "db.serverless"),
+		}},
+		ParameterPages: map[string][][]*rds.Parameter{"group": exportertest.Parameters("LEAST({DBInstanceClassMemory/9531392},5000)")},
+		ClusterPages: [][]*rds.DBCluster{{
+			{DBClusterIdentifier: aws.String("mixed"), ServerlessV2ScalingConfiguration: &rds.ServerlessV2ScalingConfigurationInfo{MinCapacity: aws.Float64(0.5), MaxCapacity: aws.Float64(16)}},
+		}},
+	}
+
+	instances, err := exporter.NewCollector(svc, exporter.Options{}).Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		maxConnections int
+		maxCapacity    float64
+		skipCode       string
+	}{
+		{maxConnections: 3360, maxCapacity: 16},
+		{maxConnections: 1800},
+		{skipCode: exporter.SkipUnknownClass},
+	}
+	for i, instance := range instances {
+		tt := tests[i]
+		if instance.MaxConnections != tt.maxConnections || instance.ServerlessMaxCapacity != tt.maxCapacity || instance.SkipCode != tt.skipCode {
+			t.Errorf("%v: got %d, %v, %q, want %d, %v, %q (%v)", *instance.DBInstance.DBInstanceIdentifier,
+				instance.MaxConnections, instance.ServerlessMaxCapacity, instance.SkipCode, tt.maxConnections, tt.maxCapacity, tt.skipCode, instance.SkipReason)
+		}
+	}
+}
+
+func TestParameterGroup(t *testing.T) {
+	group := func(name, status string) *rds.DBParameterGroupStatus {
+		return &rds.DBParameterGroupStatus{DBParameterGroupName: aws.String(name), ParameterApplyStatus: aws.String(status)}
+	}
+	tests := []struct {
+		engine, version string
+		groups          []*rds.DBParameterGroupStatus
+		want            string
+		ambiguous       bool
+	}{
+		{engine: "postgres", version: "15.4"},
+		{engine: "postgres", version: "15.4", groups: []*rds.DBParameterGroupStatus{group("custom", "pending-reboot")}, want: "custom"},
+		{engine: "postgres", version: "15.4", groups: []*rds.DBParameterGroupStatus{group("new", "applying"), group("old", "in-sync")}, want: "old"},
+		{engine: "postgres", version: "15.4", groups: []*rds.DBParameterGroupStatus{group("default.postgres15", "in-sync"), group("default.postgres14", "in-sync")}, want: "default.postgres15"},
+		{engine: "postgres", version: "9.6.22", groups: []*rds.DBParameterGroupStatus{group("default.postgres9.6", "in-sync"), group("default.postgres10", "in-sync")}, want: "default.postgres9.6"},
+		{engine: "aurora-mysql", version: "8.0.mysql_aurora.3.04.0", groups: []*rds.DBParameterGroupStatus{group("aurora-mysql8.0-app", "in-sync"), group("aurora-mysql5.7-app", "in-sync")}, want: "aurora-mysql8.0-app"},
+		{engine: "postgres", version: "15.4", groups: []*rds.DBParameterGroupStatus{group("a", "in-sync"), group("b", "in-sync")}, want: "b", ambiguous: true},
+		{engine: "postgres", version: "15.4", groups: []*rds.DBParameterGroupStatus{group("a", "applying"), group("b", "pending-reboot")}, want: "b", ambiguous: true},
+	}
+
+	for _, tt := range tests {
+		instance := &rds.DBInstance{Engine: aws.String(tt.engine), EngineVersion: aws.String(tt.version), DBParameterGroups: tt.groups}
+		got, ambiguous := exporter.ParameterGroup(instance)
+		if got != tt.want || ambiguous != tt.ambiguous {
+			t.Errorf("%v %v %v: got %q, %v, want %q, %v", tt.engine, tt.version, tt.groups, got, ambiguous, tt.want, tt.ambiguous)
+		}
+	}
+}
+
+func TestRawMaxConnections(t *testing.T) {
+	svc := &exportertest.RDS{
+		ParameterPages: map[string][][]*rds.Parameter{
+			"unset": {
+				{{ParameterName: aws.String("shared_buffers"), ParameterValue: aws.String("{DBInstanceClassMemory/32768}")}},
+				{{ParameterName: aws.String("work_mem"), ParameterValue: aws.String("4096")}},
+			},
+		},
+	}
+	collector := exporter.NewCollector(svc, exporter.Options{})
+
+	// every page is fetched when the parameter is not set
+	raw, err := collector.RawMaxConnections(context.Background(), "unset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw != "" {
+		t.Errorf("got %q, want empty", raw)
+	}
+	if n := svc.ParameterPagesFetched("unset"); n != 2 {
+		t.Errorf("fetched %d pages, want 2", n)
+	}
+
+	_, err = collector.RawMaxConnections(context.Background(), "missing")
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) || aerr.Code() != rds.ErrCodeDBParameterGroupNotFoundFault {
+		t.Errorf("got error %v, want %v", err, rds.ErrCodeDBParameterGroupNotFoundFault)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	aurora := exportertest.Instance("aurora", "aurora-postgresql", "group")
+	aurora.DBClusterIdentifier = aws.String("cluster")
+	svc := &exportertest.RDS{
+		InstancePages:  [][]*rds.DBInstance{{aurora}},
+		ParameterPages: map[string][][]*rds.Parameter{"group": exportertest.Parameters("LEAST({DBInstanceClassMemory/9531392},5000)")},
+	}
+
+	infos, err := exporter.Discover(context.Background(), exporter.DiscoverOptions{Client: svc, Region: "us-east-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("got %d instances, want 1", len(infos))
+	}
+	got := infos[0]
+	if got.DBInstanceIdentifier != "aurora" || got.DBEngine != "aurora-postgresql" || got.DBClusterIdentifier != "cluster" ||
+		got.Region != "us-east-1" || got.DBParameterGroupName != "group" || got.MaxConnections != 1800 || got.Resolution == nil {
+		t.Errorf("unexpected instance: %+v", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	throttling := awserr.New("Throttling", "Rate exceeded", nil)
+	svc := &exportertest.RDS{
+		InstancesErr:  throttling,
+		ParametersErr: map[string]error{"throttled": throttling},
+	}
+	collector := exporter.NewCollector(svc, exporter.Options{})
+
+	_, err := collector.Collect(context.Background())
+	var aerr awserr.Error
+	if !errors.Is(err, exporter.ErrThrottled) || !errors.As(err, &aerr) || aerr.Code() != "Throttling" {
+		t.Errorf("Collect: got error %v, want a throttled Throttling error", err)
+	}
+	_, err = collector.RawMaxConnections(context.Background(), "throttled")
+	if !errors.Is(err, exporter.ErrThrottled) {
+		t.Errorf("RawMaxConnections: got error %v, want a throttled error", err)
+	}
+	_, err = collector.RawMaxConnections(context.Background(), "missing")
+	if errors.Is(err, exporter.ErrThrottled) {
+		t.Errorf("RawMaxConnections: got error %v, want an error not throttled", err)
+	}
+
+	tests := []struct {
+		engine string
+		class  string
+		want   error
+	}{
+		{engine: "mysql", class: "db.r5.large", want: exporter.ErrUnsupportedEngine},
+		{engine: "postgres", class: "db.x9.large", want: maxcon.ErrUnknownInstanceClass},
+	}
+	for _, tt := range tests {
+		_, err := exporter.Resolve(tt.engine, "LEAST({DBInstanceClassMemory/9531392},5000)", tt.class)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Resolve(%v, %v): got error %v, want %v", tt.engine, tt.class, err, tt.want)
+		}
+	}
+}
+
+func ExampleCollector_Collect() {
+	// use rds.New(sess) outside of the tests
+	svc := &exportertest.RDS{
+		InstancePages: [][]*rds.DBInstance{{
+			exportertest.Instance("postgres", "postgres", "default.postgres15"),
+			exportertest.Instance("mysql", "mysql", "default.mysql8.0"),
+		}},
+		ParameterPages: map[string][][]*rds.Parameter{
+			"default.postgres15": exportertest.Parameters("LEAST({DBInstanceClassMemory/9531392},5000)"),
+			"default.mysql8.0":   exportertest.Parameters("{DBInstanceClassMemory/12582880}"),
+		},
+	}
+	collector := exporter.NewCollector(svc, exporter.Options{})
+
+	instances, err := collector.Collect(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	for _, instance := range instances {
+		if instance.SkipReason != "" {
+			fmt.Printf("%v: %v\n", *instance.DBInstance.DBInstanceIdentifier, instance.SkipReason)
+			continue
+		}
+		fmt.Printf("%v: %d\n", *instance.DBInstance.DBInstanceIdentifier, instance.MaxConnections)
+	}
+	// Output:
+	// postgres: 1800
+	// mysql: unsupported engine: mysql
+}
@@ -0,0 +1,166 @@
+// Package exportertest provides a fake RDS API for testing the code using
+// the exporter package.
+package exportertest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter"
+)
+
+// RDS serves the instances, the clusters and the parameters of each parameter
+// group and cluster parameter group in the given pages, and counts the pages
+// fetched. The parameter groups missing from ParameterPages and
+// ClusterParameterPages are not found.
+type RDS struct {
+	InstancePages         [][]*rds.DBInstance
+	ParameterPages        map[string][][]*rds.Parameter
+	ClusterPages          [][]*rds.DBCluster
+	ClusterParameterPages map[string][][]*rds.Parameter
+	// InstancesErr is returned by DescribeDBInstances
+	InstancesErr error
+	// ParametersErr is returned by DescribeDBParameters for each group
+	ParametersErr map[string]error
+	// ClustersErr is returned by DescribeDBClusters
+	ClustersErr error
+
+	mu      sync.Mutex
+	fetched map[string]int
+}
+
+var (
+	_ exporter.RDSAPI              = (*RDS)(nil)
+	_ exporter.ClusterParameterAPI = (*RDS)(nil)
+)
+
+// ParameterPagesFetched returns the number of pages of a parameter group
+// fetched so far.
+func (f *RDS) ParameterPagesFetched(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.fetched[name]
+}
+
+// ClusterParameterPagesFetched returns the number of pages of a cluster
+// parameter group fetched so far.
+func (f *RDS) ClusterParameterPagesFetched(name string) int {
+	return f.ParameterPagesFetched(clusterPrefix + name)
+}
+
+// clusterPrefix keeps the pages fetched of the cluster parameter groups apart
+// from the parameter groups of the same name.
+const clusterPrefix = "cluster:"
+
+func (f *RDS) count(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.fetched == nil {
+		f.fetched = map[string]int{}
+	}
+	f.fetched[name]++
+}
+
+func (f *RDS) DescribeDBInstancesWithContext(ctx context.Context, input *rds.DescribeDBInstancesInput, opts ...request.Option) (*rds.DescribeDBInstancesOutput, error) {
+	if f.InstancesErr != nil {
+		return nil, f.InstancesErr
+	}
+	for _, page := range f.InstancePages {
+		for _, instance := range page {
+			if aws.StringValue(instance.DBInstanceIdentifier) == aws.StringValue(input.DBInstanceIdentifier) {
+				return &rds.DescribeDBInstancesOutput{DBInstances: []*rds.DBInstance{instance}}, nil
+			}
+		}
+	}
+
+	return nil, awserr.New(rds.ErrCodeDBInstanceNotFoundFault, "DBInstance not found", nil)
+}
+
+func (f *RDS) DescribeDBInstancesPagesWithContext(ctx context.Context, input *rds.DescribeDBInstancesInput, fn func(*rds.DescribeDBInstancesOutput, bool) bool, opts ...request.Option) error {
+	if f.InstancesErr != nil {
+		return f.InstancesErr
+	}
+	for i, page := range f.InstancePages {
+		if !fn(&rds.DescribeDBInstancesOutput{DBInstances: page}, i == len(f.InstancePages)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (f *RDS) DescribeDBParametersPagesWithContext(ctx context.Context, input *rds.DescribeDBParametersInput, fn func(*rds.DescribeDBParametersOutput, bool) bool, opts ...request.Option) error {
+	name := aws.StringValue(input.DBParameterGroupName)
+	if err := f.ParametersErr[name]; err != nil {
+		return err
+	}
+	pages, ok := f.ParameterPages[name]
+	if !ok {
+		return awserr.New(rds.ErrCodeDBParameterGroupNotFoundFault, "DBParameterGroup not found", nil)
+	}
+	for i, page := range pages {
+		f.count(name)
+		if !fn(&rds.DescribeDBParametersOutput{Parameters: page}, i == len(pages)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (f *RDS) DescribeDBClustersPagesWithContext(ctx context.Context, input *rds.DescribeDBClustersInput, fn func(*rds.DescribeDBClustersOutput, bool) bool, opts ...request.Option) error {
+	if f.ClustersErr != nil {
+		return f.ClustersErr
+	}
+	for i, page := range f.ClusterPages {
+		if !fn(&rds.DescribeDBClustersOutput{DBClusters: page}, i == len(f.ClusterPages)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (f *RDS) DescribeDBClusterParametersPagesWithContext(ctx context.Context, input *rds.DescribeDBClusterParametersInput, fn func(*rds.DescribeDBClusterParametersOutput, bool) bool, opts ...request.Option) error {
+	name := aws.StringValue(input.DBClusterParameterGroupName)
+	pages, ok := f.ClusterParameterPages[name]
+	if !ok {
+		return awserr.New(rds.ErrCodeDBParameterGroupNotFoundFault, "DBClusterParameterGroup not found", nil)
+	}
+	for i, page := range pages {
+		f.count(clusterPrefix + name)
+		if !fn(&rds.DescribeDBClusterParametersOutput{Parameters: page}, i == len(pages)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Instance returns an available instance of the class db.r5.large using a
+// parameter group.
+func Instance(identifier, engine, group string) *rds.DBInstance {
+	return &rds.DBInstance{
+		DBInstanceIdentifier: aws.String(identifier),
+		DBInstanceClass:      aws.String("db.r5.large"),
+		Engine:               aws.String(engine),
+		DBInstanceStatus:     aws.String("available"),
+		DBParameterGroups: []*rds.DBParameterGroupStatus{
+			{DBParameterGroupName: aws.String(group), ParameterApplyStatus: aws.String("in-sync")},
+		},
+	}
+}
+
+// Parameters returns a single page of parameters setting max_connections.
+func Parameters(maxConnections string) [][]*rds.Parameter {
+	return [][]*rds.Parameter{{
+		{ParameterName: aws.String("shared_buffers"), ParameterValue: aws.String("{DBInstanceClassMemory/32768}")},
+		{ParameterName: aws.String("max_connections"), ParameterValue: aws.String(maxConnections)},
+	}}
+}
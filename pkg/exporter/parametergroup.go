@@ -0,0 +1,76 @@
+package exporter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// ParameterGroup returns the parameter group max_connections of an instance
+// is read from, among the groups attached to it: the one in-sync with the
+// instance, preferring the groups of the family of its engine version by
+// name, such as default.aurora-postgresql15 for Aurora PostgreSQL 15.4, the
+// last one of the candidates as RDS lists them otherwise. Ambiguous tells
+// that several groups were candidates, or that several groups are attached
+// and none is in-sync.
+func ParameterGroup(instance *rds.DBInstance) (name string, ambiguous bool) {
+	groups := instance.DBParameterGroups
+	if len(groups) == 0 {
+		return "", false
+	}
+
+	var candidates []*rds.DBParameterGroupStatus
+	for _, group := range groups {
+		if aws.StringValue(group.ParameterApplyStatus) == "in-sync" {
+			candidates = append(candidates, group)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = groups
+	}
+
+	if len(candidates) > 1 {
+		family := parameterGroupFamily(aws.StringValue(instance.Engine), aws.StringValue(instance.EngineVersion))
+		var matching []*rds.DBParameterGroupStatus
+		for _, group := range candidates {
+			if len(family) != 0 && strings.Contains(aws.StringValue(group.DBParameterGroupName), family) {
+				matching = append(matching, group)
+			}
+		}
+		if len(matching) != 0 {
+			candidates = matching
+		}
+	}
+
+	return aws.StringValue(candidates[len(candidates)-1].DBParameterGroupName), len(candidates) > 1
+}
+
+// parameterGroupFamily returns the family of the parameter groups of an
+// engine version, such as postgres15 for PostgreSQL 15.4, postgres9.6 for
+// 9.6.22, mysql8.0 for MySQL 8.0.35 and aurora-mysql8.0 for
+// 8.0.mysql_aurora.3.04.0, empty when the version is not known.
+func parameterGroupFamily(engine, version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+
+	major := parts[0] + "." + parts[1]
+	// the families of PostgreSQL 10 and later are named by the major version only
+	if n, err := strconv.Atoi(parts[0]); err == nil && n >= 10 && strings.Contains(engine, "postgres") {
+		major = parts[0]
+	}
+
+	return engine + major
+}
+
+func parameterGroupNames(instance *rds.DBInstance) []string {
+	names := make([]string, 0, len(instance.DBParameterGroups))
+	for _, group := range instance.DBParameterGroups {
+		names = append(names, aws.StringValue(group.DBParameterGroupName)+"="+aws.StringValue(group.ParameterApplyStatus))
+	}
+
+	return names
+}
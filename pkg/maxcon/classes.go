@@ -0,0 +1,112 @@
+package maxcon
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+)
+
+const gib = 1024 * 1024 * 1024
+
+// instanceClassesCSV lists the memory and the default max_connections of the
+// instance classes. A class is supported by adding a line, an engine by
+// adding a column.
+//
+//go:embed instance_classes.csv
+var instanceClassesCSV []byte
+
+//nolint:gochecknoglobals
+var instanceClasses = mustParseInstanceClasses(instanceClassesCSV)
+
+// InstanceClass is a line of the table of the instance classes.
+type InstanceClass struct {
+	Class string
+	// Memory is the nominal memory in bytes
+	Memory int64
+	// DefaultMaxConnections is max_connections of the default formula by
+	// engine, missing when it is not documented
+	DefaultMaxConnections map[string]int
+}
+
+// InstanceClasses returns the table of the instance classes, in the order of
+// the file.
+func InstanceClasses() []InstanceClass {
+	ret := make([]InstanceClass, 0, len(instanceClasses.order))
+	for _, class := range instanceClasses.order {
+		ret = append(ret, instanceClasses.byClass[class])
+	}
+
+	return ret
+}
+
+// InstanceClassMemory returns the nominal memory of an instance class in
+// bytes, 0 when it is unknown.
+func InstanceClassMemory(instanceClass string) int64 {
+	return instanceClasses.byClass[instanceClass].Memory
+}
+
+// DefaultMaxConnections returns the documented max_connections of the default
+// formula of engine for an instance class, and whether it is known.
+func DefaultMaxConnections(engine, instanceClass string) (int, bool) {
+	v, ok := instanceClasses.byClass[instanceClass].DefaultMaxConnections[engine]
+	return v, ok
+}
+
+type instanceClassTable struct {
+	order   []string
+	byClass map[string]InstanceClass
+}
+
+func mustParseInstanceClasses(data []byte) instanceClassTable {
+	t, err := parseInstanceClasses(data)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+// parseInstanceClasses parses the CSV of the instance classes, whose header
+// is "class,memory_gib" followed by an engine per column.
+func parseInstanceClasses(data []byte) (instanceClassTable, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		return instanceClassTable{}, fmt.Errorf("failed to read instance classes: %w", err)
+	}
+	if len(records) == 0 || len(records[0]) < 2 || records[0][0] != "class" || records[0][1] != "memory_gib" {
+		return instanceClassTable{}, fmt.Errorf("instance classes must start with the header class,memory_gib")
+	}
+	engines := records[0][2:]
+
+	t := instanceClassTable{byClass: map[string]InstanceClass{}}
+	for _, record := range records[1:] {
+		class := record[0]
+		if _, ok := t.byClass[class]; ok {
+			return instanceClassTable{}, fmt.Errorf("instance class %v is duplicated", class)
+		}
+		memory, err := strconv.ParseFloat(record[1], 64)
+		if err != nil || memory <= 0 {
+			return instanceClassTable{}, fmt.Errorf("invalid memory %q of instance class %v", record[1], class)
+		}
+
+		c := InstanceClass{Class: class, Memory: int64(memory * gib), DefaultMaxConnections: map[string]int{}}
+		for i, engine := range engines {
+			if len(record[i+2]) == 0 {
+				continue
+			}
+			v, err := strconv.Atoi(record[i+2])
+			if err != nil || v <= 0 {
+				return instanceClassTable{}, fmt.Errorf("invalid %v max connections %q of instance class %v", engine, record[i+2], class)
+			}
+			c.DefaultMaxConnections[engine] = v
+		}
+		t.order = append(t.order, class)
+		t.byClass[class] = c
+	}
+
+	return t, nil
+}
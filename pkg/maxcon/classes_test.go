@@ -0,0 +1,78 @@
+package maxcon
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestInstanceClasses(t *testing.T) {
+	classRep := regexp.MustCompile(`^db\.[a-z0-9]+\.[a-z0-9]+$`)
+
+	classes := InstanceClasses()
+	if len(classes) == 0 {
+		t.Fatal("no instance classes")
+	}
+	for _, c := range classes {
+		if !classRep.MatchString(c.Class) {
+			t.Errorf("instance class %q is not of the form db.family.size", c.Class)
+		}
+		if InstanceClassMemory(c.Class) != c.Memory {
+			t.Errorf("InstanceClassMemory(%v) = %d, want %d", c.Class, InstanceClassMemory(c.Class), c.Memory)
+		}
+	}
+}
+
+func TestParseInstanceClasses(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want map[string]InstanceClass
+		err  bool
+	}{
+		{
+			name: "engines",
+			csv:  "# comment\nclass,memory_gib,postgresql,mysql\ndb.r5.large,16,1800,\ndb.t3.micro,1,125,66\n",
+			want: map[string]InstanceClass{
+				"db.r5.large": {Class: "db.r5.large", Memory: 16 * gib, DefaultMaxConnections: map[string]int{"postgresql": 1800}},
+				"db.t3.micro": {Class: "db.t3.micro", Memory: 1 * gib, DefaultMaxConnections: map[string]int{"postgresql": 125, "mysql": 66}},
+			},
+		},
+		{name: "no header", csv: "db.r5.large,16,1800\n", err: true},
+		{name: "empty", csv: "", err: true},
+		{name: "duplicated", csv: "class,memory_gib\ndb.r5.large,16\ndb.r5.large,16\n", err: true},
+		{name: "invalid memory", csv: "class,memory_gib\ndb.r5.large,16GiB\n", err: true},
+		{name: "zero memory", csv: "class,memory_gib\ndb.r5.large,0\n", err: true},
+		{name: "invalid default", csv: "class,memory_gib,postgresql\ndb.r5.large,16,many\n", err: true},
+		{name: "missing column", csv: "class,memory_gib,postgresql\ndb.r5.large,16\n", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInstanceClasses([]byte(tt.csv))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.byClass) != len(tt.want) {
+				t.Fatalf("got %d instance classes, want %d", len(got.byClass), len(tt.want))
+			}
+			for class, want := range tt.want {
+				c := got.byClass[class]
+				if c.Class != want.Class || c.Memory != want.Memory || len(c.DefaultMaxConnections) != len(want.DefaultMaxConnections) {
+					t.Errorf("got %+v, want %+v", c, want)
+					continue
+				}
+				for engine, v := range want.DefaultMaxConnections {
+					if c.DefaultMaxConnections[engine] != v {
+						t.Errorf("%v max connections of %v = %d, want %d", engine, class, c.DefaultMaxConnections[engine], v)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package maxcon
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownInstanceClass is matched by the errors of an instance class
+// missing from the table, with errors.Is.
+var ErrUnknownInstanceClass = errors.New("instance class is not supported")
+
+// UnknownInstanceClassError is returned when the default max_connections of
+// an instance class is not known.
+type UnknownInstanceClassError struct {
+	Class string
+}
+
+func (e *UnknownInstanceClassError) Error() string {
+	return fmt.Sprintf("instance class %v is not supported", e.Class)
+}
+
+// Is makes the error match ErrUnknownInstanceClass.
+func (e *UnknownInstanceClassError) Is(target error) bool {
+	return target == ErrUnknownInstanceClass
+}
@@ -0,0 +1,238 @@
+package maxcon
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxDepth bounds the nesting of the formulas.
+const maxDepth = 32
+
+// ErrUnknownMemory is returned when a formula uses DBInstanceClassMemory of
+// an instance class whose memory is not known.
+var ErrUnknownMemory = errors.New("memory of the instance class is unknown")
+
+// Evaluate computes a parameter formula of RDS, such as
+// "GREATEST({log(DBInstanceClassMemory/805306368)*45},{log(DBInstanceClassMemory/8187281408)*1000})",
+// for an instance class with the given memory in bytes, 0 when unknown.
+//
+// The formulas are made of numbers, the DBInstanceClassMemory variable, the
+// operators + - * /, the GREATEST, LEAST and SUM functions, and log, which is
+// of base 2. Subexpressions are grouped with braces or parentheses.
+// ref: https://docs.aws.amazon.com/AmazonRDS/latest/UserGuide/USER_ParamValuesRef.html
+func Evaluate(formula string, memory int64) (float64, error) {
+	p := &parser{input: formula, memory: memory}
+
+	v, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpaces()
+	if p.pos != len(p.input) {
+		return 0, p.errorf("unexpected %q", p.input[p.pos:])
+	}
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("invalid formula %q: the result is not finite", formula)
+	}
+
+	return v, nil
+}
+
+type parser struct {
+	input  string
+	pos    int
+	depth  int
+	memory int64
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid formula %q at %d: %v", p.input, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// consume skips c and reports whether it was next.
+func (p *parser) consume(c byte) bool {
+	p.skipSpaces()
+	if p.pos < len(p.input) && p.input[p.pos] == c {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+// expr := term (("+" | "-") term)*
+func (p *parser) expr() (float64, error) {
+	v, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		switch {
+		case p.consume('+'):
+			w, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			v += w
+		case p.consume('-'):
+			w, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			v -= w
+		default:
+			return v, nil
+		}
+	}
+}
+
+// term := factor (("*" | "/") factor)*
+func (p *parser) term() (float64, error) {
+	v, err := p.factor()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		switch {
+		case p.consume('*'):
+			w, err := p.factor()
+			if err != nil {
+				return 0, err
+			}
+			v *= w
+		case p.consume('/'):
+			w, err := p.factor()
+			if err != nil {
+				return 0, err
+			}
+			if w == 0 {
+				return 0, p.errorf("division by zero")
+			}
+			v /= w
+		default:
+			return v, nil
+		}
+	}
+}
+
+// factor := number | variable | function "(" expr ("," expr)* ")"
+//
+//	| "{" expr "}" | "(" expr ")" | "-" factor
+func (p *parser) factor() (float64, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return 0, p.errorf("nested too deeply")
+	}
+
+	switch {
+	case p.consume('-'):
+		v, err := p.factor()
+		return -v, err
+	case p.consume('{'):
+		return p.group('}')
+	case p.consume('('):
+		return p.group(')')
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && (unicode.IsLetter(rune(p.input[p.pos])) || unicode.IsDigit(rune(p.input[p.pos])) || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	token := p.input[start:p.pos]
+	if len(token) == 0 {
+		return 0, p.errorf("expected a number, a variable or a function")
+	}
+
+	// Inf and NaN are not numbers of the formulas
+	if unicode.IsDigit(rune(token[0])) || token[0] == '.' {
+		v, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return 0, p.errorf("invalid number %v", token)
+		}
+		return v, nil
+	}
+	if p.consume('(') {
+		return p.call(token)
+	}
+	if token == "DBInstanceClassMemory" {
+		if p.memory == 0 {
+			return 0, ErrUnknownMemory
+		}
+		return float64(p.memory), nil
+	}
+
+	return 0, p.errorf("unknown variable %v", token)
+}
+
+func (p *parser) group(end byte) (float64, error) {
+	v, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	if !p.consume(end) {
+		return 0, p.errorf("expected %q", end)
+	}
+
+	return v, nil
+}
+
+func (p *parser) call(name string) (float64, error) {
+	var args []float64
+	for {
+		v, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, v)
+		if p.consume(')') {
+			break
+		}
+		if !p.consume(',') {
+			return 0, p.errorf("expected \",\" or \")\"")
+		}
+	}
+
+	switch strings.ToUpper(name) {
+	case "GREATEST":
+		v := args[0]
+		for _, arg := range args[1:] {
+			v = math.Max(v, arg)
+		}
+		return v, nil
+	case "LEAST":
+		v := args[0]
+		for _, arg := range args[1:] {
+			v = math.Min(v, arg)
+		}
+		return v, nil
+	case "SUM":
+		var v float64
+		for _, arg := range args {
+			v += arg
+		}
+		return v, nil
+	case "LOG":
+		if len(args) != 1 {
+			return 0, p.errorf("log takes 1 argument, got %d", len(args))
+		}
+		if args[0] <= 0 {
+			return 0, p.errorf("log of %v", args[0])
+		}
+		return math.Log2(args[0]), nil
+	}
+
+	return 0, p.errorf("unknown function %v", name)
+}
@@ -0,0 +1,76 @@
+package maxcon_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
+)
+
+func TestEvaluate(t *testing.T) {
+	const memory = 16 * 1024 * 1024 * 1024
+
+	tests := []struct {
+		formula string
+		memory  int64
+		want    float64
+		err     bool
+	}{
+		{formula: "100", want: 100},
+		{formula: "LEAST({DBInstanceClassMemory/9531392},5000)", memory: memory, want: 1802.5},
+		{formula: "{DBInstanceClassMemory/12582880}", memory: memory, want: 1365.3},
+		{formula: "GREATEST({log(DBInstanceClassMemory/805306368)*45},{log(DBInstanceClassMemory/8187281408)*1000})", memory: memory, want: 1069.3},
+		{formula: "SUM({DBInstanceClassMemory/1073741824}, 10) * 2 - 1", memory: memory, want: 51},
+		{formula: "-(2 + 3)", want: -5},
+		{formula: "{DBInstanceClassMemory/9531392}", err: true},
+		{formula: "{DBInstanceClassMemory/0}", memory: memory, err: true},
+		{formula: "LEAST({DBInstanceClassMemory/9531392},5000", memory: memory, err: true},
+		{formula: "MEDIAN(1,2)", err: true},
+		{formula: "AllocatedStorage/10", err: true},
+		{formula: "log(0)", err: true},
+		{formula: "1 2", err: true},
+		{formula: "", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.formula, func(t *testing.T) {
+			got, err := maxcon.Evaluate(tt.formula, tt.memory)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tt.want) > 0.1 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateUnknownMemory(t *testing.T) {
+	_, err := maxcon.Evaluate("{DBInstanceClassMemory/9531392}", 0)
+	if !errors.Is(err, maxcon.ErrUnknownMemory) {
+		t.Errorf("got error %v, want %v", err, maxcon.ErrUnknownMemory)
+	}
+}
+
+func FuzzEvaluate(f *testing.F) {
+	f.Add("LEAST({DBInstanceClassMemory/9531392},5000)", int64(16*1024*1024*1024))
+	f.Add("GREATEST({log(DBInstanceClassMemory/805306368)*45},{log(DBInstanceClassMemory/8187281408)*1000})", int64(8*1024*1024*1024))
+	f.Add("{DBInstanceClassMemory/12582880}", int64(0))
+	f.Add("SUM(1, -2) * (3 / 4)", int64(0))
+	f.Add("1e308*1e308", int64(0))
+	f.Add("Inf", int64(0))
+
+	f.Fuzz(func(t *testing.T, formula string, memory int64) {
+		v, err := maxcon.Evaluate(formula, memory)
+		if err == nil && (math.IsInf(v, 0) || math.IsNaN(v)) {
+			t.Errorf("Evaluate(%q, %d) = %v", formula, memory, v)
+		}
+	})
+}
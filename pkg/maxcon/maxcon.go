@@ -0,0 +1,38 @@
+// Package maxcon computes max_connections of RDS instances from the raw value
+// of the parameter. The formula evaluator and the memory of the instance
+// classes are shared by the engines, whose defaults live in a subpackage
+// each.
+package maxcon
+
+// Branches of the resolution, which tell how max_connections was resolved.
+// BranchEngineDefault is the default formula of the engine, resolved when no
+// parameter group sets max_connections.
+const (
+	BranchDefaultFormula = "default formula"
+	BranchFormula        = "formula"
+	BranchExplicitValue  = "explicit value"
+	BranchNoValue        = "no value"
+	BranchEngineDefault  = "engine default"
+)
+
+// Resolution records each step of resolving max_connections from the raw
+// parameter value, for debugging.
+type Resolution struct {
+	Raw    string
+	Branch string
+	// Divisor and Limit are the arguments of the default formula
+	Divisor int64
+	Limit   int
+	// Memory is the nominal memory of the instance class in bytes, 0 when unknown
+	Memory int64
+	Value  int
+}
+
+// Resolver resolves max_connections of an instance class from the raw
+// parameter value, for an engine.
+type Resolver func(rawMaxConnections string, instanceClass string) (Resolution, error)
+
+// ServerlessResolver resolves max_connections of an Aurora Serverless v2
+// instance from the raw parameter value and the maximum capacity of its
+// cluster in ACUs, for an engine.
+type ServerlessResolver func(rawMaxConnections string, maxCapacity float64) (Resolution, error)
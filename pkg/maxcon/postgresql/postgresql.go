@@ -0,0 +1,135 @@
+// Package postgresql resolves max_connections of the RDS PostgreSQL and
+// Aurora PostgreSQL instances.
+package postgresql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
+)
+
+// maxMaxConnections is the largest max_connections PostgreSQL accepts.
+const maxMaxConnections = 262143
+
+// DefaultFormula is the max_connections of the default parameter groups of
+// RDS PostgreSQL and Aurora PostgreSQL, which applies when it is not set.
+const DefaultFormula = "LEAST({DBInstanceClassMemory/9531392},5000)"
+
+// defaultDivisor and defaultLimit are the arguments of DefaultFormula.
+const (
+	defaultDivisor = 9531392
+	defaultLimit   = 5000
+)
+
+// Resolve parses rawMaxConnections and calculates with the instance class.
+// The default formula "LEAST({DBInstanceClassMemory/9531392},5000)", shared
+// by RDS PostgreSQL and Aurora PostgreSQL, is resolved with the documented
+// values of the instance classes, and the other formulas, including variants
+// of the default one, with their nominal memory. A value out of the range PostgreSQL accepts is an error.
+func Resolve(rawMaxConnections string, instanceClass string) (maxcon.Resolution, error) {
+	return resolve(rawMaxConnections, maxcon.InstanceClassMemory(instanceClass), func(maxcon.Resolution) (int, error) {
+		return DefaultMaxConnections(instanceClass)
+	})
+}
+
+// serverlessDefaults are the documented max_connections of the default
+// formula for the maximum capacities of Aurora Serverless v2 in ACUs.
+// ref: https://docs.aws.amazon.com/AmazonRDS/latest/AuroraUserGuide/aurora-serverless-v2.setting-capacity.html
+//
+//nolint:gochecknoglobals
+var serverlessDefaults = map[float64]int{1: 189, 4: 823, 8: 1669, 16: 3360, 32: 5000, 64: 5000, 128: 5000, 256: 5000}
+
+// ResolveServerless parses rawMaxConnections and calculates with the maximum
+// capacity in ACUs of the cluster of an Aurora Serverless v2 instance, whose
+// class is maxcon.ServerlessInstanceClass. The default formula is resolved
+// with the documented values of the maximum capacities, and the other
+// capacities and formulas with the nominal memory of the capacity.
+func ResolveServerless(rawMaxConnections string, maxCapacity float64) (maxcon.Resolution, error) {
+	if maxCapacity <= 0 {
+		return maxcon.Resolution{Raw: rawMaxConnections, Branch: maxcon.BranchNoValue},
+			fmt.Errorf("the maximum capacity of the cluster is not known: %w", &maxcon.UnknownInstanceClassError{Class: maxcon.ServerlessInstanceClass})
+	}
+
+	return resolve(rawMaxConnections, maxcon.ServerlessMemory(maxCapacity), func(r maxcon.Resolution) (int, error) {
+		if v, ok := serverlessDefaults[maxCapacity]; ok {
+			return v, nil
+		}
+		return int(math.Min(float64(r.Memory/r.Divisor), float64(r.Limit))), nil
+	})
+}
+
+// resolve resolves rawMaxConnections for the memory, with defaultValue for
+// the default formula.
+func resolve(rawMaxConnections string, memory int64, defaultValue func(r maxcon.Resolution) (int, error)) (maxcon.Resolution, error) {
+	r := maxcon.Resolution{
+		Raw:    rawMaxConnections,
+		Branch: maxcon.BranchNoValue,
+		Memory: memory,
+	}
+	raw := strings.TrimSpace(rawMaxConnections)
+
+	// only the default formula itself has documented values
+	if raw == DefaultFormula {
+		r.Branch = maxcon.BranchDefaultFormula
+		r.Divisor = defaultDivisor
+		r.Limit = defaultLimit
+
+		ret, err := defaultValue(r)
+		if err != nil {
+			return r, fmt.Errorf("failed to get default max connections: %w", err)
+		}
+		r.Value = ret
+		return r, nil
+	}
+
+	if v, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		r.Branch = maxcon.BranchExplicitValue
+		return checkRange(r, float64(v))
+	}
+
+	if len(raw) != 0 {
+		r.Branch = maxcon.BranchFormula
+		v, err := maxcon.Evaluate(raw, r.Memory)
+		if err != nil {
+			return r, fmt.Errorf("failed to evaluate max connections: %w", err)
+		}
+		return checkRange(r, math.Floor(v))
+	}
+
+	return r, nil
+}
+
+func checkRange(r maxcon.Resolution, v float64) (maxcon.Resolution, error) {
+	if v < 0 || v > maxMaxConnections {
+		return r, fmt.Errorf("max connections %v is out of [0, %d]", v, maxMaxConnections)
+	}
+	r.Value = int(v)
+
+	return r, nil
+}
+
+// engine is the column of the defaults in the table of the instance classes.
+const engine = "postgresql"
+
+// DefaultMaxConnections returns max_connections of the default formula for
+// an instance class.
+//
+// Aurora PostgreSQL: "LEAST({DBInstanceClassMemory/9531392},5000)"
+// Default is set to this value for all instance classes.
+// Note that the DBInstance Class Memory, which is 5000, is,
+// DBInstanceClassMemory = 5000 * 9531392(Byte) = 47656960000(Byte) = 47.65696(GB)
+// In other words, for instances with a memory size larger than 47.65696 GB,
+// max_connection is 5000.
+// The values are the postgresql column of pkg/maxcon/instance_classes.csv,
+// and the other classes return a *maxcon.UnknownInstanceClassError.
+func DefaultMaxConnections(instanceClass string) (int, error) {
+	ret, ok := maxcon.DefaultMaxConnections(engine, instanceClass)
+	if !ok {
+		return 0, &maxcon.UnknownInstanceClassError{Class: instanceClass}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,167 @@
+package postgresql_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon/postgresql"
+)
+
+func FuzzResolve(f *testing.F) {
+	f.Add("LEAST({DBInstanceClassMemory/9531392},5000)", "db.r5.large")
+	f.Add("LEAST({DBInstanceClassMemory/9531392},5000)", "db.x9.large")
+	f.Add("100", "db.t3.micro")
+	f.Add("-1", "db.t3.micro")
+	f.Add("99999999999999999999", "db.t3.micro")
+	f.Add("GREATEST({DBInstanceClassMemory/9531392},100)", "db.m5.large")
+	f.Add("{DBInstanceClassMemory*1e300}", "db.m5.large")
+	f.Add("", "db.r5.large")
+
+	f.Fuzz(func(t *testing.T, raw, class string) {
+		r, err := postgresql.Resolve(raw, class)
+		if r.Raw != raw {
+			t.Errorf("Resolve(%q, %q).Raw = %q", raw, class, r.Raw)
+		}
+		switch r.Branch {
+		case maxcon.BranchDefaultFormula, maxcon.BranchFormula, maxcon.BranchExplicitValue, maxcon.BranchNoValue:
+		default:
+			t.Errorf("Resolve(%q, %q).Branch = %q", raw, class, r.Branch)
+		}
+		if err != nil {
+			return
+		}
+		// PostgreSQL does not start with more
+		if r.Value < 0 || r.Value > 262143 {
+			t.Errorf("Resolve(%q, %q) = %d", raw, class, r.Value)
+		}
+	})
+}
+
+func TestDefaultMaxConnections(t *testing.T) {
+	tests := []struct {
+		class string
+		want  int
+		err   bool
+	}{
+		{class: "db.t3.micro", want: 125},
+		{class: "db.r4.large", want: 1600},
+		{class: "db.r5.large", want: 1800},
+		{class: "db.r5.24xlarge", want: 5000},
+		{class: "db.x9.large", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.class, func(t *testing.T) {
+			got, err := postgresql.DefaultMaxConnections(tt.class)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDefaultMaxConnectionsFormula validates the documented values of the
+// table against the default formula with the nominal memory. They differ by
+// the memory RDS reserves, and for the smallest classes by a rounding up of
+// the documentation.
+func TestDefaultMaxConnectionsFormula(t *testing.T) {
+	const (
+		formula   = "LEAST({DBInstanceClassMemory/9531392},5000)"
+		tolerance = 0.12
+	)
+
+	for _, c := range maxcon.InstanceClasses() {
+		documented, ok := c.DefaultMaxConnections["postgresql"]
+		if !ok {
+			continue
+		}
+		t.Run(c.Class, func(t *testing.T) {
+			computed, err := maxcon.Evaluate(formula, c.Memory)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if computed == 5000 {
+				if documented != 5000 {
+					t.Errorf("documented %d, want the limit 5000", documented)
+				}
+				return
+			}
+			if math.Abs(float64(documented)-computed) > computed*tolerance {
+				t.Errorf("documented %d, computed %.1f from %d bytes", documented, computed, c.Memory)
+			}
+		})
+	}
+}
+
+func TestResolveServerless(t *testing.T) {
+	tests := []struct {
+		raw         string
+		maxCapacity float64
+		want        int
+		err         bool
+	}{
+		{raw: "LEAST({DBInstanceClassMemory/9531392},5000)", maxCapacity: 16, want: 3360},
+		{raw: "LEAST({DBInstanceClassMemory/9531392},5000)", maxCapacity: 128, want: 5000},
+		// not documented, from the nominal memory of 2 GiB per ACU
+		{raw: "LEAST({DBInstanceClassMemory/9531392},5000)", maxCapacity: 2.5, want: 563},
+		{raw: "{DBInstanceClassMemory/16777216}", maxCapacity: 8, want: 1024},
+		{raw: "100", maxCapacity: 8, want: 100},
+		{raw: "LEAST({DBInstanceClassMemory/9531392},5000)", err: true},
+	}
+
+	for _, tt := range tests {
+		r, err := postgresql.ResolveServerless(tt.raw, tt.maxCapacity)
+		if tt.err {
+			if !errors.Is(err, maxcon.ErrUnknownInstanceClass) {
+				t.Errorf("ResolveServerless(%q, %v): got error %v, want an unknown instance class", tt.raw, tt.maxCapacity, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ResolveServerless(%q, %v): %v", tt.raw, tt.maxCapacity, err)
+			continue
+		}
+		if r.Value != tt.want {
+			t.Errorf("ResolveServerless(%q, %v) = %d, want %d", tt.raw, tt.maxCapacity, r.Value, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		raw    string
+		class  string
+		want   int
+		branch string
+	}{
+		{raw: "LEAST({DBInstanceClassMemory/9531392},5000)", class: "db.r5.large", want: 1800, branch: maxcon.BranchDefaultFormula},
+		{raw: " LEAST({DBInstanceClassMemory/9531392},5000) ", class: "db.t3.micro", want: 125, branch: maxcon.BranchDefaultFormula},
+		// the variants of the default formula are evaluated with the nominal memory of 16 GiB
+		{raw: "LEAST({DBInstanceClassMemory/4765696},5000)", class: "db.r5.large", want: 3604, branch: maxcon.BranchFormula},
+		{raw: "LEAST({DBInstanceClassMemory/9531392},1000)", class: "db.r5.large", want: 1000, branch: maxcon.BranchFormula},
+		{raw: "GREATEST(LEAST({DBInstanceClassMemory/9531392},5000),2000)", class: "db.r5.large", want: 2000, branch: maxcon.BranchFormula},
+		{raw: "100", class: "db.r5.large", want: 100, branch: maxcon.BranchExplicitValue},
+	}
+
+	for _, tt := range tests {
+		r, err := postgresql.Resolve(tt.raw, tt.class)
+		if err != nil {
+			t.Errorf("Resolve(%q, %q): %v", tt.raw, tt.class, err)
+			continue
+		}
+		if r.Value != tt.want || r.Branch != tt.branch {
+			t.Errorf("Resolve(%q, %q) = %d of %v, want %d of %v", tt.raw, tt.class, r.Value, r.Branch, tt.want, tt.branch)
+		}
+	}
+}
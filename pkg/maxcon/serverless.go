@@ -0,0 +1,15 @@
+package maxcon
+
+// ServerlessInstanceClass is the instance class of the Aurora Serverless v2
+// instances, whose memory follows the capacity of their cluster rather than
+// the table of the instance classes.
+const ServerlessInstanceClass = "db.serverless"
+
+// acuMemory is the memory of an Aurora capacity unit.
+const acuMemory = 2 * gib
+
+// ServerlessMemory returns the nominal memory in bytes of a capacity of
+// Aurora Serverless v2 in ACUs.
+func ServerlessMemory(capacity float64) int64 {
+	return int64(capacity * acuMemory)
+}
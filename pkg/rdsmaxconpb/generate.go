@@ -0,0 +1,4 @@
+// Package rdsmaxconpb contains the generated gRPC API of the exporter.
+package rdsmaxconpb
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/chaspy/aws-rds-maxcon-prometheus-exporter --go-grpc_out=../.. --go-grpc_opt=module=github.com/chaspy/aws-rds-maxcon-prometheus-exporter rdsmaxcon/v1/rdsmaxcon.proto
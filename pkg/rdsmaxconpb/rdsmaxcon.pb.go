@@ -0,0 +1,367 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        v5.27.1
+// source: rdsmaxcon/v1/rdsmaxcon.proto
+
+package rdsmaxconpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListInstancesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ListInstancesRequest) Reset() {
+	*x = ListInstancesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rdsmaxcon_v1_rdsmaxcon_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListInstancesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListInstancesRequest) ProtoMessage() {}
+
+func (x *ListInstancesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rdsmaxcon_v1_rdsmaxcon_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListInstancesRequest.ProtoReflect.Descriptor instead.
+func (*ListInstancesRequest) Descriptor() ([]byte, []int) {
+	return file_rdsmaxcon_v1_rdsmaxcon_proto_rawDescGZIP(), []int{0}
+}
+
+type GetInstanceRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	DbInstanceIdentifier string `protobuf:"bytes,1,opt,name=db_instance_identifier,json=dbInstanceIdentifier,proto3" json:"db_instance_identifier,omitempty"`
+	Region               string `protobuf:"bytes,2,opt,name=region,proto3" json:"region,omitempty"`
+}
+
+func (x *GetInstanceRequest) Reset() {
+	*x = GetInstanceRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rdsmaxcon_v1_rdsmaxcon_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetInstanceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetInstanceRequest) ProtoMessage() {}
+
+func (x *GetInstanceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rdsmaxcon_v1_rdsmaxcon_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetInstanceRequest.ProtoReflect.Descriptor instead.
+func (*GetInstanceRequest) Descriptor() ([]byte, []int) {
+	return file_rdsmaxcon_v1_rdsmaxcon_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetInstanceRequest) GetDbInstanceIdentifier() string {
+	if x != nil {
+		return x.DbInstanceIdentifier
+	}
+	return ""
+}
+
+func (x *GetInstanceRequest) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+type Instance struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	DbInstanceIdentifier string   `protobuf:"bytes,1,opt,name=db_instance_identifier,json=dbInstanceIdentifier,proto3" json:"db_instance_identifier,omitempty"`
+	DbInstanceClass      string   `protobuf:"bytes,2,opt,name=db_instance_class,json=dbInstanceClass,proto3" json:"db_instance_class,omitempty"`
+	Engine               string   `protobuf:"bytes,3,opt,name=engine,proto3" json:"engine,omitempty"`
+	MaxConnections       int64    `protobuf:"varint,4,opt,name=max_connections,json=maxConnections,proto3" json:"max_connections,omitempty"`
+	DbParameterGroupName string   `protobuf:"bytes,5,opt,name=db_parameter_group_name,json=dbParameterGroupName,proto3" json:"db_parameter_group_name,omitempty"`
+	DbClusterIdentifier  string   `protobuf:"bytes,6,opt,name=db_cluster_identifier,json=dbClusterIdentifier,proto3" json:"db_cluster_identifier,omitempty"`
+	DatabaseConnections  *float64 `protobuf:"fixed64,7,opt,name=database_connections,json=databaseConnections,proto3,oneof" json:"database_connections,omitempty"`
+	Region               string   `protobuf:"bytes,8,opt,name=region,proto3" json:"region,omitempty"`
+}
+
+func (x *Instance) Reset() {
+	*x = Instance{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rdsmaxcon_v1_rdsmaxcon_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Instance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Instance) ProtoMessage() {}
+
+func (x *Instance) ProtoReflect() protoreflect.Message {
+	mi := &file_rdsmaxcon_v1_rdsmaxcon_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Instance.ProtoReflect.Descriptor instead.
+func (*Instance) Descriptor() ([]byte, []int) {
+	return file_rdsmaxcon_v1_rdsmaxcon_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Instance) GetDbInstanceIdentifier() string {
+	if x != nil {
+		return x.DbInstanceIdentifier
+	}
+	return ""
+}
+
+func (x *Instance) GetDbInstanceClass() string {
+	if x != nil {
+		return x.DbInstanceClass
+	}
+	return ""
+}
+
+func (x *Instance) GetEngine() string {
+	if x != nil {
+		return x.Engine
+	}
+	return ""
+}
+
+func (x *Instance) GetMaxConnections() int64 {
+	if x != nil {
+		return x.MaxConnections
+	}
+	return 0
+}
+
+func (x *Instance) GetDbParameterGroupName() string {
+	if x != nil {
+		return x.DbParameterGroupName
+	}
+	return ""
+}
+
+func (x *Instance) GetDbClusterIdentifier() string {
+	if x != nil {
+		return x.DbClusterIdentifier
+	}
+	return ""
+}
+
+func (x *Instance) GetDatabaseConnections() float64 {
+	if x != nil && x.DatabaseConnections != nil {
+		return *x.DatabaseConnections
+	}
+	return 0
+}
+
+func (x *Instance) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+var File_rdsmaxcon_v1_rdsmaxcon_proto protoreflect.FileDescriptor
+
+var file_rdsmaxcon_v1_rdsmaxcon_proto_rawDesc = []byte{
+	0x0a, 0x1c, 0x72, 0x64, 0x73, 0x6d, 0x61, 0x78, 0x63, 0x6f, 0x6e, 0x2f, 0x76, 0x31, 0x2f, 0x72,
+	0x64, 0x73, 0x6d, 0x61, 0x78, 0x63, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0c,
+	0x72, 0x64, 0x73, 0x6d, 0x61, 0x78, 0x63, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x22, 0x16, 0x0a, 0x14,
+	0x4c, 0x69, 0x73, 0x74, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x22, 0x62, 0x0a, 0x12, 0x47, 0x65, 0x74, 0x49, 0x6e, 0x73, 0x74, 0x61,
+	0x6e, 0x63, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x34, 0x0a, 0x16, 0x64, 0x62,
+	0x5f, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69,
+	0x66, 0x69, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x14, 0x64, 0x62, 0x49, 0x6e,
+	0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72,
+	0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x72, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x22, 0x81, 0x03, 0x0a, 0x08, 0x49, 0x6e, 0x73,
+	0x74, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x34, 0x0a, 0x16, 0x64, 0x62, 0x5f, 0x69, 0x6e, 0x73, 0x74,
+	0x61, 0x6e, 0x63, 0x65, 0x5f, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x14, 0x64, 0x62, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63,
+	0x65, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x12, 0x2a, 0x0a, 0x11, 0x64,
+	0x62, 0x5f, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x5f, 0x63, 0x6c, 0x61, 0x73, 0x73,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x64, 0x62, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e,
+	0x63, 0x65, 0x43, 0x6c, 0x61, 0x73, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x65, 0x6e, 0x67, 0x69, 0x6e,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x65, 0x6e, 0x67, 0x69, 0x6e, 0x65, 0x12,
+	0x27, 0x0a, 0x0f, 0x6d, 0x61, 0x78, 0x5f, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0e, 0x6d, 0x61, 0x78, 0x43, 0x6f, 0x6e,
+	0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x35, 0x0a, 0x17, 0x64, 0x62, 0x5f, 0x70,
+	0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x5f, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x5f, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x14, 0x64, 0x62, 0x50, 0x61, 0x72,
+	0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x4e, 0x61, 0x6d, 0x65, 0x12,
+	0x32, 0x0a, 0x15, 0x64, 0x62, 0x5f, 0x63, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x5f, 0x69, 0x64,
+	0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x13,
+	0x64, 0x62, 0x43, 0x6c, 0x75, 0x73, 0x74, 0x65, 0x72, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66,
+	0x69, 0x65, 0x72, 0x12, 0x36, 0x0a, 0x14, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x5f,
+	0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x01, 0x48, 0x00, 0x52, 0x13, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x43, 0x6f, 0x6e,
+	0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x88, 0x01, 0x01, 0x12, 0x16, 0x0a, 0x06, 0x72,
+	0x65, 0x67, 0x69, 0x6f, 0x6e, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x67,
+	0x69, 0x6f, 0x6e, 0x42, 0x17, 0x0a, 0x15, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65,
+	0x5f, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x32, 0xa9, 0x01, 0x0a,
+	0x0f, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x12, 0x4d, 0x0a, 0x0d, 0x4c, 0x69, 0x73, 0x74, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65,
+	0x73, 0x12, 0x22, 0x2e, 0x72, 0x64, 0x73, 0x6d, 0x61, 0x78, 0x63, 0x6f, 0x6e, 0x2e, 0x76, 0x31,
+	0x2e, 0x4c, 0x69, 0x73, 0x74, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x72, 0x64, 0x73, 0x6d, 0x61, 0x78, 0x63, 0x6f,
+	0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x30, 0x01, 0x12,
+	0x47, 0x0a, 0x0b, 0x47, 0x65, 0x74, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x20,
+	0x2e, 0x72, 0x64, 0x73, 0x6d, 0x61, 0x78, 0x63, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65,
+	0x74, 0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x16, 0x2e, 0x72, 0x64, 0x73, 0x6d, 0x61, 0x78, 0x63, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e,
+	0x49, 0x6e, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x42, 0x46, 0x5a, 0x44, 0x67, 0x69, 0x74, 0x68,
+	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x63, 0x68, 0x61, 0x73, 0x70, 0x79, 0x2f, 0x61, 0x77,
+	0x73, 0x2d, 0x72, 0x64, 0x73, 0x2d, 0x6d, 0x61, 0x78, 0x63, 0x6f, 0x6e, 0x2d, 0x70, 0x72, 0x6f,
+	0x6d, 0x65, 0x74, 0x68, 0x65, 0x75, 0x73, 0x2d, 0x65, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x72,
+	0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x72, 0x64, 0x73, 0x6d, 0x61, 0x78, 0x63, 0x6f, 0x6e, 0x70, 0x62,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_rdsmaxcon_v1_rdsmaxcon_proto_rawDescOnce sync.Once
+	file_rdsmaxcon_v1_rdsmaxcon_proto_rawDescData = file_rdsmaxcon_v1_rdsmaxcon_proto_rawDesc
+)
+
+func file_rdsmaxcon_v1_rdsmaxcon_proto_rawDescGZIP() []byte {
+	file_rdsmaxcon_v1_rdsmaxcon_proto_rawDescOnce.Do(func() {
+		file_rdsmaxcon_v1_rdsmaxcon_proto_rawDescData = protoimpl.X.CompressGZIP(file_rdsmaxcon_v1_rdsmaxcon_proto_rawDescData)
+	})
+	return file_rdsmaxcon_v1_rdsmaxcon_proto_rawDescData
+}
+
+var file_rdsmaxcon_v1_rdsmaxcon_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_rdsmaxcon_v1_rdsmaxcon_proto_goTypes = []any{
+	(*ListInstancesRequest)(nil), // 0: rdsmaxcon.v1.ListInstancesRequest
+	(*GetInstanceRequest)(nil),   // 1: rdsmaxcon.v1.GetInstanceRequest
+	(*Instance)(nil),             // 2: rdsmaxcon.v1.Instance
+}
+var file_rdsmaxcon_v1_rdsmaxcon_proto_depIdxs = []int32{
+	0, // 0: rdsmaxcon.v1.InstanceService.ListInstances:input_type -> rdsmaxcon.v1.ListInstancesRequest
+	1, // 1: rdsmaxcon.v1.InstanceService.GetInstance:input_type -> rdsmaxcon.v1.GetInstanceRequest
+	2, // 2: rdsmaxcon.v1.InstanceService.ListInstances:output_type -> rdsmaxcon.v1.Instance
+	2, // 3: rdsmaxcon.v1.InstanceService.GetInstance:output_type -> rdsmaxcon.v1.Instance
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_rdsmaxcon_v1_rdsmaxcon_proto_init() }
+func file_rdsmaxcon_v1_rdsmaxcon_proto_init() {
+	if File_rdsmaxcon_v1_rdsmaxcon_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_rdsmaxcon_v1_rdsmaxcon_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*ListInstancesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rdsmaxcon_v1_rdsmaxcon_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*GetInstanceRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rdsmaxcon_v1_rdsmaxcon_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*Instance); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_rdsmaxcon_v1_rdsmaxcon_proto_msgTypes[2].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_rdsmaxcon_v1_rdsmaxcon_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_rdsmaxcon_v1_rdsmaxcon_proto_goTypes,
+		DependencyIndexes: file_rdsmaxcon_v1_rdsmaxcon_proto_depIdxs,
+		MessageInfos:      file_rdsmaxcon_v1_rdsmaxcon_proto_msgTypes,
+	}.Build()
+	File_rdsmaxcon_v1_rdsmaxcon_proto = out.File
+	file_rdsmaxcon_v1_rdsmaxcon_proto_rawDesc = nil
+	file_rdsmaxcon_v1_rdsmaxcon_proto_goTypes = nil
+	file_rdsmaxcon_v1_rdsmaxcon_proto_depIdxs = nil
+}
@@ -0,0 +1,184 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             v5.27.1
+// source: rdsmaxcon/v1/rdsmaxcon.proto
+
+package rdsmaxconpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	InstanceService_ListInstances_FullMethodName = "/rdsmaxcon.v1.InstanceService/ListInstances"
+	InstanceService_GetInstance_FullMethodName   = "/rdsmaxcon.v1.InstanceService/GetInstance"
+)
+
+// InstanceServiceClient is the client API for InstanceService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// InstanceService serves the instances discovered by the last snapshot.
+type InstanceServiceClient interface {
+	// ListInstances streams every instance discovered by the last snapshot.
+	ListInstances(ctx context.Context, in *ListInstancesRequest, opts ...grpc.CallOption) (InstanceService_ListInstancesClient, error)
+	// GetInstance returns a single instance by its identifier.
+	GetInstance(ctx context.Context, in *GetInstanceRequest, opts ...grpc.CallOption) (*Instance, error)
+}
+
+type instanceServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewInstanceServiceClient(cc grpc.ClientConnInterface) InstanceServiceClient {
+	return &instanceServiceClient{cc}
+}
+
+func (c *instanceServiceClient) ListInstances(ctx context.Context, in *ListInstancesRequest, opts ...grpc.CallOption) (InstanceService_ListInstancesClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &InstanceService_ServiceDesc.Streams[0], InstanceService_ListInstances_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &instanceServiceListInstancesClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type InstanceService_ListInstancesClient interface {
+	Recv() (*Instance, error)
+	grpc.ClientStream
+}
+
+type instanceServiceListInstancesClient struct {
+	grpc.ClientStream
+}
+
+func (x *instanceServiceListInstancesClient) Recv() (*Instance, error) {
+	m := new(Instance)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *instanceServiceClient) GetInstance(ctx context.Context, in *GetInstanceRequest, opts ...grpc.CallOption) (*Instance, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Instance)
+	err := c.cc.Invoke(ctx, InstanceService_GetInstance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// InstanceServiceServer is the server API for InstanceService service.
+// All implementations must embed UnimplementedInstanceServiceServer
+// for forward compatibility
+//
+// InstanceService serves the instances discovered by the last snapshot.
+type InstanceServiceServer interface {
+	// ListInstances streams every instance discovered by the last snapshot.
+	ListInstances(*ListInstancesRequest, InstanceService_ListInstancesServer) error
+	// GetInstance returns a single instance by its identifier.
+	GetInstance(context.Context, *GetInstanceRequest) (*Instance, error)
+	mustEmbedUnimplementedInstanceServiceServer()
+}
+
+// UnimplementedInstanceServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedInstanceServiceServer struct {
+}
+
+func (UnimplementedInstanceServiceServer) ListInstances(*ListInstancesRequest, InstanceService_ListInstancesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListInstances not implemented")
+}
+func (UnimplementedInstanceServiceServer) GetInstance(context.Context, *GetInstanceRequest) (*Instance, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetInstance not implemented")
+}
+func (UnimplementedInstanceServiceServer) mustEmbedUnimplementedInstanceServiceServer() {}
+
+// UnsafeInstanceServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to InstanceServiceServer will
+// result in compilation errors.
+type UnsafeInstanceServiceServer interface {
+	mustEmbedUnimplementedInstanceServiceServer()
+}
+
+func RegisterInstanceServiceServer(s grpc.ServiceRegistrar, srv InstanceServiceServer) {
+	s.RegisterService(&InstanceService_ServiceDesc, srv)
+}
+
+func _InstanceService_ListInstances_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListInstancesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InstanceServiceServer).ListInstances(m, &instanceServiceListInstancesServer{ServerStream: stream})
+}
+
+type InstanceService_ListInstancesServer interface {
+	Send(*Instance) error
+	grpc.ServerStream
+}
+
+type instanceServiceListInstancesServer struct {
+	grpc.ServerStream
+}
+
+func (x *instanceServiceListInstancesServer) Send(m *Instance) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _InstanceService_GetInstance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInstanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InstanceServiceServer).GetInstance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InstanceService_GetInstance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InstanceServiceServer).GetInstance(ctx, req.(*GetInstanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// InstanceService_ServiceDesc is the grpc.ServiceDesc for InstanceService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var InstanceService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rdsmaxcon.v1.InstanceService",
+	HandlerType: (*InstanceServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetInstance",
+			Handler:    _InstanceService_GetInstance_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListInstances",
+			Handler:       _InstanceService_ListInstances_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "rdsmaxcon/v1/rdsmaxcon.proto",
+}
@@ -0,0 +1,22 @@
+package main
+
+import "math"
+
+// recommendedPoolSize returns the size of the pool of each client of an
+// instance of PoolSizing.Clients, splitting its max_connections less the
+// reserved ones among its clients and their surge during a rolling deploy, 0
+// when they do not even get a connection each.
+func recommendedPoolSize(cfg PoolSizingConfig, identifier string, maxConnections int) (int, bool) {
+	clients, ok := cfg.Clients[identifier]
+	if !ok {
+		return 0, false
+	}
+
+	clients += int(math.Ceil(float64(clients) * float64(cfg.MaxSurge) / 100))
+	available := maxConnections - cfg.ReservedConnections
+	if available < clients {
+		return 0, true
+	}
+
+	return available / clients, true
+}
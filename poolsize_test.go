@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestRecommendedPoolSize(t *testing.T) {
+	cfg := defaultConfig().PoolSizing
+	cfg.Clients = map[string]int{"api": 12, "batch": 1, "crowded": 200}
+
+	for _, tc := range []struct {
+		identifier     string
+		maxConnections int
+		want           int
+		ok             bool
+	}{
+		// (1800 - 3) / (12 + 3 surging)
+		{identifier: "api", maxConnections: 1800, want: 119, ok: true},
+		// a single client also surges to 2
+		{identifier: "batch", maxConnections: 100, want: 48, ok: true},
+		{identifier: "crowded", maxConnections: 100, want: 0, ok: true},
+		{identifier: "unknown", maxConnections: 1800},
+	} {
+		got, ok := recommendedPoolSize(cfg, tc.identifier, tc.maxConnections)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%v: got %v %v, want %v %v", tc.identifier, got, ok, tc.want, tc.ok)
+		}
+	}
+}
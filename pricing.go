@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+)
+
+const (
+	// pricingRegion is a region of the endpoint of the Price List API, which
+	// serves the prices of all the regions
+	pricingRegion = "us-east-1"
+	// pricesRefreshInterval is how long the prices are cached, as they
+	// seldom change
+	pricesRefreshInterval = 24 * time.Hour
+)
+
+// newPricingAPI returns the Price List client of a session, replaced by a fake
+// in the tests.
+//
+//nolint:gochecknoglobals
+var newPricingAPI = func(sess *session.Session) pricingiface.PricingAPI {
+	return pricing.New(sess, aws.NewConfig().WithRegion(pricingRegion))
+}
+
+// pricingEngines are the databaseEngine attributes of the prices of the
+// supported engines.
+//
+//nolint:gochecknoglobals
+var pricingEngines = map[string]string{
+	"postgres":          "PostgreSQL",
+	"aurora-postgresql": "Aurora PostgreSQL",
+}
+
+// hourlyPrices caches the on-demand hourly prices in USD by region, engine,
+// class and deployment option, nil when no price is found.
+//
+//nolint:gochecknoglobals
+var hourlyPrices = struct {
+	mu      sync.Mutex
+	byClass map[string]priceEntry
+}{byClass: map[string]priceEntry{}}
+
+type priceEntry struct {
+	price *float64
+	at    time.Time
+}
+
+// setHourlyPrices fills HourlyPrice with the on-demand hourly price of the
+// class of each exported instance in its region, reading the ones not read
+// within pricesRefreshInterval. The Aurora instances have the price of the
+// standard storage, the lowest one. The cached prices are kept when the API
+// fails.
+func setHourlyPrices(ctx context.Context, svc pricingiface.PricingAPI, infos []RDSInfo) error {
+	now := clk.Now()
+
+	for i := range infos {
+		info := &infos[i]
+		engine, ok := pricingEngines[info.DBEngine]
+		if len(info.SkipReason) != 0 || !ok || info.ServerlessMaxCapacity != 0 {
+			continue
+		}
+		// an Aurora instance is priced on its own in any number of zones
+		deployment := "Single-AZ"
+		if info.MultiAZ && info.DBEngine == "postgres" {
+			deployment = "Multi-AZ"
+		}
+		key := info.Region + "|" + engine + "|" + info.DBInstanceClass + "|" + deployment
+
+		hourlyPrices.mu.Lock()
+		entry, ok := hourlyPrices.byClass[key]
+		hourlyPrices.mu.Unlock()
+		if !ok || now.Sub(entry.at) >= pricesRefreshInterval {
+			price, err := getHourlyPrice(ctx, svc, info.Region, engine, info.DBInstanceClass, deployment)
+			if err != nil {
+				return err
+			}
+			entry = priceEntry{price: price, at: now}
+			hourlyPrices.mu.Lock()
+			hourlyPrices.byClass[key] = entry
+			hourlyPrices.mu.Unlock()
+		}
+		info.HourlyPrice = entry.price
+	}
+
+	return nil
+}
+
+// getHourlyPrice returns the lowest on-demand hourly price in USD of the
+// products of the class, nil when there is none.
+func getHourlyPrice(ctx context.Context, svc pricingiface.PricingAPI, region, engine, class, deployment string) (*float64, error) {
+	filter := func(field, value string) *pricing.Filter {
+		return &pricing.Filter{Type: aws.String(pricing.FilterTypeTermMatch), Field: aws.String(field), Value: aws.String(value)}
+	}
+	input := &pricing.GetProductsInput{
+		ServiceCode: aws.String("AmazonRDS"),
+		Filters: []*pricing.Filter{
+			filter("regionCode", region),
+			filter("databaseEngine", engine),
+			filter("instanceType", class),
+			filter("deploymentOption", deployment),
+		},
+	}
+
+	var ret *float64
+	err := svc.GetProductsPagesWithContext(ctx, input, func(page *pricing.GetProductsOutput, lastPage bool) bool {
+		for _, product := range page.PriceList {
+			for _, price := range onDemandPrices(product) {
+				if ret == nil || price < *ret {
+					v := price
+					ret = &v
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prices of %v: %w", class, err)
+	}
+
+	return ret, nil
+}
+
+// onDemandPrices returns the hourly prices in USD of the on-demand terms of a
+// product of the price list, whose terms.OnDemand are offers by code, whose
+// priceDimensions are prices by rate code.
+func onDemandPrices(product aws.JSONValue) []float64 {
+	object := func(v any, key string) map[string]any {
+		m, _ := v.(map[string]any)
+		ret, _ := m[key].(map[string]any)
+		return ret
+	}
+
+	var ret []float64
+	for _, offer := range object(map[string]any(product)["terms"], "OnDemand") {
+		for _, dimension := range object(offer, "priceDimensions") {
+			if unit, _ := dimension.(map[string]any)["unit"].(string); unit != "Hrs" {
+				continue
+			}
+			usd, _ := object(dimension, "pricePerUnit")["USD"].(string)
+			if price, err := strconv.ParseFloat(usd, 64); err == nil && price > 0 {
+				ret = append(ret, price)
+			}
+		}
+	}
+
+	return ret
+}
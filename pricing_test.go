@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+)
+
+// fakePricing serves the hourly prices of the products by instance type and
+// deployment option, counting the requests.
+type fakePricing struct {
+	pricingiface.PricingAPI
+	prices   map[string][]string
+	requests int
+}
+
+func (f *fakePricing) GetProductsPagesWithContext(_ aws.Context, input *pricing.GetProductsInput, fn func(*pricing.GetProductsOutput, bool) bool, _ ...request.Option) error {
+	f.requests++
+	filters := map[string]string{}
+	for _, filter := range input.Filters {
+		filters[aws.StringValue(filter.Field)] = aws.StringValue(filter.Value)
+	}
+
+	var products []aws.JSONValue
+	for _, usd := range f.prices[filters["instanceType"]+"|"+filters["deploymentOption"]] {
+		products = append(products, aws.JSONValue{"terms": map[string]any{"OnDemand": map[string]any{
+			"TERM.CODE": map[string]any{"priceDimensions": map[string]any{
+				"RATE.CODE": map[string]any{"unit": "Hrs", "pricePerUnit": map[string]any{"USD": usd}},
+			}},
+		}}})
+	}
+	fn(&pricing.GetProductsOutput{PriceList: products}, true)
+	return nil
+}
+
+func TestSetHourlyPrices(t *testing.T) {
+	fc := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := clk
+	t.Cleanup(func() { clk = c })
+	clk = fc
+
+	svc := &fakePricing{prices: map[string][]string{
+		"db.r5.large|Single-AZ": {"0.29"},
+		"db.r5.large|Multi-AZ":  {"0.58"},
+		// the I/O-Optimized storage of Aurora is more expensive
+		"db.r5.xlarge|Single-AZ": {"0.754", "0.58"},
+	}}
+	infos := []RDSInfo{
+		{DBInstanceIdentifier: "single", DBEngine: "postgres", DBInstanceClass: "db.r5.large", Region: "test-region-1"},
+		{DBInstanceIdentifier: "multi", DBEngine: "postgres", DBInstanceClass: "db.r5.large", Region: "test-region-1", MultiAZ: true},
+		{DBInstanceIdentifier: "aurora", DBEngine: "aurora-postgresql", DBInstanceClass: "db.r5.xlarge", Region: "test-region-1", MultiAZ: true},
+		{DBInstanceIdentifier: "single2", DBEngine: "postgres", DBInstanceClass: "db.r5.large", Region: "test-region-1"},
+		{DBInstanceIdentifier: "unknown", DBEngine: "postgres", DBInstanceClass: "db.x9.large", Region: "test-region-1"},
+		{DBInstanceIdentifier: "serverless", DBEngine: "aurora-postgresql", DBInstanceClass: "db.serverless", Region: "test-region-1", ServerlessMaxCapacity: 16},
+	}
+
+	err := setHourlyPrices(context.Background(), svc, infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []float64{0.29, 0.58, 0.58, 0.29} {
+		if got := infos[i].HourlyPrice; got == nil || *got != want {
+			t.Errorf("%v: got price %v, want %v", infos[i].DBInstanceIdentifier, got, want)
+		}
+	}
+	if infos[4].HourlyPrice != nil || infos[5].HourlyPrice != nil {
+		t.Errorf("got prices %v %v, want none", infos[4].HourlyPrice, infos[5].HourlyPrice)
+	}
+	// the prices of a class are read once
+	if svc.requests != 4 {
+		t.Errorf("got %v requests, want 4", svc.requests)
+	}
+
+	fc.Advance(pricesRefreshInterval)
+	err = setHourlyPrices(context.Background(), svc, infos[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.requests != 5 {
+		t.Errorf("got %v requests, want the price read again after the refresh interval", svc.requests)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
+)
+
+const (
+	recommendUpsize   = "upsize"
+	recommendDownsize = "downsize"
+
+	// peakConnectionsPeriod is the period of the data points the peak
+	// connections are the maximum of
+	peakConnectionsPeriod = time.Hour
+)
+
+// peakConnections caches the peak connections of the instances, nil when an
+// instance has no data point.
+//
+//nolint:gochecknoglobals
+var peakConnections = newHistoryCache[*float64]()
+
+// setPeakConnections fills PeakDatabaseConnections with the maximum
+// DatabaseConnections of each exported instance over the lookback, reading
+// the ones not read within the refresh interval. The cached values are kept
+// when CloudWatch fails.
+func setPeakConnections(ctx context.Context, svc cloudwatchiface.CloudWatchAPI, cfg RecommendationsConfig, infos []RDSInfo) error {
+	return peakConnections.set(ctx, svc, infos, cfg.Lookback, peakConnectionsPeriod, cfg.RefreshInterval,
+		func(points []connectionsPoint) *float64 {
+			var peak *float64
+			for _, point := range points {
+				if peak == nil || point.value > *peak {
+					v := point.value
+					peak = &v
+				}
+			}
+			return peak
+		},
+		func(info *RDSInfo, peak *float64) { info.PeakDatabaseConnections = peak })
+}
+
+// apiRecommendation suggests another class of the same family for an instance,
+// from its peak connections over the lookback.
+type apiRecommendation struct {
+	DBInstanceIdentifier string  `json:"db_instance_identifier"`
+	Region               string  `json:"region"`
+	DBEngine             string  `json:"engine"`
+	DBInstanceClass      string  `json:"db_instance_class"`
+	MemoryBytes          int64   `json:"memory_bytes"`
+	MaxConnections       int     `json:"max_connections"`
+	PeakConnections      float64 `json:"peak_connections"`
+	// Utilization is the peak connections in percent of max_connections
+	Utilization float64 `json:"utilization"`
+	// Action is upsize or downsize
+	Action string `json:"action"`
+	// RecommendedClass is empty when no class of the family is large enough
+	RecommendedClass          string  `json:"recommended_class,omitempty"`
+	RecommendedMemoryBytes    int64   `json:"recommended_memory_bytes,omitempty"`
+	RecommendedMaxConnections int     `json:"recommended_max_connections,omitempty"`
+	RecommendedUtilization    float64 `json:"recommended_utilization,omitempty"`
+	Reason                    string  `json:"reason"`
+}
+
+type apiRecommendationsResponse struct {
+	UpdatedAt       *time.Time          `json:"updated_at"`
+	Recommendations []apiRecommendation `json:"recommendations"`
+}
+
+// recommend returns the recommendation for an instance, false when its class
+// fits its peak connections, or when it can not be resized to change its
+// max_connections: an override, a serverless instance or an unknown class.
+// The candidates are computed with the max_connections parameter of the
+// instance, such as a formula of the memory.
+func recommend(cfg RecommendationsConfig, info RDSInfo) (apiRecommendation, bool) {
+	if len(info.SkipReason) != 0 || info.Overridden || info.Resolution == nil || info.PeakDatabaseConnections == nil ||
+		info.DBInstanceClass == maxcon.ServerlessInstanceClass {
+		return apiRecommendation{}, false
+	}
+	maxConnections, err := strconv.Atoi(info.MaxConnections)
+	memory := maxcon.InstanceClassMemory(info.DBInstanceClass)
+	if err != nil || maxConnections <= 0 || memory == 0 {
+		return apiRecommendation{}, false
+	}
+
+	peak := *info.PeakDatabaseConnections
+	ret := apiRecommendation{
+		DBInstanceIdentifier: info.DBInstanceIdentifier,
+		Region:               info.Region,
+		DBEngine:             info.DBEngine,
+		DBInstanceClass:      info.DBInstanceClass,
+		MemoryBytes:          memory,
+		MaxConnections:       maxConnections,
+		PeakConnections:      peak,
+		Utilization:          utilization(peak, maxConnections),
+	}
+	// fits tells whether a class keeps the peak under the upsize utilization
+	fits := func(value int) bool { return utilization(peak, value) < cfg.UpsizeUtilization }
+	recommended := func(class maxcon.InstanceClass, value int) {
+		ret.RecommendedClass = class.Class
+		ret.RecommendedMemoryBytes = class.Memory
+		ret.RecommendedMaxConnections = value
+		ret.RecommendedUtilization = utilization(peak, value)
+	}
+
+	switch {
+	case ret.Utilization >= cfg.UpsizeUtilization:
+		ret.Action = recommendUpsize
+		for _, class := range familyClasses(info.DBInstanceClass) {
+			if class.Memory <= memory {
+				continue
+			}
+			value, ok := candidateMaxConnections(info, class.Class)
+			if ok && fits(value) {
+				recommended(class, value)
+				ret.Reason = fmt.Sprintf("the peak of %v connections is %v%% of max_connections, at least %v%%", peak, ret.Utilization, cfg.UpsizeUtilization)
+				return ret, true
+			}
+		}
+		ret.Reason = fmt.Sprintf("the peak of %v connections is %v%% of max_connections, and no larger class of the family keeps it under %v%%", peak, ret.Utilization, cfg.UpsizeUtilization)
+		return ret, true
+	case ret.Utilization <= cfg.DownsizeUtilization:
+		// the smallest class which would not need to be upsized
+		for _, class := range familyClasses(info.DBInstanceClass) {
+			if class.Memory >= memory {
+				break
+			}
+			value, ok := candidateMaxConnections(info, class.Class)
+			if ok && fits(value) {
+				ret.Action = recommendDownsize
+				recommended(class, value)
+				ret.Reason = fmt.Sprintf("the peak of %v connections is %v%% of max_connections, at most %v%%", peak, ret.Utilization, cfg.DownsizeUtilization)
+				return ret, true
+			}
+		}
+	}
+
+	return apiRecommendation{}, false
+}
+
+// familyClasses returns the known classes of the family of an instance class,
+// such as db.r5 of db.r5.large, in ascending order of memory.
+func familyClasses(instanceClass string) []maxcon.InstanceClass {
+	i := strings.LastIndex(instanceClass, ".")
+	if i < 0 {
+		return nil
+	}
+	family := instanceClass[:i+1]
+
+	var ret []maxcon.InstanceClass
+	for _, class := range maxcon.InstanceClasses() {
+		if strings.HasPrefix(class.Class, family) && !strings.Contains(class.Class[len(family):], ".") && class.Memory != 0 {
+			ret = append(ret, class)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Memory < ret[j].Memory })
+
+	return ret
+}
+
+// candidateMaxConnections returns the max_connections an instance would have
+// with another class and the same parameter.
+func candidateMaxConnections(info RDSInfo, instanceClass string) (int, bool) {
+	r, err := exporter.Resolve(info.DBEngine, info.Resolution.Raw, instanceClass)
+	if err != nil {
+		return 0, false
+	}
+
+	return r.Value, true
+}
+
+// utilization returns connections in percent of maxConnections, rounded to
+// one decimal.
+func utilization(connections float64, maxConnections int) float64 {
+	return math.Round(connections/float64(maxConnections)*1000) / 10
+}
+
+// recommendationsHandler serves GET /api/v1/recommendations with the instances
+// of the last snapshot whose class could be downsized or must be upsized, with
+// the thresholds of the current configuration.
+func recommendationsHandler(st *state, store *Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		cfg, _ := st.get()
+		if !cfg.Recommendations.Enabled {
+			http.Error(w, "recommendations are not enabled", http.StatusNotFound)
+			return
+		}
+
+		infos, updatedAt := store.Get()
+
+		resp := apiRecommendationsResponse{
+			Recommendations: []apiRecommendation{},
+		}
+		if !updatedAt.IsZero() {
+			resp.UpdatedAt = &updatedAt
+		}
+		for _, info := range infos {
+			if recommendation, ok := recommend(cfg.Recommendations, info); ok {
+				resp.Recommendations = append(resp.Recommendations, recommendation)
+			}
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
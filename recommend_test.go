@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon/postgresql"
+)
+
+// fakeCloudWatch serves the DatabaseConnections data points of the instances
+// by identifier, in pages of one result, counting the queries, and records
+// the metrics put.
+type fakeCloudWatch struct {
+	cloudwatchiface.CloudWatchAPI
+	values  map[string][]float64
+	queries int
+	put     []*cloudwatch.PutMetricDataInput
+}
+
+func (f *fakeCloudWatch) GetMetricDataPagesWithContext(_ aws.Context, input *cloudwatch.GetMetricDataInput, fn func(*cloudwatch.GetMetricDataOutput, bool) bool, _ ...request.Option) error {
+	for i, query := range input.MetricDataQueries {
+		f.queries++
+		result := &cloudwatch.MetricDataResult{Id: query.Id}
+		for j, v := range f.values[aws.StringValue(query.MetricStat.Metric.Dimensions[0].Value)] {
+			result.Timestamps = append(result.Timestamps, aws.Time(input.StartTime.Add(time.Duration(j)*time.Hour)))
+			result.Values = append(result.Values, aws.Float64(v))
+		}
+		if !fn(&cloudwatch.GetMetricDataOutput{MetricDataResults: []*cloudwatch.MetricDataResult{result}}, i == len(input.MetricDataQueries)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func TestRecommend(t *testing.T) {
+	cfg := defaultConfig().Recommendations
+	info := func(identifier, class, maxConnections string, peak float64) RDSInfo {
+		return RDSInfo{
+			DBInstanceIdentifier: identifier, DBEngine: "postgres", DBInstanceClass: class, MaxConnections: maxConnections,
+			Resolution:              &maxcon.Resolution{Raw: postgresql.DefaultFormula, Branch: maxcon.BranchDefaultFormula},
+			PeakDatabaseConnections: &peak,
+		}
+	}
+	overridden := info("overridden", "db.r5.large", "100", 95)
+	overridden.Overridden = true
+
+	for _, tc := range []struct {
+		info        RDSInfo
+		action      string
+		recommended string
+	}{
+		{info: info("busy", "db.r5.large", "1800", 1600), action: recommendUpsize, recommended: "db.r5.xlarge"},
+		{info: info("idle", "db.r5.4xlarge", "5000", 200), action: recommendDownsize, recommended: "db.r5.large"},
+		// max_connections is capped at 5000 from db.r5.2xlarge
+		{info: info("capped", "db.r5.2xlarge", "5000", 4500), action: recommendUpsize},
+		{info: info("fit", "db.r5.xlarge", "3600", 2000)},
+		// a larger class does not raise an override
+		{info: overridden},
+	} {
+		got, ok := recommend(cfg, tc.info)
+		if ok != (len(tc.action) != 0) || got.Action != tc.action || got.RecommendedClass != tc.recommended {
+			t.Errorf("%v: got %v %+v, want %q %q", tc.info.DBInstanceIdentifier, ok, got, tc.action, tc.recommended)
+		}
+	}
+}
+
+func TestSetPeakConnections(t *testing.T) {
+	fc := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := clk
+	t.Cleanup(func() { clk = c })
+	clk = fc
+
+	cfg := defaultConfig().Recommendations
+	svc := &fakeCloudWatch{values: map[string][]float64{"a01": {10, 120, 30}}}
+	infos := []RDSInfo{
+		{DBInstanceIdentifier: "a01", Region: "test-region-1"},
+		{DBInstanceIdentifier: "quiet", Region: "test-region-1"},
+		{DBInstanceIdentifier: "skipped", Region: "test-region-1", SkipReason: "parameter group error"},
+	}
+
+	for i := 0; i < 2; i++ {
+		err := setPeakConnections(context.Background(), svc, cfg, infos)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p := infos[0].PeakDatabaseConnections; p == nil || *p != 120 {
+			t.Errorf("got peak %v, want 120", p)
+		}
+		if infos[1].PeakDatabaseConnections != nil || infos[2].PeakDatabaseConnections != nil {
+			t.Errorf("got peaks %v %v, want none without data points", infos[1].PeakDatabaseConnections, infos[2].PeakDatabaseConnections)
+		}
+	}
+	// the second snapshot is within the refresh interval
+	if svc.queries != 2 {
+		t.Errorf("got %v queries, want 2", svc.queries)
+	}
+
+	fc.Advance(cfg.RefreshInterval)
+	svc.values["a01"] = []float64{80}
+	err := setPeakConnections(context.Background(), svc, cfg, infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := infos[0].PeakDatabaseConnections; p == nil || *p != 80 || svc.queries != 4 {
+		t.Errorf("got peak %v after %v queries, want 80 after 4", p, svc.queries)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// errRedisNil is the reply of a missing key.
+var errRedisNil = errors.New("redis: nil")
+
+// redisClient is a client of a single connection to Redis speaking RESP2,
+// enough for the parameter cache, which is reconnected on the next command
+// after an error.
+// ref: https://redis.io/docs/latest/develop/reference/protocol-spec/
+type redisClient struct {
+	cfg RedisConfig
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// connect dials Redis, authenticates and selects the database.
+func (c *redisClient) connect() error {
+	dialer := &net.Dialer{Timeout: c.cfg.Timeout}
+	var conn net.Conn
+	var err error
+	if c.cfg.TLS {
+		host, _, _ := net.SplitHostPort(c.cfg.Address)
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.cfg.Address, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+	} else {
+		conn, err = dialer.Dial("tcp", c.cfg.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+
+	if len(c.cfg.Password) != 0 {
+		args := []string{"AUTH", c.cfg.Password}
+		if len(c.cfg.Username) != 0 {
+			args = []string{"AUTH", c.cfg.Username, c.cfg.Password}
+		}
+		if _, err := c.roundTrip(args); err != nil {
+			return fmt.Errorf("failed to authenticate to redis: %w", err)
+		}
+	}
+	if c.cfg.DB != 0 {
+		if _, err := c.roundTrip([]string{"SELECT", strconv.Itoa(c.cfg.DB)}); err != nil {
+			return fmt.Errorf("failed to select redis database: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// do sends a command and returns its reply, errRedisNil for a nil one.
+func (c *redisClient) do(args ...string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			c.close()
+			return "", err
+		}
+	}
+	reply, err := c.roundTrip(args)
+	var re redisError
+	if err != nil && !errors.Is(err, errRedisNil) && !errors.As(err, &re) {
+		// the connection is in an unknown state
+		c.close()
+	}
+
+	return reply, err
+}
+
+func (c *redisClient) close() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn, c.r = nil, nil
+}
+
+func (c *redisClient) roundTrip(args []string) (string, error) {
+	err := c.conn.SetDeadline(time.Now().Add(c.cfg.Timeout))
+	if err != nil {
+		return "", fmt.Errorf("failed to set deadline: %w", err)
+	}
+
+	// a command is an array of bulk strings
+	b := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		b = append(b, "$"+strconv.Itoa(len(arg))+"\r\n"+arg+"\r\n"...)
+	}
+	if _, err := c.conn.Write(b); err != nil {
+		return "", fmt.Errorf("failed to send redis command: %w", err)
+	}
+
+	return readRedisReply(c.r)
+}
+
+// redisError is an error reply.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readRedisReply reads a simple string, error, integer or bulk string reply.
+func readRedisReply(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read redis reply: %w", err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("invalid redis reply: %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", redisError(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid redis reply: %q", line)
+		}
+		if n < 0 {
+			return "", errRedisNil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return "", fmt.Errorf("failed to read redis reply: %w", err)
+		}
+		return string(b[:n]), nil
+	default:
+		return "", fmt.Errorf("unexpected redis reply: %q", line)
+	}
+}
+
+// redisParameterCache keeps the parameter cache in Redis, shared by the
+// replicas, under the key prefix. The in-memory cache is used while Redis
+// fails, so that an outage of Redis does not fail the snapshots.
+type redisParameterCache struct {
+	client   *redisClient
+	prefix   string
+	fallback *parameterCache
+}
+
+type redisParameterEntry struct {
+	Fingerprint string `json:"fingerprint"`
+	Raw         string `json:"raw"`
+}
+
+func newRedisParameterCache(cfg RedisConfig) *redisParameterCache {
+	return &redisParameterCache{
+		client:   &redisClient{cfg: cfg},
+		prefix:   cfg.KeyPrefix,
+		fallback: parameters,
+	}
+}
+
+func (c *redisParameterCache) get(key, fingerprint string) (string, bool) {
+	reply, err := c.client.do("GET", c.prefix+key)
+	switch {
+	case errors.Is(err, errRedisNil):
+		parameterCacheRequests.WithLabelValues("miss").Inc()
+		return "", false
+	case err != nil:
+		parameterCacheRequests.WithLabelValues("error").Inc()
+		slog.Warn("failed to get parameter group from redis", "key", key, "err", err)
+		return c.fallback.get(key, fingerprint)
+	}
+
+	var entry redisParameterEntry
+	if err := json.Unmarshal([]byte(reply), &entry); err != nil {
+		parameterCacheRequests.WithLabelValues("miss").Inc()
+		return "", false
+	}
+	if entry.Fingerprint != fingerprint {
+		parameterCacheRequests.WithLabelValues("changed").Inc()
+		return "", false
+	}
+	parameterCacheRequests.WithLabelValues("hit").Inc()
+
+	return entry.Raw, true
+}
+
+func (c *redisParameterCache) set(key, fingerprint, raw string, ttl time.Duration) {
+	c.fallback.set(key, fingerprint, raw, ttl)
+
+	b, _ := json.Marshal(redisParameterEntry{Fingerprint: fingerprint, Raw: raw})
+	_, err := c.client.do("SET", c.prefix+key, string(b), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		slog.Warn("failed to set parameter group in redis", "key", key, "err", err)
+	}
+}
+
+// redisParameterCaches are the Redis caches by configuration, kept across
+// the reloads of the configuration.
+//
+//nolint:gochecknoglobals
+var redisParameterCaches = struct {
+	mu       sync.Mutex
+	byConfig map[RedisConfig]*redisParameterCache
+}{byConfig: map[RedisConfig]*redisParameterCache{}}
+
+// parameterStoreFor returns the storage of the parameter cache of the
+// configuration: Redis when configured, else the memory.
+func parameterStoreFor(cfg *Config) parameterStore {
+	if len(cfg.Redis.Address) == 0 {
+		return parameters
+	}
+
+	redisParameterCaches.mu.Lock()
+	defer redisParameterCaches.mu.Unlock()
+	c, ok := redisParameterCaches.byConfig[cfg.Redis]
+	if !ok {
+		c = newRedisParameterCache(cfg.Redis)
+		redisParameterCaches.byConfig[cfg.Redis] = c
+	}
+
+	return c
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves GET, SET and AUTH of RESP2 from a map, ignoring the
+// expiries.
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mu     sync.Mutex
+	values map[string]string
+	set    []string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{listener: l, password: password, values: map[string]string{}}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authenticated := len(f.password) == 0
+	for {
+		var n int
+		if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var size int
+			if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+				return
+			}
+			b := make([]byte, size+2)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return
+			}
+			args[i] = string(b[:size])
+		}
+
+		f.mu.Lock()
+		switch {
+		case args[0] == "AUTH":
+			if args[len(args)-1] == f.password {
+				authenticated = true
+				fmt.Fprint(conn, "+OK\r\n")
+			} else {
+				fmt.Fprint(conn, "-WRONGPASS invalid username-password pair\r\n")
+			}
+		case !authenticated:
+			fmt.Fprint(conn, "-NOAUTH Authentication required.\r\n")
+		case args[0] == "GET":
+			if v, ok := f.values[args[1]]; ok {
+				fmt.Fprintf(conn, "$%d\r\n%v\r\n", len(v), v)
+			} else {
+				fmt.Fprint(conn, "$-1\r\n")
+			}
+		case args[0] == "SET":
+			f.values[args[1]] = args[2]
+			f.set = append(f.set, args[1]+" "+args[4])
+			fmt.Fprint(conn, "+OK\r\n")
+		default:
+			fmt.Fprintf(conn, "-ERR unknown command '%v'\r\n", args[0])
+		}
+		f.mu.Unlock()
+	}
+}
+
+func TestRedisParameterCache(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	cfg := defaultConfig().Redis
+	cfg.Address = f.listener.Addr().String()
+	cfg.Password = "secret"
+
+	// two replicas share the groups
+	a, b := newRedisParameterCache(cfg), newRedisParameterCache(cfg)
+	a.fallback = &parameterCache{entries: map[string]parameterCacheEntry{}}
+	b.fallback = &parameterCache{entries: map[string]parameterCacheEntry{}}
+	a.set("group", "a01=in-sync", "LEAST({DBInstanceClassMemory/9531392},5000)", time.Hour)
+	if raw, ok := b.get("group", "a01=in-sync"); !ok || raw != "LEAST({DBInstanceClassMemory/9531392},5000)" {
+		t.Errorf("got %q %v, want the group set by the other replica", raw, ok)
+	}
+	if _, ok := b.get("group", "a01=pending-reboot"); ok {
+		t.Error("got a hit with another fingerprint")
+	}
+	if _, ok := b.get("unknown", ""); ok {
+		t.Error("got a hit of an unknown group")
+	}
+	if want := "aws-rds-maxcon:group " + strconv.Itoa(int(time.Hour.Milliseconds())); len(f.set) != 1 || f.set[0] != want {
+		t.Errorf("got SET %v, want %v", f.set, want)
+	}
+
+	wrong := cfg
+	wrong.Password = "wrong"
+	_, err := (&redisClient{cfg: wrong}).do("GET", "group")
+	var re redisError
+	if !errors.As(err, &re) {
+		t.Errorf("got %v, want an error reply with a wrong password", err)
+	}
+
+	// the memory is used while Redis is down
+	f.listener.Close()
+	a.client.close()
+	if raw, ok := a.get("group", "a01=in-sync"); !ok || len(raw) == 0 {
+		t.Errorf("got %q %v, want the group from the memory", raw, ok)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// refreshEvery reads the configuration source every interval and reloads it
+// when it changed, such as of an S3 object or a parameter shared by the
+// deployments of many accounts, which can not be watched like a file. A
+// source failing to be read, or an invalid change, keeps the current
+// configuration. It returns when ctx is done.
+func (s *state) refreshEvery(ctx context.Context, f *flags, interval time.Duration) {
+	last, _ := f.source.read()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		b, err := f.source.read()
+		if err != nil {
+			slog.Error("failed to refresh config", "source", f.source.String(), "err", err)
+			continue
+		}
+		if bytes.Equal(b, last) {
+			continue
+		}
+		last = b
+
+		err = s.reload(f)
+		if err != nil {
+			slog.Error("failed to reload refreshed config", "source", f.source.String(), "err", err)
+			continue
+		}
+		slog.Info("reloaded refreshed config", "source", f.source.String())
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// state is the configuration and the outputs in use, which are replaced on
+// reload while the HTTP listener and the last snapshot are kept.
+type state struct {
+	mu      sync.RWMutex
+	cfg     *Config
+	outputs []Output
+	// outputsByKey are the outputs by the key of their section, which are
+	// kept by a reload not changing it
+	outputsByKey map[string]Output
+
+	// reloading serializes the reloads of the signal, the watch and the
+	// refresh
+	reloading sync.Mutex
+	// reloaded is notified after a successful reload
+	reloaded chan struct{}
+}
+
+func newState(cfg *Config) (*state, error) {
+	outputs, byKey, err := reuseOutputs(cfg.Outputs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state{
+		cfg:          cfg,
+		outputs:      outputs,
+		outputsByKey: byKey,
+		reloaded:     make(chan struct{}, 1),
+	}, nil
+}
+
+func (s *state) get() (*Config, []Output) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cfg, s.outputs
+}
+
+// close closes the outputs once they are not written anymore.
+func (s *state) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	closeOutputs(unusedOutputs(s.outputsByKey, nil))
+	s.outputs = nil
+	s.outputsByKey = nil
+}
+
+// reload loads the configuration again and replaces the current one only if
+// it is valid. The listen addresses are not changed. The outputs whose
+// section did not change are kept, and the replaced ones are closed.
+func (s *state) reload(f *flags) error {
+	s.reloading.Lock()
+	defer s.reloading.Unlock()
+
+	cfg, err := loadConfig(f.source, f.overrides)
+	if err != nil {
+		configReloadSuccess.Set(0)
+		return err
+	}
+
+	s.mu.RLock()
+	previous := s.outputsByKey
+	s.mu.RUnlock()
+	outputs, byKey, err := reuseOutputs(cfg.Outputs, previous)
+	if err != nil {
+		configReloadSuccess.Set(0)
+		return fmt.Errorf("failed to create outputs: %w", err)
+	}
+	configReloadSuccess.Set(1)
+
+	logLevel.Set(mustParseLogLevel(cfg.Log.Level))
+	setStaleAfter(cfg)
+
+	s.mu.Lock()
+	s.cfg = cfg
+	s.outputs = outputs
+	s.outputsByKey = byKey
+	s.mu.Unlock()
+	// a write in flight of the previous configuration fails and is logged
+	closeOutputs(unusedOutputs(previous, byKey))
+
+	select {
+	case s.reloaded <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// reloadOnSIGHUP reloads the configuration whenever SIGHUP is received, until
+// ctx is done. A failed reload keeps the current configuration.
+func (s *state) reloadOnSIGHUP(ctx context.Context, f *flags) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+
+		err := s.reload(f)
+		if err != nil {
+			slog.Error("failed to reload config", "err", err)
+			continue
+		}
+		slog.Info("reloaded config")
+	}
+}
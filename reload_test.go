@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReloadOutputs(t *testing.T) {
+	address, _ := listenStatsD(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(prefix string) {
+		t.Helper()
+		content := fmt.Sprintf("outputs:\n  statsd:\n    address: %v\n    prefix: %v\n  webhook:\n    url: %v\n", address, prefix, server.URL)
+		err := os.WriteFile(path, []byte(content), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a")
+	f := &flags{source: fileConfig(path)}
+	cfg, err := loadConfig(f.source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := newState(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.close()
+	_, before := st.get()
+
+	// the unchanged webhook keeps the instances it diffs with
+	err = before[1].Write([]RDSInfo{{DBInstanceIdentifier: "a01", MaxConnections: "1800"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("b")
+	err = st.reload(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, after := st.get()
+
+	if len(after) != 2 || after[1] != before[1] {
+		t.Errorf("got outputs %v, want the webhook of %v kept", after, before)
+	}
+	if _, ok := after[1].(*Webhook).previous["|a01"]; !ok {
+		t.Error("got the state of the webhook reset")
+	}
+	// the changed StatsD is replaced and closed
+	if after[0] == before[0] || after[0].(*StatsD).prefix != "b" {
+		t.Errorf("got StatsD %+v, want a new one with the b prefix", after[0])
+	}
+	err = before[0].Write([]RDSInfo{{DBInstanceIdentifier: "a01", MaxConnections: "1800"}})
+	if err == nil {
+		t.Error("got no error writing to the replaced StatsD, want it closed")
+	}
+}
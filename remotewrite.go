@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/klauspost/compress/s2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// RemoteWrite pushes the metrics of each snapshot with the Prometheus remote
+// write protocol, e.g. to the remote write endpoint of an Amazon Managed
+// Service for Prometheus workspace, when they are not scraped, as in Lambda.
+// The requests are signed with SigV4 for aps when the region is set.
+// ref: https://prometheus.io/docs/concepts/remote_write_spec/
+type RemoteWrite struct {
+	client   *http.Client
+	url      string
+	gatherer prometheus.Gatherer
+	// signer signs the requests for region, nil when they are not signed
+	signer *v4.Signer
+	region string
+}
+
+func NewRemoteWrite(url, region string) *RemoteWrite {
+	r := &RemoteWrite{
+		client:   &http.Client{Timeout: 30 * time.Second},
+		url:      url,
+		gatherer: prometheus.DefaultGatherer,
+		region:   region,
+	}
+	if len(region) != 0 {
+		sess := session.Must(session.NewSessionWithOptions(session.Options{
+			SharedConfigState: session.SharedConfigEnable,
+		}))
+		r.signer = v4.NewSigner(sess.Config.Credentials)
+	}
+
+	return r
+}
+
+func (r *RemoteWrite) Name() string {
+	return "remote_write"
+}
+
+func (r *RemoteWrite) Close() error {
+	return nil
+}
+
+// Write pushes the metrics of the registry rather than infos, so that the same
+// series are stored as when they are scraped, without the runtime metrics.
+func (r *RemoteWrite) Write(_ []RDSInfo) error {
+	families, err := r.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	body := s2.EncodeSnappy(nil, encodeWriteRequest(families, time.Now()))
+
+	req, err := http.NewRequest(http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if r.signer != nil {
+		_, err := r.signer.Sign(req, bytes.NewReader(body), "aps", r.region, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to sign request: %w", err)
+		}
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post write request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("remote write returned status %v: %s", resp.Status, b)
+	}
+
+	return nil
+}
+
+// remoteWriteSample is a sample of a series of the remote write request.
+type remoteWriteSample struct {
+	// labels are the label pairs of the series, __name__ included
+	labels    [][2]string
+	value     float64
+	timestamp int64
+}
+
+// encodeWriteRequest encodes the samples of the families but the runtime
+// ones as a prometheus.WriteRequest, the histograms and summaries as their
+// _bucket, _sum and _count series. The samples without a timestamp are at
+// now.
+func encodeWriteRequest(families []*dto.MetricFamily, now time.Time) []byte {
+	var samples []remoteWriteSample
+	for _, family := range families {
+		if hasAnyPrefix(family.GetName(), runtimeMetricPrefixes) {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			timestamp := now.UnixMilli()
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs()
+			}
+			add := func(name string, value float64, extra ...string) {
+				labels := [][2]string{{"__name__", name}}
+				for _, pair := range m.GetLabel() {
+					labels = append(labels, [2]string{pair.GetName(), pair.GetValue()})
+				}
+				for i := 0; i+1 < len(extra); i += 2 {
+					labels = append(labels, [2]string{extra[i], extra[i+1]})
+				}
+				sort.Slice(labels, func(i, j int) bool { return labels[i][0] < labels[j][0] })
+				samples = append(samples, remoteWriteSample{labels: labels, value: value, timestamp: timestamp})
+			}
+
+			name := family.GetName()
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, bucket := range h.GetBucket() {
+					add(name+"_bucket", float64(bucket.GetCumulativeCount()), "le", strconv.FormatFloat(bucket.GetUpperBound(), 'g', -1, 64))
+				}
+				add(name+"_bucket", float64(h.GetSampleCount()), "le", "+Inf")
+				add(name+"_sum", h.GetSampleSum())
+				add(name+"_count", float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, quantile := range s.GetQuantile() {
+					add(name, quantile.GetValue(), "quantile", strconv.FormatFloat(quantile.GetQuantile(), 'g', -1, 64))
+				}
+				add(name+"_sum", s.GetSampleSum())
+				add(name+"_count", float64(s.GetSampleCount()))
+			}
+		}
+	}
+
+	// WriteRequest: repeated TimeSeries timeseries = 1
+	// TimeSeries: repeated Label labels = 1; repeated Sample samples = 2
+	// Label: string name = 1; string value = 2
+	// Sample: double value = 1; int64 timestamp = 2
+	var b []byte
+	for _, sample := range samples {
+		var series []byte
+		for _, pair := range sample.labels {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, pair[0])
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, pair[1])
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, label)
+		}
+		var s []byte
+		s = protowire.AppendTag(s, 1, protowire.Fixed64Type)
+		s = protowire.AppendFixed64(s, math.Float64bits(sample.value))
+		s = protowire.AppendTag(s, 2, protowire.VarintType)
+		s = protowire.AppendVarint(s, uint64(sample.timestamp))
+		series = protowire.AppendTag(series, 2, protowire.BytesType)
+		series = protowire.AppendBytes(series, s)
+
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, series)
+	}
+
+	return b
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeWriteRequest decodes the series of a WriteRequest as their labels in
+// the text format, such as {__name__="a",b="c"}, with their value.
+func decodeWriteRequest(t *testing.T, b []byte) map[string]float64 {
+	t.Helper()
+
+	// fields returns the fields of a message by number, the varints and
+	// fixed64 as their value and the others as their bytes
+	fields := func(b []byte) map[protowire.Number][]any {
+		ret := map[protowire.Number][]any{}
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			b = b[n:]
+			var v any
+			switch typ {
+			case protowire.BytesType:
+				v, n = protowire.ConsumeBytes(b)
+			case protowire.VarintType:
+				v, n = protowire.ConsumeVarint(b)
+			case protowire.Fixed64Type:
+				v, n = protowire.ConsumeFixed64(b)
+			default:
+				t.Fatalf("unexpected wire type %v", typ)
+			}
+			if n < 0 {
+				t.Fatal(protowire.ParseError(n))
+			}
+			b = b[n:]
+			ret[num] = append(ret[num], v)
+		}
+		return ret
+	}
+
+	ret := map[string]float64{}
+	for _, series := range fields(b)[1] {
+		series := fields(series.([]byte))
+		var labels []string
+		for _, label := range series[1] {
+			label := fields(label.([]byte))
+			labels = append(labels, string(label[1][0].([]byte))+"="+`"`+string(label[2][0].([]byte))+`"`)
+		}
+		sample := fields(series[2][0].([]byte))
+		ret["{"+strings.Join(labels, ",")+"}"] = math.Float64frombits(sample[1][0].(uint64))
+	}
+
+	return ret
+}
+
+func TestRemoteWrite(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "aws_custom_rds_max_connections"}, []string{"dbinstanceidentifier"})
+	gauge.WithLabelValues("a01").Set(1800)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "aws_custom_rds_latency_seconds", Buckets: []float64{0.5}})
+	histogram.Observe(0.1)
+	histogram.Observe(1)
+	reg.MustRegister(gauge, histogram, prometheus.NewGoCollector())
+
+	var got map[string]float64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("got headers %v, want snappy protobuf", r.Header)
+		}
+		b, _ := io.ReadAll(r.Body)
+		b, err := s2.Decode(nil, b)
+		if err != nil {
+			t.Error(err)
+		}
+		got = decodeWriteRequest(t, b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	r := &RemoteWrite{client: server.Client(), url: server.URL, gatherer: reg}
+	err := r.Write(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]float64{
+		`{__name__="aws_custom_rds_max_connections",dbinstanceidentifier="a01"}`: 1800,
+		`{__name__="aws_custom_rds_latency_seconds_bucket",le="0.5"}`:            1,
+		`{__name__="aws_custom_rds_latency_seconds_bucket",le="+Inf"}`:           2,
+		`{__name__="aws_custom_rds_latency_seconds_sum"}`:                        1.1,
+		`{__name__="aws_custom_rds_latency_seconds_count"}`:                      2,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got series %v, want %v", got, want)
+	}
+	for series, value := range want {
+		if v, ok := got[series]; !ok || v != value {
+			t.Errorf("got %v %v, want %v", series, v, value)
+		}
+	}
+
+	// a rejected write fails the output
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	})
+	err = r.Write(nil)
+	if err == nil || !strings.Contains(err.Error(), "out of order sample") {
+		t.Errorf("got error %v, want the rejection", err)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	reportFormatMarkdown = "markdown"
+	reportFormatCSV      = "csv"
+)
+
+// The sections of the report.
+const (
+	reportOver    = "over"
+	reportUnder   = "under"
+	reportSkipped = "skipped"
+	reportPending = "pending"
+)
+
+// reportRow is an instance in a section of the report of the report command.
+type reportRow struct {
+	section string
+	info    RDSInfo
+	// peak and utilization are set in the over and under sections
+	peak        float64
+	utilization float64
+	// detail is the recommendation, the skip reason or the pending changes
+	detail string
+}
+
+// report prints the capacity review of the instances of a single discovery
+// pass: the ones whose peak connections over the lookback of the
+// recommendations are over the upsize utilization or under the downsize one,
+// the skipped ones, and the pending changes, in Markdown or CSV.
+func report(ctx context.Context, w io.Writer, cfg *Config, format string) error {
+	// the report needs the peak connections and the pending maintenance
+	cfg.Recommendations.Enabled = true
+	cfg.ExportMaintenance = true
+	infos, err := collect(ctx, cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	return writeReport(w, cfg.Recommendations, infos, format, clk.Now())
+}
+
+func reportRows(cfg RecommendationsConfig, infos []RDSInfo) []reportRow {
+	var over, under, skipped, pending []reportRow
+	for _, info := range infos {
+		if len(info.SkipReason) != 0 {
+			skipped = append(skipped, reportRow{section: reportSkipped, info: info, detail: info.SkipReason})
+			continue
+		}
+
+		maxConnections, err := strconv.Atoi(info.MaxConnections)
+		if info.PeakDatabaseConnections != nil && err == nil && maxConnections > 0 {
+			row := reportRow{info: info, peak: *info.PeakDatabaseConnections, utilization: utilization(*info.PeakDatabaseConnections, maxConnections)}
+			if r, ok := recommend(cfg, info); ok {
+				row.detail = r.Action
+				if len(r.RecommendedClass) != 0 {
+					row.detail += " to " + r.RecommendedClass
+				}
+			}
+			switch {
+			case row.utilization >= cfg.UpsizeUtilization:
+				row.section = reportOver
+				over = append(over, row)
+			case row.utilization <= cfg.DownsizeUtilization:
+				row.section = reportUnder
+				under = append(under, row)
+			}
+		}
+
+		var changes []string
+		if len(info.PendingDBInstanceClass) != 0 {
+			changes = append(changes, "class change to "+info.PendingDBInstanceClass)
+		}
+		if info.PendingReboot {
+			changes = append(changes, "parameter changes pending a reboot")
+		}
+		if info.PendingMaintenanceActions != 0 {
+			change := fmt.Sprintf("%d pending maintenance actions", info.PendingMaintenanceActions)
+			if info.PendingMaintenanceActions == 1 {
+				change = "a pending maintenance action"
+			}
+			if info.PendingMaintenanceApplyDate != nil {
+				change += " applied from " + info.PendingMaintenanceApplyDate.UTC().Format(time.RFC3339)
+			}
+			changes = append(changes, change)
+		}
+		if len(changes) != 0 {
+			pending = append(pending, reportRow{section: reportPending, info: info, detail: strings.Join(changes, ", ")})
+		}
+	}
+
+	return append(append(append(over, under...), skipped...), pending...)
+}
+
+func writeReport(w io.Writer, cfg RecommendationsConfig, infos []RDSInfo, format string, now time.Time) error {
+	rows := reportRows(cfg, infos)
+
+	switch format {
+	case reportFormatMarkdown:
+		var b strings.Builder
+		fmt.Fprintf(&b, "# RDS connection capacity report\n\n")
+		fmt.Fprintf(&b, "%v, from the peak connections of the last %v.\n", now.UTC().Format(time.RFC3339), cfg.Lookback)
+
+		table := func(title, section string, header []string, cells func(reportRow) []string) {
+			fmt.Fprintf(&b, "\n## %v\n\n", title)
+			var n int
+			for _, row := range rows {
+				if row.section != section {
+					continue
+				}
+				if n == 0 {
+					fmt.Fprintf(&b, "| %v |\n|%v\n", strings.Join(header, " | "), strings.Repeat(" --- |", len(header)))
+				}
+				n++
+				values := cells(row)
+				for i := range values {
+					values[i] = strings.ReplaceAll(values[i], "|", `\|`)
+				}
+				fmt.Fprintf(&b, "| %v |\n", strings.Join(values, " | "))
+			}
+			if n == 0 {
+				fmt.Fprintf(&b, "None.\n")
+			}
+		}
+		utilizationHeader := []string{"Instance", "Region", "Engine", "Class", "max_connections", "Peak connections", "Utilization", "Recommendation"}
+		utilizationCells := func(row reportRow) []string {
+			return []string{
+				row.info.DBInstanceIdentifier, row.info.Region, row.info.DBEngine, row.info.DBInstanceClass, row.info.MaxConnections,
+				strconv.FormatFloat(row.peak, 'f', -1, 64), strconv.FormatFloat(row.utilization, 'f', -1, 64) + "%", row.detail,
+			}
+		}
+		table(fmt.Sprintf("Over %v%% of max_connections", cfg.UpsizeUtilization), reportOver, utilizationHeader, utilizationCells)
+		table(fmt.Sprintf("Under %v%% of max_connections", cfg.DownsizeUtilization), reportUnder, utilizationHeader, utilizationCells)
+		table("Unsupported and skipped instances", reportSkipped, []string{"Instance", "Region", "Engine", "Class", "Reason"}, func(row reportRow) []string {
+			return []string{row.info.DBInstanceIdentifier, row.info.Region, row.info.DBEngine, row.info.DBInstanceClass, row.detail}
+		})
+		table("Pending changes", reportPending, []string{"Instance", "Region", "Class", "Changes"}, func(row reportRow) []string {
+			return []string{row.info.DBInstanceIdentifier, row.info.Region, row.info.DBInstanceClass, row.detail}
+		})
+
+		_, err := io.WriteString(w, b.String())
+		if err != nil {
+			return fmt.Errorf("failed to write Markdown: %w", err)
+		}
+	case reportFormatCSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{
+			"section", "region", "db_instance_identifier", "engine", "db_instance_class", "max_connections",
+			"peak_connections", "utilization", "detail",
+		})
+		for _, row := range rows {
+			var peak, utilization string
+			if row.section == reportOver || row.section == reportUnder {
+				peak = strconv.FormatFloat(row.peak, 'f', -1, 64)
+				utilization = strconv.FormatFloat(row.utilization, 'f', -1, 64)
+			}
+			maxConnections := row.info.MaxConnections
+			if row.section == reportSkipped {
+				maxConnections = ""
+			}
+			_ = cw.Write([]string{
+				row.section, row.info.Region, row.info.DBInstanceIdentifier, row.info.DBEngine, row.info.DBInstanceClass, maxConnections,
+				peak, utilization, row.detail,
+			})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown format: %v", format)
+	}
+
+	return nil
+}
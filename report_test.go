@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/maxcon/postgresql"
+)
+
+func TestWriteReport(t *testing.T) {
+	info := func(identifier, class, maxConnections string, peak float64) RDSInfo {
+		return RDSInfo{
+			Region: "ap-northeast-1", DBInstanceIdentifier: identifier, DBEngine: "postgres", DBInstanceClass: class, MaxConnections: maxConnections,
+			Resolution:              &maxcon.Resolution{Raw: postgresql.DefaultFormula, Branch: maxcon.BranchDefaultFormula},
+			PeakDatabaseConnections: &peak,
+		}
+	}
+	pending := info("pending", "db.r5.xlarge", "3600", 2000)
+	pending.PendingDBInstanceClass = "db.r5.2xlarge"
+	pending.PendingReboot = true
+	pending.PendingMaintenanceActions = 1
+	pending.PendingMaintenanceApplyDate = ptr(time.Date(2024, 1, 7, 5, 0, 0, 0, time.UTC))
+	infos := []RDSInfo{
+		info("busy", "db.r5.large", "1800", 1600),
+		info("idle", "db.r5.4xlarge", "5000", 200),
+		pending,
+		{Region: "ap-northeast-1", DBInstanceIdentifier: "mysql", DBEngine: "mysql", DBInstanceClass: "db.r5.large", SkipReason: "unsupported engine: mysql"},
+	}
+	cfg := defaultConfig().Recommendations
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var b bytes.Buffer
+	err := writeReport(&b, cfg, infos, reportFormatMarkdown, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# RDS connection capacity report
+
+2024-01-01T00:00:00Z, from the peak connections of the last 336h0m0s.
+
+## Over 80% of max_connections
+
+| Instance | Region | Engine | Class | max_connections | Peak connections | Utilization | Recommendation |
+| --- | --- | --- | --- | --- | --- | --- | --- |
+| busy | ap-northeast-1 | postgres | db.r5.large | 1800 | 1600 | 88.9% | upsize to db.r5.xlarge |
+
+## Under 30% of max_connections
+
+| Instance | Region | Engine | Class | max_connections | Peak connections | Utilization | Recommendation |
+| --- | --- | --- | --- | --- | --- | --- | --- |
+| idle | ap-northeast-1 | postgres | db.r5.4xlarge | 5000 | 200 | 4% | downsize to db.r5.large |
+
+## Unsupported and skipped instances
+
+| Instance | Region | Engine | Class | Reason |
+| --- | --- | --- | --- | --- |
+| mysql | ap-northeast-1 | mysql | db.r5.large | unsupported engine: mysql |
+
+## Pending changes
+
+| Instance | Region | Class | Changes |
+| --- | --- | --- | --- |
+| pending | ap-northeast-1 | db.r5.xlarge | class change to db.r5.2xlarge, parameter changes pending a reboot, a pending maintenance action applied from 2024-01-07T05:00:00Z |
+`
+	if b.String() != want {
+		t.Errorf("got Markdown:\n%s\nwant:\n%s", &b, want)
+	}
+
+	b.Reset()
+	err = writeReport(&b, cfg, infos[:1], reportFormatCSV, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `section,region,db_instance_identifier,engine,db_instance_class,max_connections,peak_connections,utilization,detail
+over,ap-northeast-1,busy,postgres,db.r5.large,1800,1600,88.9,upsize to db.r5.xlarge
+`
+	if b.String() != want {
+		t.Errorf("got CSV:\n%s\nwant:\n%s", &b, want)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// s3ConfigTimeout bounds the read of the configuration from S3.
+const s3ConfigTimeout = 30 * time.Second
+
+// s3Config is an S3 object holding the configuration document, given as
+// s3://bucket/key, in the region of the environment or of a region query
+// parameter such as s3://bucket/key?region=eu-west-1. The object is only
+// downloaded again when its ETag changed, so that it can be refreshed often.
+type s3Config struct {
+	uri    string
+	bucket string
+	key    string
+	svc    s3iface.S3API
+
+	mu   sync.Mutex
+	etag string
+	body []byte
+}
+
+func newS3Config(uri string) (*s3Config, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "s3" || len(u.Host) == 0 || len(strings.TrimPrefix(u.Path, "/")) == 0 {
+		return nil, fmt.Errorf("invalid S3 config URL, want s3://bucket/key: %v", uri)
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	handleAudit(sess, "")
+	config := aws.NewConfig()
+	if region := u.Query().Get("region"); len(region) != 0 {
+		config = config.WithRegion(region)
+	}
+
+	return &s3Config{
+		uri:    uri,
+		bucket: u.Host,
+		key:    strings.TrimPrefix(u.Path, "/"),
+		svc:    s3.New(sess, config),
+	}, nil
+}
+
+func (s *s3Config) String() string {
+	return s.uri
+}
+
+func (s *s3Config) read() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3ConfigTimeout)
+	defer cancel()
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key),
+	}
+	if len(s.etag) != 0 {
+		input.IfNoneMatch = aws.String(s.etag)
+	}
+	out, err := s.svc.GetObjectWithContext(ctx, input)
+	var failure awserr.RequestFailure
+	if errors.As(err, &failure) && failure.StatusCode() == http.StatusNotModified {
+		return s.body, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config object %v: %w", s.uri, err)
+	}
+	defer out.Body.Close()
+
+	b, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config object %v: %w", s.uri, err)
+	}
+	s.etag, s.body = aws.StringValue(out.ETag), b
+
+	return b, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// fakeS3 serves the versions of an object, changing on each request until the
+// last one, whose ETag is their content, counting the downloads.
+type fakeS3 struct {
+	s3iface.S3API
+	mu        sync.Mutex
+	versions  []string
+	requests  int
+	downloads int
+}
+
+func (f *fakeS3) GetObjectWithContext(_ aws.Context, input *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	current := f.versions[min(f.requests, len(f.versions)-1)]
+	f.requests++
+	etag := `"` + current + `"`
+	if aws.StringValue(input.IfNoneMatch) == etag {
+		return nil, awserr.NewRequestFailure(awserr.New("NotModified", "Not Modified", nil), http.StatusNotModified, "")
+	}
+	f.downloads++
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(current)), ETag: aws.String(etag)}, nil
+}
+
+func TestS3ConfigRefresh(t *testing.T) {
+	// loaded, read when starting to refresh, then changed
+	eu := "targets:\n  - region: eu-west-1\n"
+	svc := &fakeS3{versions: []string{eu, eu, "targets:\n  - region: us-east-1\n"}}
+	f := &flags{source: &s3Config{uri: "s3://config/rds-maxcon.yaml", bucket: "config", key: "rds-maxcon.yaml", svc: svc}}
+
+	cfg, err := loadConfig(f.source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := newState(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go st.refreshEvery(ctx, f, 10*time.Millisecond)
+
+	select {
+	case <-st.reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the changed object was not reloaded")
+	}
+	cfg, _ = st.get()
+	if len(cfg.Targets) != 1 || cfg.Targets[0].Region != "us-east-1" {
+		t.Errorf("got targets %+v, want us-east-1", cfg.Targets)
+	}
+	cancel()
+
+	// the unchanged object is not downloaded on each refresh
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if svc.downloads != 2 {
+		t.Errorf("got %v downloads, want 2", svc.downloads)
+	}
+}
+
+func TestNewS3Config(t *testing.T) {
+	for _, uri := range []string{"s3://config", "s3:///rds-maxcon.yaml", "s3://config/"} {
+		_, err := newS3Config(uri)
+		if err == nil {
+			t.Errorf("%v: got no error, want an invalid URL", uri)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+	// the time zones of the schedules, which the image does not have
+	_ "time/tzdata"
+
+	"github.com/robfig/cron/v3"
+)
+
+// schedule collects each target in the background on its own interval, starting
+// right away so that /metrics is not empty for a whole interval after
+// startup. The targets are restarted on reload, keeping the last results of
+// the targets that are still configured, starting with previous. It returns
+// when ctx is done and all the targets stopped.
+func schedule(ctx context.Context, st *state, store *Store, previous map[string][]RDSInfo) {
+	parent := ctx
+	for {
+		ctx, cancel := context.WithCancel(parent)
+		cfg, outputs := st.get()
+		r := newResults(cfg, previous)
+		// drop the removed targets
+		targetUp.Reset()
+		circuitBreakerOpen.Reset()
+
+		var wg sync.WaitGroup
+		for _, target := range cfg.Targets {
+			wg.Add(1)
+			go func(target Target) {
+				defer wg.Done()
+				r.runTarget(ctx, cfg, store, outputs, target)
+			}(target)
+		}
+
+		select {
+		case <-parent.Done():
+			cancel()
+			wg.Wait()
+			return
+		case <-st.reloaded:
+		}
+		cancel()
+		wg.Wait()
+
+		previous = r.byTarget
+	}
+}
+
+// results are the last instances collected from each target, which are
+// published together whenever a target is collected.
+type results struct {
+	mu       sync.Mutex
+	keys     []string
+	byTarget map[string][]RDSInfo
+	// followed is when the snapshot of the leader served by the standby was
+	// saved
+	followed time.Time
+}
+
+func newResults(cfg *Config, previous map[string][]RDSInfo) *results {
+	r := &results{byTarget: map[string][]RDSInfo{}}
+
+	for _, target := range cfg.Targets {
+		key := target.key()
+		r.keys = append(r.keys, key)
+		if infos, ok := previous[key]; ok {
+			r.byTarget[key] = infos
+		}
+	}
+
+	return r
+}
+
+// runTarget collects a target every interval, or on the cron schedule when
+// configured, each tick being delayed by a random jitter so that replicas do
+// not call the AWS APIs at the same second. A standby serves the snapshots of
+// the leader instead, and collects right away when it gains the leadership.
+func (r *results) runTarget(ctx context.Context, cfg *Config, store *Store, outputs []Output, target Target) {
+	var ticks <-chan time.Time
+	if cfg.schedule == nil {
+		ticker := clk.NewTicker(cfg.TargetInterval(target))
+		defer ticker.Stop()
+		ticks = ticker.C()
+	}
+
+	var b breaker
+	for {
+		// taken before the check, so that a leadership gained meanwhile is not missed
+		gained := leader.Gained()
+		switch {
+		case !leader.IsLeader():
+			r.followLeader(cfg, store)
+		case b.allow(clk.Now()):
+			err := r.snapshotTarget(ctx, cfg, store, outputs, target)
+			b.record(cfg.CircuitBreaker, target, clk.Now(), err)
+		}
+		dog.beat(target)
+
+		if !nextTick(ctx, ticks, cfg.schedule, gained) {
+			return
+		}
+
+		if cfg.Jitter > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-clk.After(time.Duration(rand.Int63n(int64(cfg.Jitter)))): //nolint:gosec
+			}
+		}
+	}
+}
+
+// nextTick waits for the next tick of the ticker, or of the schedule when not
+// nil, or for wake to be closed, and reports false when ctx is done.
+func nextTick(ctx context.Context, ticks <-chan time.Time, schedule cron.Schedule, wake <-chan struct{}) bool {
+	if schedule != nil {
+		now := clk.Now()
+		timer := clk.NewTimer(schedule.Next(now).Sub(now))
+		defer timer.Stop()
+		ticks = timer.C()
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-ticks:
+		return true
+	case <-wake:
+		return true
+	}
+}
+
+// followLeader serves the snapshot the leader saved to snapshot_file, which
+// is on a volume shared by the replicas, when it is newer than the one
+// served. The outputs are not written, since the leader writes them.
+func (r *results) followLeader(cfg *Config, store *Store) {
+	if len(cfg.SnapshotFile) == 0 {
+		return
+	}
+
+	snapshot, err := loadSnapshot(cfg.SnapshotFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		slog.Warn("failed to read snapshot of the leader", "err", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !snapshot.SavedAt.After(r.followed) {
+		return
+	}
+	r.followed = snapshot.SavedAt
+
+	var all []RDSInfo
+	for _, key := range r.keys {
+		r.byTarget[key] = snapshot.Targets[key]
+		all = append(all, r.byTarget[key]...)
+	}
+	err = publish(cfg, store, nil, all)
+	if err != nil {
+		slog.Warn("failed to serve snapshot of the leader", "err", err)
+		return
+	}
+	setSnapshotTime(snapshot.SavedAt)
+}
+
+// snapshotTarget collects a target within the snapshot timeout and publishes
+// it with the last results of the other targets. On error the previous
+// results of the target are kept until they expire with series_ttl, and the
+// error of the collection is returned for the circuit breaker.
+func (r *results) snapshotTarget(ctx context.Context, cfg *Config, store *Store, outputs []Output, target Target) error {
+	timeout := cfg.TargetSnapshotTimeout(target)
+	tctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	infos, err := collectTarget(tctx, cfg, target, outputs)
+	if ctx.Err() != nil {
+		// reloading
+		return nil
+	}
+	setTargetUp(target, err)
+	if err != nil {
+		if errors.Is(tctx.Err(), context.DeadlineExceeded) {
+			snapshotTimeouts.Inc()
+		}
+		// keep serving the last good snapshot and retry on the next tick
+		snapshotErrors.Inc()
+		slog.Error("failed to take snapshot", "target", targetName(target), "timeout", timeout, "err", err)
+		if cfg.SeriesTTL != 0 {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			key := target.key()
+			previous := r.byTarget[key]
+			r.byTarget[key] = expireInstances(cfg, previous, nil, false)
+			// the series of the expired instances are removed right away
+			if len(r.byTarget[key]) != len(previous) {
+				r.publish(cfg, store, outputs, "")
+			}
+		}
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := target.key()
+	r.byTarget[key] = expireInstances(cfg, r.byTarget[key], infos, true)
+	r.publish(cfg, store, outputs, key)
+
+	return nil
+}
+
+// publish publishes the last results of all the targets, and saves them to
+// the snapshot file. Only the instances of the collected target, none when it
+// is empty, are written to the outputs which are not stateful, so that they
+// are written once per interval of their target. r.mu must be held.
+func (r *results) publish(cfg *Config, store *Store, outputs []Output, collected string) {
+	var all []RDSInfo
+	start, end := 0, 0
+	for _, key := range r.keys {
+		if key == collected {
+			start = len(all)
+		}
+		all = append(all, r.byTarget[key]...)
+		if key == collected {
+			end = len(all)
+		}
+	}
+
+	err := publishCollected(cfg, store, outputs, all, func(i int) bool { return start <= i && i < end })
+	if err != nil {
+		snapshotErrors.Inc()
+		slog.Error("failed to publish snapshot", "err", err)
+	}
+
+	if len(cfg.SnapshotFile) != 0 {
+		err = saveSnapshot(cfg.SnapshotFile, r.byTarget)
+		if err != nil {
+			slog.Warn("failed to save snapshot", "err", err)
+		}
+	}
+}
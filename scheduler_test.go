@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter"
+	"github.com/chaspy/aws-rds-maxcon-prometheus-exporter/pkg/exporter/exportertest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robfig/cron/v3"
+)
+
+// fakeClock only moves on Advance, firing the timers and the tickers whose
+// time came.
+type fakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	clock  *fakeClock
+	at     time.Time
+	period time.Duration // 0 for a timer
+	c      chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	c := &fakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) add(d, period time.Duration) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &fakeWaiter{clock: c, at: c.now.Add(d), period: period, c: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	c.cond.Broadcast()
+
+	return w
+}
+
+func (c *fakeClock) remove(w *fakeWaiter) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, waiter := range c.waiters {
+		if waiter == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time { return c.add(d, 0).c }
+func (c *fakeClock) NewTicker(d time.Duration) ticker       { return fakeTicker{c.add(d, d)} }
+func (c *fakeClock) NewTimer(d time.Duration) timer         { return c.add(d, 0) }
+
+func (w *fakeWaiter) C() <-chan time.Time { return w.c }
+func (w *fakeWaiter) Stop() bool          { return w.clock.remove(w) }
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTicker) Stop() { t.fakeWaiter.Stop() }
+
+// Advance moves the clock, firing the waiters in order. As the real ticker, a
+// ticker drops the ticks that are not received.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	var waiters []*fakeWaiter
+	for _, w := range c.waiters {
+		for !w.at.After(c.now) {
+			select {
+			case w.c <- w.at:
+			default:
+			}
+			if w.period == 0 {
+				break
+			}
+			w.at = w.at.Add(w.period)
+		}
+		if w.period != 0 || w.at.After(c.now) {
+			waiters = append(waiters, w)
+		}
+	}
+	c.waiters = waiters
+}
+
+// BlockUntil waits until n timers or tickers are pending.
+func (c *fakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+// collectingRDS signals each discovery.
+type collectingRDS struct {
+	*exportertest.RDS
+	collected chan struct{}
+}
+
+func (f *collectingRDS) DescribeDBInstancesPagesWithContext(ctx context.Context, input *rds.DescribeDBInstancesInput, fn func(*rds.DescribeDBInstancesOutput, bool) bool, opts ...request.Option) error {
+	f.collected <- struct{}{}
+	return f.RDS.DescribeDBInstancesPagesWithContext(ctx, input, fn, opts...)
+}
+
+// startTarget runs the collection of the target of cfg on a fake clock,
+// returning the channel receiving each discovery.
+func startTarget(t *testing.T, cfg *Config, c *fakeClock) <-chan struct{} {
+	t.Helper()
+
+	svc := &collectingRDS{
+		RDS: &exportertest.RDS{
+			InstancePages:  [][]*rds.DBInstance{{exportertest.Instance("db", "postgres", "group")}},
+			ParameterPages: map[string][][]*rds.Parameter{"group": exportertest.Parameters("100")},
+		},
+		collected: make(chan struct{}),
+	}
+	newRDS, realClk := newRDSAPI, clk
+	newRDSAPI = func(*session.Session) exporter.RDSAPI { return svc }
+	clk = c
+	registerInstanceMetrics(prometheus.NewRegistry(), cfg.LabelNames())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r := newResults(cfg, nil)
+		r.runTarget(ctx, cfg, &Store{}, nil, cfg.Targets[0])
+	}()
+	t.Cleanup(func() {
+		cancel()
+		// a discovery in flight is not received anymore
+		for {
+			select {
+			case <-svc.collected:
+			case <-done:
+				newRDSAPI, clk = newRDS, realClk
+				return
+			}
+		}
+	})
+
+	return svc.collected
+}
+
+func expectCollected(t *testing.T, collected <-chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-collected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the target was not collected")
+	}
+}
+
+func expectNotCollected(t *testing.T, collected <-chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-collected:
+		t.Fatal("the target was collected too early")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRunTargetInterval(t *testing.T) {
+	cfg := testConfig(t, func(cfg *Config) { cfg.Interval = time.Minute })
+	c := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	collected := startTarget(t, cfg, c)
+
+	// the first snapshot is taken at startup, without waiting for the interval
+	expectCollected(t, collected)
+
+	for i := 0; i < 3; i++ {
+		c.BlockUntil(1)
+		c.Advance(59 * time.Second)
+		expectNotCollected(t, collected)
+		c.Advance(time.Second)
+		expectCollected(t, collected)
+	}
+}
+
+func TestRunTargetJitter(t *testing.T) {
+	cfg := testConfig(t, func(cfg *Config) {
+		cfg.Interval = time.Minute
+		cfg.Jitter = 10 * time.Second
+	})
+	c := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	collected := startTarget(t, cfg, c)
+	expectCollected(t, collected)
+
+	// the tick is delayed by less than the jitter
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	c.BlockUntil(2)
+	expectNotCollected(t, collected)
+	c.Advance(10 * time.Second)
+	expectCollected(t, collected)
+}
+
+func TestRunTargetSchedule(t *testing.T) {
+	schedule, err := cron.ParseStandard("*/5 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(t, func(cfg *Config) { cfg.Interval = time.Minute })
+	cfg.schedule = schedule
+	c := newFakeClock(time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC))
+	collected := startTarget(t, cfg, c)
+	expectCollected(t, collected)
+
+	// the schedule is followed instead of the interval
+	c.BlockUntil(1)
+	c.Advance(3 * time.Minute)
+	expectNotCollected(t, collected)
+	c.Advance(time.Minute)
+	expectCollected(t, collected)
+
+	c.BlockUntil(1)
+	c.Advance(4 * time.Minute)
+	expectNotCollected(t, collected)
+	c.Advance(time.Minute)
+	expectCollected(t, collected)
+}
+
+// recordingOutput records the identifiers of the instances of each write.
+type recordingOutput struct {
+	stateful bool
+	writes   [][]string
+}
+
+func (o *recordingOutput) Name() string   { return "recording" }
+func (o *recordingOutput) Close() error   { return nil }
+func (o *recordingOutput) Stateful() bool { return o.stateful }
+
+func (o *recordingOutput) Write(infos []RDSInfo) error {
+	var identifiers []string
+	for _, info := range infos {
+		identifiers = append(identifiers, info.DBInstanceIdentifier)
+	}
+	o.writes = append(o.writes, identifiers)
+	return nil
+}
+
+func TestResultsPublishOutputs(t *testing.T) {
+	cfg := testConfig(t, func(cfg *Config) {
+		cfg.Targets = []Target{{Region: "ap-northeast-1"}, {Region: "us-east-1"}}
+	})
+	registerInstanceMetrics(prometheus.NewRegistry(), cfg.LabelNames())
+	r := newResults(cfg, map[string][]RDSInfo{
+		cfg.Targets[0].key(): {{DBInstanceIdentifier: "a01", MaxConnections: "100"}},
+		cfg.Targets[1].key(): {{DBInstanceIdentifier: "b01", MaxConnections: "100"}, {DBInstanceIdentifier: "b02", MaxConnections: "100"}},
+	})
+	samples, changes := &recordingOutput{}, &recordingOutput{stateful: true}
+	outputs := []Output{samples, changes}
+
+	r.mu.Lock()
+	r.publish(cfg, &Store{}, outputs, cfg.Targets[1].key())
+	r.publish(cfg, &Store{}, outputs, cfg.Targets[0].key())
+	r.publish(cfg, &Store{}, outputs, "")
+	r.mu.Unlock()
+
+	// the samples of a target are written once per collection of the target
+	if len(samples.writes) != 3 || !equalStrings(samples.writes[0], []string{"b01", "b02"}) || !equalStrings(samples.writes[1], []string{"a01"}) || len(samples.writes[2]) != 0 {
+		t.Errorf("got writes %q, want the instances of the collected target", samples.writes)
+	}
+	// while the stateful outputs compare the whole snapshots
+	for _, write := range changes.writes {
+		if !equalStrings(write, []string{"a01", "b01", "b02"}) {
+			t.Errorf("got writes %q, want all the instances", changes.writes)
+		}
+	}
+}
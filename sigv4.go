@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+	// sigV4MaxSkew is how old or early a signature is accepted, as by AWS
+	sigV4MaxSkew = 5 * time.Minute
+	// sigV4MaxBody is the largest body hashed to verify a signature
+	sigV4MaxBody = 1 << 20
+	// sigV4UnsignedPayload is the payload hash of a request whose body is
+	// not signed, only accepted without a body
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// requireSigV4 rejects the requests which are not signed with AWS Signature
+// Version 4 by the configured access key, for the region and service, but the
+// ones of the health endpoints.
+func requireSigV4(cfg SigV4Config, next http.Handler) http.Handler {
+	if len(cfg.AccessKeyID) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if healthPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(r.Header.Get("Authorization")) == 0 {
+			http.Error(w, "SigV4 signature required", http.StatusUnauthorized)
+			return
+		}
+		err := verifySigV4(cfg, r, clk.Now())
+		if err != nil {
+			// the details, such as the expected access key and scope, are
+			// only logged, not told to the client
+			slog.Warn("rejected request with an invalid SigV4 signature", "path", r.URL.Path, "remote_addr", r.RemoteAddr, "err", err)
+			http.Error(w, "invalid SigV4 signature", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// verifySigV4 recomputes the signature of a request from its signed headers
+// and the hash of its body, which X-Amz-Content-Sha256 must match when set.
+// ref: https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func verifySigV4(cfg SigV4Config, r *http.Request, now time.Time) error {
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), sigV4Algorithm+" ")
+	if !ok {
+		return fmt.Errorf("unsupported algorithm")
+	}
+	fields := map[string]string{}
+	for _, field := range strings.Split(auth, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(field), "=")
+		fields[k] = v
+	}
+
+	credential := strings.Split(fields["Credential"], "/")
+	if len(credential) != 5 || credential[4] != "aws4_request" {
+		return fmt.Errorf("invalid credential")
+	}
+	if credential[0] != cfg.AccessKeyID {
+		return fmt.Errorf("unknown access key %v", credential[0])
+	}
+	if credential[2] != cfg.Region || credential[3] != cfg.Service {
+		return fmt.Errorf("scope is not %v/%v", cfg.Region, cfg.Service)
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse(sigV4TimeFormat, amzDate)
+	if err != nil {
+		return fmt.Errorf("invalid X-Amz-Date")
+	}
+	if signedAt.Format("20060102") != credential[1] {
+		return fmt.Errorf("X-Amz-Date is not of the credential date")
+	}
+	if d := now.Sub(signedAt); d > sigV4MaxSkew || d < -sigV4MaxSkew {
+		return fmt.Errorf("signed at %v, out of %v", amzDate, sigV4MaxSkew)
+	}
+
+	signedHeaders := strings.Split(fields["SignedHeaders"], ";")
+	if !slices.Contains(signedHeaders, "host") {
+		return fmt.Errorf("host is not signed")
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, sigV4MaxBody+1))
+		if err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+		if len(body) > sigV4MaxBody {
+			return fmt.Errorf("body is larger than %d bytes", sigV4MaxBody)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	payloadHash := sha256Hex(body)
+	switch header := r.Header.Get("X-Amz-Content-Sha256"); {
+	case len(header) == 0 || header == payloadHash:
+	case header == sigV4UnsignedPayload && len(body) == 0:
+		payloadHash = header
+	default:
+		return fmt.Errorf("X-Amz-Content-Sha256 is not the hash of the body")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL),
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders(r, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+	scope := strings.Join(credential[1:], "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + cfg.SecretAccessKey)
+	for _, part := range credential[1:] {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if !hmac.Equal([]byte(signature), []byte(fields["Signature"])) {
+		return fmt.Errorf("signature does not match")
+	}
+
+	return nil
+}
+
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if len(path) == 0 {
+		return "/"
+	}
+
+	return path
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, sigV4Escape(k)+"="+sigV4Escape(v))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Escape escapes all but the unreserved characters of RFC 3986.
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func canonicalHeaders(r *http.Request, signedHeaders []string) string {
+	var b strings.Builder
+	for _, name := range signedHeaders {
+		var values []string
+		if name == "host" {
+			values = []string{r.Host}
+		} else {
+			values = r.Header.Values(name)
+		}
+		trimmed := make([]string, 0, len(values))
+		for _, v := range values {
+			trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+		}
+		b.WriteString(name + ":" + strings.Join(trimmed, ",") + "\n")
+	}
+
+	return b.String()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
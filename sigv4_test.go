@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+func TestRequireSigV4(t *testing.T) {
+	cfg := SigV4Config{Region: "ap-northeast-1", Service: "aps", AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}
+	now := time.Now()
+	handler := requireSigV4(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	signAs := func(t *testing.T, r *http.Request, accessKeyID, secret string, at time.Time) {
+		t.Helper()
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = v4.NewSigner(credentials.NewStaticCredentials(accessKeyID, secret, "")).Sign(r, bytes.NewReader(body), cfg.Service, cfg.Region, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	sign := func(t *testing.T, r *http.Request, secret string, at time.Time) {
+		t.Helper()
+		signAs(t, r, cfg.AccessKeyID, secret, at)
+	}
+	signed := func(t *testing.T, r *http.Request) { sign(t, r, "secret", now) }
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		// header is set before signing
+		header map[string]string
+		sign   func(t *testing.T, r *http.Request)
+		modify func(r *http.Request)
+		want   int
+	}{
+		{name: "signed", path: "/metrics?name[]=a b&name[]=c", sign: func(t *testing.T, r *http.Request) { sign(t, r, "secret", now) }, want: http.StatusOK},
+		{name: "not signed", path: "/metrics", want: http.StatusUnauthorized},
+		{name: "other secret", path: "/metrics", sign: func(t *testing.T, r *http.Request) { sign(t, r, "other", now) }, want: http.StatusForbidden},
+		{name: "expired", path: "/metrics", sign: func(t *testing.T, r *http.Request) { sign(t, r, "secret", now.Add(-time.Hour)) }, want: http.StatusForbidden},
+		{name: "tampered", path: "/metrics", sign: func(t *testing.T, r *http.Request) { sign(t, r, "secret", now) },
+			modify: func(r *http.Request) { r.URL.Path = "/api/v1/instances" }, want: http.StatusForbidden},
+		{name: "unknown access key", path: "/metrics", sign: func(t *testing.T, r *http.Request) { signAs(t, r, "AKIDOTHER", "secret", now) }, want: http.StatusForbidden},
+		{name: "signed body", path: "/api/v1/instances", body: "a=1", sign: signed, want: http.StatusOK},
+		{name: "tampered body", path: "/api/v1/instances", body: "a=1", sign: signed,
+			modify: func(r *http.Request) { r.Body = io.NopCloser(strings.NewReader("a=2")) }, want: http.StatusForbidden},
+		{name: "content hash of another body", path: "/api/v1/instances", body: "a=2",
+			header: map[string]string{"X-Amz-Content-Sha256": sha256Hex([]byte("a=1"))}, sign: signed, want: http.StatusForbidden},
+		{name: "unsigned payload", path: "/metrics",
+			header: map[string]string{"X-Amz-Content-Sha256": "UNSIGNED-PAYLOAD"}, sign: signed, want: http.StatusOK},
+		{name: "unsigned payload with a body", path: "/api/v1/instances", body: "a=1",
+			header: map[string]string{"X-Amz-Content-Sha256": "UNSIGNED-PAYLOAD"}, sign: signed, want: http.StatusForbidden},
+		{name: "health", path: "/-/healthy", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://exporter.example.com"+strings.ReplaceAll(tt.path, " ", "%20"), strings.NewReader(tt.body))
+			for name, v := range tt.header {
+				r.Header.Set(name, v)
+			}
+			if tt.sign != nil {
+				tt.sign(t, r)
+			}
+			if tt.modify != nil {
+				tt.modify(r)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("got status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			// the reason is only logged
+			if w.Code == http.StatusForbidden && w.Body.String() != "invalid SigV4 signature\n" {
+				t.Errorf("got body %q, want no details", w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// skipLogs remembers the skipped instances which were logged, since the same
+// instances are skipped for the same reason on every snapshot.
+type skipLogs struct {
+	mu      sync.Mutex
+	entries map[string]skipLogEntry
+	pruned  time.Time
+}
+
+type skipLogEntry struct {
+	line   string
+	logged time.Time
+}
+
+//nolint:gochecknoglobals
+var skipped = &skipLogs{entries: map[string]skipLogEntry{}}
+
+// log tells whether the line of an instance is logged: when it is the first
+// one of the instance, when it changed or when the last one was logged
+// interval ago. The instances not logged for interval are forgotten.
+func (s *skipLogs) log(key, line string, interval time.Duration, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.pruned) >= interval {
+		for k, entry := range s.entries {
+			if now.Sub(entry.logged) >= interval {
+				delete(s.entries, k)
+			}
+		}
+		s.pruned = now
+	}
+
+	entry, ok := s.entries[key]
+	if ok && entry.line == line && now.Sub(entry.logged) < interval {
+		return false
+	}
+	s.entries[key] = skipLogEntry{line: line, logged: now}
+
+	return true
+}
+
+// dedupedMessages are the prefixes of the messages of the collector which
+// repeat for the same instances on every snapshot.
+//
+//nolint:gochecknoglobals
+var dedupedMessages = []string{"skip:", "ambiguous parameter groups:"}
+
+// skipLogHandler drops the records of dedupedMessages of the instances which
+// were already logged with the same message and attributes.
+type skipLogHandler struct {
+	slog.Handler
+	target   string
+	interval time.Duration
+}
+
+func (h *skipLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !hasAnyPrefix(r.Message, dedupedMessages) {
+		return h.Handler.Handle(ctx, r) //nolint:wrapcheck
+	}
+
+	var identifier string
+	line := r.Message
+	r.Attrs(func(a slog.Attr) bool {
+		value := a.Value.String()
+		switch a.Key {
+		case "dbinstanceidentifier":
+			identifier = value
+		case "err":
+			// the AWS errors end with their request ID, which changes on every call
+			value, _, _ = strings.Cut(value, "\n")
+		}
+		line += " " + a.Key + "=" + value
+		return true
+	})
+	if len(identifier) != 0 && !skipped.log(h.target+"|"+identifier, line, h.interval, clk.Now()) {
+		return nil
+	}
+
+	return h.Handler.Handle(ctx, r) //nolint:wrapcheck
+}
+
+func (h *skipLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &skipLogHandler{Handler: h.Handler.WithAttrs(attrs), target: h.target, interval: h.interval}
+}
+
+func (h *skipLogHandler) WithGroup(name string) slog.Handler {
+	return &skipLogHandler{Handler: h.Handler.WithGroup(name), target: h.target, interval: h.interval}
+}
+
+// skipLogger returns the logger of the collector of a target, which logs the
+// skipped instances when they are new or changed and every log.skip_interval.
+func skipLogger(cfg *Config, target Target) *slog.Logger {
+	if cfg.Log.SkipInterval == 0 {
+		return slog.Default()
+	}
+
+	return slog.New(&skipLogHandler{
+		Handler:  slog.Default().Handler(),
+		target:   target.Region + "|" + target.RoleARN + "|" + target.Endpoint,
+		interval: cfg.Log.SkipInterval,
+	})
+}
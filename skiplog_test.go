@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSkipLogs(t *testing.T) {
+	s := &skipLogs{entries: map[string]skipLogEntry{}}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		key   string
+		line  string
+		after time.Duration
+		want  bool
+	}{
+		{key: "a", line: "skip: unsupported engine", want: true},
+		{key: "a", line: "skip: unsupported engine", after: 30 * time.Minute, want: false},
+		{key: "b", line: "skip: unsupported engine", after: 30 * time.Minute, want: true},
+		{key: "a", line: "skip: max connection is 0", after: 40 * time.Minute, want: true},
+		{key: "a", line: "skip: max connection is 0", after: 99 * time.Minute, want: false},
+		{key: "a", line: "skip: max connection is 0", after: 100 * time.Minute, want: true},
+	}
+	for _, tt := range tests {
+		got := s.log(tt.key, tt.line, time.Hour, now.Add(tt.after))
+		if got != tt.want {
+			t.Errorf("%v %q after %v: got %v, want %v", tt.key, tt.line, tt.after, got, tt.want)
+		}
+	}
+
+	// b was not logged for an hour
+	if _, ok := s.entries["b"]; ok {
+		t.Error("b was not forgotten")
+	}
+}
+
+func TestSkipLogHandler(t *testing.T) {
+	skipped = &skipLogs{entries: map[string]skipLogEntry{}}
+	var b bytes.Buffer
+	log := slog.New(&skipLogHandler{Handler: slog.NewTextHandler(&b, nil), target: "ap-northeast-1||", interval: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		log.Warn("skip: failed to get Parameter Group", "dbinstanceidentifier", "denied",
+			"err", errors.New("AccessDenied: not authorized\n\tstatus code: 403, request id: "+strings.Repeat("x", i)))
+		log.Info("resolved max connections", "dbinstanceidentifier", "denied")
+	}
+	log.Warn("skip: failed to get max connections", "dbinstanceidentifier", "denied")
+
+	got := strings.Count(b.String(), "skip:")
+	if got != 2 {
+		t.Errorf("got %v skip lines, want 2:\n%s", got, &b)
+	}
+	if got := strings.Count(b.String(), "resolved"); got != 3 {
+		t.Errorf("got %v other lines, want 3:\n%s", got, &b)
+	}
+}
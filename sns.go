@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+)
+
+// SNS publishes a message to a topic when the connection utilization of an
+// instance exceeds the threshold. An instance is notified once per breach and
+// again only after it has gone back under the threshold.
+type SNS struct {
+	svc       snsiface.SNSAPI
+	topicARN  string
+	threshold float64
+	breached  map[string]bool
+}
+
+const defaultSNSUtilizationThreshold = 80
+
+// SNS subjects must be shorter than 100 characters.
+const snsMaxSubjectLength = 99
+
+func NewSNS(topicARN string, threshold float64) *SNS {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	handleAudit(sess, "")
+
+	return &SNS{
+		svc:       sns.New(sess),
+		topicARN:  topicARN,
+		threshold: threshold,
+		breached:  map[string]bool{},
+	}
+}
+
+func (s *SNS) Name() string {
+	return "sns"
+}
+
+func (s *SNS) Stateful() bool {
+	return true
+}
+
+func (s *SNS) Close() error {
+	return nil
+}
+
+func (s *SNS) NeedDatabaseConnections() bool {
+	return true
+}
+
+func (s *SNS) Write(infos []RDSInfo) error {
+	breached := make(map[string]bool, len(s.breached))
+
+	for _, info := range infos {
+		if info.DatabaseConnections == nil {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(info.MaxConnections, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse max connections to float64: %w", err)
+		}
+		if v <= 0 {
+			continue
+		}
+
+		utilization := *info.DatabaseConnections / v * 100
+		if utilization < s.threshold {
+			continue
+		}
+
+		key := info.Region + "|" + info.DBInstanceIdentifier
+		breached[key] = true
+		if s.breached[key] {
+			continue
+		}
+
+		err = s.publish(info, v, utilization)
+		if err != nil {
+			return err
+		}
+		// keep what has been notified so far so that a failure does not notify twice
+		s.breached[key] = true
+	}
+
+	s.breached = breached
+
+	return nil
+}
+
+func (s *SNS) publish(info RDSInfo, maxConnections, utilization float64) error {
+	subject := fmt.Sprintf("RDS connection utilization %.1f%%: %v", utilization, info.DBInstanceIdentifier)
+	if len(subject) > snsMaxSubjectLength {
+		subject = subject[:snsMaxSubjectLength]
+	}
+
+	message := strings.Join([]string{
+		fmt.Sprintf("Connection utilization of %v exceeds %v%%.", info.DBInstanceIdentifier, s.threshold),
+		"",
+		fmt.Sprintf("Region: %v", info.Region),
+		fmt.Sprintf("DBInstanceIdentifier: %v", info.DBInstanceIdentifier),
+		fmt.Sprintf("DBInstanceClass: %v", info.DBInstanceClass),
+		fmt.Sprintf("Engine: %v", info.DBEngine),
+		fmt.Sprintf("DatabaseConnections: %v", *info.DatabaseConnections),
+		fmt.Sprintf("MaxConnections: %v", maxConnections),
+		fmt.Sprintf("Utilization: %.1f%%", utilization),
+	}, "\n")
+
+	_, err := s.svc.Publish(&sns.PublishInput{
+		TopicArn: aws.String(s.topicARN),
+		Subject:  aws.String(subject),
+		Message:  aws.String(message),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to %v: %w", s.topicARN, err)
+	}
+
+	return nil
+}
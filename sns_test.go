@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+)
+
+// fakeSNS records the published messages, failing with err when set.
+type fakeSNS struct {
+	snsiface.SNSAPI
+	published []*sns.PublishInput
+	err       error
+}
+
+func (f *fakeSNS) Publish(input *sns.PublishInput) (*sns.PublishOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.published = append(f.published, input)
+	return &sns.PublishOutput{}, nil
+}
+
+func TestSNS(t *testing.T) {
+	svc := &fakeSNS{}
+	s := &SNS{svc: svc, topicARN: "arn:aws:sns:ap-northeast-1:123456789012:rds", threshold: 80, breached: map[string]bool{}}
+	write := func(connections ...float64) {
+		t.Helper()
+		svc.published = nil
+		var infos []RDSInfo
+		for i, c := range connections {
+			infos = append(infos, RDSInfo{
+				DBInstanceIdentifier: []string{"a01", "b01"}[i],
+				DBInstanceClass:      "db.r5.large",
+				DBEngine:             "postgres",
+				MaxConnections:       "100",
+				DatabaseConnections:  aws.Float64(c),
+			})
+		}
+		err := s.Write(infos)
+		if svc.err == nil && err != nil {
+			t.Fatal(err)
+		}
+	}
+	subjects := func() []string {
+		var ret []string
+		for _, input := range svc.published {
+			ret = append(ret, aws.StringValue(input.Subject))
+		}
+		return ret
+	}
+
+	write(90, 10)
+	if want := []string{"RDS connection utilization 90.0%: a01"}; !equalStrings(subjects(), want) {
+		t.Errorf("got subjects %q, want %q", subjects(), want)
+	}
+	if input := svc.published[0]; aws.StringValue(input.TopicArn) != s.topicARN || !strings.Contains(aws.StringValue(input.Message), "MaxConnections: 100") {
+		t.Errorf("got message %v, want the instance on the topic", input)
+	}
+
+	// a breach is notified once
+	write(95, 10)
+	if len(svc.published) != 0 {
+		t.Errorf("got subjects %q, want none", subjects())
+	}
+
+	// and again once it went back under the threshold
+	write(50, 80)
+	if want := []string{"RDS connection utilization 80.0%: b01"}; !equalStrings(subjects(), want) {
+		t.Errorf("got subjects %q, want %q", subjects(), want)
+	}
+	write(85, 80)
+	if want := []string{"RDS connection utilization 85.0%: a01"}; !equalStrings(subjects(), want) {
+		t.Errorf("got subjects %q, want %q", subjects(), want)
+	}
+
+	// a failed notification is retried on the next snapshot
+	write(10, 10)
+	svc.err = errors.New("throttled")
+	write(90, 10)
+	svc.err = nil
+	write(90, 10)
+	if want := []string{"RDS connection utilization 90.0%: a01"}; !equalStrings(subjects(), want) {
+		t.Errorf("got subjects %q, want %q", subjects(), want)
+	}
+
+	// the same identifier in another region is another breach
+	svc.published = nil
+	err := s.Write([]RDSInfo{{Region: "us-east-1", DBInstanceIdentifier: "a01", MaxConnections: "100", DatabaseConnections: aws.Float64(90)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svc.published) != 1 || !strings.Contains(aws.StringValue(svc.published[0].Message), "Region: us-east-1") {
+		t.Errorf("got subjects %q, want a01 of us-east-1 notified", subjects())
+	}
+}
+
+func TestSNSRedact(t *testing.T) {
+	svc := &fakeSNS{}
+	hashed := publishRedacted(t, &SNS{svc: svc, threshold: 80, breached: map[string]bool{}})
+
+	if len(svc.published) != 1 {
+		t.Fatalf("got %d messages, want 1", len(svc.published))
+	}
+	subject, message := aws.StringValue(svc.published[0].Subject), aws.StringValue(svc.published[0].Message)
+	if strings.Contains(subject+message, "tenant") || !strings.Contains(subject, hashed) || !strings.Contains(message, "DBInstanceIdentifier: "+hashed) {
+		t.Errorf("got message %q: %q, want the identifier hashed as %v", subject, message, hashed)
+	}
+}
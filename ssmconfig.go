@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// ssmConfigTimeout bounds the read of the configuration from Parameter Store.
+const ssmConfigTimeout = 30 * time.Second
+
+// ssmConfig is a String, or a SecureString decrypted with its KMS key, of SSM
+// Parameter Store holding the configuration document, by name such as
+// /prod/rds-maxcon/config or by the ARN of a parameter shared by another
+// account, whose region it is read from.
+type ssmConfig struct {
+	name string
+	svc  ssmiface.SSMAPI
+}
+
+func newSSMConfig(name string) *ssmConfig {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	handleAudit(sess, "")
+
+	region := aws.StringValue(sess.Config.Region)
+	if a, err := arn.Parse(name); err == nil {
+		region = a.Region
+	}
+
+	return &ssmConfig{name: name, svc: ssm.New(sess, aws.NewConfig().WithRegion(region))}
+}
+
+func (s *ssmConfig) String() string {
+	return "ssm:" + s.name
+}
+
+func (s *ssmConfig) read() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ssmConfigTimeout)
+	defer cancel()
+
+	out, err := s.svc.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(s.name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config parameter %v: %w", s.name, err)
+	}
+	if out.Parameter == nil {
+		return nil, fmt.Errorf("config parameter %v not found", s.name)
+	}
+
+	return []byte(aws.StringValue(out.Parameter.Value)), nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+type fakeSSM struct {
+	ssmiface.SSMAPI
+	parameters map[string]string
+}
+
+func (f *fakeSSM) GetParameterWithContext(_ aws.Context, input *ssm.GetParameterInput, _ ...request.Option) (*ssm.GetParameterOutput, error) {
+	if !aws.BoolValue(input.WithDecryption) {
+		return nil, errors.New("SecureString not decrypted")
+	}
+	value, ok := f.parameters[aws.StringValue(input.Name)]
+	if !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, "parameter not found", nil)
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String(value)}}, nil
+}
+
+func TestSSMConfig(t *testing.T) {
+	svc := &fakeSSM{parameters: map[string]string{"/prod/rds-maxcon/config": "targets:\n  - region: eu-west-1\n"}}
+
+	cfg, err := loadConfig(&ssmConfig{name: "/prod/rds-maxcon/config", svc: svc}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Targets) != 1 || cfg.Targets[0].Region != "eu-west-1" {
+		t.Errorf("got targets %+v, want eu-west-1", cfg.Targets)
+	}
+
+	_, err = loadConfig(&ssmConfig{name: "/prod/rds-maxcon/missing", svc: svc}, nil)
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) || aerr.Code() != ssm.ErrCodeParameterNotFound {
+		t.Errorf("got error %v, want ParameterNotFound", err)
+	}
+
+	// the parameter is validated like a file
+	svc.parameters["/prod/rds-maxcon/config"] = "targets:\n  - region: moon\n"
+	_, err = loadConfig(&ssmConfig{name: "/prod/rds-maxcon/config", svc: svc}, nil)
+	var fe *fieldError
+	if !errors.As(err, &fe) || fe.Path != "targets[0].region" {
+		t.Errorf("got error %v, want an invalid region", err)
+	}
+}
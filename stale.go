@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// staleness tells how old the served data is, since the last values keep
+// being served when AWS is unreachable.
+type staleness struct {
+	// last is the time of the last successful snapshot in Unix nanoseconds,
+	// the start of the process before the first one
+	last atomic.Int64
+	// after is the age from which the data is stale
+	after atomic.Int64
+}
+
+//nolint:gochecknoglobals
+var freshness = newStaleness()
+
+func newStaleness() *staleness {
+	s := &staleness{}
+	s.last.Store(time.Now().UnixNano())
+
+	return s
+}
+
+// setSnapshotTime records a successful snapshot taken at t.
+func setSnapshotTime(t time.Time) {
+	freshness.last.Store(t.UnixNano())
+	lastSnapshotSuccess.Set(float64(t.Unix()))
+}
+
+// setStaleAfter sets the age from which the data is stale, twice the longest
+// interval of the targets unless configured, so that a single failed
+// snapshot is not stale.
+func setStaleAfter(cfg *Config) {
+	after := cfg.StaleAfter
+	if after == 0 {
+		for _, target := range cfg.Targets {
+			if interval := 2 * cfg.TargetInterval(target); interval > after {
+				after = interval
+			}
+		}
+	}
+
+	freshness.after.Store(int64(after))
+}
+
+func (s *staleness) age() time.Duration {
+	return time.Since(time.Unix(0, s.last.Load()))
+}
+
+func newStalenessCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "snapshot_age_seconds",
+			Help:      "Age of the served data, since the last successful snapshot or the start of the process",
+		}, func() float64 {
+			return freshness.age().Seconds()
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "data_stale",
+			Help:      "1 when the served data is older than stale_after, such as during an AWS API outage",
+		}, func() float64 {
+			if freshness.age() > time.Duration(freshness.after.Load()) {
+				return 1
+			}
+			return 0
+		}),
+	}
+}
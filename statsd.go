@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// StatsD sends max_connections and connection utilization as gauges over UDP.
+// With tags enabled it speaks the DogStatsD dialect, otherwise the instance
+// is encoded into the metric name for plain StatsD/Graphite pipelines.
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+	tags   bool
+}
+
+const (
+	// Each datagram is kept under the common Ethernet MTU.
+	statsDMaxPacketSize = 1432
+
+	defaultStatsDPrefix = "aws_custom.rds"
+)
+
+//nolint:gochecknoglobals
+var statsDNameReplacer = strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", " ", "_")
+
+func NewDogStatsD(address string) (*StatsD, error) {
+	return newStatsD(address, defaultStatsDPrefix, true)
+}
+
+func NewStatsD(address, prefix string) (*StatsD, error) {
+	if len(prefix) == 0 {
+		prefix = defaultStatsDPrefix
+	}
+
+	return newStatsD(address, prefix, false)
+}
+
+func newStatsD(address, prefix string, tags bool) (*StatsD, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %v: %w", address, err)
+	}
+
+	return &StatsD{conn: conn, prefix: prefix, tags: tags}, nil
+}
+
+func (s *StatsD) Name() string {
+	if s.tags {
+		return "dogstatsd"
+	}
+	return "statsd"
+}
+
+func (s *StatsD) Close() error {
+	return s.conn.Close() //nolint:wrapcheck
+}
+
+func (s *StatsD) NeedDatabaseConnections() bool {
+	return true
+}
+
+func (s *StatsD) Write(infos []RDSInfo) error {
+	var buf bytes.Buffer
+
+	for _, info := range infos {
+		v, err := strconv.ParseFloat(info.MaxConnections, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse max connections to float64: %w", err)
+		}
+
+		lines := []string{s.gauge(info, "max_connections", v)}
+		if info.DatabaseConnections != nil && v > 0 {
+			lines = append(lines, s.gauge(info, "connection_utilization", *info.DatabaseConnections/v))
+		}
+
+		for _, line := range lines {
+			if buf.Len() > 0 && buf.Len()+len(line)+1 > statsDMaxPacketSize {
+				if err := s.flush(&buf); err != nil {
+					return err
+				}
+			}
+			if buf.Len() > 0 {
+				buf.WriteByte('\n')
+			}
+			buf.WriteString(line)
+		}
+	}
+
+	return s.flush(&buf)
+}
+
+func (s *StatsD) flush(buf *bytes.Buffer) error {
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	_, err := s.conn.Write(buf.Bytes())
+	buf.Reset()
+	if err != nil {
+		return fmt.Errorf("failed to send %v packet: %w", s.Name(), err)
+	}
+
+	return nil
+}
+
+func (s *StatsD) gauge(info RDSInfo, name string, value float64) string {
+	formatted := strconv.FormatFloat(value, 'f', -1, 64)
+
+	if !s.tags {
+		// e.g. aws_custom.rds.postgres-api-production-a01.max_connections:5000|g
+		return fmt.Sprintf("%v.%v.%v:%v|g", s.prefix, statsDNameReplacer.Replace(info.DBInstanceIdentifier), name, formatted)
+	}
+
+	tags := []string{
+		"dbinstanceidentifier:" + info.DBInstanceIdentifier,
+		"dbinstanceclass:" + info.DBInstanceClass,
+		"dbengine:" + info.DBEngine,
+	}
+
+	return fmt.Sprintf("%v.%v:%v|g|#%v", s.prefix, name, formatted, strings.Join(tags, ","))
+}
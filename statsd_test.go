@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// listenStatsD listens for the datagrams of a StatsD output on a local UDP
+// port, and returns its address and a function reading the datagrams sent so
+// far.
+func listenStatsD(t *testing.T) (string, func() []string) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn.LocalAddr().String(), func() []string {
+		var ret []string
+		b := make([]byte, 65536)
+		for {
+			_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			n, _, err := conn.ReadFrom(b)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return ret
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			ret = append(ret, string(b[:n]))
+		}
+	}
+}
+
+func TestDogStatsD(t *testing.T) {
+	address, read := listenStatsD(t)
+	s, err := NewDogStatsD(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Write([]RDSInfo{
+		{DBInstanceIdentifier: "a01", DBInstanceClass: "db.r5.large", DBEngine: "postgres", MaxConnections: "1800", DatabaseConnections: aws.Float64(900)},
+		{DBInstanceIdentifier: "b01", DBInstanceClass: "db.t3.micro", DBEngine: "mysql", MaxConnections: "66"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{strings.Join([]string{
+		"aws_custom.rds.max_connections:1800|g|#dbinstanceidentifier:a01,dbinstanceclass:db.r5.large,dbengine:postgres",
+		"aws_custom.rds.connection_utilization:0.5|g|#dbinstanceidentifier:a01,dbinstanceclass:db.r5.large,dbengine:postgres",
+		"aws_custom.rds.max_connections:66|g|#dbinstanceidentifier:b01,dbinstanceclass:db.t3.micro,dbengine:mysql",
+	}, "\n")}
+	if got := read(); !equalStrings(got, want) {
+		t.Errorf("got datagrams %q, want %q", got, want)
+	}
+}
+
+func TestStatsD(t *testing.T) {
+	address, read := listenStatsD(t)
+	s, err := NewStatsD(address, "custom")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the identifiers are encoded into the metric names
+	err = s.Write([]RDSInfo{{DBInstanceIdentifier: "a01.prod", MaxConnections: "1800", DatabaseConnections: aws.Float64(450)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"custom.a01_prod.max_connections:1800|g\ncustom.a01_prod.connection_utilization:0.25|g"}
+	if got := read(); !equalStrings(got, want) {
+		t.Errorf("got datagrams %q, want %q", got, want)
+	}
+}
+
+func TestStatsDPacketSize(t *testing.T) {
+	address, read := listenStatsD(t)
+	s, err := NewStatsD(address, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var infos []RDSInfo
+	for i := 0; i < 100; i++ {
+		infos = append(infos, RDSInfo{DBInstanceIdentifier: fmt.Sprintf("postgres-api-production-%03d", i), MaxConnections: "5000"})
+	}
+	err = s.Write(infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	datagrams := read()
+	if len(datagrams) < 2 {
+		t.Errorf("got %d datagrams, want the lines split into several", len(datagrams))
+	}
+	lines := 0
+	for _, datagram := range datagrams {
+		if len(datagram) > statsDMaxPacketSize {
+			t.Errorf("got a datagram of %d bytes, want at most %d", len(datagram), statsDMaxPacketSize)
+		}
+		lines += len(strings.Split(datagram, "\n"))
+	}
+	if lines != len(infos) {
+		t.Errorf("got %d lines, want %d", lines, len(infos))
+	}
+}
+
+func TestStatsDRedact(t *testing.T) {
+	for _, tags := range []bool{true, false} {
+		address, read := listenStatsD(t)
+		s, err := newStatsD(address, defaultStatsDPrefix, tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hashed := publishRedacted(t, s)
+		got := strings.Join(read(), "\n")
+		if strings.Contains(got, "tenant") || !strings.Contains(got, hashed) {
+			t.Errorf("%v: got datagrams %q, want the identifier hashed as %v", s.Name(), got, hashed)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Store keeps the instances discovered by the last snapshot so that they can
+// be served outside of the Prometheus registry.
+type Store struct {
+	mu        sync.RWMutex
+	infos     []RDSInfo
+	updatedAt time.Time
+}
+
+func (s *Store) Set(infos []RDSInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.infos = infos
+	s.updatedAt = time.Now()
+}
+
+// Get returns the last snapshot and when it was taken. The returned slice
+// must not be modified.
+func (s *Store) Get() ([]RDSInfo, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.infos, s.updatedAt
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// streamHistory is the number of recent events replayed to a client
+	// reconnecting with Last-Event-ID
+	streamHistory = 256
+	// streamBuffer is the number of events a client can lag behind before
+	// it is disconnected, to reconnect from its last event
+	streamBuffer = 64
+	// streamKeepAlive is the interval of the comments keeping idle streams
+	// open through the proxies
+	streamKeepAlive = 30 * time.Second
+)
+
+// instanceChange is the previous and the current value of an attribute of an
+// instance.
+type instanceChange struct {
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+}
+
+// changeEvent is an event of /api/v1/stream: the changed attributes of an
+// instance between two snapshots, by name, and the instance as of the last
+// one.
+type changeEvent struct {
+	id         uint64
+	Time       time.Time                 `json:"time"`
+	Region     string                    `json:"region"`
+	Changes    map[string]instanceChange `json:"changes"`
+	Instance   apiInstance               `json:"instance"`
+	SkipReason string                    `json:"skip_reason,omitempty"`
+}
+
+// instanceChanges returns the events of the instances of both snapshots whose
+// max_connections, class or status changed. A skipped instance has no
+// max_connections.
+func instanceChanges(previous, current []RDSInfo, now time.Time) []changeEvent {
+	key := func(info RDSInfo) string { return info.Region + "|" + info.DBInstanceIdentifier }
+	maxConnections := func(info RDSInfo) string {
+		if len(info.SkipReason) != 0 {
+			return ""
+		}
+		return info.MaxConnections
+	}
+
+	byKey := make(map[string]RDSInfo, len(previous))
+	for _, info := range previous {
+		byKey[key(info)] = info
+	}
+
+	var ret []changeEvent
+	for _, info := range current {
+		p, ok := byKey[key(info)]
+		if !ok {
+			continue
+		}
+		changes := map[string]instanceChange{}
+		if maxConnections(p) != maxConnections(info) {
+			changes["max_connections"] = instanceChange{Previous: maxConnections(p), Current: maxConnections(info)}
+		}
+		if p.DBInstanceClass != info.DBInstanceClass {
+			changes["db_instance_class"] = instanceChange{Previous: p.DBInstanceClass, Current: info.DBInstanceClass}
+		}
+		// the snapshots saved by the previous versions have no status
+		if len(p.Status) != 0 && p.Status != info.Status {
+			changes["status"] = instanceChange{Previous: p.Status, Current: info.Status}
+		}
+		if len(changes) != 0 {
+			ret = append(ret, changeEvent{
+				Time: now, Region: info.Region, Changes: changes, Instance: newAPIInstance(info), SkipReason: info.SkipReason,
+			})
+		}
+	}
+
+	return ret
+}
+
+// changes is the broker of the changes of the published snapshots.
+//
+//nolint:gochecknoglobals
+var changes = newChangeBroker()
+
+// changeBroker fans the change events out to the clients of /api/v1/stream,
+// keeping the recent ones to resume the streams from.
+type changeBroker struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []changeEvent
+	subscribers map[chan changeEvent]struct{}
+	closed      bool
+}
+
+func newChangeBroker() *changeBroker {
+	return &changeBroker{subscribers: map[chan changeEvent]struct{}{}}
+}
+
+// publish numbers the events and sends them to the subscribers. A subscriber
+// which lags behind is disconnected rather than blocking the snapshots.
+func (b *changeBroker) publish(events []changeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, event := range events {
+		b.lastID++
+		event.id = b.lastID
+		b.history = append(b.history, event)
+		if len(b.history) > streamHistory {
+			b.history = b.history[len(b.history)-streamHistory:]
+		}
+		for ch := range b.subscribers {
+			select {
+			case ch <- event:
+			default:
+				delete(b.subscribers, ch)
+				close(ch)
+			}
+		}
+	}
+}
+
+// subscribe returns the events after lastID still in the history, and a
+// channel of the next ones, closed when the subscriber lags behind or the
+// broker is closed. cancel must be called when done.
+func (b *changeBroker) subscribe(lastID uint64) (missed []changeEvent, events <-chan changeEvent, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, event := range b.history {
+		if event.id > lastID {
+			missed = append(missed, event)
+		}
+	}
+	ch := make(chan changeEvent, streamBuffer)
+	if b.closed {
+		close(ch)
+		return missed, ch, func() {}
+	}
+	b.subscribers[ch] = struct{}{}
+
+	return missed, ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// close ends the streams, as the shutdown of the server waits for them.
+func (b *changeBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// streamHandler serves GET /api/v1/stream with the server-sent events of the
+// changes of the instances. A client reconnecting with Last-Event-ID receives
+// the events it missed first, if they are recent enough.
+func streamHandler(broker *changeBroker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		lastID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+		missed, events, cancel := broker.subscribe(lastID)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		write := func(event changeEvent) error {
+			b, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("failed to marshal event: %w", err)
+			}
+			_, err = fmt.Fprintf(w, "id: %d\nevent: change\ndata: %s\n\n", event.id, b)
+			return err
+		}
+		for _, event := range missed {
+			if write(event) != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		ticker := time.NewTicker(streamKeepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-events:
+				if !ok || write(event) != nil {
+					return
+				}
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	})
+}
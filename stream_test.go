@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvent reads the next event of a stream, skipping the comments.
+func readEvent(t *testing.T, r *bufio.Reader) (id string, event changeEvent) {
+	t.Helper()
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event)
+			if err != nil {
+				t.Fatal(err)
+			}
+		case len(line) == 0 && len(id) != 0:
+			return id, event
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	previous := []RDSInfo{
+		{Region: "ap-northeast-1", DBInstanceIdentifier: "a01", DBInstanceClass: "db.r5.large", MaxConnections: "1800", Status: "available"},
+		{Region: "ap-northeast-1", DBInstanceIdentifier: "b01", DBInstanceClass: "db.r5.large", MaxConnections: "1800", Status: "available"},
+		// saved by a previous version
+		{Region: "ap-northeast-1", DBInstanceIdentifier: "c01", DBInstanceClass: "db.r5.large", MaxConnections: "1800"},
+	}
+	current := []RDSInfo{
+		{Region: "ap-northeast-1", DBInstanceIdentifier: "a01", DBInstanceClass: "db.r5.xlarge", MaxConnections: "3600", Status: "modifying"},
+		{Region: "ap-northeast-1", DBInstanceIdentifier: "b01", DBInstanceClass: "db.r5.large", MaxConnections: "1800", Status: "available"},
+		{Region: "ap-northeast-1", DBInstanceIdentifier: "c01", DBInstanceClass: "db.r5.large", MaxConnections: "1800", Status: "available"},
+		{Region: "ap-northeast-1", DBInstanceIdentifier: "d01", DBInstanceClass: "db.r5.large", MaxConnections: "1800", Status: "available"},
+	}
+
+	broker := newChangeBroker()
+	server := httptest.NewServer(streamHandler(broker))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("got Content-Type %v, want text/event-stream", ct)
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	broker.publish(instanceChanges(previous, current, now))
+	broker.publish(instanceChanges(current[:1], []RDSInfo{{Region: "ap-northeast-1", DBInstanceIdentifier: "a01", DBInstanceClass: "db.r5.xlarge",
+		MaxConnections: "0", Status: "available", SkipReason: "parameter group error"}}, now))
+
+	r := bufio.NewReader(resp.Body)
+	id, event := readEvent(t, r)
+	want := map[string]instanceChange{
+		"max_connections":   {Previous: "1800", Current: "3600"},
+		"db_instance_class": {Previous: "db.r5.large", Current: "db.r5.xlarge"},
+		"status":            {Previous: "available", Current: "modifying"},
+	}
+	if id != "1" || event.Instance.DBInstanceIdentifier != "a01" || len(event.Changes) != len(want) {
+		t.Fatalf("got event %v %+v, want the changes of a01", id, event)
+	}
+	for name, change := range want {
+		if event.Changes[name] != change {
+			t.Errorf("got change of %v %+v, want %+v", name, event.Changes[name], change)
+		}
+	}
+	// the skipped instance has lost its max_connections
+	id, event = readEvent(t, r)
+	if id != "2" || event.Changes["max_connections"] != (instanceChange{Previous: "3600"}) || event.SkipReason != "parameter group error" {
+		t.Errorf("got event %v %+v, want max_connections of a01 unknown", id, event)
+	}
+
+	// a client reconnecting receives the events it missed
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", "1")
+	resumed, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Body.Close()
+	if id, _ := readEvent(t, bufio.NewReader(resumed.Body)); id != "2" {
+		t.Errorf("got event %v, want 2 after Last-Event-ID 1", id)
+	}
+
+	// the streams end on shutdown
+	broker.close()
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Error("got more of the stream after close, want its end")
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// targetFiles returns the files matching the patterns of target_files, in
+// order. The hidden files are skipped, such as the ..data directory of a
+// mounted ConfigMap, whose keys are links into it.
+func targetFiles(patterns []string) ([]string, error) {
+	var ret []string
+	for i, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, &fieldError{Path: fmt.Sprintf("target_files[%d]", i), Err: fmt.Errorf("invalid pattern: %w", err)}
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			if strings.HasPrefix(filepath.Base(path), ".") {
+				continue
+			}
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				continue
+			}
+			ret = append(ret, path)
+		}
+	}
+
+	return ret, nil
+}
+
+// loadTargetFiles appends the targets listed by the files of target_files to
+// the targets of the configuration, so that the targets of the tenants can be
+// added to a ConfigMap by a GitOps change and picked up by --config.watch. A
+// file is a YAML list of the same targets as the config file.
+func (c *Config) loadTargetFiles() error {
+	paths, err := targetFiles(c.TargetFiles)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read target file: %w", err)
+		}
+
+		var targets []Target
+		decoder := yaml.NewDecoder(bytes.NewReader(b))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&targets)
+		// an empty file lists no target
+		if err != nil && !errors.Is(err, io.EOF) {
+			return &targetFileError{Path: path, Err: err}
+		}
+		for i := range targets {
+			targets[i].path = fmt.Sprintf("%v[%d]", path, i)
+		}
+		c.Targets = append(c.Targets, targets...)
+	}
+
+	return nil
+}
+
+// targetFileError is an error parsing the target file at Path, so that it can
+// be located in that file rather than in the config file.
+type targetFileError struct {
+	Path string
+	Err  error
+}
+
+func (e *targetFileError) Error() string {
+	return fmt.Sprintf("failed to parse target file %v: %v", e.Path, e.Err)
+}
+
+func (e *targetFileError) Unwrap() error {
+	return e.Err
+}
+
+// readTargetFiles returns the names and the contents of the files of
+// target_files, to tell whether they changed.
+func readTargetFiles(patterns []string) []byte {
+	paths, _ := targetFiles(patterns)
+
+	var b bytes.Buffer
+	for _, path := range paths {
+		content, _ := os.ReadFile(path)
+		fmt.Fprintf(&b, "%v\n%d\n", path, len(content))
+		b.Write(content)
+	}
+
+	return b.Bytes()
+}
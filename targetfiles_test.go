@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfigMap writes the keys like a mounted ConfigMap: links to the files
+// of a hidden directory, which is replaced on update.
+func writeConfigMap(t *testing.T, dir string, data map[string]string) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Join(dir, "..2024_01_01_00_00_00.000000000"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink("..2024_01_01_00_00_00.000000000", filepath.Join(dir, "..data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range data {
+		err := os.WriteFile(filepath.Join(dir, "..2024_01_01_00_00_00.000000000", key), []byte(value), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Symlink(filepath.Join("..data", key), filepath.Join(dir, key))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadTargetFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigMap(t, dir, map[string]string{
+		"tenant-a.yaml": "- region: ap-northeast-1\n  role_arn: arn:aws:iam::123456789012:role/rds-maxcon-exporter\n  labels:\n    tenant: a\n",
+		"tenant-b.yaml": "- region: us-east-1\n  labels:\n    tenant: b\n",
+		"empty.yaml":    "",
+	})
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(config, []byte("targets:\n  - region: eu-west-1\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(fileConfig(config), []func(*Config) error{func(c *Config) error {
+		c.TargetFiles = []string{filepath.Join(dir, "*")}
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var regions []string
+	for _, target := range cfg.Targets {
+		regions = append(regions, target.Region+"/"+target.Labels["tenant"])
+	}
+	if want := []string{"eu-west-1/", "ap-northeast-1/a", "us-east-1/b"}; !equalStrings(regions, want) {
+		t.Errorf("got targets %v, want %v", regions, want)
+	}
+
+	// the errors are located in the target files
+	err = os.WriteFile(filepath.Join(dir, "..data", "tenant-b.yaml"), []byte("- region: moon\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadConfig(fileConfig(config), []func(*Config) error{func(c *Config) error {
+		c.TargetFiles = []string{filepath.Join(dir, "*.yaml")}
+		return nil
+	}})
+	var fe *fieldError
+	if !errors.As(err, &fe) || fe.Path != filepath.Join(dir, "tenant-b.yaml")+"[0].region" {
+		t.Errorf("got error %v, want an invalid region of tenant-b.yaml", err)
+	}
+}
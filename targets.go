@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// engineFamilies are the families of the engines scraped by the same
+// exporter of their database. The other engines are their own family.
+//
+//nolint:gochecknoglobals
+var engineFamilies = map[string]string{
+	"postgres":          "postgres",
+	"aurora-postgresql": "postgres",
+	"mysql":             "mysql",
+	"mariadb":           "mysql",
+	"aurora-mysql":      "mysql",
+}
+
+func engineFamily(engine string) string {
+	if family, ok := engineFamilies[engine]; ok {
+		return family
+	}
+
+	return engine
+}
+
+// generateTargets prints the target groups of the endpoints of the instances
+// of a single discovery pass for the file service discovery of Prometheus, as
+// served by /api/v1/sd, or writes those of each engine family to
+// <family>.json in outputDir, such as postgres.json for postgres_exporter and
+// mysql.json for mysqld_exporter.
+func generateTargets(ctx context.Context, w io.Writer, cfg *Config, outputDir string) error {
+	infos, err := collect(ctx, cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	return writeTargets(w, infos, outputDir)
+}
+
+func writeTargets(w io.Writer, infos []RDSInfo, outputDir string) error {
+	groups := []sdTargetGroup{}
+	byFamily := map[string][]sdTargetGroup{}
+	for _, info := range infos {
+		if len(info.Endpoint) == 0 {
+			continue
+		}
+		group := newSDTargetGroup(info)
+		groups = append(groups, group)
+		byFamily[engineFamily(info.DBEngine)] = append(byFamily[engineFamily(info.DBEngine)], group)
+	}
+
+	if len(outputDir) == 0 {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(groups)
+		if err != nil {
+			return fmt.Errorf("failed to encode targets: %w", err)
+		}
+		return nil
+	}
+
+	families := make([]string, 0, len(byFamily))
+	for family := range byFamily {
+		families = append(families, family)
+	}
+	sort.Strings(families)
+	for _, family := range families {
+		path := filepath.Join(outputDir, family+".json")
+		err := writeTargetsFile(path, byFamily[family])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%v: %d targets\n", path, len(byFamily[family]))
+	}
+
+	return nil
+}
+
+// writeTargetsFile writes the target groups to a temporary file renamed over
+// path, so that Prometheus never reads a partial file.
+func writeTargetsFile(path string, groups []sdTargetGroup) error {
+	b, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode targets: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create targets file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(append(b, '\n'))
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write targets file: %w", err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write targets file: %w", err)
+	}
+	// CreateTemp creates the file readable by its owner only
+	err = os.Chmod(tmp.Name(), 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write targets file: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("failed to rename targets file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteTargets(t *testing.T) {
+	infos := []RDSInfo{
+		{DBInstanceIdentifier: "postgres-a01", DBEngine: "postgres", Endpoint: "postgres-a01.example.com", Port: 5432},
+		{DBInstanceIdentifier: "aurora-a01", DBEngine: "aurora-postgresql", Endpoint: "aurora-a01.example.com", Port: 5432},
+		{DBInstanceIdentifier: "mysql-a01", DBEngine: "mysql", Endpoint: "mysql-a01.example.com", Port: 3306, SkipReason: "unsupported engine: mysql"},
+		{DBInstanceIdentifier: "creating", DBEngine: "postgres"},
+	}
+
+	var b bytes.Buffer
+	err := writeTargets(&b, infos, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var groups []sdTargetGroup
+	err = json.Unmarshal(b.Bytes(), &groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 3 {
+		t.Errorf("got %v target groups, want 3 without the creating instance", len(groups))
+	}
+
+	dir := t.TempDir()
+	err = writeTargets(&b, infos, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for family, want := range map[string][]string{
+		"postgres": {"postgres-a01.example.com:5432", "aurora-a01.example.com:5432"},
+		"mysql":    {"mysql-a01.example.com:3306"},
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, family+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var groups []sdTargetGroup
+		err = json.Unmarshal(content, &groups)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, group := range groups {
+			got = append(got, group.Targets...)
+			if group.Labels["__meta_rds_engine_family"] != family {
+				t.Errorf("got engine family %v in %v.json", group.Labels["__meta_rds_engine_family"], family)
+			}
+		}
+		if !equalStrings(got, want) {
+			t.Errorf("got targets %v in %v.json, want %v", got, family, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// adaptiveDelay delays the requests to the AWS APIs of a target after they
+// are throttled, doubling the delay on each throttled request up to max and
+// halving it on each success, so that the exporter backs off on shared API
+// quotas beyond the retries of a single request.
+type adaptiveDelay struct {
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+//nolint:gochecknoglobals
+var (
+	adaptiveDelaysMu sync.Mutex
+	// adaptiveDelays are kept across snapshots by region and role
+	adaptiveDelays = map[string]*adaptiveDelay{}
+)
+
+func getAdaptiveDelay(target Target) *adaptiveDelay {
+	adaptiveDelaysMu.Lock()
+	defer adaptiveDelaysMu.Unlock()
+
+	key := target.Region + "|" + target.RoleARN
+	d, ok := adaptiveDelays[key]
+	if !ok {
+		d = &adaptiveDelay{}
+		adaptiveDelays[key] = d
+	}
+
+	return d
+}
+
+func (d *adaptiveDelay) wait(r *request.Request) {
+	d.mu.Lock()
+	delay := d.delay
+	d.mu.Unlock()
+
+	if delay == 0 {
+		return
+	}
+
+	select {
+	case <-r.Context().Done():
+	case <-clk.After(delay):
+	}
+}
+
+// update adapts the delay to the result of a request. The Retry-After
+// duration of a throttled response is a minimum of the delay.
+func (d *adaptiveDelay) update(cfg ThrottlingConfig, throttled bool, retryAfter time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch {
+	case throttled && d.delay == 0:
+		d.delay = max(cfg.MinDelay, retryAfter)
+	case throttled:
+		d.delay = max(2*d.delay, retryAfter)
+	default:
+		d.delay /= 2
+		if d.delay < cfg.MinDelay {
+			d.delay = 0
+		}
+	}
+	d.delay = min(d.delay, cfg.MaxDelay)
+}
+
+// handleThrottling counts the throttled requests of a session, and delays
+// its requests adaptively when enabled.
+func handleThrottling(sess *session.Session, cfg ThrottlingConfig, target Target) {
+	d := getAdaptiveDelay(target)
+
+	if cfg.Adaptive {
+		sess.Handlers.Send.PushFront(d.wait)
+	}
+
+	sess.Handlers.CompleteAttempt.PushBack(func(r *request.Request) {
+		throttled := request.IsErrorThrottle(r.Error)
+		if throttled {
+			throttledRequests.WithLabelValues(r.ClientInfo.ServiceName, r.Operation.Name).Inc()
+		}
+		if cfg.Adaptive && (throttled || r.Error == nil) {
+			d.update(cfg, throttled, retryAfter(r))
+		}
+	})
+}
+
+// retryAfter returns the Retry-After duration of a response in seconds, 0
+// when there is none.
+func retryAfter(r *request.Request) time.Duration {
+	if r.HTTPResponse == nil {
+		return 0
+	}
+
+	seconds, err := strconv.Atoi(r.HTTPResponse.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
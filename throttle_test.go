@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const (
+	rdsThrottlingResponse = `<ErrorResponse><Error><Type>Sender</Type><Code>Throttling</Code><Message>Rate exceeded</Message></Error><RequestId>1</RequestId></ErrorResponse>`
+	rdsInstancesResponse  = `<DescribeDBInstancesResponse><DescribeDBInstancesResult><DBInstances></DBInstances></DescribeDBInstancesResult></DescribeDBInstancesResponse>`
+)
+
+func TestHandleThrottling(t *testing.T) {
+	// the first two attempts are throttled, the second one with Retry-After
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(rdsThrottlingResponse))
+		case 2:
+			w.Header().Set("Retry-After", "5")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(rdsThrottlingResponse))
+		default:
+			_, _ = w.Write([]byte(rdsInstancesResponse))
+		}
+	}))
+	defer server.Close()
+
+	c := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	realClk := clk
+	clk = c
+	target := Target{Region: "ap-northeast-1", RoleARN: "arn:aws:iam::123456789012:role/throttled"}
+	t.Cleanup(func() {
+		clk = realClk
+		adaptiveDelaysMu.Lock()
+		delete(adaptiveDelays, target.Region+"|"+target.RoleARN)
+		adaptiveDelaysMu.Unlock()
+	})
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String(target.Region),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		// the retries of the SDK are not delayed, only the adaptive delay is
+		Retryer: client.DefaultRetryer{NumMaxRetries: 3, MinRetryDelay: time.Millisecond, MaxRetryDelay: time.Millisecond, MinThrottleDelay: time.Millisecond, MaxThrottleDelay: time.Millisecond},
+	}))
+	handleThrottling(sess, ThrottlingConfig{Adaptive: true, MinDelay: time.Second, MaxDelay: time.Minute}, target)
+	svc := rds.New(sess)
+	throttled := func() float64 {
+		return testutil.ToFloat64(throttledRequests.WithLabelValues("rds", "DescribeDBInstances"))
+	}
+	before := throttled()
+
+	describe := func() <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := svc.DescribeDBInstances(&rds.DescribeDBInstancesInput{})
+			done <- err
+		}()
+		return done
+	}
+	expectRequests := func(n int32) {
+		t.Helper()
+		if got := requests.Load(); got != n {
+			t.Fatalf("got %d requests, want %d", got, n)
+		}
+	}
+
+	done := describe()
+	// the retry of the throttled request waits for the min delay
+	c.BlockUntil(1)
+	expectRequests(1)
+	c.Advance(time.Second)
+	// then for the Retry-After of the response, more than twice the delay
+	c.BlockUntil(1)
+	expectRequests(2)
+	c.Advance(4 * time.Second)
+	expectRequests(2)
+	c.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatalf("got error %v, want the retried request to succeed", err)
+	}
+	expectRequests(3)
+	if got := throttled() - before; got != 2 {
+		t.Errorf("got %v throttled requests, want 2", got)
+	}
+
+	// the delay is halved on success, until it is under the min delay
+	for i, delay := range []time.Duration{2500 * time.Millisecond, 1250 * time.Millisecond} {
+		done := describe()
+		c.BlockUntil(1)
+		expectRequests(int32(3 + i))
+		c.Advance(delay)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := <-describe(); err != nil {
+		t.Fatal(err)
+	}
+	expectRequests(6)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// healthPaths do not require a client certificate, so that the probes of the
+// orchestrator keep working with mTLS.
+//
+//nolint:gochecknoglobals
+var healthPaths = map[string]bool{"/-/healthy": true, "/-/ready": true}
+
+// serverTLSConfig returns the TLS configuration of the web server, nil to
+// serve plain HTTP. The client certificates are verified when given, and
+// required by requireClientCert.
+func serverTLSConfig(cfg WebTLSConfig) (*tls.Config, error) {
+	if len(cfg.CertFile) == 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if len(cfg.ClientCAFile) != 0 {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in client CA %v", cfg.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return config, nil
+}
+
+// requireClientCert rejects the requests without a verified client
+// certificate, or whose certificate is not of an allowed SPIFFE ID, but the
+// ones of the health endpoints.
+func requireClientCert(cfg WebTLSConfig, next http.Handler) http.Handler {
+	if len(cfg.ClientCAFile) == 0 {
+		return next
+	}
+
+	allowed := make(map[string]bool, len(cfg.ClientSPIFFEIDs))
+	for _, id := range cfg.ClientSPIFFEIDs {
+		allowed[id] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if healthPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			http.Error(w, "client certificate required", http.StatusUnauthorized)
+			return
+		}
+		if len(allowed) != 0 && !hasSPIFFEID(r.TLS.VerifiedChains[0][0], allowed) {
+			http.Error(w, "client certificate not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hasSPIFFEID reports whether the URI SAN of a certificate is one of the
+// allowed SPIFFE IDs.
+func hasSPIFFEID(cert *x509.Certificate, allowed map[string]bool) bool {
+	for _, uri := range cert.URIs {
+		if allowed[uri.String()] {
+			return true
+		}
+	}
+
+	return false
+}
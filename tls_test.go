@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA signs the certificates of the TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCA{cert: cert, key: key}
+}
+
+// issue returns a certificate for the server when uri is empty, or for a
+// client of the SPIFFE ID uri.
+func (ca *testCA) issue(t *testing.T, uri string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if len(uri) != 0 {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		template.IPAddresses = nil
+		template.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequireClientCert(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	serverCert := ca.issue(t, "")
+	key, err := x509.MarshalECPrivateKey(serverCert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := WebTLSConfig{
+		CertFile:        filepath.Join(dir, "server.crt"),
+		KeyFile:         filepath.Join(dir, "server.key"),
+		ClientCAFile:    filepath.Join(dir, "ca.crt"),
+		ClientSPIFFEIDs: []string{"spiffe://example.org/prometheus"},
+	}
+	writePEM(t, cfg.CertFile, "CERTIFICATE", serverCert.Certificate[0])
+	writePEM(t, cfg.KeyFile, "EC PRIVATE KEY", key)
+	writePEM(t, cfg.ClientCAFile, "CERTIFICATE", ca.cert.Raw)
+
+	tlsConfig, err := serverTLSConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(requireClientCert(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	server.TLS = tlsConfig
+	// the handshake errors are expected
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	otherCA := newTestCA(t)
+
+	tests := []struct {
+		name string
+		path string
+		cert *tls.Certificate
+		want int
+	}{
+		{name: "allowed", path: "/metrics", cert: ptr(ca.issue(t, "spiffe://example.org/prometheus")), want: http.StatusOK},
+		{name: "no certificate", path: "/metrics", want: http.StatusUnauthorized},
+		{name: "other SPIFFE ID", path: "/metrics", cert: ptr(ca.issue(t, "spiffe://example.org/grafana")), want: http.StatusForbidden},
+		{name: "health without certificate", path: "/-/ready", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConfig := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+			if tt.cert != nil {
+				clientConfig.Certificates = []tls.Certificate{*tt.cert}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+
+			resp, err := client.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	// a certificate of another CA fails the handshake
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{otherCA.issue(t, "spiffe://example.org/prometheus")},
+		MinVersion:   tls.VersionTLS12,
+	}}}
+	resp, err := client.Get(server.URL + "/metrics")
+	if err == nil {
+		resp.Body.Close()
+		t.Errorf("got status %d, want a handshake error", resp.StatusCode)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
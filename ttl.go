@@ -0,0 +1,40 @@
+package main
+
+import "log/slog"
+
+// expireInstances returns the instances of a target after a snapshot, which
+// are the current ones and the previous ones not seen by it for less than
+// series_ttl snapshots, current being nil when the snapshot failed. The
+// other instances expire, so that an instance does not flap when a filter
+// does, nor linger while its target fails. Without series_ttl, the current
+// instances replace the previous ones after a success, and the previous ones
+// are kept after a failure.
+func expireInstances(cfg *Config, previous, current []RDSInfo, ok bool) []RDSInfo {
+	if cfg.SeriesTTL == 0 {
+		if ok {
+			return current
+		}
+		return previous
+	}
+
+	seen := make(map[string]bool, len(current))
+	for _, info := range current {
+		seen[info.Region+"|"+info.DBInstanceIdentifier] = true
+	}
+
+	infos := current
+	for _, info := range previous {
+		if seen[info.Region+"|"+info.DBInstanceIdentifier] {
+			continue
+		}
+		info.Missed++
+		if info.Missed >= cfg.SeriesTTL {
+			seriesExpired.Inc()
+			slog.Info("series expired: instance not seen", "dbinstanceidentifier", info.DBInstanceIdentifier, "region", info.Region, "snapshots", info.Missed)
+			continue
+		}
+		infos = append(infos, info)
+	}
+
+	return infos
+}
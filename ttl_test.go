@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestExpireInstances(t *testing.T) {
+	cfg := testConfig(t, func(cfg *Config) { cfg.SeriesTTL = 2 })
+	before := testutil.ToFloat64(seriesExpired)
+
+	a := RDSInfo{DBInstanceIdentifier: "a", Region: "us-east-1", MaxConnections: "100"}
+	b := RDSInfo{DBInstanceIdentifier: "b", Region: "us-east-1", MaxConnections: "200"}
+	steps := []struct {
+		current []RDSInfo
+		ok      bool
+		want    map[string]int
+	}{
+		{current: []RDSInfo{a, b}, ok: true, want: map[string]int{"a": 0, "b": 0}},
+		// b is filtered out once, then seen again
+		{current: []RDSInfo{a}, ok: true, want: map[string]int{"a": 0, "b": 1}},
+		{current: []RDSInfo{a, b}, ok: true, want: map[string]int{"a": 0, "b": 0}},
+		{current: []RDSInfo{a}, ok: true, want: map[string]int{"a": 0, "b": 1}},
+		{current: []RDSInfo{a}, ok: true, want: map[string]int{"a": 0}},
+		// the instances of a failing target expire too
+		{ok: false, want: map[string]int{"a": 1}},
+		{ok: false, want: map[string]int{}},
+	}
+
+	var infos []RDSInfo
+	for i, step := range steps {
+		infos = expireInstances(cfg, infos, step.current, step.ok)
+		got := map[string]int{}
+		for _, info := range infos {
+			got[info.DBInstanceIdentifier] = info.Missed
+		}
+		if len(got) != len(step.want) {
+			t.Fatalf("step %d: got %v, want %v", i, got, step.want)
+		}
+		for id, missed := range step.want {
+			if m, ok := got[id]; !ok || m != missed {
+				t.Fatalf("step %d: got %v, want %v", i, got, step.want)
+			}
+		}
+	}
+	if got := testutil.ToFloat64(seriesExpired) - before; got != 2 {
+		t.Errorf("got %v expired series, want 2", got)
+	}
+
+	// without series_ttl the last successful snapshot is kept
+	cfg.SeriesTTL = 0
+	infos = expireInstances(cfg, []RDSInfo{a, b}, []RDSInfo{a}, true)
+	if len(infos) != 1 {
+		t.Errorf("got %v, want a", infos)
+	}
+	infos = expireInstances(cfg, infos, nil, false)
+	if len(infos) != 1 {
+		t.Errorf("got %v, want a", infos)
+	}
+}
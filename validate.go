@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//nolint:gochecknoglobals
+var (
+	yamlLineRep  = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	pathIndexRep = regexp.MustCompile(`^(.*)\[(\d+)\]$`)
+)
+
+// validateFile checks a configuration file as the exporter would load it,
+// without the command-line flags, and prints every problem as
+// "file:line: message" so that CI can gate config changes. It reports whether
+// the file is valid.
+func validateFile(w io.Writer, path string) bool {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(w, "%v: %v\n", path, err)
+		return false
+	}
+
+	var root yaml.Node
+	err = yaml.Unmarshal(b, &root)
+	if err != nil {
+		printYAMLError(w, path, err.Error())
+		return false
+	}
+
+	cfg := defaultConfig()
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	err = decoder.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		printDecodeError(w, path, err)
+		return false
+	}
+
+	err = cfg.loadTargetFiles()
+	if err != nil {
+		var fileErr *targetFileError
+		if errors.As(err, &fileErr) {
+			printDecodeError(w, fileErr.Path, fileErr.Err)
+		} else {
+			fmt.Fprintf(w, "%v: %v\n", path, err)
+		}
+		return false
+	}
+
+	err = cfg.validate()
+	if err == nil {
+		fmt.Fprintf(w, "%v: ok\n", path)
+		return true
+	}
+
+	// the targets of the target files are located in their own file
+	roots := map[string]*yaml.Node{path: &root}
+	targetPaths, _ := targetFiles(cfg.TargetFiles)
+	for _, targetPath := range targetPaths {
+		var node yaml.Node
+		b, _ := os.ReadFile(targetPath)
+		if yaml.Unmarshal(b, &node) == nil {
+			roots[targetPath] = &node
+		}
+	}
+
+	for _, err := range unwrapJoined(err) {
+		var fieldErr *fieldError
+		if !errors.As(err, &fieldErr) {
+			fmt.Fprintf(w, "%v: %v\n", path, err)
+			continue
+		}
+		file, fieldPath := path, fieldErr.Path
+		for _, targetPath := range targetPaths {
+			if strings.HasPrefix(fieldErr.Path, targetPath+"[") {
+				file, fieldPath = targetPath, strings.TrimPrefix(fieldErr.Path, targetPath)
+				break
+			}
+		}
+		if node := lookupNode(roots[file], fieldPath); node != nil {
+			fmt.Fprintf(w, "%v:%d: %v\n", file, node.Line, fieldErr)
+		} else {
+			fmt.Fprintf(w, "%v: %v\n", file, fieldErr)
+		}
+	}
+
+	return false
+}
+
+// printDecodeError prints an error decoding the YAML file at path, each error
+// of a type error on its own line.
+func printDecodeError(w io.Writer, path string, err error) {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		printYAMLError(w, path, err.Error())
+		return
+	}
+
+	for _, msg := range typeErr.Errors {
+		printYAMLError(w, path, msg)
+	}
+}
+
+// printYAMLError moves the line number of a YAML error next to the file name.
+func printYAMLError(w io.Writer, path, msg string) {
+	if m := yamlLineRep.FindStringSubmatch(msg); m != nil {
+		fmt.Fprintf(w, "%v:%v: %v\n", path, m[1], m[2])
+		return
+	}
+
+	fmt.Fprintf(w, "%v: %v\n", path, strings.TrimPrefix(msg, "yaml: "))
+}
+
+func unwrapJoined(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok { //nolint:errorlint
+		return joined.Unwrap()
+	}
+
+	return []error{err}
+}
+
+// lookupNode returns the node of a path such as "targets[1].role_arn", or the
+// deepest node found on the way when the field is not in the file, such as a
+// default value. A path starting with an index, such as "[0].region", is in
+// a list at the root, such as a target file.
+func lookupNode(root *yaml.Node, path string) *yaml.Node {
+	node := root
+	if node == nil {
+		return nil
+	}
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+
+	found := (*yaml.Node)(nil)
+	segments := strings.Split(path, ".")
+	for i := 0; i < len(segments); i++ {
+		key := segments[i]
+		index := -1
+		if m := pathIndexRep.FindStringSubmatch(key); m != nil {
+			key = m[1]
+			index, _ = strconv.Atoi(m[2])
+		}
+
+		next := node
+		if len(key) != 0 {
+			next = mappingValue(node, key)
+		}
+		// keys such as tag names may contain dots
+		for j := i + 1; next == nil && j < len(segments); j++ {
+			key = strings.Join(segments[i:j+1], ".")
+			if next = mappingValue(node, key); next != nil {
+				i = j
+			}
+		}
+		if next == nil {
+			return found
+		}
+		node, found = next, next
+
+		if index >= 0 {
+			if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+				return found
+			}
+			node = node.Content[index]
+			found = node
+		}
+	}
+
+	return found
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
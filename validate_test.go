@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateFile(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "unknown key",
+			content: "interval: 1m\ntargets:\n  - region: ap-northeast-1\n    regoin: us-east-1\n",
+			want:    []string{":4: field regoin not found in type main.Target"},
+		},
+		{
+			name:    "type error",
+			content: "interval: 1m\nconcurrency: many\ntargets:\n  - region: ap-northeast-1\n    interval: soon\n",
+			want:    []string{":2: cannot unmarshal !!str `many` into int", ":5: "},
+		},
+		{
+			name:    "nested target",
+			content: "targets:\n  - region: ap-northeast-1\n  - region: moon\n    labels:\n      bad-name: x\n",
+			want:    []string{":3: targets[1].region: ", ":5: targets[1].labels.bad-name: invalid label name"},
+		},
+		{
+			name:    "dotted key",
+			content: "tag_labels:\n  app.kubernetes.io/name: bad-name\n",
+			want:    []string{":2: tag_labels.app.kubernetes.io/name: invalid label name"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			err := os.WriteFile(path, []byte(tc.content), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var b bytes.Buffer
+			if validateFile(&b, path) {
+				t.Fatalf("got %v valid, want errors", path)
+			}
+			lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+			if len(lines) != len(tc.want) {
+				t.Fatalf("got %q, want %d errors", lines, len(tc.want))
+			}
+			for i, want := range tc.want {
+				if !strings.HasPrefix(lines[i], path+want) {
+					t.Errorf("got %q, want %q", lines[i], path+want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateTargetFiles(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.yaml")
+	tenant := filepath.Join(dir, "tenant.yaml")
+	err := os.WriteFile(config, []byte("target_files:\n  - "+filepath.Join(dir, "tenant*.yaml")+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"valid", "- region: ap-northeast-1\n", config + ": ok\n"},
+		{"syntax error", "- region: [ap-northeast-1\n", tenant + ":1: "},
+		{"unknown key", "- region: ap-northeast-1\n  regoin: us-east-1\n", tenant + ":2: field regoin not found"},
+		{"invalid target", "- region: ap-northeast-1\n- region: moon\n", tenant + ":2: " + tenant + "[1].region: "},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := os.WriteFile(tenant, []byte(tc.content), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var b bytes.Buffer
+			ok := validateFile(&b, config)
+			if ok != (tc.name == "valid") || !strings.HasPrefix(b.String(), tc.want) {
+				t.Errorf("got %v (valid %v), want %q", b.String(), ok, tc.want)
+			}
+		})
+	}
+}
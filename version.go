@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// These are set with -ldflags "-X main.version=... -X main.commit=... -X main.date=...",
+// which is also what GoReleaser does by default.
+//
+//nolint:gochecknoglobals
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+func versionString() string {
+	return fmt.Sprintf("aws-rds-maxcon-prometheus-exporter version %v (commit: %v, built at: %v, %v)", version, commit, date, runtime.Version())
+}
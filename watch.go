@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay waits for the writes of an editor or of a ConfigMap update to
+// settle before reloading.
+const watchDelay = time.Second
+
+// reloadOnChange reloads the configuration whenever the config file or a
+// target file changes, so that a filter or a label mapping can be changed,
+// and a target added, without a deployment. The directories are watched
+// rather than the files, because editors and ConfigMap updates replace the
+// files instead of writing to them, and so that new target files are picked
+// up. The directories of the target files are the ones of the configuration
+// at startup. It stops watching when ctx is done.
+func (s *state) reloadOnChange(ctx context.Context, f *flags) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+
+	cfg, _ := s.get()
+	dirs := map[string]bool{}
+	if isConfigFile(f.source) {
+		dirs[filepath.Dir(f.configFile)] = true
+	}
+	for _, pattern := range cfg.TargetFiles {
+		dirs[filepath.Dir(pattern)] = true
+	}
+	for dir := range dirs {
+		err = watcher.Add(dir)
+		if err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %v: %w", dir, err)
+		}
+	}
+
+	read := func() []byte {
+		var b []byte
+		if isConfigFile(f.source) {
+			b, _ = os.ReadFile(f.configFile)
+		}
+		return append(b, readTargetFiles(cfg.TargetFiles)...)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		last := read()
+		var timer <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				timer = time.After(watchDelay)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("failed to watch config file", "err", err)
+			case <-timer:
+				timer = nil
+
+				b := read()
+				if bytes.Equal(b, last) {
+					continue
+				}
+				last = b
+
+				err = s.reload(f)
+				if err != nil {
+					slog.Error("failed to reload changed config", "err", err)
+					continue
+				}
+				slog.Info("reloaded changed config")
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// watchdogCheckInterval is how often the watchdog checks the collection.
+const watchdogCheckInterval = 10 * time.Second
+
+// watchdog detects a wedged collection loop, such as one hanging on a stuck
+// TCP connection, which has no other visible symptom since the last values
+// keep being served. Each target has its own loop, so that a hung target is
+// detected while the others still go around.
+type watchdog struct {
+	mu sync.Mutex
+	// last is when the loop of each target, by key, last went around, or
+	// when the target was first checked
+	last   map[string]time.Time
+	wedged atomic.Bool
+}
+
+//nolint:gochecknoglobals
+var dog = newWatchdog()
+
+func newWatchdog() *watchdog {
+	return &watchdog{last: map[string]time.Time{}}
+}
+
+// beat records that the collection loop of a target went around, whether it
+// collected, failed or skipped the collection.
+func (w *watchdog) beat(target Target) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.last[target.key()] = time.Now()
+}
+
+// Wedged reports whether the loop of a target did not go around in time.
+func (w *watchdog) Wedged() bool {
+	return w.wedged.Load()
+}
+
+// run checks the collection until ctx is done. It is wedged when the loop of
+// a target did not go around before its deadline, in which case the readiness
+// fails, and the process exits if configured so that the orchestrator
+// restarts it.
+func (w *watchdog) run(ctx context.Context, st *state) {
+	ticker := time.NewTicker(watchdogCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cfg, _ := st.get()
+		if cfg.Watchdog.Intervals == 0 {
+			w.wedged.Store(false)
+			continue
+		}
+
+		wedged := w.check(cfg, time.Now())
+		if len(wedged) == 0 {
+			if w.wedged.Swap(false) {
+				slog.Info("collection loop recovered")
+			}
+			continue
+		}
+
+		if !w.wedged.Swap(true) {
+			slog.Error("collection loop is wedged: no snapshot completed in time", "targets", wedged)
+		}
+		if cfg.Watchdog.Exit {
+			slog.Error("exiting so that the orchestrator restarts the exporter")
+			os.Exit(1)
+		}
+	}
+}
+
+// check returns the names of the targets of cfg whose loop did not go around
+// before their deadline at now. A target not seen yet, such as one added by a
+// reload, starts at now, and the removed targets are forgotten.
+func (w *watchdog) check(cfg *Config, now time.Time) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var wedged []string
+	keys := make(map[string]bool, len(cfg.Targets))
+	for _, target := range cfg.Targets {
+		key := target.key()
+		keys[key] = true
+		last, ok := w.last[key]
+		if !ok {
+			w.last[key] = now
+			continue
+		}
+		if !now.Before(w.deadline(cfg, target, last)) {
+			wedged = append(wedged, targetName(target))
+		}
+	}
+	for key := range w.last {
+		if !keys[key] {
+			delete(w.last, key)
+		}
+	}
+
+	return wedged
+}
+
+// deadline is when the loop of a target is wedged, after the configured
+// number of its ticks since last and its snapshot timeout.
+func (w *watchdog) deadline(cfg *Config, target Target, last time.Time) time.Time {
+	d := last
+	for i := 0; i < cfg.Watchdog.Intervals; i++ {
+		if cfg.schedule != nil {
+			d = cfg.schedule.Next(d)
+		} else {
+			d = d.Add(cfg.TargetInterval(target))
+		}
+	}
+
+	return d.Add(cfg.TargetSnapshotTimeout(target))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchdogCheck(t *testing.T) {
+	cfg := testConfig(t, func(cfg *Config) {
+		cfg.Interval = time.Minute
+		cfg.Watchdog.Intervals = 3
+		cfg.Targets = []Target{{Region: "ap-northeast-1"}, {Region: "us-east-1", Interval: 10 * time.Minute}}
+	})
+	w := newWatchdog()
+	start := time.Now()
+
+	// the targets start when they are first checked
+	if wedged := w.check(cfg, start); len(wedged) != 0 {
+		t.Errorf("got wedged targets %v, want none", wedged)
+	}
+
+	// a hung target is wedged while the other still goes around
+	w.last[cfg.Targets[1].key()] = start.Add(4 * time.Minute)
+	if wedged := w.check(cfg, start.Add(4*time.Minute)); !equalStrings(wedged, []string{targetName(cfg.Targets[0])}) {
+		t.Errorf("got wedged targets %v, want %v", wedged, targetName(cfg.Targets[0]))
+	}
+
+	// each target has the deadline of its interval
+	w.last[cfg.Targets[0].key()] = start.Add(38 * time.Minute)
+	w.last[cfg.Targets[1].key()] = start
+	if wedged := w.check(cfg, start.Add(39*time.Minute)); !equalStrings(wedged, nil) {
+		t.Errorf("got wedged targets %v, want none", wedged)
+	}
+	if wedged := w.check(cfg, start.Add(40*time.Minute)); !equalStrings(wedged, []string{targetName(cfg.Targets[1])}) {
+		t.Errorf("got wedged targets %v, want %v", wedged, targetName(cfg.Targets[1]))
+	}
+
+	// the removed targets are forgotten
+	cfg.Targets = cfg.Targets[:1]
+	w.beat(cfg.Targets[0])
+	w.check(cfg, time.Now())
+	if len(w.last) != 1 {
+		t.Errorf("got heartbeats %v, want the one of the configured target", w.last)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Webhook posts the instances that were added, changed or removed since the
+// previous snapshot as JSON. When a secret is configured, the body is signed
+// with HMAC-SHA256 and the signature is sent in the X-Signature-256 header as
+// "sha256=<hex>".
+type Webhook struct {
+	client   *http.Client
+	url      string
+	secret   []byte
+	previous map[string]apiInstance
+}
+
+type webhookPayload struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Added     []apiInstance `json:"added"`
+	Changed   []apiInstance `json:"changed"`
+	Removed   []apiInstance `json:"removed"`
+}
+
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{
+		client: &http.Client{Timeout: 30 * time.Second},
+		url:    url,
+		secret: []byte(secret),
+	}
+}
+
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+func (w *Webhook) Stateful() bool {
+	return true
+}
+
+func (w *Webhook) Close() error {
+	return nil
+}
+
+func (w *Webhook) Write(infos []RDSInfo) error {
+	payload, current := diffInstances(w.previous, infos)
+	if len(payload.Added)+len(payload.Changed)+len(payload.Removed) == 0 {
+		w.previous = current
+		return nil
+	}
+
+	err := w.post(payload)
+	if err != nil {
+		return err
+	}
+
+	// only remember the snapshot once delivered so that the changes are sent again on the next try
+	w.previous = current
+
+	return nil
+}
+
+// diffInstances compares the snapshot with the previous one, keyed by region
+// and identifier, and returns the differences and the new state to compare with.
+func diffInstances(previous map[string]apiInstance, infos []RDSInfo) (webhookPayload, map[string]apiInstance) {
+	current := make(map[string]apiInstance, len(infos))
+	payload := webhookPayload{
+		Timestamp: time.Now(),
+		Added:     []apiInstance{},
+		Changed:   []apiInstance{},
+		Removed:   []apiInstance{},
+	}
+
+	for _, info := range infos {
+		i := newAPIInstance(info)
+		// the current connections change every snapshot and are not a change of the instance
+		i.DatabaseConnections = nil
+		current[i.key()] = i
+
+		prev, ok := previous[i.key()]
+		switch {
+		case !ok:
+			payload.Added = append(payload.Added, i)
+		case prev != i:
+			payload.Changed = append(payload.Changed, i)
+		}
+	}
+	for key, prev := range previous {
+		if _, ok := current[key]; !ok {
+			payload.Removed = append(payload.Removed, prev)
+		}
+	}
+
+	return payload, current
+}
+
+func (w *Webhook) post(payload webhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.secret) != 0 {
+		mac := hmac.New(sha256.New, w.secret)
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %v: %s", resp.Status, b)
+	}
+
+	return nil
+}
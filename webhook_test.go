@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// webhookIdentifiers returns the identifiers of the instances of a payload by
+// change, such as "added=a01", in order.
+func webhookIdentifiers(payload webhookPayload) []string {
+	var ret []string
+	for change, instances := range map[string][]apiInstance{"added": payload.Added, "changed": payload.Changed, "removed": payload.Removed} {
+		for _, i := range instances {
+			ret = append(ret, change+"="+i.DBInstanceIdentifier)
+		}
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+func TestWebhook(t *testing.T) {
+	var payloads []webhookPayload
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(b)
+		if got, want := r.Header.Get("X-Signature-256"), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+			t.Errorf("got signature %q, want %q", got, want)
+		}
+
+		var payload webhookPayload
+		err := json.Unmarshal(b, &payload)
+		if err != nil {
+			t.Error(err)
+		}
+		payloads = append(payloads, payload)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	w := NewWebhook(server.URL, "secret")
+	write := func(infos ...RDSInfo) {
+		t.Helper()
+		payloads = nil
+		err := w.Write(infos)
+		if status == http.StatusOK && err != nil {
+			t.Fatal(err)
+		}
+		if status != http.StatusOK && err == nil {
+			t.Fatal("got no error, want the error status")
+		}
+	}
+	a01 := RDSInfo{DBInstanceIdentifier: "a01", DBInstanceClass: "db.r5.large", MaxConnections: "1800", DatabaseConnections: aws.Float64(10)}
+	b01 := RDSInfo{DBInstanceIdentifier: "b01", DBInstanceClass: "db.t3.micro", MaxConnections: "66"}
+
+	write(a01, b01)
+	if len(payloads) != 1 || !equalStrings(webhookIdentifiers(payloads[0]), []string{"added=a01", "added=b01"}) {
+		t.Errorf("got payloads %+v, want a01 and b01 added", payloads)
+	}
+
+	// the connections are not a change
+	a01.DatabaseConnections = aws.Float64(20)
+	write(a01, b01)
+	if len(payloads) != 0 {
+		t.Errorf("got payloads %+v, want none", payloads)
+	}
+
+	// a failed delivery is sent again
+	a01.DBInstanceClass = "db.r5.xlarge"
+	a01.MaxConnections = "3600"
+	status = http.StatusInternalServerError
+	write(a01)
+	status = http.StatusOK
+	write(a01)
+	if len(payloads) != 1 || !equalStrings(webhookIdentifiers(payloads[0]), []string{"changed=a01", "removed=b01"}) {
+		t.Errorf("got payloads %+v, want a01 changed and b01 removed", payloads)
+	}
+	if got := payloads[0].Changed[0]; got.MaxConnections != 3600 || got.DatabaseConnections != nil {
+		t.Errorf("got changed instance %+v, want 3600 max connections without the connections", got)
+	}
+}
+
+func TestDiffInstancesRegions(t *testing.T) {
+	tokyo := RDSInfo{Region: "ap-northeast-1", DBInstanceIdentifier: "a01", MaxConnections: "1800"}
+	virginia := RDSInfo{Region: "us-east-1", DBInstanceIdentifier: "a01", MaxConnections: "1800"}
+	_, previous := diffInstances(nil, []RDSInfo{tokyo})
+
+	// the same identifier in another region is another instance
+	payload, current := diffInstances(previous, []RDSInfo{tokyo, virginia})
+	if len(payload.Added) != 1 || payload.Added[0].Region != "us-east-1" || len(payload.Changed) != 0 {
+		t.Errorf("got payload %+v, want a01 of us-east-1 added", payload)
+	}
+
+	payload, _ = diffInstances(current, []RDSInfo{virginia})
+	if len(payload.Removed) != 1 || payload.Removed[0].Region != "ap-northeast-1" || len(payload.Added)+len(payload.Changed) != 0 {
+		t.Errorf("got payload %+v, want a01 of ap-northeast-1 removed", payload)
+	}
+}
+
+func TestWebhookUnsigned(t *testing.T) {
+	var signature []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Values("X-Signature-256")
+	}))
+	defer server.Close()
+
+	err := NewWebhook(server.URL, "").Write([]RDSInfo{{DBInstanceIdentifier: "a01", MaxConnections: "1800"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signature) != 0 {
+		t.Errorf("got signature %v, want none without a secret", signature)
+	}
+}
+
+func TestWebhookRedact(t *testing.T) {
+	var payload webhookPayload
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		err := json.Unmarshal(body, &payload)
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	hashed := publishRedacted(t, NewWebhook(server.URL, ""))
+	if got := webhookIdentifiers(payload); !equalStrings(got, []string{"added=" + hashed}) {
+		t.Errorf("got changes %q, want the identifier hashed as %v", got, hashed)
+	}
+	if strings.Contains(string(body), "tenant") {
+		t.Errorf("got payload %s, want every name of the tenant redacted", body)
+	}
+}